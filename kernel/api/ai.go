@@ -35,7 +35,7 @@ func chatGPT(c *gin.Context) {
 	}
 
 	msg := arg["msg"].(string)
-	ret.Data = model.ChatGPT(msg)
+	ret.Data = model.ChatGPT(msg, aiStreamArg(arg))
 }
 
 func chatGPTWithAction(c *gin.Context) {
@@ -53,5 +53,14 @@ func chatGPTWithAction(c *gin.Context) {
 		ids = append(ids, id.(string))
 	}
 	action := arg["action"].(string)
-	ret.Data = model.ChatGPTWithAction(ids, action)
+	ret.Data = model.ChatGPTWithAction(ids, action, aiStreamArg(arg))
+}
+
+// aiStreamArg 解析流式推送的目标，生成的内容只推送给发起请求的应用会话。
+func aiStreamArg(arg map[string]interface{}) (ret *model.AIStream) {
+	ret = &model.AIStream{}
+	ret.ID, _ = arg["streamID"].(string)
+	ret.App, _ = arg["app"].(string)
+	ret.Session, _ = arg["session"].(string)
+	return
 }
@@ -208,6 +208,9 @@ func getDocImageAssets(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	assets, err := model.DocImageAssets(id)
 	if err != nil {
 		ret.Code = -1
@@ -227,6 +230,9 @@ func getDocAssets(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	assets, err := model.DocAssets(id)
 	if err != nil {
 		ret.Code = -1
@@ -274,6 +280,9 @@ func getFileAnnotation(c *gin.Context) {
 
 	p := arg["path"].(string)
 	p = strings.ReplaceAll(p, "%23", "#")
+	if model.RefuseToReadAsset(c, strings.TrimSuffix(p, ".sya"), ret) {
+		return
+	}
 	readPath, err := resolveFileAnnotationAbsPath(p)
 	if err != nil {
 		ret.Code = -1
@@ -31,7 +31,7 @@ func getBookmarkLabels(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	ret.Data = model.BookmarkLabels()
+	ret.Data = model.BookmarkLabels(model.GetReadableFilter(c))
 }
 
 func batchGetBlockAttrs(c *gin.Context) {
@@ -48,6 +48,7 @@ func batchGetBlockAttrs(c *gin.Context) {
 	for _, id := range ids {
 		idList = append(idList, id.(string))
 	}
+	idList = model.FilterBlockIDs(c, idList)
 
 	ret.Data = sql.BatchGetBlockAttrs(idList)
 }
@@ -65,6 +66,9 @@ func getBlockAttrs(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	ret.Data = sql.GetBlockAttrs(id)
 }
@@ -0,0 +1,101 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func queryAudits(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	from, to, actors, box := parseAuditFilters(arg)
+	page, pageSize := 1, 32
+	if pageArg, ok := arg["page"].(float64); ok {
+		page = int(pageArg)
+	}
+	if pageSizeArg, ok := arg["pageSize"].(float64); ok {
+		pageSize = int(pageSizeArg)
+	}
+	if 1 > pageSize {
+		pageSize = 32
+	}
+
+	audits, total := model.QueryAudits(from, to, actors, box, page, pageSize)
+	ret.Data = map[string]interface{}{
+		"audits": audits,
+		"total":  total,
+	}
+}
+
+func exportAudits(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	from, to, actors, box := parseAuditFilters(arg)
+	exportPath, err := model.ExportAudits(from, to, actors, box)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"path": exportPath,
+	}
+}
+
+// parseAuditFilters 解析审计日志过滤条件，actor 为空字符串时表示工作空间所有者，未传入时不过滤操作者。
+func parseAuditFilters(arg map[string]interface{}) (from, to int64, actors []string, box string) {
+	if fromArg, ok := arg["from"].(float64); ok {
+		from = int64(fromArg)
+	}
+	if toArg, ok := arg["to"].(float64); ok {
+		to = int64(toArg)
+	}
+	switch actorArg := arg["actor"].(type) {
+	case string:
+		actors = []string{actorArg}
+	case []interface{}:
+		actors = []string{}
+		for _, a := range actorArg {
+			if s, ok := a.(string); ok {
+				actors = append(actors, s)
+			}
+		}
+	}
+	if notebookArg, ok := arg["notebook"].(string); ok {
+		box = notebookArg
+	}
+	return
+}
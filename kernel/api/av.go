@@ -28,6 +28,70 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+func getAttributeViewAddressBooks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	books := model.GetAttributeViewAddressBooks()
+	var data []map[string]interface{}
+	for _, book := range books {
+		data = append(data, map[string]interface{}{
+			"avID":    book.AvID,
+			"name":    book.Name,
+			"created": book.Created,
+			"path":    book.Path(),
+		})
+	}
+	ret.Data = map[string]interface{}{
+		"books": data,
+	}
+}
+
+func bindAttributeViewAddressBook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	var name string
+	if nameArg := arg["name"]; nil != nameArg {
+		name = nameArg.(string)
+	}
+	book, err := model.BindAttributeViewAddressBook(avID, name)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"avID": book.AvID,
+		"name": book.Name,
+		"path": book.Path(),
+	}
+}
+
+func unbindAttributeViewAddressBook(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	if err := model.UnbindAttributeViewAddressBook(avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
 func removeUnusedAttributeView(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -58,6 +122,10 @@ func getUnusedAttributeViews(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
+	if model.RefuseRestricted(c, ret) {
+		return
+	}
+
 	unusedAttributeViews := model.UnusedAttributeViews()
 	total := len(unusedAttributeViews)
 
@@ -80,6 +148,9 @@ func getAttributeViewItemIDsByBoundIDs(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if model.RefuseToReadAttributeView(c, avID, "", ret) {
+		return
+	}
 	blockIDsArg := arg["blockIDs"].([]interface{})
 	var blockIDs []string
 	for _, v := range blockIDsArg {
@@ -99,6 +170,9 @@ func getAttributeViewBoundBlockIDsByItemIDs(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if model.RefuseToReadAttributeView(c, avID, "", ret) {
+		return
+	}
 	itemIDsArg := arg["itemIDs"].([]interface{})
 	var itemIDs []string
 	for _, v := range itemIDsArg {
@@ -120,6 +194,9 @@ func getAttributeViewAddingBlockDefaultValues(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if model.RefuseToReadAttributeView(c, avID, "", ret) {
+		return
+	}
 	var viewID string
 	if viewIDArg := arg["viewID"]; nil != viewIDArg {
 		viewID = viewIDArg.(string)
@@ -283,6 +360,9 @@ func getAttributeViewKeysByID(c *gin.Context) {
 		return
 	}
 	avID := arg["avID"].(string)
+	if model.RefuseToReadAttributeView(c, avID, "", ret) {
+		return
+	}
 	keyIDsArg := arg["keyIDs"].([]interface{})
 	var keyIDs []string
 	for _, v := range keyIDsArg {
@@ -863,6 +943,10 @@ func renderAttributeView(c *gin.Context) {
 	if nil != groupPagingArg {
 		groupPaging = groupPagingArg.(map[string]interface{})
 	}
+	if model.RefuseToReadAttributeView(c, id, blockID, ret) {
+		c.JSON(http.StatusOK, ret)
+		return
+	}
 
 	ret = renderAttrView(blockID, id, viewID, query, page, pageSize, groupPaging)
 	c.JSON(http.StatusOK, ret)
@@ -914,6 +998,9 @@ func getCurrentAttrViewImages(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadAttributeView(c, id, "", ret) {
+		return
+	}
 	viewIDArg := arg["viewID"]
 	var viewID string
 	if nil != viewIDArg {
@@ -945,6 +1032,9 @@ func getAttributeViewKeys(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	blockAttributeViewKeys := model.GetBlockAttributeViewKeys(id)
 	ret.Data = blockAttributeViewKeys
 }
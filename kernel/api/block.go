@@ -46,6 +46,7 @@ func checkBlockRef(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 	ids = gulu.Str.RemoveDuplicatedElem(ids)
 
 	ret.Data = model.CheckBlockRef(ids)
@@ -65,6 +66,7 @@ func getBlockTreeInfos(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 
 	ret.Data = model.GetBlockTreeInfos(ids)
 }
@@ -79,6 +81,9 @@ func getBlockSiblingID(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	parent, previous, next := model.GetBlockSiblingID(id)
 	ret.Data = map[string]string{
 		"parent":   parent,
@@ -97,6 +102,9 @@ func getBlockRelevantIDs(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	parentID, previousID, nextID, err := model.GetBlockRelevantIDs(id)
 	if nil != err {
 		ret.Code = -1
@@ -186,6 +194,9 @@ func getHeadingChildrenIDs(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	ids := model.GetHeadingChildrenIDs(id)
 	ret.Data = ids
 }
@@ -214,6 +225,9 @@ func getHeadingChildrenDOM(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	removeFoldAttr := true
 	if nil != arg["removeFoldAttr"] {
 		removeFoldAttr = arg["removeFoldAttr"].(bool)
@@ -232,6 +246,9 @@ func getHeadingDeleteTransaction(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	transaction, err := model.GetHeadingDeleteTransaction(id)
 	if err != nil {
@@ -254,6 +271,9 @@ func getHeadingInsertTransaction(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	transaction, err := model.GetHeadingInsertTransaction(id)
 	if err != nil {
@@ -276,6 +296,9 @@ func getHeadingLevelTransaction(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	level := int(arg["level"].(float64))
 
 	transaction, err := model.GetHeadingLevelTransaction(id, level)
@@ -319,6 +342,9 @@ func getUnfoldedParentID(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	parentID := model.GetUnfoldedParentID(id)
 	ret.Data = map[string]interface{}{
 		"parentID": parentID,
@@ -335,6 +361,9 @@ func checkBlockFold(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	isFolded, isRoot := model.IsBlockFolded(id)
 	ret.Data = map[string]interface{}{
 		"isFolded": isFolded,
@@ -352,6 +381,9 @@ func checkBlockExist(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	b, err := model.GetBlock(id, nil)
 	if errors.Is(err, model.ErrIndexing) {
 		ret.Code = 0
@@ -371,6 +403,10 @@ func getDocInfo(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+
 	info := model.GetDocInfo(id)
 	if nil == info {
 		ret.Code = -1
@@ -393,6 +429,7 @@ func getDocsInfo(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 	queryRefCount := arg["refCount"].(bool)
 	queryAv := arg["av"].(bool)
 	info := model.GetDocsInfo(ids, queryRefCount, queryAv)
@@ -408,7 +445,7 @@ func getRecentUpdatedBlocks(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	blocks := model.RecentUpdatedBlocks()
+	blocks := model.RecentUpdatedBlocks(model.GetReadableFilter(c))
 	ret.Data = blocks
 }
 
@@ -442,6 +479,7 @@ func getBlocksWordCount(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 	ret.Data = map[string]any{
 		"reqId": arg["reqId"],
 		"stat":  filesys.BlocksWordCount(ids),
@@ -458,6 +496,9 @@ func getTreeStat(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	ret.Data = map[string]any{
 		"reqId": arg["reqId"],
 		"stat":  filesys.StatTree(id),
@@ -490,6 +531,9 @@ func getRefText(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	refText := model.GetBlockRefText(id)
 	if "" == refText {
@@ -523,6 +567,9 @@ func getRefIDs(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	refDefs, originalRefBlockIDs := model.GetBlockRefs(id)
 	ret.Data = map[string]any{
 		"refDefs":             refDefs,
@@ -541,6 +588,7 @@ func getRefIDsByFileAnnotationID(c *gin.Context) {
 
 	id := arg["id"].(string)
 	refIDs := model.GetBlockRefIDsByFileAnnotationID(id)
+	refIDs = model.FilterBlockIDs(c, refIDs)
 	var retRefDefs []model.RefDefs
 	for _, blockID := range refIDs {
 		retRefDefs = append(retRefDefs, model.RefDefs{RefID: blockID, DefIDs: []string{}})
@@ -571,6 +619,7 @@ func getBlockDefIDsByRefText(c *gin.Context) {
 	}
 	excludeIDs = nil // 不限制虚拟引用搜索自己 https://ld246.com/article/1633243424177
 	ids := model.GetBlockDefIDsByRefText(anchor, excludeIDs)
+	ids = model.FilterBlockIDs(c, ids)
 	var retRefDefs []model.RefDefs
 	for _, id := range ids {
 		retRefDefs = append(retRefDefs, model.RefDefs{RefID: id, DefIDs: []string{}})
@@ -594,6 +643,9 @@ func getBlockBreadcrumb(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	excludeTypesArg := arg["excludeTypes"]
 	var excludeTypes []string
 	if nil != excludeTypesArg {
@@ -622,6 +674,9 @@ func getBlockIndex(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	index := model.GetBlockIndex(id)
 	ret.Data = index
 }
@@ -640,6 +695,7 @@ func getBlocksIndexes(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 	index := model.GetBlocksIndexes(ids)
 	ret.Data = index
 }
@@ -654,6 +710,9 @@ func getBlockInfo(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	// 仅在此处使用带重建索引的加载函数，其他地方不要使用
 	tree, err := model.LoadTreeByBlockIDWithReindex(id)
@@ -721,6 +780,10 @@ func getBlockDOM(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+
 	dom := model.GetBlockDOM(id)
 	ret.Data = map[string]string{
 		"id":  id,
@@ -742,6 +805,7 @@ func getBlockDOMs(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 
 	doms := model.GetBlockDOMs(ids)
 	ret.Data = doms
@@ -757,7 +821,10 @@ func getBlockDOMWithEmbed(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
-	dom := model.GetBlockDOMWithEmbed(id)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+	dom := model.GetBlockDOMWithEmbed(id, model.GetReadableFilter(c))
 	ret.Data = map[string]string{
 		"id":  id,
 		"dom": dom,
@@ -778,8 +845,9 @@ func getBlockDOMsWithEmbed(c *gin.Context) {
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
+	ids = model.FilterBlockIDs(c, ids)
 
-	doms := model.GetBlockDOMsWithEmbed(ids)
+	doms := model.GetBlockDOMsWithEmbed(ids, model.GetReadableFilter(c))
 	ret.Data = doms
 }
 
@@ -796,6 +864,9 @@ func getBlockKramdown(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	// md：Markdown 标记符模式，使用标记符导出
 	// textmark：文本标记模式，使用 span 标签导出
@@ -835,6 +906,7 @@ func getBlockKramdowns(c *gin.Context) {
 			ids = append(ids, idStr)
 		}
 	}
+	ids = model.FilterBlockIDs(c, ids)
 
 	// md：Markdown 标记符模式，使用标记符导出
 	// textmark：文本标记模式，使用 span 标签导出
@@ -866,6 +938,9 @@ func getChildBlocks(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	ret.Data = model.GetChildBlocks(id)
 }
@@ -883,6 +958,9 @@ func getTailChildBlocks(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	var n int
 	nArg := arg["n"]
@@ -73,6 +73,7 @@ func moveOutlineHeading(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -126,6 +127,7 @@ func appendDailyNoteBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -179,6 +181,7 @@ func prependDailyNoteBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -240,6 +243,7 @@ func unfoldBlock(c *gin.Context) {
 		}
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -300,6 +304,7 @@ func foldBlock(c *gin.Context) {
 		}
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -374,6 +379,7 @@ func moveBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -421,6 +427,7 @@ func appendBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -469,6 +476,7 @@ func batchAppendBlock(c *gin.Context) {
 		})
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -514,6 +522,7 @@ func prependBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -562,6 +571,7 @@ func batchPrependBlock(c *gin.Context) {
 		})
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -625,6 +635,7 @@ func insertBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -724,6 +735,7 @@ func updateBlock(c *gin.Context) {
 		}
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -790,6 +802,7 @@ func batchInsertBlock(c *gin.Context) {
 		})
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -905,6 +918,7 @@ func batchUpdateBlock(c *gin.Context) {
 	}
 
 	tx.DoOperations = ops
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -937,6 +951,7 @@ func deleteBlock(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 
 	ret.Data = transactions
@@ -29,7 +29,7 @@ func getBookmark(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	ret.Data = model.BuildBookmark()
+	ret.Data = model.BuildBookmark(model.GetReadableFilter(c))
 }
 
 func removeBookmark(c *gin.Context) {
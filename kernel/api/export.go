@@ -94,7 +94,24 @@ func exportEPUB(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "epub", ".epub")
+	name, zipPath := model.ExportEPUB(id)
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"zip":  zipPath,
+	}
+}
+
+func exportTypst(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	name, zipPath := model.ExportTypst(id)
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -351,6 +368,33 @@ func exportNotebookMd(c *gin.Context) {
 	}
 }
 
+func exportSite(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	p := "/"
+	if pathArg, ok := arg["path"].(string); ok && "" != pathArg {
+		p = pathArg
+	}
+	zipPath := model.ExportSite(notebook, p)
+	if "" == zipPath {
+		ret.Code = -1
+		ret.Msg = "export site failed"
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"name": path.Base(zipPath),
+		"zip":  zipPath,
+	}
+}
+
 func exportMds(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -773,6 +817,10 @@ func exportPreview(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	// 导出时会展开其他文档中的嵌入块和引用，无法逐块检查，受限请求不支持导出预览
+	if model.RefuseRestricted(c, ret) {
+		return
+	}
 
 	userAgentStr := c.GetHeader("User-Agent")
 	fillCSSVar := true
@@ -100,6 +100,7 @@ func globalCopyFiles(c *gin.Context) {
 			ret.Msg = err.Error()
 			return
 		}
+		model.LogMemberChange(c, "copied file", src+" -> "+dest)
 	}
 
 	model.IncSync()
@@ -157,6 +158,7 @@ func copyFile(c *gin.Context) {
 		return
 	}
 
+	model.LogMemberChange(c, "copied file", src+" -> "+dest)
 	model.IncSync()
 }
 
@@ -391,6 +393,7 @@ func renameFile(c *gin.Context) {
 		return
 	}
 
+	model.LogMemberChange(c, "renamed file", srcPath+" -> "+destPath)
 	model.IncSync()
 }
 
@@ -430,6 +433,7 @@ func removeFile(c *gin.Context) {
 		return
 	}
 
+	model.LogMemberChange(c, "removed file", filePath)
 	model.IncSync()
 }
 
@@ -526,6 +530,7 @@ func putFile(c *gin.Context) {
 		return
 	}
 
+	model.LogMemberChange(c, "put file", filePath)
 	model.IncSync()
 }
 
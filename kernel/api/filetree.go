@@ -348,6 +348,9 @@ func getHPathByPath(c *gin.Context) {
 	}
 
 	p := arg["path"].(string)
+	if model.RefuseToReadDoc(c, notebook, p, ret) {
+		return
+	}
 
 	hPath, err := model.GetHPathByPath(notebook, p)
 	if err != nil {
@@ -372,6 +375,7 @@ func getHPathsByPaths(c *gin.Context) {
 	for _, p := range pathsArg {
 		paths = append(paths, p.(string))
 	}
+	paths = model.FilterDocPaths(c, paths)
 	hPath, err := model.GetHPathsByPaths(paths)
 	if err != nil {
 		ret.Code = -1
@@ -394,6 +398,9 @@ func getHPathByID(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	hPath, err := model.GetHPathByID(id)
 	if err != nil {
@@ -417,6 +424,9 @@ func getPathByID(c *gin.Context) {
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 
 	p, notebook, err := model.GetPathByID(id)
 	if err != nil {
@@ -443,6 +453,9 @@ func getFullHPathByID(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
 	hPath, err := model.GetFullHPathByID(id)
 	if err != nil {
 		ret.Code = -1
@@ -479,6 +492,7 @@ func getIDsByHPath(c *gin.Context) {
 		ret.Msg = err.Error()
 		return
 	}
+	ids = model.FilterBlockIDs(c, ids)
 	ret.Data = ids
 }
 
@@ -1043,7 +1057,7 @@ func searchDocs(c *gin.Context) {
 	}
 
 	k := arg["k"].(string)
-	ret.Data = model.SearchDocs(k, flashcard, excludeIDs)
+	ret.Data = model.FilterDocMapsByRole(c, model.SearchDocs(k, flashcard, excludeIDs))
 }
 
 func listDocsByPath(c *gin.Context) {
@@ -1079,12 +1093,17 @@ func listDocsByPath(c *gin.Context) {
 		showHidden = arg["showHidden"].(bool)
 	}
 
+	if model.RefuseToReadDoc(c, notebook, p, ret) {
+		return
+	}
+
 	files, totals, err := model.ListDocTree(notebook, p, sortMode, flashcard, showHidden, maxListCount)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		return
 	}
+	files = model.FilterFilesByRole(c, notebook, files)
 	if maxListCount < totals {
 		// API `listDocsByPath` add an optional parameter `ignoreMaxListHint` https://github.com/siyuan-note/siyuan/issues/10290
 		ignoreMaxListHintArg := arg["ignoreMaxListHint"]
@@ -1175,6 +1194,10 @@ func getDoc(c *gin.Context) {
 		highlight = highlightArg.(bool)
 	}
 
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+
 	blockCount, content, parentID, parent2ID, rootID, typ, eof, scroll, boxID, docPath, isBacklinkExpand, keywords, err :=
 		model.GetDoc(startID, endID, id, index, query, queryTypes, queryMethod, mode, size, isBacklink, originalRefBlockIDs, highlight)
 	if model.ErrBlockNotFound == err {
@@ -81,6 +81,7 @@ func getGraph(c *gin.Context) {
 	model.Conf.Save()
 
 	boxID, nodes, links := model.BuildGraph(query)
+	nodes, links = model.FilterGraphByRole(c, nodes, links)
 	ret.Data = map[string]interface{}{
 		"nodes": nodes,
 		"links": links,
@@ -126,7 +127,12 @@ func getLocalGraph(c *gin.Context) {
 	model.Conf.Graph.Local = local
 	model.Conf.Save()
 
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+
 	boxID, nodes, links := model.BuildTreeGraph(id, keyword)
+	nodes, links = model.FilterGraphByRole(c, nodes, links)
 	ret.Data = map[string]interface{}{
 		"id":    id,
 		"box":   boxID,
@@ -240,3 +240,71 @@ func rollbackAttributeViewHistory(c *gin.Context) {
 		return
 	}
 }
+
+func getBlockHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var rootID string
+	if rootIDArg := arg["rootID"]; nil != rootIDArg {
+		rootID = rootIDArg.(string)
+	}
+	histories, err := model.GetBlockHistory(id, rootID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"histories": histories,
+	}
+}
+
+func getDocBlame(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	blames, err := model.GetDocBlame(id)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"blames": blames,
+	}
+}
+
+func rollbackBlockHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	source := arg["source"].(string)
+	path := arg["path"].(string)
+	err := model.RollbackBlockHistory(id, source, path)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
@@ -17,7 +17,9 @@
 package api
 
 import (
+	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -97,6 +99,97 @@ func importSY(c *gin.Context) {
 	}
 }
 
+func importAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	util.PushEndlessProgress(model.Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		logging.LogErrorf("parse import attribute view failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	files := form.File["file"]
+	if 1 > len(files) {
+		logging.LogErrorf("parse import attribute view failed, no file found")
+		ret.Code = -1
+		ret.Msg = "no file found"
+		return
+	}
+	file := files[0]
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ".csv" != ext && ".tsv" != ext && ".tab" != ext && ".txt" != ext && ".xlsx" != ext && ".xlsm" != ext {
+		ret.Code = -1
+		ret.Msg = "unsupported file type [" + ext + "]"
+		return
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		logging.LogErrorf("read import attribute view file failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer reader.Close()
+
+	importDir := filepath.Join(util.TempDir, "import", util.CurrentTimeSecondsStr())
+	if err = os.MkdirAll(importDir, 0755); err != nil {
+		logging.LogErrorf("make import dir [%s] failed: %s", importDir, err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer os.RemoveAll(importDir)
+	writePath := filepath.Join(importDir, util.FilterUploadFileName(file.Filename))
+	writer, err := os.OpenFile(writePath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		logging.LogErrorf("open import attribute view file [%s] failed: %s", writePath, err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if _, err = io.Copy(writer, reader); err != nil {
+		logging.LogErrorf("write import attribute view file failed: %s", err)
+		writer.Close()
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	writer.Close()
+
+	formValue := func(name string) string {
+		if values := form.Value[name]; 0 < len(values) {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+
+	keyMapping := map[string]string{}
+	if mapping := formValue("keyMapping"); "" != mapping {
+		if err = gulu.JSON.UnmarshalJSON([]byte(mapping), &keyMapping); err != nil {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	avID, err := model.ImportAttributeView(writePath, formValue("avID"), formValue("blockID"), keyMapping, formValue("notebook"), formValue("toPath"))
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"avID": avID,
+	}
+}
+
 func importData(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -187,6 +280,149 @@ func importStdMd(c *gin.Context) {
 	}
 }
 
+func importObsidian(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	localPath := arg["localPath"].(string)
+	toPath := arg["toPath"].(string)
+	propsAsAv := false
+	if propsAsAvArg := arg["propsAsAv"]; nil != propsAsAvArg {
+		propsAsAv = propsAsAvArg.(bool)
+	}
+	report, err := model.ImportObsidianVault(notebook, localPath, toPath, propsAsAv)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = report
+}
+
+func importNotion(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	util.PushEndlessProgress(model.Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	form, writePath, err := saveImportFile(c)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer os.RemoveAll(writePath)
+
+	notebook := form.Value["notebook"][0]
+	toPath := form.Value["toPath"][0]
+	report, err := model.ImportNotionZip(notebook, writePath, toPath)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = report
+}
+
+func importENEX(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	util.PushEndlessProgress(model.Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	form, writePath, err := saveImportFile(c)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer os.RemoveAll(writePath)
+
+	notebook := form.Value["notebook"][0]
+	toPath := form.Value["toPath"][0]
+	report, err := model.ImportENEX(notebook, writePath, toPath)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = report
+}
+
+func importHTMLZip(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	util.PushEndlessProgress(model.Conf.Language(73))
+	defer util.ClearPushProgress(100)
+
+	form, writePath, err := saveImportFile(c)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer os.RemoveAll(writePath)
+
+	notebook := form.Value["notebook"][0]
+	toPath := form.Value["toPath"][0]
+	report, err := model.ImportHTMLZip(notebook, writePath, toPath)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = report
+}
+
+// saveImportFile 将上传的导入文件 file 保存到临时文件夹中。
+func saveImportFile(c *gin.Context) (form *multipart.Form, writePath string, err error) {
+	form, err = c.MultipartForm()
+	if err != nil {
+		logging.LogErrorf("parse import file failed: %s", err)
+		return
+	}
+
+	files := form.File["file"]
+	if 1 > len(files) {
+		logging.LogErrorf("parse import file failed, no file found")
+		err = errors.New("no file found")
+		return
+	}
+	file := files[0]
+	reader, err := file.Open()
+	if err != nil {
+		logging.LogErrorf("read import file failed: %s", err)
+		return
+	}
+	defer reader.Close()
+
+	importDir := filepath.Join(util.TempDir, "import")
+	if err = os.MkdirAll(importDir, 0755); err != nil {
+		logging.LogErrorf("make import dir [%s] failed: %s", importDir, err)
+		return
+	}
+	writePath = filepath.Join(importDir, filepath.Base(file.Filename))
+	writer, err := os.OpenFile(writePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		logging.LogErrorf("open import file [%s] failed: %s", writePath, err)
+		return
+	}
+	defer writer.Close()
+	if _, err = io.Copy(writer, reader); err != nil {
+		logging.LogErrorf("write import file [%s] failed: %s", writePath, err)
+	}
+	return
+}
+
 func importZipMd(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(200, ret)
@@ -41,6 +41,9 @@ func getNotebookInfo(c *gin.Context) {
 	if util.InvalidIDPattern(boxID, ret) {
 		return
 	}
+	if model.RefuseToReadDoc(c, boxID, "/", ret) {
+		return
+	}
 
 	box := model.Conf.Box(boxID)
 	if nil == box {
@@ -311,6 +314,9 @@ func getNotebookConf(c *gin.Context) {
 	if util.InvalidIDPattern(notebook, ret) {
 		return
 	}
+	if model.RefuseToReadDoc(c, notebook, "/", ret) {
+		return
+	}
 
 	box := model.Conf.GetBox(notebook)
 	if nil == box {
@@ -421,6 +427,7 @@ func lsNotebooks(c *gin.Context) {
 		}
 	}
 
+	notebooks = model.FilterNotebooksByRole(c, notebooks)
 	ret.Data = map[string]interface{}{
 		"notebooks": notebooks,
 	}
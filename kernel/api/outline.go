@@ -47,6 +47,9 @@ func getDocOutline(c *gin.Context) {
 	if util.InvalidIDPattern(rootID, ret) {
 		return
 	}
+	if model.RefuseToReadBlock(c, rootID, ret) {
+		return
+	}
 
 	headings, err := model.Outline(rootID, preview)
 	if err != nil {
@@ -60,6 +60,10 @@ func getBackmentionDoc(c *gin.Context) {
 	if val, ok := arg["highlight"]; ok {
 		highlight = val.(bool)
 	}
+	if model.RefuseToReadBlock(c, defID, ret) || model.RefuseToReadBlock(c, refTreeID, ret) {
+		return
+	}
+
 	backlinks, keywords := model.GetBackmentionDoc(defID, refTreeID, keyword, containChildren, highlight)
 	ret.Data = map[string]interface{}{
 		"backmentions": backlinks,
@@ -87,6 +91,10 @@ func getBacklinkDoc(c *gin.Context) {
 	if val, ok := arg["highlight"]; ok {
 		highlight = val.(bool)
 	}
+	if model.RefuseToReadBlock(c, defID, ret) || model.RefuseToReadBlock(c, refTreeID, ret) {
+		return
+	}
+
 	backlinks, keywords := model.GetBacklinkDoc(defID, refTreeID, keyword, containChildren, highlight)
 	ret.Data = map[string]interface{}{
 		"backlinks": backlinks,
@@ -124,7 +132,13 @@ func getBacklink2(c *gin.Context) {
 	if val, ok := arg["containChildren"]; ok {
 		containChildren = val.(bool)
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+
 	boxID, backlinks, backmentions, linkRefsCount, mentionsCount := model.GetBacklink2(id, keyword, mentionKeyword, sort, mentionSort, containChildren)
+	backlinks = model.FilterPathsByRole(c, backlinks)
+	backmentions = model.FilterPathsByRole(c, backmentions)
 	ret.Data = map[string]interface{}{
 		"backlinks":     backlinks,
 		"linkRefsCount": linkRefsCount,
@@ -160,7 +174,13 @@ func getBacklink(c *gin.Context) {
 	if val, ok := arg["containChildren"]; ok {
 		containChildren = val.(bool)
 	}
+	if model.RefuseToReadBlock(c, id, ret) {
+		return
+	}
+
 	boxID, backlinks, backmentions, linkRefsCount, mentionsCount := model.GetBacklink(id, keyword, mentionKeyword, beforeLen, containChildren)
+	backlinks = model.FilterPathsByRole(c, backlinks)
+	backmentions = model.FilterPathsByRole(c, backmentions)
 	ret.Data = map[string]interface{}{
 		"backlinks":     backlinks,
 		"linkRefsCount": linkRefsCount,
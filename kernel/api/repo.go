@@ -147,6 +147,27 @@ func diffRepoSnapshots(c *gin.Context) {
 	}
 }
 
+func diffRepoSnapshotDoc(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	left := arg["left"].(string)
+	right := arg["right"].(string)
+	diff, err := model.DiffRepoSnapshotDoc(left, right)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = diff
+}
+
 func getCloudSpace(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -27,6 +27,133 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+func getRiffReviewStats(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	days := 365
+	if daysArg, ok := arg["days"].(float64); ok {
+		days = int(daysArg)
+	}
+
+	stat, err := model.GetFlashcardReviewStat(getRiffStatFilter(arg), days)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = stat
+}
+
+func getRiffCardStats(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	days := 30
+	if daysArg, ok := arg["days"].(float64); ok {
+		days = int(daysArg)
+	}
+
+	stat, err := model.GetFlashcardCardStat(getRiffStatFilter(arg), days)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = stat
+}
+
+func getRiffStatFilter(arg map[string]interface{}) (ret *model.FlashcardStatFilter) {
+	ret = &model.FlashcardStatFilter{}
+	if deckIDArg, ok := arg["deckID"].(string); ok {
+		ret.DeckID = deckIDArg
+	}
+	if boxIDArg, ok := arg["notebook"].(string); ok {
+		ret.BoxID = boxIDArg
+	}
+	if rootIDArg, ok := arg["rootID"].(string); ok {
+		ret.RootID = rootIDArg
+	}
+	return
+}
+
+func optimizeRiffFSRS(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID, _ := arg["deckID"].(string)
+	apply, _ := arg["apply"].(bool)
+	result, err := model.OptimizeFSRS(deckID, apply)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
+
+func getRiffDeckFSRS(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID := arg["deckID"].(string)
+	fsrsParams, custom := model.GetDeckFSRS(deckID)
+	ret.Data = map[string]interface{}{
+		"fsrs":   fsrsParams,
+		"custom": custom,
+	}
+}
+
+func setRiffDeckFSRS(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deckID := arg["deckID"].(string)
+	var requestRetention float64
+	var maximumInterval int
+	var weights string
+	if requestRetentionArg, ok := arg["requestRetention"].(float64); ok {
+		requestRetention = requestRetentionArg
+	}
+	if maximumIntervalArg, ok := arg["maximumInterval"].(float64); ok {
+		maximumInterval = int(maximumIntervalArg)
+	}
+	if weightsArg, ok := arg["weights"].(string); ok {
+		weights = weightsArg
+	}
+
+	if err := model.SetDeckFSRS(deckID, requestRetention, maximumInterval, weights); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
 func getRiffCardsByBlockIDs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -324,6 +451,7 @@ func removeRiffCards(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -362,6 +490,7 @@ func addRiffCards(c *gin.Context) {
 		},
 	}
 
+	model.SetTransactionsRequest(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -32,6 +32,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/system/uiproc", addUIProcess)
 	ginServer.Handle("POST", "/api/system/loginAuth", model.LoginAuth)
 	ginServer.Handle("POST", "/api/system/logoutAuth", model.LogoutAuth)
+	ginServer.Handle("POST", "/api/system/loginMember", model.LoginMember)
 	ginServer.Handle("GET", "/api/system/getCaptcha", model.GetCaptcha)
 	ginServer.Handle("GET", "/api/icon/getDynamicIcon", getDynamicIcon)
 
@@ -98,6 +99,17 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/account/deactivate", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, deactivateUser)
 	ginServer.Handle("POST", "/api/account/startFreeTrial", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, startFreeTrial)
 
+	ginServer.Handle("POST", "/api/member/listMembers", model.CheckAuth, model.CheckAdminRole, listMembers)
+	ginServer.Handle("POST", "/api/member/createMember", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createMember)
+	ginServer.Handle("POST", "/api/member/updateMember", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, updateMember)
+	ginServer.Handle("POST", "/api/member/removeMember", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeMember)
+	ginServer.Handle("POST", "/api/member/createMemberToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createMemberToken)
+	ginServer.Handle("POST", "/api/member/revokeMemberToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, revokeMemberToken)
+	ginServer.Handle("POST", "/api/member/getCurrentMember", model.CheckAuth, getCurrentMember)
+
+	ginServer.Handle("POST", "/api/audit/queryAudits", model.CheckAuth, model.CheckAdminRole, queryAudits)
+	ginServer.Handle("POST", "/api/audit/exportAudits", model.CheckAuth, model.CheckAdminRole, exportAudits)
+
 	ginServer.Handle("POST", "/api/notebook/lsNotebooks", model.CheckAuth, lsNotebooks)
 	ginServer.Handle("POST", "/api/notebook/openNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, openNotebook)
 	ginServer.Handle("POST", "/api/notebook/closeNotebook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, closeNotebook)
@@ -156,6 +168,9 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/history/reindexHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, reindexHistory)
 	ginServer.Handle("POST", "/api/history/searchHistory", model.CheckAuth, model.CheckAdminRole, searchHistory)
 	ginServer.Handle("POST", "/api/history/getHistoryItems", model.CheckAuth, model.CheckAdminRole, getHistoryItems)
+	ginServer.Handle("POST", "/api/history/getBlockHistory", model.CheckAuth, model.CheckAdminRole, getBlockHistory)
+	ginServer.Handle("POST", "/api/history/getDocBlame", model.CheckAuth, model.CheckAdminRole, getDocBlame)
+	ginServer.Handle("POST", "/api/history/rollbackBlockHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, rollbackBlockHistory)
 
 	ginServer.Handle("POST", "/api/outline/getDocOutline", model.CheckAuth, getDocOutline)
 
@@ -172,6 +187,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/lute/copyStdMarkdown", model.CheckAuth, copyStdMarkdown)
 
 	ginServer.Handle("POST", "/api/query/sql", model.CheckAuth, SQL)
+	ginServer.Handle("POST", "/api/query/sqlReadonly", model.CheckAuth, sqlReadonly)
+	ginServer.Handle("POST", "/api/query/cancelSQLReadonly", model.CheckAuth, cancelSQLReadonly)
 	ginServer.Handle("POST", "/api/sqlite/flushTransaction", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, flushTransaction)
 
 	ginServer.Handle("POST", "/api/search/searchTag", model.CheckAuth, searchTag)
@@ -244,6 +261,12 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/block/checkBlockRef", model.CheckAuth, checkBlockRef)
 	ginServer.Handle("POST", "/api/block/appendHeadingChildren", model.CheckAuth, appendHeadingChildren)
 
+	ginServer.Handle("POST", "/api/reminder/setReminder", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setReminder)
+	ginServer.Handle("POST", "/api/reminder/removeReminder", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeReminder)
+	ginServer.Handle("POST", "/api/reminder/snoozeReminder", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, snoozeReminder)
+	ginServer.Handle("POST", "/api/reminder/getReminder", model.CheckAuth, model.CheckAdminRole, getReminder)
+	ginServer.Handle("POST", "/api/reminder/getUpcomingReminders", model.CheckAuth, model.CheckAdminRole, getUpcomingReminders)
+
 	ginServer.Handle("POST", "/api/file/getFile", model.CheckAuth, getFile)
 	ginServer.Handle("POST", "/api/file/putFile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, putFile)
 	ginServer.Handle("POST", "/api/file/copyFile", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, copyFile)
@@ -289,6 +312,8 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/sync/importSyncProviderS3", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importSyncProviderS3)
 	ginServer.Handle("POST", "/api/sync/exportSyncProviderWebDAV", model.CheckAuth, model.CheckAdminRole, exportSyncProviderWebDAV)
 	ginServer.Handle("POST", "/api/sync/importSyncProviderWebDAV", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importSyncProviderWebDAV)
+	ginServer.Handle("POST", "/api/sync/getSyncMergeConflicts", model.CheckAuth, model.CheckAdminRole, getSyncMergeConflicts)
+	ginServer.Handle("POST", "/api/sync/resolveSyncMergeConflict", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resolveSyncMergeConflict)
 
 	ginServer.Handle("POST", "/api/inbox/getShorthands", model.CheckAuth, model.CheckAdminRole, getShorthands)
 	ginServer.Handle("POST", "/api/inbox/getShorthand", model.CheckAuth, model.CheckAdminRole, getShorthand)
@@ -319,6 +344,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/asset/statAsset", model.CheckAuth, model.CheckAdminRole, statAsset)
 
 	ginServer.Handle("POST", "/api/export/exportNotebookMd", model.CheckAuth, model.CheckAdminRole, exportNotebookMd)
+	ginServer.Handle("POST", "/api/export/exportSite", model.CheckAuth, model.CheckAdminRole, exportSite)
 	ginServer.Handle("POST", "/api/export/exportMds", model.CheckAuth, model.CheckAdminRole, exportMds)
 	ginServer.Handle("POST", "/api/export/exportMd", model.CheckAuth, model.CheckAdminRole, exportMd)
 	ginServer.Handle("POST", "/api/export/exportSYs", model.CheckAuth, model.CheckAdminRole, exportSYs)
@@ -347,6 +373,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/export/exportODT", model.CheckAuth, model.CheckAdminRole, exportODT)
 	ginServer.Handle("POST", "/api/export/exportRTF", model.CheckAuth, model.CheckAdminRole, exportRTF)
 	ginServer.Handle("POST", "/api/export/exportEPUB", model.CheckAuth, model.CheckAdminRole, exportEPUB)
+	ginServer.Handle("POST", "/api/export/exportTypst", model.CheckAuth, model.CheckAdminRole, exportTypst)
 	ginServer.Handle("POST", "/api/export/exportAttributeView", model.CheckAuth, model.CheckAdminRole, exportAttributeView)
 	ginServer.Handle("POST", "/api/export/exportCodeBlock", model.CheckAuth, model.CheckAdminRole, exportCodeBlock)
 
@@ -354,6 +381,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/import/importZipMd", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importZipMd)
 	ginServer.Handle("POST", "/api/import/importData", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importData)
 	ginServer.Handle("POST", "/api/import/importSY", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importSY)
+	ginServer.Handle("POST", "/api/import/importAttributeView", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importAttributeView)
+	ginServer.Handle("POST", "/api/import/importObsidian", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importObsidian)
+	ginServer.Handle("POST", "/api/import/importNotion", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importNotion)
+	ginServer.Handle("POST", "/api/import/importENEX", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importENEX)
+	ginServer.Handle("POST", "/api/import/importHTMLZip", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importHTMLZip)
 
 	ginServer.Handle("POST", "/api/convert/pandoc", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, pandoc)
 
@@ -361,7 +393,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/template/docSaveAsTemplate", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, docSaveAsTemplate)
 	ginServer.Handle("POST", "/api/template/renderSprig", model.CheckAuth, renderSprig)
 
-	ginServer.Handle("POST", "/api/transactions", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, performTransactions)
+	ginServer.Handle("POST", "/api/transactions", model.CheckAuth, model.CheckTransactionRole, model.CheckReadonly, performTransactions)
 
 	ginServer.Handle("POST", "/api/setting/setAccount", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAccount)
 	ginServer.Handle("POST", "/api/setting/setEditor", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEditor)
@@ -379,12 +411,28 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/setting/setBazaar", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setBazaar)
 	ginServer.Handle("POST", "/api/setting/setPublish", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setPublish)
 	ginServer.Handle("POST", "/api/setting/getPublish", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, getPublish)
+	ginServer.Handle("POST", "/api/setting/createPublishShare", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createPublishShare)
+	ginServer.Handle("POST", "/api/setting/removePublishShare", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removePublishShare)
+	ginServer.Handle("POST", "/api/setting/setACL", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setACL)
+	ginServer.Handle("POST", "/api/setting/getACL", model.CheckAuth, model.CheckAdminRole, getACL)
 	ginServer.Handle("POST", "/api/setting/refreshVirtualBlockRef", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, refreshVirtualBlockRef)
 	ginServer.Handle("POST", "/api/setting/addVirtualBlockRefInclude", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, addVirtualBlockRefInclude)
 	ginServer.Handle("POST", "/api/setting/addVirtualBlockRefExclude", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, addVirtualBlockRefExclude)
 	ginServer.Handle("POST", "/api/setting/setSnippet", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setConfSnippet)
 	ginServer.Handle("POST", "/api/setting/setEditorReadOnly", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setEditorReadOnly)
 
+	ginServer.Handle("POST", "/api/automation/getAutomation", model.CheckAuth, model.CheckAdminRole, getAutomation)
+	ginServer.Handle("POST", "/api/automation/setAutomation", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAutomation)
+	ginServer.Handle("POST", "/api/automation/runAutomation", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, runAutomation)
+	ginServer.Handle("POST", "/api/automation/getAutomationRuns", model.CheckAuth, model.CheckAdminRole, getAutomationRuns)
+
+	ginServer.Handle("POST", "/api/webhook/getWebhooks", model.CheckAuth, model.CheckAdminRole, getWebhooks)
+	ginServer.Handle("POST", "/api/webhook/setWebhooks", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setWebhooks)
+	ginServer.Handle("POST", "/api/webhook/testWebhook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, testWebhook)
+	ginServer.Handle("POST", "/api/webhook/getWebhookDeadLetters", model.CheckAuth, model.CheckAdminRole, getWebhookDeadLetters)
+	ginServer.Handle("POST", "/api/webhook/redeliverWebhookDeadLetter", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, redeliverWebhookDeadLetter)
+	ginServer.Handle("POST", "/api/webhook/removeWebhookDeadLetters", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeWebhookDeadLetters)
+
 	ginServer.Handle("POST", "/api/graph/resetGraph", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resetGraph)
 	ginServer.Handle("POST", "/api/graph/resetLocalGraph", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resetLocalGraph)
 	ginServer.Handle("POST", "/api/graph/getGraph", model.CheckAuth, getGraph)
@@ -433,6 +481,7 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/repo/downloadCloudSnapshot", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, downloadCloudSnapshot)
 	ginServer.Handle("POST", "/api/repo/diffRepoSnapshots", model.CheckAuth, model.CheckAdminRole, diffRepoSnapshots)
 	ginServer.Handle("POST", "/api/repo/openRepoSnapshotDoc", model.CheckAuth, model.CheckAdminRole, openRepoSnapshotDoc)
+	ginServer.Handle("POST", "/api/repo/diffRepoSnapshotDoc", model.CheckAuth, model.CheckAdminRole, diffRepoSnapshotDoc)
 	ginServer.Handle("POST", "/api/repo/getRepoFile", model.CheckAuth, model.CheckAdminRole, getRepoFile)
 	ginServer.Handle("POST", "/api/repo/setRepoIndexRetentionDays", model.CheckAuth, model.CheckAdminRole, setRepoIndexRetentionDays)
 	ginServer.Handle("POST", "/api/repo/setRetentionIndexesDaily", model.CheckAuth, model.CheckAdminRole, setRetentionIndexesDaily)
@@ -454,6 +503,11 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/riff/resetRiffCards", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, resetRiffCards)
 	ginServer.Handle("POST", "/api/riff/batchSetRiffCardsDueTime", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, batchSetRiffCardsDueTime)
 	ginServer.Handle("POST", "/api/riff/getRiffCardsByBlockIDs", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, getRiffCardsByBlockIDs)
+	ginServer.Handle("POST", "/api/riff/getRiffReviewStats", model.CheckAuth, model.CheckAdminRole, getRiffReviewStats)
+	ginServer.Handle("POST", "/api/riff/getRiffCardStats", model.CheckAuth, model.CheckAdminRole, getRiffCardStats)
+	ginServer.Handle("POST", "/api/riff/optimizeRiffFSRS", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, optimizeRiffFSRS)
+	ginServer.Handle("POST", "/api/riff/getRiffDeckFSRS", model.CheckAuth, model.CheckAdminRole, getRiffDeckFSRS)
+	ginServer.Handle("POST", "/api/riff/setRiffDeckFSRS", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setRiffDeckFSRS)
 
 	ginServer.Handle("POST", "/api/notification/pushMsg", model.CheckAuth, model.CheckAdminRole, pushMsg)
 	ginServer.Handle("POST", "/api/notification/pushErrMsg", model.CheckAuth, model.CheckAdminRole, pushErrMsg)
@@ -497,6 +551,9 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/av/getUnusedAttributeViews", model.CheckAuth, getUnusedAttributeViews)
 	ginServer.Handle("POST", "/api/av/removeUnusedAttributeViews", model.CheckAuth, removeUnusedAttributeViews)
 	ginServer.Handle("POST", "/api/av/removeUnusedAttributeView", model.CheckAuth, removeUnusedAttributeView)
+	ginServer.Handle("POST", "/api/av/getAttributeViewAddressBooks", model.CheckAuth, model.CheckAdminRole, getAttributeViewAddressBooks)
+	ginServer.Handle("POST", "/api/av/bindAttributeViewAddressBook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, bindAttributeViewAddressBook)
+	ginServer.Handle("POST", "/api/av/unbindAttributeViewAddressBook", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, unbindAttributeViewAddressBook)
 
 	ginServer.Handle("POST", "/api/ai/chatGPT", model.CheckAuth, model.CheckAdminRole, chatGPT)
 	ginServer.Handle("POST", "/api/ai/chatGPTWithAction", model.CheckAuth, model.CheckAdminRole, chatGPTWithAction)
@@ -35,6 +35,10 @@ func listInvalidBlockRefs(c *gin.Context) {
 		return
 	}
 
+	if model.RefuseRestricted(c, ret) {
+		return
+	}
+
 	page := 1
 	if nil != arg["page"] {
 		page = int(arg["page"].(float64))
@@ -73,8 +77,13 @@ func getAssetContent(c *gin.Context) {
 	query := arg["query"].(string)
 	queryMethod := int(arg["queryMethod"].(float64))
 
+	assetContent := model.GetAssetContent(id, query, queryMethod)
+	if nil != assetContent && model.RefuseToReadAsset(c, assetContent.Path, ret) {
+		return
+	}
+
 	ret.Data = map[string]interface{}{
-		"assetContent": model.GetAssetContent(id, query, queryMethod),
+		"assetContent": assetContent,
 	}
 	return
 }
@@ -89,7 +98,7 @@ func fullTextSearchAssetContent(c *gin.Context) {
 	}
 
 	page, pageSize, query, types, method, orderBy := parseSearchAssetContentArgs(arg)
-	assetContents, matchedAssetCount, pageCount := model.FullTextSearchAssetContent(query, types, method, orderBy, page, pageSize)
+	assetContents, matchedAssetCount, pageCount := model.FullTextSearchAssetContent(query, types, method, orderBy, page, pageSize, model.GetReadableFilter(c))
 	ret.Data = map[string]interface{}{
 		"assetContents":     assetContents,
 		"matchedAssetCount": matchedAssetCount,
@@ -154,7 +163,7 @@ func searchAsset(c *gin.Context) {
 		}
 	}
 
-	ret.Data = model.SearchAssetsByName(k, exts)
+	ret.Data = model.SearchAssetsByName(k, exts, model.GetReadableFilter(c))
 	return
 }
 
@@ -168,7 +177,7 @@ func searchTag(c *gin.Context) {
 	}
 
 	k := arg["k"].(string)
-	tags := model.SearchTags(k)
+	tags := model.SearchTags(k, model.GetReadableFilter(c))
 	if 1 > len(tags) {
 		tags = []string{}
 	}
@@ -257,6 +266,7 @@ func getEmbedBlock(c *gin.Context) {
 		breadcrumb = breadcrumbArg.(bool)
 	}
 
+	includeIDs = model.FilterBlockIDs(c, includeIDs)
 	blocks := model.GetEmbedBlock(embedBlockID, includeIDs, headingMode, breadcrumb)
 	ret.Data = map[string]interface{}{
 		"blocks": blocks,
@@ -313,7 +323,7 @@ func searchEmbedBlock(c *gin.Context) {
 		breadcrumb = breadcrumbArg.(bool)
 	}
 
-	blocks := model.SearchEmbedBlock(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
+	blocks := model.SearchEmbedBlock(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb, model.GetReadableFilter(c))
 	ret.Data = map[string]interface{}{
 		"blocks": blocks,
 	}
@@ -349,6 +359,7 @@ func searchRefBlock(c *gin.Context) {
 	keyword := arg["k"].(string)
 	beforeLen := int(arg["beforeLen"].(float64))
 	blocks, newDoc := model.SearchRefBlock(id, rootID, keyword, beforeLen, isSquareBrackets, isDatabase)
+	blocks = model.FilterBlocksByRole(c, blocks)
 	ret.Data = map[string]interface{}{
 		"blocks": blocks,
 		"newDoc": newDoc,
@@ -367,7 +378,7 @@ func fullTextSearchBlock(c *gin.Context) {
 	}
 
 	page, pageSize, query, paths, boxes, types, method, orderBy, groupBy := parseSearchBlockArgs(arg)
-	blocks, matchedBlockCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(query, boxes, paths, types, method, orderBy, groupBy, page, pageSize)
+	blocks, matchedBlockCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(query, boxes, paths, types, method, orderBy, groupBy, page, pageSize, model.GetReadableFilter(c))
 	ret.Data = map[string]interface{}{
 		"blocks":            blocks,
 		"matchedBlockCount": matchedBlockCount,
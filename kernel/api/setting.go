@@ -186,6 +186,10 @@ func setAI(c *gin.Context) {
 		return
 	}
 
+	if nil == ai.OpenAI {
+		ai.OpenAI = conf.NewAI().OpenAI
+	}
+
 	if 5 > ai.OpenAI.APITimeout {
 		ai.OpenAI.APITimeout = 5
 	}
@@ -205,6 +209,8 @@ func setAI(c *gin.Context) {
 		ai.OpenAI.APIMaxContexts = 7
 	}
 
+	ai.FixProviders()
+
 	model.Conf.AI = ai
 	model.Conf.Save()
 
@@ -584,6 +590,7 @@ func setPublish(c *gin.Context) {
 		return
 	}
 
+	publish.Shares = model.Conf.Publish.Shares // 分享链接通过单独的接口管理
 	model.Conf.Publish = publish
 	model.Conf.Save()
 
@@ -613,6 +620,104 @@ func getPublish(c *gin.Context) {
 	}
 }
 
+func createPublishShare(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	var memo string
+	if memoArg, ok := arg["memo"].(string); ok {
+		memo = memoArg
+	}
+	var expired int64
+	if expiredArg, ok := arg["expired"].(float64); ok {
+		expired = int64(expiredArg)
+	}
+
+	share, token, err := model.CreatePublishShare(id, memo, expired)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]any{
+		"share": share,
+		"token": token,
+		"path":  "/?" + model.PublishShareQueryKey + "=" + token,
+	}
+}
+
+func removePublishShare(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if err := model.RemovePublishShare(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = model.Conf.Publish.Shares
+}
+
+func setACL(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	acl := conf.NewACL()
+	if err = gulu.JSON.UnmarshalJSON(param, acl); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	for _, rule := range acl.Rules {
+		if uint(model.RoleAdministrator) == rule.Role || uint(model.RoleVisitor) < rule.Role {
+			ret.Code = -1
+			ret.Msg = fmt.Sprintf("invalid role [%d]", rule.Role)
+			return
+		}
+	}
+
+	model.Conf.ACL = acl
+	model.Conf.Save()
+	ret.Data = model.Conf.ACL
+}
+
+func getACL(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.Conf.ACL
+}
+
 func getCloudUser(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
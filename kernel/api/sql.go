@@ -17,7 +17,10 @@
 package api
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
@@ -45,6 +48,18 @@ func SQL(c *gin.Context) {
 	}
 
 	stmt := arg["stmt"].(string)
+	if readable := model.SQLReadableFunc(c); nil != readable {
+		// 受限时在只读连接上执行，查询结果中的 box、path 列不可信，只能由连接上的访问控制约束
+		result, err := sql.QueryReadonly(c.Request.Context(), &sql.ReadonlyQuery{Stmt: stmt, Limit: model.Conf.Search.Limit, Readable: readable})
+		if err != nil {
+			ret.Code = 1
+			ret.Msg = err.Error()
+			return
+		}
+		ret.Data = result.Rows
+		return
+	}
+
 	result, err := sql.Query(stmt, model.Conf.Search.Limit)
 	if err != nil {
 		ret.Code = 1
@@ -54,3 +69,103 @@ func SQL(c *gin.Context) {
 
 	ret.Data = result
 }
+
+func sqlReadonly(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	stmt, _ := arg["stmt"].(string)
+	query := &sql.ReadonlyQuery{
+		Stmt:     stmt,
+		Params:   map[string]interface{}{},
+		Limit:    model.Conf.Search.Limit,
+		Readable: model.SQLReadableFunc(c),
+		Session:  model.GetGinContextSessionKey(c),
+	}
+	if idArg, exists := arg["id"]; exists {
+		id, ok := idArg.(string)
+		if !ok {
+			ret.Code = -1
+			ret.Msg = "id must be a string"
+			return
+		}
+		query.ID = id
+	}
+	if paramsArg, ok := arg["params"].(map[string]interface{}); ok {
+		for name, value := range paramsArg {
+			switch v := value.(type) {
+			case float64:
+				if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+					query.Params[name] = int64(v)
+				} else {
+					query.Params[name] = v
+				}
+			case string, bool, nil:
+				query.Params[name] = v
+			default:
+				ret.Code = -1
+				ret.Msg = fmt.Sprintf("unsupported value type of param [%s]", name)
+				return
+			}
+		}
+	}
+	if timeoutArg, ok := arg["timeout"].(float64); ok {
+		query.Timeout = time.Duration(timeoutArg) * time.Millisecond
+	}
+	if limitArg, ok := arg["limit"].(float64); ok && 0 < limitArg && int(limitArg) < query.Limit {
+		query.Limit = int(limitArg)
+	}
+	if keysetArg, ok := arg["keyset"].([]interface{}); ok {
+		for _, key := range keysetArg {
+			k, ok := key.(string)
+			if !ok {
+				ret.Code = -1
+				ret.Msg = "keyset must be an array of strings"
+				return
+			}
+			query.Keyset = append(query.Keyset, k)
+		}
+	}
+	if descArg, ok := arg["desc"].(bool); ok {
+		query.Desc = descArg
+	}
+	if cursorArg, ok := arg["cursor"].(string); ok {
+		query.Cursor = cursorArg
+	}
+	if explainArg, ok := arg["explain"].(bool); ok {
+		query.Explain = explainArg
+	}
+
+	result, err := sql.QueryReadonly(c.Request.Context(), query)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = result
+}
+
+func cancelSQLReadonly(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id, ok := arg["id"].(string)
+	if !ok || "" == id {
+		ret.Code = -1
+		ret.Msg = "id is required"
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"canceled": sql.CancelReadonlyQuery(model.GetGinContextSessionKey(c), id),
+	}
+}
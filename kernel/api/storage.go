@@ -46,7 +46,7 @@ func getRecentDocs(c *gin.Context) {
 		ret.Msg = err.Error()
 		return
 	}
-	ret.Data = data
+	ret.Data = model.FilterRecentDocsByRole(c, data)
 }
 
 func removeCriterion(c *gin.Context) {
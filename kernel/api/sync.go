@@ -747,3 +747,31 @@ func setCloudSyncDir(c *gin.Context) {
 	name := arg["name"].(string)
 	model.SetCloudSyncDir(name)
 }
+
+func getSyncMergeConflicts(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"conflicts": model.GetSyncMergeConflicts(),
+	}
+}
+
+func resolveSyncMergeConflict(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	keep := arg["keep"].(string)
+	err := model.ResolveSyncMergeConflict(id, keep)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
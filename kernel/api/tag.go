@@ -51,7 +51,7 @@ func getTag(c *gin.Context) {
 	}
 
 	app := arg["app"].(string)
-	ret.Data = model.BuildTags(ignoreMaxListHint, app)
+	ret.Data = model.BuildTags(ignoreMaxListHint, app, model.GetReadableFilter(c))
 }
 
 func renameTag(c *gin.Context) {
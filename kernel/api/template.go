@@ -34,6 +34,11 @@ func renderSprig(c *gin.Context) {
 		return
 	}
 
+	// 模板中可以执行 SQL 查询，受限请求不支持渲染
+	if model.RefuseRestricted(c, ret) {
+		return
+	}
+
 	template := arg["template"].(string)
 	content, err := model.RenderGoTemplate(template)
 	if err != nil {
@@ -63,6 +63,11 @@ func performTransactions(c *gin.Context) {
 	for _, transaction := range transactions {
 		transaction.Timestamp = timestamp
 	}
+	model.SetTransactionsRequest(c, transactions)
+
+	if model.RefuseToPerformTransactions(transactions, ret) {
+		return
+	}
 
 	model.PerformTransactions(&transactions)
 
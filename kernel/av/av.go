@@ -29,6 +29,7 @@ import (
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -118,6 +119,7 @@ const (
 	KeyTypeRelation   KeyType = "relation"   // 关联
 	KeyTypeRollup     KeyType = "rollup"     // 汇总
 	KeyTypeLineNumber KeyType = "lineNumber" // 行号
+	KeyTypeFormula    KeyType = "formula"    // 公式
 )
 
 // Key 描述了属性视图属性字段的基础结构。
@@ -145,6 +147,9 @@ type Key struct {
 	// 汇总
 	Rollup *Rollup `json:"rollup,omitempty"` // 汇总信息
 
+	// 公式
+	Formula string `json:"formula,omitempty"` // 公式表达式
+
 	// 日期
 	Date *Date `json:"date,omitempty"` // 日期设置
 
@@ -212,19 +217,21 @@ type SelectOption struct {
 
 // View 描述了视图的结构。
 type View struct {
-	ID               string         `json:"id"`                // 视图 ID
-	Icon             string         `json:"icon"`              // 视图图标
-	Name             string         `json:"name"`              // 视图名称
-	HideAttrViewName bool           `json:"hideAttrViewName"`  // 是否隐藏属性视图名称
-	Desc             string         `json:"desc"`              // 视图描述
-	Filters          []*ViewFilter  `json:"filters,omitempty"` // 过滤规则
-	Sorts            []*ViewSort    `json:"sorts,omitempty"`   // 排序规则
-	PageSize         int            `json:"pageSize"`          // 每页条目数
-	LayoutType       LayoutType     `json:"type"`              // 当前布局类型
-	Table            *LayoutTable   `json:"table,omitempty"`   // 表格布局
-	Gallery          *LayoutGallery `json:"gallery,omitempty"` // 卡片布局
-	Kanban           *LayoutKanban  `json:"kanban,omitempty"`  // 看板布局
-	ItemIDs          []string       `json:"itemIds,omitempty"` // 项目 ID 列表，用于维护所有项目
+	ID               string          `json:"id"`                 // 视图 ID
+	Icon             string          `json:"icon"`               // 视图图标
+	Name             string          `json:"name"`               // 视图名称
+	HideAttrViewName bool            `json:"hideAttrViewName"`   // 是否隐藏属性视图名称
+	Desc             string          `json:"desc"`               // 视图描述
+	Filters          []*ViewFilter   `json:"filters,omitempty"`  // 过滤规则
+	Sorts            []*ViewSort     `json:"sorts,omitempty"`    // 排序规则
+	PageSize         int             `json:"pageSize"`           // 每页条目数
+	LayoutType       LayoutType      `json:"type"`               // 当前布局类型
+	Table            *LayoutTable    `json:"table,omitempty"`    // 表格布局
+	Gallery          *LayoutGallery  `json:"gallery,omitempty"`  // 卡片布局
+	Kanban           *LayoutKanban   `json:"kanban,omitempty"`   // 看板布局
+	Calendar         *LayoutCalendar `json:"calendar,omitempty"` // 日历布局
+	Timeline         *LayoutTimeline `json:"timeline,omitempty"` // 时间线布局
+	ItemIDs          []string        `json:"itemIds,omitempty"`  // 项目 ID 列表，用于维护所有项目
 
 	Group        *ViewGroup `json:"group,omitempty"`     // 分组规则
 	GroupCreated int64      `json:"groupCreated"`        // 分组生成时间戳
@@ -314,9 +321,11 @@ type GroupCalc struct {
 type LayoutType string
 
 const (
-	LayoutTypeTable   LayoutType = "table"   // 属性视图类型 - 表格
-	LayoutTypeGallery LayoutType = "gallery" // 属性视图类型 - 卡片
-	LayoutTypeKanban  LayoutType = "kanban"  // 属性视图类型 - 看板
+	LayoutTypeTable    LayoutType = "table"    // 属性视图类型 - 表格
+	LayoutTypeGallery  LayoutType = "gallery"  // 属性视图类型 - 卡片
+	LayoutTypeKanban   LayoutType = "kanban"   // 属性视图类型 - 看板
+	LayoutTypeCalendar LayoutType = "calendar" // 属性视图类型 - 日历
+	LayoutTypeTimeline LayoutType = "timeline" // 属性视图类型 - 时间线
 )
 
 const (
@@ -378,6 +387,30 @@ func NewKanbanView() (ret *View) {
 	}
 }
 
+func NewCalendarView() (ret *View) {
+	return &View{
+		ID:         ast.NewNodeID(),
+		Name:       GetAttributeViewI18n("calendar"),
+		Filters:    []*ViewFilter{},
+		Sorts:      []*ViewSort{},
+		PageSize:   ViewDefaultPageSize,
+		LayoutType: LayoutTypeCalendar,
+		Calendar:   NewLayoutCalendar(),
+	}
+}
+
+func NewTimelineView() (ret *View) {
+	return &View{
+		ID:         ast.NewNodeID(),
+		Name:       GetAttributeViewI18n("timeline"),
+		Filters:    []*ViewFilter{},
+		Sorts:      []*ViewSort{},
+		PageSize:   ViewDefaultPageSize,
+		LayoutType: LayoutTypeTimeline,
+		Timeline:   NewLayoutTimeline(),
+	}
+}
+
 // Viewable 描述了视图的接口。
 type Viewable interface {
 
@@ -645,6 +678,8 @@ func SaveAttributeView(av *AttributeView) (err error) {
 		msg := fmt.Sprintf(util.Langs[util.Lang][268], av.Name+" "+filepath.Base(avJSONPath), util.LargeFileWarningSize)
 		util.PushErrMsg(msg, 7000)
 	}
+
+	eventbus.Publish(util.EvtAttributeViewSaved, av.ID)
 	return
 }
 
@@ -847,6 +882,18 @@ func (av *AttributeView) Clone() (ret *AttributeView) {
 			for _, field := range view.Kanban.Fields {
 				field.ID = keyIDMap[field.ID]
 			}
+		case LayoutTypeCalendar:
+			view.Calendar.ID = ast.NewNodeID()
+			view.Calendar.DateKeyID = keyIDMap[view.Calendar.DateKeyID]
+			for _, field := range view.Calendar.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
+		case LayoutTypeTimeline:
+			view.Timeline.ID = ast.NewNodeID()
+			view.Timeline.DateKeyID = keyIDMap[view.Timeline.DateKeyID]
+			for _, field := range view.Timeline.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
 		}
 		view.ItemIDs = []string{}
 	}
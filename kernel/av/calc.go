@@ -128,9 +128,58 @@ func calcField(collection Collection, field Field, fieldIndex int, attrView *Att
 		calcFieldRelation(collection, field, fieldIndex)
 	case KeyTypeRollup:
 		calcFieldRollup(collection, field, fieldIndex)
+	case KeyTypeFormula:
+		calcFieldFormula(collection, field, fieldIndex)
 	}
 }
 
+// calcFieldFormula 按照公式计算结果的类型进行字段计算。
+func calcFieldFormula(collection Collection, field Field, fieldIndex int) {
+	resultType := KeyTypeText
+	for _, item := range collection.GetItems() {
+		if v := item.GetValues()[fieldIndex].Unwrap(); !v.IsEmpty() {
+			resultType = v.Type
+			break
+		}
+	}
+
+	formulaCollection := &formulaResultCollection{Collection: collection, fieldIndex: fieldIndex}
+	switch resultType {
+	case KeyTypeNumber:
+		calcFieldNumber(formulaCollection, field, fieldIndex)
+	case KeyTypeDate:
+		calcFieldDate(formulaCollection, field, fieldIndex)
+	case KeyTypeCheckbox:
+		calcFieldCheckbox(formulaCollection, field, fieldIndex)
+	default:
+		calcFieldText(formulaCollection, field, fieldIndex)
+	}
+}
+
+// formulaResultCollection 将公式字段的值替换为计算结果，以便复用其他类型字段的计算逻辑。
+type formulaResultCollection struct {
+	Collection
+	fieldIndex int
+}
+
+func (c *formulaResultCollection) GetItems() (ret []Item) {
+	for _, item := range c.Collection.GetItems() {
+		ret = append(ret, &formulaResultItem{Item: item, fieldIndex: c.fieldIndex})
+	}
+	return
+}
+
+type formulaResultItem struct {
+	Item
+	fieldIndex int
+}
+
+func (item *formulaResultItem) GetValues() (ret []*Value) {
+	ret = append(ret, item.Item.GetValues()...)
+	ret[item.fieldIndex] = ret[item.fieldIndex].Unwrap()
+	return
+}
+
 func calcFieldTemplate(collection Collection, field Field, fieldIndex int) {
 	calc := field.GetCalc()
 	switch calc.Operator {
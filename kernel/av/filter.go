@@ -156,6 +156,27 @@ func (value *Value) Filter(filter *ViewFilter, attrView *AttributeView, itemID s
 		}
 	}
 
+	// 单独处理公式，使用计算结果按照结果类型进行过滤
+	if KeyTypeFormula == value.Type {
+		content := value.Unwrap()
+		if nil == content {
+			return false
+		}
+
+		other := filter.Value.Unwrap()
+		if nil == other {
+			if nil != filter.RelativeDate && KeyTypeDate == content.Type {
+				return content.filter(&Value{Type: KeyTypeDate, Date: &ValueDate{}}, filter.RelativeDate, filter.RelativeDate2, filter.Operator)
+			}
+			return true
+		}
+		if content.Type != other.Type {
+			// 计算结果类型和过滤值类型不同时无法比较，不匹配
+			return false
+		}
+		return content.filter(other, filter.RelativeDate, filter.RelativeDate2, filter.Operator)
+	}
+
 	// 单独处理汇总
 	if nil != value.Rollup && KeyTypeRollup == value.Type && nil != filter.Value && KeyTypeRollup == filter.Value.Type && nil != filter.Value.Rollup {
 		key, _ := attrView.GetKey(value.KeyID)
@@ -1038,7 +1059,7 @@ func (filter *ViewFilter) IsValid() bool {
 
 func (filter *ViewFilter) GetAffectValue(key *Key, addingBlockID string) (ret *Value) {
 	if nil != filter.Value {
-		if KeyTypeRelation == filter.Value.Type || KeyTypeTemplate == filter.Value.Type || KeyTypeRollup == filter.Value.Type || KeyTypeUpdated == filter.Value.Type || KeyTypeCreated == filter.Value.Type {
+		if KeyTypeRelation == filter.Value.Type || KeyTypeTemplate == filter.Value.Type || KeyTypeRollup == filter.Value.Type || KeyTypeUpdated == filter.Value.Type || KeyTypeCreated == filter.Value.Type || KeyTypeFormula == filter.Value.Type {
 			// 所有生成的数据都不设置默认值
 			return nil
 		}
@@ -0,0 +1,1306 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/araddon/dateparse"
+)
+
+// 公式字段使用的表达式语言：
+//
+//   - 字面量：数字 1.5 或 1e20、字符串 "abc" 或 'abc'、布尔值 true/false
+//   - 字段引用：prop("字段名")
+//   - 运算符：+ - * / % 、比较 == != > >= < <= 、逻辑 && || !（也可以使用 and or not），类型不同无法比较时比较结果为假
+//   - 函数：if、empty、数学函数、字符串函数、日期函数和列表函数，见 formulaFuncs
+//
+// 计算结果为数字、日期、复选框或者文本，关联和汇总字段的值作为列表参与计算。
+
+var (
+	ErrFormulaSyntax = errors.New("formula syntax error")
+)
+
+// Formula 描述了解析后的公式。
+type Formula struct {
+	Expr string
+	root formulaNode
+}
+
+// ParseFormula 解析公式表达式。
+func ParseFormula(expr string) (ret *Formula, err error) {
+	p := &formulaParser{}
+	if p.tokens, err = lexFormula(expr); err != nil {
+		return
+	}
+
+	root, err := p.parseExpr(0)
+	if err != nil {
+		return
+	}
+	if p.pos < len(p.tokens) {
+		err = fmt.Errorf("%w: unexpected [%s]", ErrFormulaSyntax, p.tokens[p.pos].text)
+		return
+	}
+	ret = &Formula{Expr: expr, root: root}
+	return
+}
+
+// Props 返回公式中通过 prop() 引用的字段名。
+func (f *Formula) Props() (ret []string) {
+	walkFormulaNode(f.root, func(n formulaNode) {
+		if call, ok := n.(*formulaCall); ok && "prop" == call.name && 1 == len(call.args) {
+			if lit, ok := call.args[0].(*formulaLiteral); ok && formulaKindText == lit.val.kind {
+				ret = append(ret, lit.val.str)
+			}
+		}
+	})
+	return
+}
+
+// Eval 计算公式，prop 用于按字段名获取当前项目的值，numberFormat 用于格式化数字结果。
+func (f *Formula) Eval(prop func(name string) (*Value, error), numberFormat NumberFormat) (ret *Value, err error) {
+	ctx := &formulaContext{prop: prop, now: time.Now()}
+	val, err := ctx.eval(f.root)
+	if err != nil {
+		return
+	}
+	ret, err = val.toValue(numberFormat)
+	return
+}
+
+type formulaKind int
+
+const (
+	formulaKindNull formulaKind = iota
+	formulaKindNumber
+	formulaKindText
+	formulaKindBool
+	formulaKindDate
+	formulaKindList
+)
+
+type formulaValue struct {
+	kind    formulaKind
+	num     float64
+	str     string
+	b       bool
+	t       time.Time
+	hasTime bool
+	list    []*formulaValue
+}
+
+var formulaNull = &formulaValue{kind: formulaKindNull}
+
+func formulaNumber(n float64) *formulaValue {
+	return &formulaValue{kind: formulaKindNumber, num: n}
+}
+
+func formulaText(s string) *formulaValue {
+	return &formulaValue{kind: formulaKindText, str: s}
+}
+
+func formulaBool(b bool) *formulaValue {
+	return &formulaValue{kind: formulaKindBool, b: b}
+}
+
+func formulaDate(t time.Time, hasTime bool) *formulaValue {
+	return &formulaValue{kind: formulaKindDate, t: t, hasTime: hasTime}
+}
+
+// unwrap 将只有一个元素的列表展开，方便对汇总计算结果直接进行运算。
+func (v *formulaValue) unwrap() *formulaValue {
+	if formulaKindList == v.kind {
+		if 1 == len(v.list) {
+			return v.list[0].unwrap()
+		}
+		if 0 == len(v.list) {
+			return formulaNull
+		}
+	}
+	return v
+}
+
+func (v *formulaValue) isEmpty() bool {
+	switch v.kind {
+	case formulaKindNull:
+		return true
+	case formulaKindText:
+		return "" == strings.TrimSpace(v.str)
+	case formulaKindList:
+		return 1 > len(v.list)
+	}
+	return false
+}
+
+func (v *formulaValue) truthy() bool {
+	v = v.unwrap()
+	switch v.kind {
+	case formulaKindNumber:
+		return 0 != v.num
+	case formulaKindText:
+		return "" != v.str
+	case formulaKindBool:
+		return v.b
+	case formulaKindDate:
+		return true
+	case formulaKindList:
+		return 0 < len(v.list)
+	}
+	return false
+}
+
+func (v *formulaValue) toNumber() (float64, error) {
+	v = v.unwrap()
+	switch v.kind {
+	case formulaKindNull:
+		return 0, nil
+	case formulaKindNumber:
+		return v.num, nil
+	case formulaKindBool:
+		if v.b {
+			return 1, nil
+		}
+		return 0, nil
+	case formulaKindDate:
+		return float64(v.t.UnixMilli()), nil
+	case formulaKindText:
+		s := strings.TrimSpace(v.str)
+		if "" == s {
+			return 0, nil
+		}
+		n, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert [%s] to number", v.str)
+		}
+		return n, nil
+	}
+	return 0, errors.New("cannot convert list to number")
+}
+
+func (v *formulaValue) toDate() (time.Time, bool, error) {
+	v = v.unwrap()
+	switch v.kind {
+	case formulaKindDate:
+		return v.t, v.hasTime, nil
+	case formulaKindNumber:
+		return time.UnixMilli(int64(v.num)), true, nil
+	case formulaKindText:
+		t, err := dateparse.ParseIn(strings.TrimSpace(v.str), time.Local)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("cannot convert [%s] to date", v.str)
+		}
+		return t, 0 != t.Hour() || 0 != t.Minute() || 0 != t.Second(), nil
+	}
+	return time.Time{}, false, errors.New("cannot convert value to date")
+}
+
+func (v *formulaValue) String() string {
+	switch v.kind {
+	case formulaKindNumber:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case formulaKindText:
+		return v.str
+	case formulaKindBool:
+		if v.b {
+			return "true"
+		}
+		return "false"
+	case formulaKindDate:
+		if v.hasTime {
+			return v.t.Format("2006-01-02 15:04")
+		}
+		return v.t.Format("2006-01-02")
+	case formulaKindList:
+		var items []string
+		for _, item := range v.list {
+			if s := item.String(); "" != s {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ", ")
+	}
+	return ""
+}
+
+func (v *formulaValue) toValue(numberFormat NumberFormat) (ret *Value, err error) {
+	v = v.unwrap()
+	switch v.kind {
+	case formulaKindNull:
+		return
+	case formulaKindNumber:
+		if math.IsNaN(v.num) || math.IsInf(v.num, 0) {
+			err = errors.New("result is not a finite number")
+			return
+		}
+		ret = &Value{Type: KeyTypeNumber, Number: NewFormattedValueNumber(v.num, numberFormat)}
+	case formulaKindBool:
+		ret = &Value{Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{Checked: v.b}}
+	case formulaKindDate:
+		date := NewFormattedValueDate(v.t.UnixMilli(), 0, DateFormatNone, !v.hasTime, false)
+		date.IsNotEmpty = true
+		ret = &Value{Type: KeyTypeDate, Date: date}
+	default:
+		ret = &Value{Type: KeyTypeText, Text: &ValueText{Content: v.String()}}
+	}
+	return
+}
+
+// newFormulaValue 将字段值转换为公式值。
+func newFormulaValue(value *Value) *formulaValue {
+	if nil == value {
+		return formulaNull
+	}
+
+	switch value.Type {
+	case KeyTypeNumber:
+		if nil == value.Number || !value.Number.IsNotEmpty {
+			return formulaNull
+		}
+		return formulaNumber(value.Number.Content)
+	case KeyTypeDate:
+		if nil == value.Date || !value.Date.IsNotEmpty {
+			return formulaNull
+		}
+		return formulaDate(time.UnixMilli(value.Date.Content), !value.Date.IsNotTime)
+	case KeyTypeCreated:
+		if nil == value.Created || !value.Created.IsNotEmpty {
+			return formulaNull
+		}
+		return formulaDate(time.UnixMilli(value.Created.Content), true)
+	case KeyTypeUpdated:
+		if nil == value.Updated || !value.Updated.IsNotEmpty {
+			return formulaNull
+		}
+		return formulaDate(time.UnixMilli(value.Updated.Content), true)
+	case KeyTypeCheckbox:
+		return formulaBool(nil != value.Checkbox && value.Checkbox.Checked)
+	case KeyTypeMSelect:
+		ret := &formulaValue{kind: formulaKindList}
+		for _, opt := range value.MSelect {
+			ret.list = append(ret.list, formulaText(opt.Content))
+		}
+		return ret
+	case KeyTypeMAsset:
+		ret := &formulaValue{kind: formulaKindList}
+		for _, asset := range value.MAsset {
+			ret.list = append(ret.list, formulaText(asset.Content))
+		}
+		return ret
+	case KeyTypeRelation:
+		ret := &formulaValue{kind: formulaKindList}
+		if nil != value.Relation {
+			for _, content := range value.Relation.Contents {
+				ret.list = append(ret.list, newFormulaValue(content))
+			}
+		}
+		return ret
+	case KeyTypeRollup:
+		ret := &formulaValue{kind: formulaKindList}
+		if nil != value.Rollup {
+			for _, content := range value.Rollup.Contents {
+				ret.list = append(ret.list, newFormulaValue(content))
+			}
+		}
+		return ret
+	case KeyTypeFormula:
+		if nil == value.Formula {
+			return formulaNull
+		}
+		return newFormulaValue(value.Formula.Content)
+	case KeyTypeLineNumber:
+		return formulaNull
+	}
+	return formulaText(value.String(false))
+}
+
+type formulaNode interface{}
+
+type formulaLiteral struct {
+	val *formulaValue
+}
+
+type formulaUnary struct {
+	op      string
+	operand formulaNode
+}
+
+type formulaBinary struct {
+	op          string
+	left, right formulaNode
+}
+
+type formulaCall struct {
+	name string
+	args []formulaNode
+}
+
+func walkFormulaNode(n formulaNode, visit func(formulaNode)) {
+	visit(n)
+	switch node := n.(type) {
+	case *formulaUnary:
+		walkFormulaNode(node.operand, visit)
+	case *formulaBinary:
+		walkFormulaNode(node.left, visit)
+		walkFormulaNode(node.right, visit)
+	case *formulaCall:
+		for _, arg := range node.args {
+			walkFormulaNode(arg, visit)
+		}
+	}
+}
+
+type formulaTokenType int
+
+const (
+	formulaTokenNumber formulaTokenType = iota
+	formulaTokenString
+	formulaTokenIdent
+	formulaTokenOp
+)
+
+type formulaToken struct {
+	typ  formulaTokenType
+	text string
+}
+
+var formulaOps = []string{"==", "!=", ">=", "<=", "&&", "||", "+", "-", "*", "/", "%", ">", "<", "!", "(", ")", ","}
+
+func lexFormula(expr string) (ret []*formulaToken, err error) {
+	for i := 0; i < len(expr); {
+		r, size := utf8.DecodeRuneInString(expr[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case '"' == r || '\'' == r:
+			buf := strings.Builder{}
+			j := i + size
+			closed := false
+			for j < len(expr) {
+				c, s := utf8.DecodeRuneInString(expr[j:])
+				if '\\' == c && j+s < len(expr) {
+					next, ns := utf8.DecodeRuneInString(expr[j+s:])
+					switch next {
+					case 'n':
+						buf.WriteRune('\n')
+					case 't':
+						buf.WriteRune('\t')
+					default:
+						buf.WriteRune(next)
+					}
+					j += s + ns
+					continue
+				}
+				j += s
+				if c == r {
+					closed = true
+					break
+				}
+				buf.WriteRune(c)
+			}
+			if !closed {
+				err = fmt.Errorf("%w: unclosed string", ErrFormulaSyntax)
+				return
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenString, text: buf.String()})
+			i = j
+		case unicode.IsDigit(r) || ('.' == r && i+1 < len(expr) && unicode.IsDigit(rune(expr[i+1]))):
+			j := i
+			for j < len(expr) && (unicode.IsDigit(rune(expr[j])) || '.' == expr[j]) {
+				j++
+			}
+			// 科学计数法 1e20、1.5E-3
+			if j < len(expr) && ('e' == expr[j] || 'E' == expr[j]) {
+				k := j + 1
+				if k < len(expr) && ('+' == expr[k] || '-' == expr[k]) {
+					k++
+				}
+				if k < len(expr) && unicode.IsDigit(rune(expr[k])) {
+					for k < len(expr) && unicode.IsDigit(rune(expr[k])) {
+						k++
+					}
+					j = k
+				}
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenNumber, text: expr[i:j]})
+			i = j
+		case unicode.IsLetter(r) || '_' == r:
+			j := i
+			for j < len(expr) {
+				c, s := utf8.DecodeRuneInString(expr[j:])
+				if !unicode.IsLetter(c) && !unicode.IsDigit(c) && '_' != c {
+					break
+				}
+				j += s
+			}
+			ret = append(ret, &formulaToken{typ: formulaTokenIdent, text: expr[i:j]})
+			i = j
+		default:
+			matched := false
+			for _, op := range formulaOps {
+				if strings.HasPrefix(expr[i:], op) {
+					ret = append(ret, &formulaToken{typ: formulaTokenOp, text: op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				err = fmt.Errorf("%w: unexpected character [%c]", ErrFormulaSyntax, r)
+				return
+			}
+		}
+	}
+	return
+}
+
+type formulaParser struct {
+	tokens []*formulaToken
+	pos    int
+}
+
+// 二元运算符优先级
+var formulaPrecedences = map[string]int{
+	"||": 1, "or": 1,
+	"&&": 2, "and": 2,
+	"==": 3, "!=": 3,
+	">": 4, ">=": 4, "<": 4, "<=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+func (p *formulaParser) peek() *formulaToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *formulaParser) binaryOp() (op string, precedence int) {
+	token := p.peek()
+	if nil == token {
+		return
+	}
+
+	op = token.text
+	if formulaTokenIdent == token.typ {
+		op = strings.ToLower(op)
+		if "and" != op && "or" != op {
+			return "", 0
+		}
+	} else if formulaTokenOp != token.typ {
+		return "", 0
+	}
+	precedence = formulaPrecedences[op]
+	if 0 == precedence {
+		op = ""
+	}
+	return
+}
+
+func (p *formulaParser) parseExpr(minPrecedence int) (ret formulaNode, err error) {
+	if ret, err = p.parseUnary(); err != nil {
+		return
+	}
+
+	for {
+		op, precedence := p.binaryOp()
+		if "" == op || precedence <= minPrecedence {
+			return
+		}
+		p.pos++
+
+		var right formulaNode
+		if right, err = p.parseExpr(precedence); err != nil {
+			return
+		}
+		switch op {
+		case "and":
+			op = "&&"
+		case "or":
+			op = "||"
+		}
+		ret = &formulaBinary{op: op, left: ret, right: right}
+	}
+}
+
+func (p *formulaParser) parseUnary() (ret formulaNode, err error) {
+	token := p.peek()
+	if nil == token {
+		err = fmt.Errorf("%w: unexpected end", ErrFormulaSyntax)
+		return
+	}
+
+	if (formulaTokenOp == token.typ && ("-" == token.text || "!" == token.text)) || (formulaTokenIdent == token.typ && "not" == strings.ToLower(token.text)) {
+		p.pos++
+		var operand formulaNode
+		if operand, err = p.parseUnary(); err != nil {
+			return
+		}
+		op := token.text
+		if "-" != op {
+			op = "!"
+		}
+		ret = &formulaUnary{op: op, operand: operand}
+		return
+	}
+	return p.parsePrimary()
+}
+
+func (p *formulaParser) parsePrimary() (ret formulaNode, err error) {
+	token := p.peek()
+	p.pos++
+	switch token.typ {
+	case formulaTokenNumber:
+		n, parseErr := strconv.ParseFloat(token.text, 64)
+		if nil != parseErr {
+			err = fmt.Errorf("%w: invalid number [%s]", ErrFormulaSyntax, token.text)
+			return
+		}
+		ret = &formulaLiteral{val: formulaNumber(n)}
+	case formulaTokenString:
+		ret = &formulaLiteral{val: formulaText(token.text)}
+	case formulaTokenIdent:
+		name := strings.ToLower(token.text)
+		switch name {
+		case "true":
+			ret = &formulaLiteral{val: formulaBool(true)}
+			return
+		case "false":
+			ret = &formulaLiteral{val: formulaBool(false)}
+			return
+		}
+
+		if next := p.peek(); nil == next || "(" != next.text {
+			err = fmt.Errorf("%w: unknown identifier [%s], use prop(\"%s\") to reference a field", ErrFormulaSyntax, token.text, token.text)
+			return
+		}
+		p.pos++
+
+		call := &formulaCall{name: name}
+		if next := p.peek(); nil != next && ")" == next.text {
+			p.pos++
+		} else {
+			for {
+				var arg formulaNode
+				if arg, err = p.parseExpr(0); err != nil {
+					return
+				}
+				call.args = append(call.args, arg)
+
+				next := p.peek()
+				if nil == next {
+					err = fmt.Errorf("%w: missing [)]", ErrFormulaSyntax)
+					return
+				}
+				p.pos++
+				if ")" == next.text {
+					break
+				}
+				if "," != next.text {
+					err = fmt.Errorf("%w: unexpected [%s]", ErrFormulaSyntax, next.text)
+					return
+				}
+			}
+		}
+		if _, ok := formulaFuncs[name]; !ok && "prop" != name && "if" != name {
+			err = fmt.Errorf("%w: unknown function [%s]", ErrFormulaSyntax, token.text)
+			return
+		}
+		ret = call
+	default:
+		if "(" == token.text {
+			if ret, err = p.parseExpr(0); err != nil {
+				return
+			}
+			if next := p.peek(); nil == next || ")" != next.text {
+				err = fmt.Errorf("%w: missing [)]", ErrFormulaSyntax)
+				return
+			}
+			p.pos++
+			return
+		}
+		err = fmt.Errorf("%w: unexpected [%s]", ErrFormulaSyntax, token.text)
+	}
+	return
+}
+
+type formulaContext struct {
+	prop func(name string) (*Value, error)
+	now  time.Time
+}
+
+func (ctx *formulaContext) eval(n formulaNode) (ret *formulaValue, err error) {
+	switch node := n.(type) {
+	case *formulaLiteral:
+		ret = node.val
+	case *formulaUnary:
+		var operand *formulaValue
+		if operand, err = ctx.eval(node.operand); err != nil {
+			return
+		}
+		if "!" == node.op {
+			ret = formulaBool(!operand.truthy())
+			return
+		}
+		var num float64
+		if num, err = operand.toNumber(); err != nil {
+			return
+		}
+		ret = formulaNumber(-num)
+	case *formulaBinary:
+		ret, err = ctx.evalBinary(node)
+	case *formulaCall:
+		ret, err = ctx.evalCall(node)
+	}
+	return
+}
+
+func (ctx *formulaContext) evalBinary(node *formulaBinary) (ret *formulaValue, err error) {
+	left, err := ctx.eval(node.left)
+	if err != nil {
+		return
+	}
+
+	// 逻辑运算短路求值
+	switch node.op {
+	case "&&":
+		if !left.truthy() {
+			return formulaBool(false), nil
+		}
+		right, e := ctx.eval(node.right)
+		if nil != e {
+			return nil, e
+		}
+		return formulaBool(right.truthy()), nil
+	case "||":
+		if left.truthy() {
+			return formulaBool(true), nil
+		}
+		right, e := ctx.eval(node.right)
+		if nil != e {
+			return nil, e
+		}
+		return formulaBool(right.truthy()), nil
+	}
+
+	right, err := ctx.eval(node.right)
+	if err != nil {
+		return
+	}
+	left, right = left.unwrap(), right.unwrap()
+
+	switch node.op {
+	case "==", "!=", ">", ">=", "<", "<=":
+		cmp, ok := compareFormulaValues(left, right)
+		if !ok {
+			// 类型不同无法比较时比较结果为假
+			return formulaBool("!=" == node.op), nil
+		}
+		switch node.op {
+		case "==":
+			return formulaBool(0 == cmp), nil
+		case "!=":
+			return formulaBool(0 != cmp), nil
+		case ">":
+			return formulaBool(0 < cmp), nil
+		case ">=":
+			return formulaBool(0 <= cmp), nil
+		case "<":
+			return formulaBool(0 > cmp), nil
+		default:
+			return formulaBool(0 >= cmp), nil
+		}
+	case "+":
+		if formulaKindText == left.kind || formulaKindText == right.kind || formulaKindList == left.kind || formulaKindList == right.kind {
+			return formulaText(left.String() + right.String()), nil
+		}
+	}
+
+	if formulaKindNull == left.kind && formulaKindNull == right.kind {
+		return formulaNull, nil
+	}
+
+	l, err := left.toNumber()
+	if err != nil {
+		return
+	}
+	r, err := right.toNumber()
+	if err != nil {
+		return
+	}
+	switch node.op {
+	case "+":
+		ret = formulaNumber(l + r)
+	case "-":
+		ret = formulaNumber(l - r)
+	case "*":
+		ret = formulaNumber(l * r)
+	case "/":
+		if 0 == r {
+			err = errors.New("division by zero")
+			return
+		}
+		ret = formulaNumber(l / r)
+	case "%":
+		if 0 == r {
+			err = errors.New("division by zero")
+			return
+		}
+		ret = formulaNumber(math.Mod(l, r))
+	}
+	return
+}
+
+// compareFormulaValues 比较两个值，类型不同并且无法转换时 ok 为 false。
+// 空值可以和数字、复选框（作为 0）以及文本（作为空字符串）比较，文本只能和文本比较，
+// 日期可以和日期、数字（毫秒时间戳）以及能够解析为日期的文本比较。
+func compareFormulaValues(left, right *formulaValue) (ret int, ok bool) {
+	switch {
+	case formulaKindNull == left.kind && formulaKindNull == right.kind:
+		return 0, true
+	case formulaKindDate == left.kind || formulaKindDate == right.kind:
+		if formulaKindNull == left.kind || formulaKindNull == right.kind || formulaKindBool == left.kind || formulaKindBool == right.kind {
+			return
+		}
+		lt, _, e1 := left.toDate()
+		rt, _, e2 := right.toDate()
+		if nil != e1 || nil != e2 {
+			return
+		}
+		return lt.Compare(rt), true
+	case isFormulaNumeric(left.kind) && isFormulaNumeric(right.kind):
+		l, _ := left.toNumber()
+		r, _ := right.toNumber()
+		switch {
+		case l < r:
+			return -1, true
+		case l > r:
+			return 1, true
+		}
+		return 0, true
+	case (formulaKindText == left.kind || formulaKindNull == left.kind) && (formulaKindText == right.kind || formulaKindNull == right.kind):
+		return strings.Compare(left.String(), right.String()), true
+	case formulaKindList == left.kind && formulaKindList == right.kind:
+		return strings.Compare(left.String(), right.String()), true
+	}
+	return
+}
+
+func isFormulaNumeric(kind formulaKind) bool {
+	return formulaKindNumber == kind || formulaKindBool == kind || formulaKindNull == kind
+}
+
+func (ctx *formulaContext) evalCall(node *formulaCall) (ret *formulaValue, err error) {
+	switch node.name {
+	case "prop":
+		if 1 != len(node.args) {
+			return nil, errors.New("prop() requires a field name")
+		}
+		var name *formulaValue
+		if name, err = ctx.eval(node.args[0]); err != nil {
+			return
+		}
+		var value *Value
+		if value, err = ctx.prop(name.String()); err != nil {
+			return
+		}
+		return newFormulaValue(value), nil
+	case "if":
+		// if 的分支惰性求值
+		if 2 > len(node.args) || 3 < len(node.args) {
+			return nil, errors.New("if() requires 2 or 3 arguments")
+		}
+		var cond *formulaValue
+		if cond, err = ctx.eval(node.args[0]); err != nil {
+			return
+		}
+		if cond.truthy() {
+			return ctx.eval(node.args[1])
+		}
+		if 3 == len(node.args) {
+			return ctx.eval(node.args[2])
+		}
+		return formulaNull, nil
+	}
+
+	var args []*formulaValue
+	for _, arg := range node.args {
+		var val *formulaValue
+		if val, err = ctx.eval(arg); err != nil {
+			return
+		}
+		args = append(args, val)
+	}
+
+	fn := formulaFuncs[node.name]
+	if len(args) < fn.minArgs || (0 <= fn.maxArgs && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for %s()", node.name)
+	}
+	return fn.call(ctx, args)
+}
+
+type formulaFunc struct {
+	minArgs, maxArgs int // maxArgs 为 -1 时不限制参数个数
+	call             func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error)
+}
+
+var formulaFuncs map[string]*formulaFunc
+
+func init() {
+	formulaFuncs = map[string]*formulaFunc{
+		// 条件
+		"empty": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaBool(args[0].unwrap().isEmpty()), nil
+		}},
+		"not": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaBool(!args[0].truthy()), nil
+		}},
+
+		// 数学
+		"abs":   formulaMathFunc(math.Abs),
+		"floor": formulaMathFunc(math.Floor),
+		"ceil":  formulaMathFunc(math.Ceil),
+		"sqrt":  formulaMathFunc(math.Sqrt),
+		"round": {1, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			n, err := args[0].toNumber()
+			if err != nil {
+				return nil, err
+			}
+			digits := 0.0
+			if 2 == len(args) {
+				if digits, err = args[1].toNumber(); err != nil {
+					return nil, err
+				}
+			}
+			pow := math.Pow(10, digits)
+			return formulaNumber(math.Round(n*pow) / pow), nil
+		}},
+		"pow": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			nums, err := formulaNumbers(args)
+			if err != nil {
+				return nil, err
+			}
+			return formulaNumber(math.Pow(nums[0], nums[1])), nil
+		}},
+		"mod": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			nums, err := formulaNumbers(args)
+			if err != nil {
+				return nil, err
+			}
+			if 0 == nums[1] {
+				return nil, errors.New("division by zero")
+			}
+			return formulaNumber(math.Mod(nums[0], nums[1])), nil
+		}},
+		"tonumber": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			if args[0].unwrap().isEmpty() {
+				return formulaNull, nil
+			}
+			n, err := args[0].toNumber()
+			if err != nil {
+				return nil, err
+			}
+			return formulaNumber(n), nil
+		}},
+		"sum": {0, -1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			nums, err := formulaNumbers(flattenFormulaValues(args))
+			if err != nil {
+				return nil, err
+			}
+			sum := 0.0
+			for _, n := range nums {
+				sum += n
+			}
+			return formulaNumber(sum), nil
+		}},
+		"average": {0, -1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			nums, err := formulaNumbers(flattenFormulaValues(args))
+			if err != nil || 1 > len(nums) {
+				return formulaNull, err
+			}
+			sum := 0.0
+			for _, n := range nums {
+				sum += n
+			}
+			return formulaNumber(sum / float64(len(nums))), nil
+		}},
+		"min": {1, -1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return extremeFormulaValue(flattenFormulaValues(args), -1), nil
+		}},
+		"max": {1, -1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return extremeFormulaValue(flattenFormulaValues(args), 1), nil
+		}},
+
+		// 字符串
+		"concat": {0, -1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			buf := strings.Builder{}
+			for _, arg := range args {
+				buf.WriteString(arg.String())
+			}
+			return formulaText(buf.String()), nil
+		}},
+		"format": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaText(args[0].String()), nil
+		}},
+		"length": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			if formulaKindList == args[0].kind {
+				return formulaNumber(float64(len(args[0].list))), nil
+			}
+			return formulaNumber(float64(utf8.RuneCountInString(args[0].String()))), nil
+		}},
+		"lower": formulaTextFunc(strings.ToLower),
+		"upper": formulaTextFunc(strings.ToUpper),
+		"trim":  formulaTextFunc(strings.TrimSpace),
+		"contains": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			if formulaKindList == args[0].kind {
+				for _, item := range args[0].list {
+					if cmp, ok := compareFormulaValues(item.unwrap(), args[1].unwrap()); ok && 0 == cmp {
+						return formulaBool(true), nil
+					}
+				}
+				return formulaBool(false), nil
+			}
+			return formulaBool(strings.Contains(args[0].String(), args[1].String())), nil
+		}},
+		"startswith": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaBool(strings.HasPrefix(args[0].String(), args[1].String())), nil
+		}},
+		"endswith": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaBool(strings.HasSuffix(args[0].String(), args[1].String())), nil
+		}},
+		"replace": {3, 3, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaText(strings.ReplaceAll(args[0].String(), args[1].String(), args[2].String())), nil
+		}},
+		"test": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			exp, err := regexp.Compile(args[1].String())
+			if err != nil {
+				return nil, err
+			}
+			return formulaBool(exp.MatchString(args[0].String())), nil
+		}},
+		"slice": {2, 3, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			runes := []rune(args[0].String())
+			nums, err := formulaNumbers(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			start, end := int(nums[0]), len(runes)
+			if 2 == len(nums) {
+				end = int(nums[1])
+			}
+			start, end = max(0, min(start, len(runes))), max(0, min(end, len(runes)))
+			if start >= end {
+				return formulaText(""), nil
+			}
+			return formulaText(string(runes[start:end])), nil
+		}},
+		"join": {1, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			sep := ", "
+			if 2 == len(args) {
+				sep = args[1].String()
+			}
+			var items []string
+			for _, item := range flattenFormulaValues(args[:1]) {
+				items = append(items, item.String())
+			}
+			return formulaText(strings.Join(items, sep)), nil
+		}},
+
+		// 列表
+		"count": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			count := 0
+			for _, item := range flattenFormulaValues(args) {
+				if !item.isEmpty() {
+					count++
+				}
+			}
+			return formulaNumber(float64(count)), nil
+		}},
+		"first": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			items := flattenFormulaValues(args)
+			if 1 > len(items) {
+				return formulaNull, nil
+			}
+			return items[0], nil
+		}},
+		"last": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			items := flattenFormulaValues(args)
+			if 1 > len(items) {
+				return formulaNull, nil
+			}
+			return items[len(items)-1], nil
+		}},
+
+		// 日期
+		"now": {0, 0, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return formulaDate(ctx.now, true), nil
+		}},
+		"today": {0, 0, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			y, m, d := ctx.now.Date()
+			return formulaDate(time.Date(y, m, d, 0, 0, 0, 0, ctx.now.Location()), false), nil
+		}},
+		"date": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			if args[0].unwrap().isEmpty() {
+				return formulaNull, nil
+			}
+			t, hasTime, err := args[0].toDate()
+			if err != nil {
+				return nil, err
+			}
+			return formulaDate(t, hasTime), nil
+		}},
+		"fromtimestamp": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			n, err := args[0].toNumber()
+			if err != nil {
+				return nil, err
+			}
+			return formulaDate(time.UnixMilli(int64(n)), true), nil
+		}},
+		"timestamp": {1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			t, _, err := args[0].toDate()
+			if err != nil {
+				return nil, err
+			}
+			return formulaNumber(float64(t.UnixMilli())), nil
+		}},
+		"dateadd": {3, 3, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return addFormulaDate(args, 1)
+		}},
+		"datesubtract": {3, 3, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			return addFormulaDate(args, -1)
+		}},
+		"datebetween": {3, 3, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			if args[0].unwrap().isEmpty() || args[1].unwrap().isEmpty() {
+				return formulaNull, nil
+			}
+			t1, _, err := args[0].toDate()
+			if err != nil {
+				return nil, err
+			}
+			t2, _, err := args[1].toDate()
+			if err != nil {
+				return nil, err
+			}
+			return formulaNumber(formulaDateBetween(t1, t2, args[2].String())), nil
+		}},
+		"formatdate": {2, 2, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+			if args[0].unwrap().isEmpty() {
+				return formulaNull, nil
+			}
+			t, _, err := args[0].toDate()
+			if err != nil {
+				return nil, err
+			}
+			return formulaText(t.Format(formulaDateLayout(args[1].String()))), nil
+		}},
+		"year":    formulaDatePartFunc(func(t time.Time) int { return t.Year() }),
+		"month":   formulaDatePartFunc(func(t time.Time) int { return int(t.Month()) }),
+		"day":     formulaDatePartFunc(func(t time.Time) int { return t.Day() }),
+		"hour":    formulaDatePartFunc(func(t time.Time) int { return t.Hour() }),
+		"minute":  formulaDatePartFunc(func(t time.Time) int { return t.Minute() }),
+		"weekday": formulaDatePartFunc(func(t time.Time) int { return (int(t.Weekday())+6)%7 + 1 }), // 周一为 1，周日为 7
+	}
+}
+
+func formulaMathFunc(f func(float64) float64) *formulaFunc {
+	return &formulaFunc{1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		if args[0].unwrap().isEmpty() {
+			return formulaNull, nil
+		}
+		n, err := args[0].toNumber()
+		if err != nil {
+			return nil, err
+		}
+		return formulaNumber(f(n)), nil
+	}}
+}
+
+func formulaTextFunc(f func(string) string) *formulaFunc {
+	return &formulaFunc{1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		return formulaText(f(args[0].String())), nil
+	}}
+}
+
+func formulaDatePartFunc(f func(time.Time) int) *formulaFunc {
+	return &formulaFunc{1, 1, func(ctx *formulaContext, args []*formulaValue) (*formulaValue, error) {
+		if args[0].unwrap().isEmpty() {
+			return formulaNull, nil
+		}
+		t, _, err := args[0].toDate()
+		if err != nil {
+			return nil, err
+		}
+		return formulaNumber(float64(f(t))), nil
+	}}
+}
+
+func formulaNumbers(args []*formulaValue) (ret []float64, err error) {
+	for _, arg := range args {
+		var n float64
+		if n, err = arg.toNumber(); err != nil {
+			return
+		}
+		ret = append(ret, n)
+	}
+	return
+}
+
+// flattenFormulaValues 展开列表参数并去掉空值。
+func flattenFormulaValues(args []*formulaValue) (ret []*formulaValue) {
+	for _, arg := range args {
+		if formulaKindList == arg.kind {
+			ret = append(ret, flattenFormulaValues(arg.list)...)
+			continue
+		}
+		if formulaKindNull != arg.kind {
+			ret = append(ret, arg)
+		}
+	}
+	return
+}
+
+func extremeFormulaValue(values []*formulaValue, sign int) (ret *formulaValue) {
+	ret = formulaNull
+	for _, v := range values {
+		if formulaKindNull == ret.kind {
+			ret = v
+			continue
+		}
+		if cmp, ok := compareFormulaValues(v, ret); ok && sign*cmp > 0 {
+			ret = v
+		}
+	}
+	return
+}
+
+func addFormulaDate(args []*formulaValue, sign int) (*formulaValue, error) {
+	if args[0].unwrap().isEmpty() {
+		return formulaNull, nil
+	}
+	t, hasTime, err := args[0].toDate()
+	if err != nil {
+		return nil, err
+	}
+	n, err := args[1].toNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(n) * sign
+	switch normalizeFormulaDateUnit(args[2].String()) {
+	case "years":
+		t = t.AddDate(count, 0, 0)
+	case "quarters":
+		t = t.AddDate(0, count*3, 0)
+	case "months":
+		t = t.AddDate(0, count, 0)
+	case "weeks":
+		t = t.AddDate(0, 0, count*7)
+	case "days":
+		t = t.AddDate(0, 0, count)
+	case "hours":
+		t, hasTime = t.Add(time.Duration(n*float64(sign))*time.Hour), true
+	case "minutes":
+		t, hasTime = t.Add(time.Duration(n*float64(sign))*time.Minute), true
+	case "seconds":
+		t, hasTime = t.Add(time.Duration(n*float64(sign))*time.Second), true
+	default:
+		return nil, fmt.Errorf("unknown date unit [%s]", args[2].String())
+	}
+	return formulaDate(t, hasTime), nil
+}
+
+// formulaDateBetween 计算 t1 - t2 的差值，结果向零取整。
+func formulaDateBetween(t1, t2 time.Time, unit string) float64 {
+	months := func() int {
+		ret := (t1.Year()-t2.Year())*12 + int(t1.Month()) - int(t2.Month())
+		if 0 < ret && t1.Before(t2.AddDate(0, ret, 0)) {
+			ret--
+		} else if 0 > ret && t1.After(t2.AddDate(0, ret, 0)) {
+			ret++
+		}
+		return ret
+	}
+
+	d := t1.Sub(t2)
+	switch normalizeFormulaDateUnit(unit) {
+	case "years":
+		return float64(months() / 12)
+	case "quarters":
+		return float64(months() / 3)
+	case "months":
+		return float64(months())
+	case "weeks":
+		return math.Trunc(d.Hours() / 24 / 7)
+	case "hours":
+		return math.Trunc(d.Hours())
+	case "minutes":
+		return math.Trunc(d.Minutes())
+	case "seconds":
+		return math.Trunc(d.Seconds())
+	}
+	return math.Trunc(d.Hours() / 24)
+}
+
+func normalizeFormulaDateUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	switch unit {
+	case "y", "year":
+		return "years"
+	case "q", "quarter":
+		return "quarters"
+	case "m", "month":
+		return "months"
+	case "w", "week":
+		return "weeks"
+	case "d", "day":
+		return "days"
+	case "h", "hour":
+		return "hours"
+	case "minute":
+		return "minutes"
+	case "s", "second":
+		return "seconds"
+	}
+	return unit
+}
+
+var formulaDateLayoutReplacer = strings.NewReplacer(
+	"YYYY", "2006", "YY", "06",
+	"MM", "01", "M", "1",
+	"DD", "02", "D", "2",
+	"HH", "15", "hh", "03",
+	"mm", "04", "ss", "05",
+	"A", "PM",
+)
+
+// formulaDateLayout 将 YYYY-MM-DD HH:mm:ss 形式的日期格式转换为 Go 的日期格式。
+func formulaDateLayout(format string) string {
+	if "" == strings.TrimSpace(format) {
+		return "2006-01-02 15:04"
+	}
+	return formulaDateLayoutReplacer.Replace(format)
+}
@@ -0,0 +1,140 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseFormula(t *testing.T) {
+	valid := []string{
+		`1 + 2 * 3`,
+		`1e20`,
+		`1.5E-3 + .5e+2`,
+		`-(1 + 2) % 2`,
+		`prop("数量") * prop('单价')`,
+		`if(prop("完成"), "done", "todo")`,
+		`not true and false or !false`,
+		`concat("a", "b", 1)`,
+		`now()`,
+	}
+	for _, expr := range valid {
+		if _, err := ParseFormula(expr); err != nil {
+			t.Errorf("parse [%s] failed: %s", expr, err)
+		}
+	}
+
+	invalid := []string{
+		``,
+		`1 +`,
+		`(1 + 2`,
+		`"abc`,
+		`foo`,
+		`unknown(1)`,
+		`1 2`,
+		`if(1, 2`,
+		`1 # 2`,
+		`1e20e`,
+	}
+	for _, expr := range invalid {
+		if _, err := ParseFormula(expr); !errors.Is(err, ErrFormulaSyntax) {
+			t.Errorf("parse [%s] expected syntax error, got %v", expr, err)
+		}
+	}
+
+	formula, err := ParseFormula(`prop("a") + prop("b") * prop("a")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props := formula.Props(); 3 != len(props) || "a" != props[0] || "b" != props[1] {
+		t.Errorf("unexpected props %v", props)
+	}
+}
+
+func TestEvalFormula(t *testing.T) {
+	values := map[string]*Value{
+		"数量":   {Type: KeyTypeNumber, Number: &ValueNumber{Content: 3, IsNotEmpty: true}},
+		"名称":   {Type: KeyTypeText, Text: &ValueText{Content: "abc"}},
+		"数字文本": {Type: KeyTypeText, Text: &ValueText{Content: "5"}},
+		"完成":   {Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{Checked: true}},
+		"日期":   {Type: KeyTypeDate, Date: &ValueDate{Content: 1700000000000, IsNotEmpty: true}},
+		"标签":   {Type: KeyTypeMSelect, MSelect: []*ValueSelect{{Content: "a"}, {Content: "b"}}},
+	}
+	prop := func(name string) (*Value, error) {
+		return values[name], nil
+	}
+
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{`1 + 2 * 3`, "7"},
+		{`1e3 + 2.5E-1`, "1000.25"},
+		{`(1 + 2) % 2`, "1"},
+		{`prop("数量") * 2`, "6"},
+		{`prop("名称") + 1`, "abc1"},
+		{`if(prop("完成"), "done", "todo")`, "done"},
+		{`if(prop("不存在"), "yes")`, ""},
+		{`prop("数量") > 2 and prop("数量") <= 3`, "true"},
+		{`prop("名称") == "abc"`, "true"},
+		{`prop("不存在") == ""`, "true"},
+		{`prop("不存在") < 1`, "true"},
+		{`contains(prop("标签"), "b")`, "true"},
+		{`max(1, 5, 3)`, "5"},
+		{`prop("日期") > date("2020-01-01")`, "true"},
+
+		// 类型不同无法比较时结果为假
+		{`prop("名称") > 1`, "false"},
+		{`prop("名称") < 1`, "false"},
+		{`prop("名称") == 1`, "false"},
+		{`prop("名称") != 1`, "true"},
+		{`prop("数字文本") == 5`, "false"},
+		{`prop("日期") > "abc"`, "false"},
+		{`prop("日期") == true`, "false"},
+		{`prop("不存在") < prop("日期")`, "false"},
+		{`contains(prop("标签"), 1)`, "false"},
+	}
+	for _, c := range cases {
+		formula, err := ParseFormula(c.expr)
+		if err != nil {
+			t.Errorf("parse [%s] failed: %s", c.expr, err)
+			continue
+		}
+		ctx := &formulaContext{prop: prop, now: time.Now()}
+		ret, err := ctx.eval(formula.root)
+		if err != nil {
+			t.Errorf("eval [%s] failed: %s", c.expr, err)
+			continue
+		}
+		if actual := ret.unwrap().String(); c.expected != actual {
+			t.Errorf("eval [%s] expected [%s], got [%s]", c.expr, c.expected, actual)
+		}
+	}
+
+	for _, expr := range []string{`1 / 0`, `prop("名称") * 2`, `abs("x")`} {
+		formula, err := ParseFormula(expr)
+		if err != nil {
+			t.Errorf("parse [%s] failed: %s", expr, err)
+			continue
+		}
+		if _, err = formula.Eval(prop, NumberFormatNone); nil == err {
+			t.Errorf("eval [%s] expected error", expr)
+		}
+	}
+}
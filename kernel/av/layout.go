@@ -84,6 +84,12 @@ func NewViewBaseInstance(view *View) *BaseInstance {
 	case LayoutTypeKanban:
 		showIcon = view.Kanban.ShowIcon
 		wrapField = view.Kanban.WrapField
+	case LayoutTypeCalendar:
+		showIcon = view.Calendar.ShowIcon
+		wrapField = view.Calendar.WrapField
+	case LayoutTypeTimeline:
+		showIcon = view.Timeline.ShowIcon
+		wrapField = view.Timeline.WrapField
 	}
 	return &BaseInstance{
 		ID:               view.ID,
@@ -156,6 +162,7 @@ type BaseInstanceField struct {
 	Options      []*SelectOption `json:"options,omitempty"`  // 选项列表
 	NumberFormat NumberFormat    `json:"numberFormat"`       // 数字字段格式化
 	Template     string          `json:"template"`           // 模板字段内容
+	Formula      string          `json:"formula,omitempty"`  // 公式字段表达式
 	Relation     *Relation       `json:"relation,omitempty"` // 关联字段
 	Rollup       *Rollup         `json:"rollup,omitempty"`   // 汇总字段
 	Date         *Date           `json:"date,omitempty"`     // 日期设置
@@ -0,0 +1,279 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"time"
+
+	"github.com/88250/lute/ast"
+)
+
+// LayoutCalendar 描述了日历布局的结构。
+type LayoutCalendar struct {
+	*BaseLayout
+
+	DateKeyID        string       `json:"dateKeyID"`        // 日期字段 ID，项目按照该字段的值放置到日历上
+	Mode             CalendarMode `json:"mode"`             // 日历模式，0：月，1：周
+	StartWeekday     int          `json:"startWeekday"`     // 每周的起始日，0：周日，1：周一
+	Current          int64        `json:"current"`          // 当前显示的日期，为 0 时显示今天所在的月或周
+	DisplayFieldName bool         `json:"displayFieldName"` // 是否显示字段名称
+
+	Fields []*ViewCalendarField `json:"fields"` // 字段
+}
+
+func NewLayoutCalendar() *LayoutCalendar {
+	return &LayoutCalendar{
+		BaseLayout: &BaseLayout{
+			Spec:     0,
+			ID:       ast.NewNodeID(),
+			ShowIcon: true,
+		},
+		Mode:         CalendarModeMonth,
+		StartWeekday: 1,
+	}
+}
+
+type CalendarMode int
+
+const (
+	CalendarModeMonth CalendarMode = iota // 月
+	CalendarModeWeek                      // 周
+)
+
+// ViewCalendarField 描述了日历字段的结构。
+type ViewCalendarField struct {
+	*BaseField
+}
+
+// Calendar 描述了日历视图实例的结构。
+type Calendar struct {
+	*BaseInstance
+
+	DateKeyID        string           `json:"dateKeyID"`        // 日期字段 ID
+	Mode             CalendarMode     `json:"mode"`             // 日历模式
+	StartWeekday     int              `json:"startWeekday"`     // 每周的起始日
+	Current          int64            `json:"current"`          // 当前显示的日期
+	DisplayFieldName bool             `json:"displayFieldName"` // 是否显示字段名称
+	Start            int64            `json:"start"`            // 日历网格的开始时间
+	End              int64            `json:"end"`              // 日历网格的结束时间（不包含）
+	Weeks            []*CalendarWeek  `json:"weeks"`            // 日历网格
+	Fields           []*CalendarField `json:"fields"`           // 卡片字段
+	Cards            []*CalendarCard  `json:"cards"`            // 日历网格范围内的卡片
+	CardCount        int              `json:"cardCount"`        // 总卡片数
+	NoDateCardCount  int              `json:"noDateCardCount"`  // 没有日期的卡片数
+}
+
+// CalendarWeek 描述了日历网格中一周的结构。
+type CalendarWeek struct {
+	Days []*CalendarDay `json:"days"` // 一周七天
+}
+
+// CalendarDay 描述了日历网格中一天的结构。
+type CalendarDay struct {
+	Date      int64    `json:"date"`      // 当天零点的时间戳
+	IsCurrent bool     `json:"isCurrent"` // 是否属于当前显示的月，周模式下总是 true
+	IsToday   bool     `json:"isToday"`   // 是否是今天
+	CardIDs   []string `json:"cardIds"`   // 当天的卡片 ID，跨天的卡片会出现在每一天中
+}
+
+// CalendarCard 描述了日历实例卡片的结构。
+type CalendarCard struct {
+	ID     string                `json:"id"`     // 卡片 ID
+	Values []*CalendarFieldValue `json:"values"` // 卡片字段值
+
+	Start     int64 `json:"start"`     // 开始时间
+	End       int64 `json:"end"`       // 结束时间，没有结束时间时等于开始时间
+	IsNotTime bool  `json:"isNotTime"` // 是否不包含时间
+	HasDate   bool  `json:"hasDate"`   // 是否有日期
+}
+
+// CalendarField 描述了日历实例字段的结构。
+type CalendarField struct {
+	*BaseInstanceField
+}
+
+// CalendarFieldValue 描述了卡片字段实例值的结构。
+type CalendarFieldValue struct {
+	*BaseValue
+}
+
+func (card *CalendarCard) GetID() string {
+	return card.ID
+}
+
+func (card *CalendarCard) GetBlockValue() (ret *Value) {
+	for _, v := range card.Values {
+		if KeyTypeBlock == v.ValueType {
+			ret = v.Value
+			break
+		}
+	}
+	return
+}
+
+func (card *CalendarCard) GetValues() (ret []*Value) {
+	ret = []*Value{}
+	for _, v := range card.Values {
+		ret = append(ret, v.Value)
+	}
+	return
+}
+
+func (card *CalendarCard) GetValue(keyID string) (ret *Value) {
+	for _, value := range card.Values {
+		if nil != value.Value && keyID == value.Value.KeyID {
+			ret = value.Value
+			break
+		}
+	}
+	return
+}
+
+// SetDate 使用日期字段的值设置卡片的开始和结束时间。
+func (card *CalendarCard) SetDate(value *Value) {
+	card.Start, card.End, card.IsNotTime, card.HasDate = value.GetDateRange()
+}
+
+func (calendar *Calendar) GetItems() (ret []Item) {
+	ret = []Item{}
+	for _, card := range calendar.Cards {
+		ret = append(ret, card)
+	}
+	return
+}
+
+func (calendar *Calendar) SetItems(items []Item) {
+	calendar.Cards = []*CalendarCard{}
+	for _, item := range items {
+		calendar.Cards = append(calendar.Cards, item.(*CalendarCard))
+	}
+}
+
+func (calendar *Calendar) CountItems() int {
+	return len(calendar.Cards)
+}
+
+func (calendar *Calendar) GetFields() (ret []Field) {
+	ret = []Field{}
+	for _, field := range calendar.Fields {
+		ret = append(ret, field)
+	}
+	return ret
+}
+
+func (calendar *Calendar) GetField(id string) (ret Field, fieldIndex int) {
+	for i, field := range calendar.Fields {
+		if field.ID == id {
+			return field, i
+		}
+	}
+	return nil, -1
+}
+
+func (calendar *Calendar) GetValue(itemID, keyID string) (ret *Value) {
+	for _, card := range calendar.Cards {
+		if card.ID == itemID {
+			return card.GetValue(keyID)
+		}
+	}
+	return nil
+}
+
+func (calendar *Calendar) GetType() LayoutType {
+	return LayoutTypeCalendar
+}
+
+// BuildGrid 生成当前月或周的日历网格，并仅保留网格范围内的卡片。
+func (calendar *Calendar) BuildGrid(now time.Time) {
+	current := now
+	if 0 < calendar.Current {
+		current = time.UnixMilli(calendar.Current)
+	}
+	current = time.Date(current.Year(), current.Month(), current.Day(), 0, 0, 0, 0, time.Local)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	// 计算网格起止日期，月模式下网格从本月第一天所在周的起始日开始，到本月最后一天所在周结束
+	startWeekday := time.Weekday(calendar.StartWeekday % 7)
+	weekStart := func(t time.Time) time.Time {
+		offset := (int(t.Weekday()) - int(startWeekday) + 7) % 7
+		return t.AddDate(0, 0, -offset)
+	}
+
+	var start, end time.Time
+	switch calendar.Mode {
+	case CalendarModeWeek:
+		start = weekStart(current)
+		end = start.AddDate(0, 0, 7)
+	default:
+		monthStart := time.Date(current.Year(), current.Month(), 1, 0, 0, 0, 0, time.Local)
+		monthEnd := monthStart.AddDate(0, 1, 0)
+		start = weekStart(monthStart)
+		end = weekStart(monthEnd.AddDate(0, 0, -1)).AddDate(0, 0, 7)
+	}
+	calendar.Start, calendar.End = start.UnixMilli(), end.UnixMilli()
+
+	calendar.CardCount = len(calendar.Cards)
+	calendar.NoDateCardCount = 0
+	var cards []*CalendarCard
+	days := map[string]*CalendarDay{}
+	calendar.Weeks = nil
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if 1 > len(calendar.Weeks) || 7 == len(calendar.Weeks[len(calendar.Weeks)-1].Days) {
+			calendar.Weeks = append(calendar.Weeks, &CalendarWeek{})
+		}
+
+		calendarDay := &CalendarDay{
+			Date:      day.UnixMilli(),
+			IsCurrent: CalendarModeWeek == calendar.Mode || day.Month() == current.Month(),
+			IsToday:   day.Equal(today),
+			CardIDs:   []string{},
+		}
+		week := calendar.Weeks[len(calendar.Weeks)-1]
+		week.Days = append(week.Days, calendarDay)
+		days[day.Format("2006-01-02")] = calendarDay
+	}
+
+	for _, card := range calendar.Cards {
+		if !card.HasDate {
+			calendar.NoDateCardCount++
+			continue
+		}
+
+		cardStart, cardEnd := time.UnixMilli(card.Start), time.UnixMilli(card.End)
+		if cardEnd.Before(cardStart) {
+			cardEnd = cardStart
+		}
+		if !cardStart.Before(end) || cardEnd.Before(start) {
+			continue
+		}
+
+		cardDay := time.Date(cardStart.Year(), cardStart.Month(), cardStart.Day(), 0, 0, 0, 0, time.Local)
+		if cardDay.Before(start) {
+			cardDay = start
+		}
+		for ; !cardDay.After(cardEnd) && cardDay.Before(end); cardDay = cardDay.AddDate(0, 0, 1) {
+			if calendarDay := days[cardDay.Format("2006-01-02")]; nil != calendarDay {
+				calendarDay.CardIDs = append(calendarDay.CardIDs, card.ID)
+			}
+		}
+		cards = append(cards, card)
+	}
+	if nil == cards {
+		cards = []*CalendarCard{}
+	}
+	calendar.Cards = cards
+}
@@ -0,0 +1,235 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"time"
+
+	"github.com/88250/lute/ast"
+)
+
+// LayoutTimeline 描述了时间线布局的结构。
+type LayoutTimeline struct {
+	*BaseLayout
+
+	DateKeyID        string        `json:"dateKeyID"`        // 日期字段 ID，使用日期的开始时间和结束时间绘制项目
+	Scale            TimelineScale `json:"scale"`            // 时间刻度，0：天，1：周，2：月，3：季度，4：年
+	DisplayFieldName bool          `json:"displayFieldName"` // 是否显示字段名称
+
+	Fields []*ViewTimelineField `json:"fields"` // 字段
+}
+
+func NewLayoutTimeline() *LayoutTimeline {
+	return &LayoutTimeline{
+		BaseLayout: &BaseLayout{
+			Spec:     0,
+			ID:       ast.NewNodeID(),
+			ShowIcon: true,
+		},
+		Scale: TimelineScaleWeek,
+	}
+}
+
+type TimelineScale int
+
+const (
+	TimelineScaleDay     TimelineScale = iota // 天
+	TimelineScaleWeek                         // 周
+	TimelineScaleMonth                        // 月
+	TimelineScaleQuarter                      // 季度
+	TimelineScaleYear                         // 年
+)
+
+// ViewTimelineField 描述了时间线字段的结构。
+type ViewTimelineField struct {
+	*BaseField
+}
+
+// Timeline 描述了时间线视图实例的结构。
+type Timeline struct {
+	*BaseInstance
+
+	DateKeyID        string           `json:"dateKeyID"`        // 日期字段 ID
+	Scale            TimelineScale    `json:"scale"`            // 时间刻度
+	DisplayFieldName bool             `json:"displayFieldName"` // 是否显示字段名称
+	Start            int64            `json:"start"`            // 时间线的开始时间，按照时间刻度对齐
+	End              int64            `json:"end"`              // 时间线的结束时间（不包含），按照时间刻度对齐
+	Fields           []*TimelineField `json:"fields"`           // 卡片字段
+	Cards            []*TimelineCard  `json:"cards"`            // 卡片
+	CardCount        int              `json:"cardCount"`        // 总卡片数
+}
+
+// TimelineCard 描述了时间线实例卡片的结构。
+type TimelineCard struct {
+	ID     string                `json:"id"`     // 卡片 ID
+	Values []*TimelineFieldValue `json:"values"` // 卡片字段值
+
+	Start     int64 `json:"start"`     // 开始时间
+	End       int64 `json:"end"`       // 结束时间，没有结束时间时等于开始时间
+	IsNotTime bool  `json:"isNotTime"` // 是否不包含时间
+	HasDate   bool  `json:"hasDate"`   // 是否有日期，没有日期的卡片不在时间线上绘制
+}
+
+// TimelineField 描述了时间线实例字段的结构。
+type TimelineField struct {
+	*BaseInstanceField
+}
+
+// TimelineFieldValue 描述了卡片字段实例值的结构。
+type TimelineFieldValue struct {
+	*BaseValue
+}
+
+func (card *TimelineCard) GetID() string {
+	return card.ID
+}
+
+func (card *TimelineCard) GetBlockValue() (ret *Value) {
+	for _, v := range card.Values {
+		if KeyTypeBlock == v.ValueType {
+			ret = v.Value
+			break
+		}
+	}
+	return
+}
+
+func (card *TimelineCard) GetValues() (ret []*Value) {
+	ret = []*Value{}
+	for _, v := range card.Values {
+		ret = append(ret, v.Value)
+	}
+	return
+}
+
+func (card *TimelineCard) GetValue(keyID string) (ret *Value) {
+	for _, value := range card.Values {
+		if nil != value.Value && keyID == value.Value.KeyID {
+			ret = value.Value
+			break
+		}
+	}
+	return
+}
+
+// SetDate 使用日期字段的值设置卡片的开始和结束时间。
+func (card *TimelineCard) SetDate(value *Value) {
+	card.Start, card.End, card.IsNotTime, card.HasDate = value.GetDateRange()
+}
+
+func (timeline *Timeline) GetItems() (ret []Item) {
+	ret = []Item{}
+	for _, card := range timeline.Cards {
+		ret = append(ret, card)
+	}
+	return
+}
+
+func (timeline *Timeline) SetItems(items []Item) {
+	timeline.Cards = []*TimelineCard{}
+	for _, item := range items {
+		timeline.Cards = append(timeline.Cards, item.(*TimelineCard))
+	}
+}
+
+func (timeline *Timeline) CountItems() int {
+	return len(timeline.Cards)
+}
+
+func (timeline *Timeline) GetFields() (ret []Field) {
+	ret = []Field{}
+	for _, field := range timeline.Fields {
+		ret = append(ret, field)
+	}
+	return ret
+}
+
+func (timeline *Timeline) GetField(id string) (ret Field, fieldIndex int) {
+	for i, field := range timeline.Fields {
+		if field.ID == id {
+			return field, i
+		}
+	}
+	return nil, -1
+}
+
+func (timeline *Timeline) GetValue(itemID, keyID string) (ret *Value) {
+	for _, card := range timeline.Cards {
+		if card.ID == itemID {
+			return card.GetValue(keyID)
+		}
+	}
+	return nil
+}
+
+func (timeline *Timeline) GetType() LayoutType {
+	return LayoutTypeTimeline
+}
+
+// BuildRange 计算时间线的起止时间，覆盖所有卡片并按照时间刻度对齐，没有卡片时以今天为准。
+func (timeline *Timeline) BuildRange(now time.Time) {
+	var start, end time.Time
+	for _, card := range timeline.Cards {
+		if !card.HasDate {
+			continue
+		}
+
+		cardStart, cardEnd := time.UnixMilli(card.Start), time.UnixMilli(card.End)
+		if start.IsZero() || cardStart.Before(start) {
+			start = cardStart
+		}
+		if end.IsZero() || cardEnd.After(end) {
+			end = cardEnd
+		}
+	}
+	if start.IsZero() {
+		start, end = now, now
+	}
+
+	start = timeline.truncate(start)
+	end = timeline.next(timeline.truncate(end))
+	timeline.Start, timeline.End = start.UnixMilli(), end.UnixMilli()
+}
+
+func (timeline *Timeline) truncate(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch timeline.Scale {
+	case TimelineScaleWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7) // 周一为一周的开始
+	case TimelineScaleMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	case TimelineScaleQuarter:
+		return time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.Local)
+	case TimelineScaleYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	}
+	return day
+}
+
+func (timeline *Timeline) next(t time.Time) time.Time {
+	switch timeline.Scale {
+	case TimelineScaleWeek:
+		return t.AddDate(0, 0, 7)
+	case TimelineScaleMonth:
+		return t.AddDate(0, 1, 0)
+	case TimelineScaleQuarter:
+		return t.AddDate(0, 3, 0)
+	case TimelineScaleYear:
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
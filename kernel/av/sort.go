@@ -466,6 +466,24 @@ func (value *Value) Compare(other *Value, attrView *AttributeView) int {
 				return 0
 			}
 
+			if util.EmojiPinYinCompare(vContent, oContent) {
+				return -1
+			}
+			return 1
+		}
+	case KeyTypeFormula:
+		// 公式按照计算结果的类型进行比较，结果类型不同时按照文本比较
+		v1, v2 := value.Unwrap(), other.Unwrap()
+		if nil != v1 && nil != v2 {
+			if v1.Type == v2.Type {
+				return v1.Compare(v2, attrView)
+			}
+
+			vContent, oContent := v1.String(true), v2.String(true)
+			if 0 == strings.Compare(vContent, oContent) {
+				return 0
+			}
+
 			if util.EmojiPinYinCompare(vContent, oContent) {
 				return -1
 			}
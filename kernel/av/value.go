@@ -57,6 +57,7 @@ type Value struct {
 	Checkbox *ValueCheckbox `json:"checkbox,omitempty"`
 	Relation *ValueRelation `json:"relation,omitempty"`
 	Rollup   *ValueRollup   `json:"rollup,omitempty"`
+	Formula  *ValueFormula  `json:"formula,omitempty"`
 
 	IsRenderAutoFill bool `json:"-"` // 标识是否是渲染阶段自动填充的值，保存数据的时候要删掉
 }
@@ -179,6 +180,11 @@ func (value *Value) String(format bool) string {
 			ret = append(ret, v.String(format))
 		}
 		return strings.TrimSpace(strings.Join(ret, ", "))
+	case KeyTypeFormula:
+		if nil == value.Formula {
+			return ""
+		}
+		return value.Formula.Content.String(format)
 	default:
 		return ""
 	}
@@ -299,6 +305,11 @@ func (value *Value) IsBlank() bool {
 		return 1 > len(value.Relation.Contents)
 	case KeyTypeRollup:
 		return 1 > len(value.Rollup.Contents)
+	case KeyTypeFormula:
+		if nil == value.Formula {
+			return true
+		}
+		return value.Formula.Content.IsEmpty()
 	}
 	return false
 }
@@ -377,6 +388,11 @@ func (value *Value) IsEmpty() bool {
 		return 1 > len(value.Relation.Contents)
 	case KeyTypeRollup:
 		return 1 > len(value.Rollup.Contents)
+	case KeyTypeFormula:
+		if nil == value.Formula {
+			return true
+		}
+		return value.Formula.Content.IsEmpty()
 	}
 	return false
 }
@@ -415,6 +431,8 @@ func (value *Value) SetValByType(typ KeyType, val interface{}) {
 		value.Relation = val.(*ValueRelation)
 	case KeyTypeRollup:
 		value.Rollup = val.(*ValueRollup)
+	case KeyTypeFormula:
+		value.Formula = val.(*ValueFormula)
 	}
 }
 
@@ -460,6 +478,8 @@ func (value *Value) GetValByType(typ KeyType) (ret interface{}) {
 		return value.Relation
 	case KeyTypeRollup:
 		return value.Rollup
+	case KeyTypeFormula:
+		return value.Formula
 	}
 	return
 }
@@ -844,7 +864,7 @@ func (r *ValueRollup) BuildContents(keyValues []*KeyValues, destKey *Key, relati
 	r.Contents = nil
 	for _, blockID := range relationVal.Relation.BlockIDs {
 		destVal := GetValue(keyValues, destKey.ID, blockID)
-		if nil != furtherCollection && (KeyTypeTemplate == destKey.Type || KeyTypeUpdated == destKey.Type || KeyTypeCreated == destKey.Type) {
+		if nil != furtherCollection && (KeyTypeTemplate == destKey.Type || KeyTypeUpdated == destKey.Type || KeyTypeCreated == destKey.Type || KeyTypeFormula == destKey.Type) {
 			destVal = furtherCollection.GetValue(blockID, destKey.ID)
 		}
 
@@ -1310,6 +1330,52 @@ func (r *ValueRollup) calcContents(calc *RollupCalc, destKey *Key) {
 	}
 }
 
+type ValueFormula struct {
+	Content *Value `json:"content,omitempty"` // 计算结果，类型为数字、日期、复选框或者文本
+	Error   string `json:"error,omitempty"`   // 公式解析或者计算错误
+}
+
+// Unwrap 返回公式的计算结果，其他类型的值原样返回。
+func (value *Value) Unwrap() *Value {
+	if nil == value || KeyTypeFormula != value.Type {
+		return value
+	}
+	if nil == value.Formula {
+		return nil
+	}
+	return value.Formula.Content
+}
+
+// GetDateRange 获取日期、创建时间、更新时间或者日期类型公式结果的开始和结束时间，没有结束时间时结束时间等于开始时间。
+func (value *Value) GetDateRange() (start, end int64, isNotTime, ok bool) {
+	value = value.Unwrap()
+	if nil == value {
+		return
+	}
+
+	switch value.Type {
+	case KeyTypeDate:
+		if nil == value.Date || !value.Date.IsNotEmpty {
+			return
+		}
+		start, end, isNotTime, ok = value.Date.Content, value.Date.Content, value.Date.IsNotTime, true
+		if value.Date.HasEndDate && value.Date.IsNotEmpty2 && value.Date.Content2 >= value.Date.Content {
+			end = value.Date.Content2
+		}
+	case KeyTypeCreated:
+		if nil == value.Created || !value.Created.IsNotEmpty {
+			return
+		}
+		start, end, ok = value.Created.Content, value.Created.Content, true
+	case KeyTypeUpdated:
+		if nil == value.Updated || !value.Updated.IsNotEmpty {
+			return
+		}
+		start, end, ok = value.Updated.Content, value.Updated.Content, true
+	}
+	return
+}
+
 func GetAttributeViewDefaultValue(valueID, keyID, blockID string, typ KeyType, keyDateIsTime bool) (ret *Value) {
 	if "" == valueID {
 		valueID = ast.NewNodeID()
@@ -1361,6 +1427,8 @@ func GetAttributeViewDefaultValue(valueID, keyID, blockID string, typ KeyType, k
 		ret.Relation = &ValueRelation{}
 	case KeyTypeRollup:
 		ret.Rollup = &ValueRollup{}
+	case KeyTypeFormula:
+		ret.Formula = &ValueFormula{}
 	}
 	return
 }
@@ -0,0 +1,412 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package cli 实现内核的命令行模式，不启动 HTTP 服务，直接调用 model 完成查询、导入导出和数据仓库等操作。
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	ExitCodeOk    = 0 // 执行成功
+	ExitCodeErr   = 1 // 执行失败
+	ExitCodeUsage = 2 // 参数错误
+)
+
+type command struct {
+	usage string
+	exec  func(args []string) (data interface{}, err error)
+}
+
+var commands = map[string]*command{
+	"notebooks": {"notebooks", listNotebooks},
+	"query":     {"query [--limit n] <stmt>", query},
+	"search":    {"search [--method 0-3] [--notebook id] [--page n] [--page-size n] <keyword>", search},
+	"export":    {"export --id <docID> --format md|sy|html|epub|typst|pdf [--subdocs] [--pdf printed.pdf] [--out path]", export},
+	"import":    {"import --notebook <id> [--to /] [--format auto|md|sy|enex|html|notion|obsidian] <path>", importData},
+	"snapshot":  {"snapshot create <memo> | list [page] | checkout <id> | diff <left> <right>", snapshot},
+	"index":     {"index rebuild", index},
+}
+
+var errUsage = errors.New("invalid arguments")
+
+// Main 是命令行模式的入口，args 为 cli 之后的参数，返回进程退出码。
+//
+// 执行结果以 JSON 输出到标准输出，日志输出到标准错误。
+func Main(args []string) int {
+	// 日志默认输出到标准输出，这里将其重定向到标准错误，保证标准输出只包含 JSON 结果
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	fs := flag.NewFlagSet("cli", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	workspace := fs.String("workspace", os.Getenv(util.SIYUAN_WORKSPACE), "dir path of the workspace")
+	wd := fs.String("wd", util.WorkingDir, "working directory of SiYuan")
+	lang := fs.String("lang", "", "language of the messages")
+	if err := fs.Parse(args); nil != err || 1 > fs.NArg() {
+		printUsage()
+		return ExitCodeUsage
+	}
+
+	cmd := commands[fs.Arg(0)]
+	if nil == cmd {
+		printUsage()
+		return ExitCodeUsage
+	}
+	if "" == *workspace {
+		fmt.Fprintln(os.Stderr, "the workspace (--workspace) must be specified")
+		return ExitCodeUsage
+	}
+
+	if err := boot(*workspace, *wd, *lang); nil != err {
+		writeResult(stdout, nil, err)
+		return ExitCodeErr
+	}
+	defer shutdown()
+
+	data, err := cmd.exec(fs.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "usage: siyuan-kernel cli --workspace <path> "+cmd.usage)
+		return ExitCodeUsage
+	}
+
+	// 等待命令产生的任务和数据库写入完成
+	task.ExecAllTasks()
+	writeResult(stdout, data, err)
+	if nil != err {
+		return ExitCodeErr
+	}
+	return ExitCodeOk
+}
+
+func boot(workspace, wd, lang string) (err error) {
+	if err = util.BootCLI(workspace, wd, lang); nil != err {
+		return
+	}
+
+	model.InitConf()
+	if err = sql.InitDatabase(false); nil != err {
+		return
+	}
+	sql.InitHistoryDatabase(false)
+	sql.InitAuditDatabase()
+	sql.InitAssetContentDatabase(false)
+	sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
+	sql.SetIndexAssetPath(model.Conf.Search.IndexAssetPath)
+
+	model.InitBoxes()
+	task.ExecAllTasks()
+	sql.FlushQueue()
+	util.SetBooted()
+	return
+}
+
+func shutdown() {
+	model.FlushTxQueue()
+	task.ExecAllTasks()
+	sql.FlushQueue()
+	sql.FlushHistoryQueue()
+	sql.FlushAssetContentQueue()
+	sql.FlushAuditQueue()
+	model.Conf.Close()
+	sql.CloseDatabase()
+	if nil != util.WorkspaceLock {
+		util.WorkspaceLock.Unlock()
+	}
+}
+
+func writeResult(w io.Writer, data interface{}, err error) {
+	ret := gulu.Ret.NewResult()
+	ret.Data = data
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+
+	output, marshalErr := gulu.JSON.MarshalIndentJSON(ret, "", "  ")
+	if nil != marshalErr {
+		fmt.Fprintf(os.Stderr, "marshal result failed: %s\n", marshalErr)
+		return
+	}
+	w.Write(output)
+	w.Write([]byte("\n"))
+}
+
+func printUsage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := strings.Builder{}
+	buf.WriteString("usage: siyuan-kernel cli --workspace <path> [--wd <path>] [--lang <lang>] <command> [args]\n\ncommands:\n")
+	for _, name := range names {
+		buf.WriteString("  " + commands[name].usage + "\n")
+	}
+	fmt.Fprint(os.Stderr, buf.String())
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	ret := flag.NewFlagSet(name, flag.ContinueOnError)
+	ret.SetOutput(io.Discard)
+	return ret
+}
+
+func listNotebooks(args []string) (data interface{}, err error) {
+	return model.ListNotebooks()
+}
+
+func query(args []string) (data interface{}, err error) {
+	fs := newFlagSet("query")
+	limit := fs.Int("limit", model.Conf.Search.Limit, "")
+	if err = fs.Parse(args); nil != err || 1 != fs.NArg() {
+		return nil, errUsage
+	}
+	return sql.Query(fs.Arg(0), *limit)
+}
+
+func search(args []string) (data interface{}, err error) {
+	fs := newFlagSet("search")
+	method := fs.Int("method", 0, "")
+	notebook := fs.String("notebook", "", "")
+	page := fs.Int("page", 1, "")
+	pageSize := fs.Int("page-size", 32, "")
+	if err = fs.Parse(args); nil != err || 1 > fs.NArg() || 0 > *method || 3 < *method {
+		return nil, errUsage
+	}
+
+	var boxes []string
+	if "" != *notebook {
+		boxes = append(boxes, *notebook)
+	}
+	keyword := strings.Join(fs.Args(), " ")
+	blocks, matchedBlockCount, matchedRootCount, pageCount, _ := model.FullTextSearchBlock(keyword, boxes, nil, nil, *method, 0, 0, *page, *pageSize, nil)
+	data = map[string]interface{}{
+		"blocks":            blocks,
+		"matchedBlockCount": matchedBlockCount,
+		"matchedRootCount":  matchedRootCount,
+		"pageCount":         pageCount,
+	}
+	return
+}
+
+func export(args []string) (data interface{}, err error) {
+	fs := newFlagSet("export")
+	id := fs.String("id", "", "")
+	format := fs.String("format", "md", "")
+	out := fs.String("out", "", "")
+	pdf := fs.String("pdf", "", "")
+	subDocs := fs.Bool("subdocs", model.Conf.Export.IncludeSubDocs, "")
+	if err = fs.Parse(args); nil != err || 0 < fs.NArg() || "" == *id {
+		return nil, errUsage
+	}
+
+	// 仅在本次导出中生效，不保存到配置
+	includeSubDocs := model.Conf.Export.IncludeSubDocs
+	model.Conf.Export.IncludeSubDocs = *subDocs
+	defer func() { model.Conf.Export.IncludeSubDocs = includeSubDocs }()
+
+	bt := treenode.GetBlockTree(*id)
+	if nil == bt {
+		return nil, fmt.Errorf("not found block [%s]", *id)
+	}
+
+	var exportPath string
+	switch *format {
+	case "md":
+		_, exportPath = model.ExportPandocConvertZip([]string{bt.RootID}, "", ".md")
+	case "sy":
+		exportPath = model.ExportSYs([]string{bt.RootID})
+	case "html":
+		exportPath = model.ExportSite(bt.BoxID, bt.Path)
+	case "epub":
+		_, exportPath = model.ExportEPUB(bt.RootID)
+	case "typst":
+		_, exportPath = model.ExportTypst(bt.RootID)
+	case "pdf":
+		// 无界面环境下无法打印 PDF，这里对 HTML 导出打印得到的 PDF 进行后续处理（书签、链接、资源文件和水印）
+		if "" == *pdf || "" == *out {
+			return nil, errUsage
+		}
+		if err = filelock.Copy(*pdf, *out); nil != err {
+			return
+		}
+		if err = model.ProcessPDF(bt.RootID, *out, false, false, false); nil != err {
+			return
+		}
+		return map[string]interface{}{"path": *out}, nil
+	default:
+		return nil, errUsage
+	}
+	if "" == exportPath {
+		return nil, fmt.Errorf("export [%s] as [%s] failed, please check the log for details", *id, *format)
+	}
+
+	// 导出函数返回的是服务路径 /export/xxx，转换为文件路径
+	name, _ := url.PathUnescape(path.Base(exportPath))
+	exportPath = filepath.Join(util.TempDir, "export", name)
+	if "" != *out {
+		if gulu.File.IsDir(*out) {
+			*out = filepath.Join(*out, name)
+		}
+		if err = filelock.Copy(exportPath, *out); nil != err {
+			return
+		}
+		os.Remove(exportPath)
+		exportPath = *out
+	}
+	return map[string]interface{}{"path": exportPath}, nil
+}
+
+func importData(args []string) (data interface{}, err error) {
+	fs := newFlagSet("import")
+	notebook := fs.String("notebook", "", "")
+	toPath := fs.String("to", "/", "")
+	format := fs.String("format", "auto", "")
+	if err = fs.Parse(args); nil != err || 1 != fs.NArg() || "" == *notebook {
+		return nil, errUsage
+	}
+
+	localPath, err := filepath.Abs(fs.Arg(0))
+	if nil != err {
+		return
+	}
+	if !gulu.File.IsExist(localPath) {
+		return nil, fmt.Errorf("not found [%s]", localPath)
+	}
+	if nil == model.Conf.Box(*notebook) {
+		return nil, fmt.Errorf("not found notebook [%s]", *notebook)
+	}
+
+	if "auto" == *format {
+		lowerPath := strings.ToLower(localPath)
+		switch {
+		case strings.HasSuffix(lowerPath, ".sy.zip"):
+			*format = "sy"
+		case strings.HasSuffix(lowerPath, ".enex"):
+			*format = "enex"
+		case gulu.File.IsDir(filepath.Join(localPath, ".obsidian")):
+			*format = "obsidian"
+		default:
+			*format = "md"
+		}
+	}
+
+	var report *model.ImportReport
+	switch *format {
+	case "md":
+		err = model.ImportFromLocalPath(*notebook, localPath, *toPath)
+	case "sy":
+		err = model.ImportSY(localPath, *notebook, *toPath)
+	case "enex":
+		report, err = model.ImportENEX(*notebook, localPath, *toPath)
+	case "html":
+		report, err = model.ImportHTMLZip(*notebook, localPath, *toPath)
+	case "notion":
+		report, err = model.ImportNotionZip(*notebook, localPath, *toPath)
+	case "obsidian":
+		report, err = model.ImportObsidianVault(*notebook, localPath, *toPath, false)
+	default:
+		return nil, errUsage
+	}
+	if nil != report {
+		data = report
+	}
+	return
+}
+
+func snapshot(args []string) (data interface{}, err error) {
+	if 1 > len(args) {
+		return nil, errUsage
+	}
+
+	switch args[0] {
+	case "create":
+		if 2 != len(args) {
+			return nil, errUsage
+		}
+		if err = model.IndexRepo(args[1]); nil != err {
+			return
+		}
+		snapshots, _, _, getErr := model.GetRepoSnapshots(1)
+		if nil == getErr && 0 < len(snapshots) {
+			data = snapshots[0]
+		}
+	case "list":
+		page := 1
+		if 2 == len(args) {
+			if _, err = fmt.Sscanf(args[1], "%d", &page); nil != err {
+				return nil, errUsage
+			}
+		}
+		snapshots, pageCount, totalCount, getErr := model.GetRepoSnapshots(page)
+		if nil != getErr {
+			return nil, getErr
+		}
+		data = map[string]interface{}{
+			"snapshots":  snapshots,
+			"pageCount":  pageCount,
+			"totalCount": totalCount,
+		}
+	case "checkout":
+		if 2 != len(args) {
+			return nil, errUsage
+		}
+		err = model.CheckoutRepoSync(args[1])
+	case "diff":
+		if 3 != len(args) {
+			return nil, errUsage
+		}
+		data, err = model.DiffRepoSnapshots(args[1], args[2])
+	default:
+		return nil, errUsage
+	}
+	return
+}
+
+func index(args []string) (data interface{}, err error) {
+	if 1 != len(args) || "rebuild" != args[0] {
+		return nil, errUsage
+	}
+
+	model.FullReindex()
+	task.ExecAllTasks()
+	sql.FlushQueue()
+	data = map[string]interface{}{
+		"trees":  treenode.CountTrees(),
+		"blocks": treenode.CountBlocks(),
+	}
+	return
+}
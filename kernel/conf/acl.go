@@ -0,0 +1,38 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type ACL struct {
+	Enable bool       `json:"enable"` // 是否启用访问控制
+	Rules  []*ACLRule `json:"rules"`  // 访问控制规则
+}
+
+// ACLRule 描述某个角色对笔记本或文档子树的访问权限。
+// 同一角色命中多条规则时，路径最长（最具体）的规则生效。
+type ACLRule struct {
+	Role       uint   `json:"role"`       // 角色，1：编辑者，2：读者，3：匿名访问者
+	Box        string `json:"box"`        // 笔记本 ID
+	Path       string `json:"path"`       // 文档路径，如 /20210808180117-6v0mkxr.sy，为空时作用于整个笔记本
+	Permission string `json:"permission"` // 权限，none：不可见，read：只读，write：读写
+}
+
+func NewACL() *ACL {
+	return &ACL{
+		Enable: false,
+		Rules:  []*ACLRule{},
+	}
+}
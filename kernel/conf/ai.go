@@ -19,6 +19,7 @@ package conf
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/siyuan-note/siyuan/kernel/util"
 
@@ -27,6 +28,179 @@ import (
 
 type AI struct {
 	OpenAI *OpenAI `json:"openAI"`
+
+	Providers       []*AIProvider             `json:"providers"`       // 自定义模型服务提供方
+	DefaultProvider string                    `json:"defaultProvider"` // 默认提供方名称，为空时使用 OpenAI 配置
+	ActionModels    map[string]*AIActionModel `json:"actionModels"`    // 按动作选择提供方和模型，键为动作名称，对话使用 chat
+	Stream          bool                      `json:"stream"`          // 是否通过 WebSocket 流式推送生成的内容
+}
+
+const (
+	AIProviderTypeOpenAI    = "openai"    // OpenAI 兼容接口
+	AIProviderTypeOllama    = "ollama"    // Ollama 本地接口
+	AIProviderTypeAnthropic = "anthropic" // Anthropic Messages 接口
+
+	AIActionChat = "chat" // 对话（非动作）使用的键
+)
+
+// AIProvider 描述了模型服务提供方。
+type AIProvider struct {
+	Name           string  `json:"name"` // 名称，唯一
+	Type           string  `json:"type"` // 类型，openai、ollama 或 anthropic
+	APIKey         string  `json:"apiKey"`
+	APITimeout     int     `json:"apiTimeout"`
+	APIProxy       string  `json:"apiProxy"`
+	APIModel       string  `json:"apiModel"` // 默认模型
+	APIMaxTokens   int     `json:"apiMaxTokens"`
+	APITemperature float64 `json:"apiTemperature"`
+	APIBaseURL     string  `json:"apiBaseURL"`
+	APIUserAgent   string  `json:"apiUserAgent"`
+	APIProvider    string  `json:"apiProvider"` // OpenAI, Azure，仅 openai 类型有效
+	APIVersion     string  `json:"apiVersion"`  // Azure API 版本或者 Anthropic API 版本
+}
+
+// AIActionModel 描述了某个动作使用的提供方和模型。
+type AIActionModel struct {
+	Provider string `json:"provider"` // 提供方名称，为空时使用默认提供方
+	Model    string `json:"model"`    // 模型，为空时使用提供方的默认模型
+}
+
+// Fix 订正提供方配置的缺省值和取值范围。
+func (provider *AIProvider) Fix() {
+	provider.Name = strings.TrimSpace(provider.Name)
+	switch provider.Type {
+	case AIProviderTypeOllama:
+		if "" == provider.APIBaseURL {
+			provider.APIBaseURL = "http://127.0.0.1:11434"
+		}
+	case AIProviderTypeAnthropic:
+		if "" == provider.APIBaseURL {
+			provider.APIBaseURL = "https://api.anthropic.com"
+		}
+		if "" == provider.APIVersion {
+			provider.APIVersion = "2023-06-01"
+		}
+		if 1 > provider.APIMaxTokens {
+			// Anthropic 接口必须指定最大输出 token 数
+			provider.APIMaxTokens = 4096
+		}
+	default:
+		provider.Type = AIProviderTypeOpenAI
+		if "" == provider.APIBaseURL {
+			provider.APIBaseURL = "https://api.openai.com/v1"
+		}
+		if "" == provider.APIProvider {
+			provider.APIProvider = "OpenAI"
+		}
+	}
+	provider.APIBaseURL = strings.TrimSuffix(provider.APIBaseURL, "/")
+
+	if "" == provider.APIUserAgent || strings.HasPrefix(provider.APIUserAgent, "SiYuan/") {
+		provider.APIUserAgent = util.UserAgent
+	}
+	if 5 > provider.APITimeout {
+		provider.APITimeout = 30
+	}
+	if 600 < provider.APITimeout {
+		provider.APITimeout = 600
+	}
+	if 0 > provider.APIMaxTokens {
+		provider.APIMaxTokens = 0
+	}
+	if 0 >= provider.APITemperature || 2 < provider.APITemperature {
+		provider.APITemperature = 1.0
+	}
+}
+
+// FixProviders 订正提供方列表，移除没有名称或者重名的提供方。
+func (ai *AI) FixProviders() {
+	var providers []*AIProvider
+	names := map[string]bool{}
+	for _, provider := range ai.Providers {
+		if nil == provider {
+			continue
+		}
+
+		provider.Fix()
+		if "" == provider.Name || names[provider.Name] {
+			continue
+		}
+		names[provider.Name] = true
+		providers = append(providers, provider)
+	}
+	if nil == providers {
+		providers = []*AIProvider{}
+	}
+	ai.Providers = providers
+
+	if !names[ai.DefaultProvider] {
+		ai.DefaultProvider = ""
+	}
+	if nil == ai.ActionModels {
+		ai.ActionModels = map[string]*AIActionModel{}
+	}
+	for action, actionModel := range ai.ActionModels {
+		if nil == actionModel {
+			delete(ai.ActionModels, action)
+		}
+	}
+}
+
+// GetProvider 根据名称获取提供方，找不到时返回 nil。
+func (ai *AI) GetProvider(name string) *AIProvider {
+	if "" == name {
+		return nil
+	}
+
+	for _, provider := range ai.Providers {
+		if name == provider.Name {
+			return provider
+		}
+	}
+	return nil
+}
+
+// ResolveProvider 获取动作使用的提供方和模型。
+// 优先使用动作单独配置的提供方和模型，其次使用默认提供方，最后使用 OpenAI 配置。
+func (ai *AI) ResolveProvider(action string) (provider *AIProvider, model string) {
+	action = strings.TrimSpace(action)
+	if "" == action {
+		action = AIActionChat
+	}
+
+	providerName := ai.DefaultProvider
+	actionModel := ai.ActionModels[action]
+	if nil != actionModel && "" != actionModel.Provider {
+		providerName = actionModel.Provider
+	}
+
+	if provider = ai.GetProvider(providerName); nil == provider {
+		provider = ai.OpenAI.Provider()
+	}
+
+	model = provider.APIModel
+	if nil != actionModel && "" != actionModel.Model {
+		model = actionModel.Model
+	}
+	return
+}
+
+// Provider 将 OpenAI 配置转换为提供方。
+func (openAI *OpenAI) Provider() *AIProvider {
+	return &AIProvider{
+		Name:           "OpenAI",
+		Type:           AIProviderTypeOpenAI,
+		APIKey:         openAI.APIKey,
+		APITimeout:     openAI.APITimeout,
+		APIProxy:       openAI.APIProxy,
+		APIModel:       openAI.APIModel,
+		APIMaxTokens:   openAI.APIMaxTokens,
+		APITemperature: openAI.APITemperature,
+		APIBaseURL:     openAI.APIBaseURL,
+		APIUserAgent:   openAI.APIUserAgent,
+		APIProvider:    openAI.APIProvider,
+		APIVersion:     openAI.APIVersion,
+	}
 }
 
 type OpenAI struct {
@@ -95,5 +269,5 @@ func NewAI() *AI {
 	if userAgent := os.Getenv("SIYUAN_OPENAI_API_USER_AGENT"); "" != userAgent {
 		openAI.APIUserAgent = userAgent
 	}
-	return &AI{OpenAI: openAI}
+	return &AI{OpenAI: openAI, Providers: []*AIProvider{}, ActionModels: map[string]*AIActionModel{}}
 }
@@ -0,0 +1,63 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type Automation struct {
+	Enable bool              `json:"enable"` // 是否启用自动化
+	Rules  []*AutomationRule `json:"rules"`  // 自动化规则
+}
+
+// AutomationRule 描述一条用户自定义的自动化：触发器命中后依次执行动作。
+type AutomationRule struct {
+	ID      string              `json:"id"`
+	Name    string              `json:"name"`
+	Enabled bool                `json:"enabled"`
+	DryRun  bool                `json:"dryRun"` // 试运行，只记录将要执行的动作而不实际执行
+	Trigger *AutomationTrigger  `json:"trigger"`
+	Actions []*AutomationAction `json:"actions"`
+}
+
+type AutomationTrigger struct {
+	Type  string `json:"type"`  // cron：定时，attr：块属性变更，doc：新建文档，av：数据库字段值变更
+	Cron  string `json:"cron"`  // 定时表达式，分 时 日 月 周
+	Box   string `json:"box"`   // 笔记本 ID，为空时不限制
+	Path  string `json:"path"`  // 文档人类可读路径前缀，如 /Inbox，为空时不限制
+	Name  string `json:"name"`  // 属性名
+	Value string `json:"value"` // 属性值或者数据库字段值，为空时任意变更都会触发
+	AvID  string `json:"avID"`  // 数据库 ID
+	KeyID string `json:"keyID"` // 数据库字段 ID
+}
+
+// AutomationAction 描述一个动作，文本字段支持 Go 模板，可以使用触发事件中的字段，如 {{.ID}}。
+type AutomationAction struct {
+	Type     string            `json:"type"`     // createDoc：使用模板新建文档，appendDailyNote：追加到日记，setAttrs：设置块属性，moveDoc：移动文档，query：执行 SQL 查询并推送结果，webhook：调用 Webhook
+	Box      string            `json:"box"`      // 目标笔记本 ID
+	Path     string            `json:"path"`     // 目标文档人类可读路径
+	Template string            `json:"template"` // 模板文件路径，相对于 data/templates/
+	Content  string            `json:"content"`  // 追加的 Markdown 内容
+	ID       string            `json:"id"`       // 目标块 ID，为空时使用触发事件中的块
+	Attrs    map[string]string `json:"attrs"`    // 需要设置的属性
+	SQL      string            `json:"sql"`      // SQL 查询语句
+	URL      string            `json:"url"`      // 推送地址
+}
+
+func NewAutomation() *Automation {
+	return &Automation{
+		Enable: true,
+		Rules:  []*AutomationRule{},
+	}
+}
@@ -39,6 +39,14 @@ type Flashcard struct {
 	Weights          string  `json:"weights"`
 }
 
+// FlashcardFSRS 描述了卡包单独使用的 FSRS 参数，保存在卡包所在的 riff 目录下，没有设置的卡包使用全局参数。
+type FlashcardFSRS struct {
+	RequestRetention float64 `json:"requestRetention"`
+	MaximumInterval  int     `json:"maximumInterval"`
+	Weights          string  `json:"weights"`
+	Updated          int64   `json:"updated"`
+}
+
 func NewFlashcard() *Flashcard {
 	param := fsrs.DefaultParam()
 	return &Flashcard{
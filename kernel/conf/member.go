@@ -0,0 +1,46 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type Members struct {
+	Accounts []*Member `json:"accounts"` // 帐号列表
+}
+
+// Member 描述共享同一个内核的多人中的一个具名帐号。
+type Member struct {
+	Name     string         `json:"name"`     // 帐号名
+	Password string         `json:"password"` // 密码的 bcrypt 哈希值
+	Role     uint           `json:"role"`     // 角色，0：管理员，1：编辑者，2：读者
+	Disabled bool           `json:"disabled"` // 是否禁用
+	Memo     string         `json:"memo"`     // 备注
+	Tokens   []*MemberToken `json:"tokens"`   // API token 列表
+	Created  int64          `json:"created"`  // 创建时间
+}
+
+type MemberToken struct {
+	ID      string `json:"id"`      // token ID
+	Memo    string `json:"memo"`    // 备注
+	Digest  string `json:"digest"`  // token 的 SHA-256 摘要，token 明文仅在创建时返回一次
+	Created int64  `json:"created"` // 创建时间
+	Revoked bool   `json:"revoked"` // 是否已吊销
+}
+
+func NewMembers() *Members {
+	return &Members{
+		Accounts: []*Member{},
+	}
+}
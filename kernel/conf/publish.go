@@ -17,9 +17,10 @@
 package conf
 
 type Publish struct {
-	Enable bool       `json:"enable"` // 是否启用发布服务
-	Port   uint16     `json:"port"`   // 发布服务端口
-	Auth   *BasicAuth `json:"auth"`   // Basic 认证
+	Enable bool            `json:"enable"` // 是否启用发布服务
+	Port   uint16          `json:"port"`   // 发布服务端口
+	Auth   *BasicAuth      `json:"auth"`   // Basic 认证
+	Shares []*PublishShare `json:"shares"` // 匿名只读分享链接
 }
 
 type BasicAuth struct {
@@ -28,9 +29,20 @@ type BasicAuth struct {
 }
 
 type BasicAuthAccount struct {
-	Username string `json:"username"` // 用户名
-	Password string `json:"password"` // 密码
-	Memo     string `json:"memo"`     // 备注
+	Username  string   `json:"username"`  // 用户名
+	Password  string   `json:"password"`  // 密码
+	Memo      string   `json:"memo"`      // 备注
+	Notebooks []string `json:"notebooks"` // 可访问的笔记本 ID 列表，和 Docs 都为空时可访问所有笔记本
+	Docs      []string `json:"docs"`      // 可访问的文档 ID 列表，包含下级文档
+}
+
+type PublishShare struct {
+	ID      string `json:"id"`      // 分享 ID
+	RootID  string `json:"rootID"`  // 分享的文档 ID，包含下级文档
+	Digest  string `json:"digest"`  // 分享令牌的 SHA-256 摘要，令牌本身不保存
+	Memo    string `json:"memo"`    // 备注
+	Created int64  `json:"created"` // 创建时间
+	Expired int64  `json:"expired"` // 过期时间，0 表示永不过期
 }
 
 func NewPublish() *Publish {
@@ -41,5 +53,6 @@ func NewPublish() *Publish {
 			Enable:   true,
 			Accounts: []*BasicAuthAccount{},
 		},
+		Shares: []*PublishShare{},
 	}
 }
@@ -0,0 +1,40 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+type Webhooks struct {
+	Enable bool       `json:"enable"` // 是否启用 Webhook
+	Hooks  []*Webhook `json:"hooks"`  // Webhook 列表
+}
+
+// Webhook 描述一个外部推送地址，事件发生后向其 POST 事件内容。
+type Webhook struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Enabled    bool     `json:"enabled"`
+	URL        string   `json:"url"`        // 推送地址
+	Secret     string   `json:"secret"`     // 签名密钥，不为空时使用 HMAC-SHA256 对请求体签名
+	Events     []string `json:"events"`     // 订阅的事件，为空时订阅所有事件
+	MaxRetries int      `json:"maxRetries"` // 推送失败后的最大重试次数，为 0 时使用默认值
+}
+
+func NewWebhooks() *Webhooks {
+	return &Webhooks{
+		Enable: true,
+		Hooks:  []*Webhook{},
+	}
+}
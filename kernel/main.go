@@ -19,7 +19,10 @@
 package main
 
 import (
+	"os"
+
 	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/cli"
 	"github.com/siyuan-note/siyuan/kernel/job"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/server"
@@ -28,6 +31,11 @@ import (
 )
 
 func main() {
+	if 1 < len(os.Args) && "cli" == os.Args[1] {
+		// 命令行模式 siyuan-kernel cli --workspace <path> <command>
+		os.Exit(cli.Main(os.Args[2:]))
+	}
+
 	util.Boot()
 
 	model.InitConf()
@@ -35,6 +43,7 @@ func main() {
 	model.InitAppearance()
 	sql.InitDatabase(false)
 	sql.InitHistoryDatabase(false)
+	sql.InitAuditDatabase()
 	sql.InitAssetContentDatabase(false)
 	sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
 	sql.SetIndexAssetPath(model.Conf.Search.IndexAssetPath)
@@ -0,0 +1,667 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/parse"
+	"github.com/gin-gonic/gin"
+	gcache "github.com/patrickmn/go-cache"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+type Permission int
+
+const (
+	PermissionNone  Permission = iota // 不可见
+	PermissionRead                    // 只读
+	PermissionWrite                   // 读写
+)
+
+func parsePermission(permission string) Permission {
+	switch permission {
+	case "read":
+		return PermissionRead
+	case "write":
+		return PermissionWrite
+	default:
+		return PermissionNone
+	}
+}
+
+// defaultPermission 返回角色在没有命中任何访问控制规则时的权限。
+func defaultPermission(role Role) Permission {
+	if IsReadOnlyRole(role) {
+		return PermissionRead
+	}
+	return PermissionWrite
+}
+
+// IsACLRestricted 判断角色是否受访问控制规则约束，管理员不受约束。
+func IsACLRestricted(role Role) bool {
+	if RoleAdministrator == role || nil == Conf.ACL || !Conf.ACL.Enable {
+		return false
+	}
+
+	for _, rule := range Conf.ACL.Rules {
+		if Role(rule.Role) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPermission 获取角色对笔记本 boxID 下路径 p 的权限，p 可以是文档路径（.sy）或者文件夹路径。
+func GetPermission(role Role, boxID, p string) (ret Permission) {
+	ret = defaultPermission(role)
+	if !IsACLRestricted(role) {
+		return
+	}
+
+	p = aclPath(p)
+	matchedLen := -1
+	for _, rule := range Conf.ACL.Rules {
+		if Role(rule.Role) != role || rule.Box != boxID {
+			continue
+		}
+
+		rulePath := aclPath(rule.Path)
+		if !isACLSubPath(rulePath, p) {
+			continue
+		}
+
+		if matchedLen < len(rulePath) {
+			matchedLen = len(rulePath)
+			ret = parsePermission(rule.Permission)
+		}
+	}
+	return
+}
+
+// IsDocVisible 判断文档树中的路径 p 是否对角色可见。
+// 路径本身不可读但下级存在可读规则时仍然可见，这样才能在文档树中逐级展开到被授权的子文档。
+func IsDocVisible(role Role, boxID, p string) bool {
+	if PermissionNone < GetPermission(role, boxID, p) {
+		return true
+	}
+
+	p = aclPath(p)
+	for _, rule := range Conf.ACL.Rules {
+		if Role(rule.Role) != role || rule.Box != boxID || PermissionNone == parsePermission(rule.Permission) {
+			continue
+		}
+
+		if rulePath := aclPath(rule.Path); rulePath != p && isACLSubPath(p, rulePath) {
+			return true
+		}
+	}
+	return false
+}
+
+func CanReadDoc(role Role, boxID, p string) bool {
+	return PermissionRead <= GetPermission(role, boxID, p)
+}
+
+func CanWriteDoc(role Role, boxID, p string) bool {
+	return PermissionWrite <= GetPermission(role, boxID, p)
+}
+
+func CanWriteBlock(role Role, id string) bool {
+	if !IsACLRestricted(role) {
+		return PermissionWrite <= defaultPermission(role)
+	}
+
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return true
+	}
+	return CanWriteDoc(role, bt.BoxID, bt.Path)
+}
+
+// RefuseToReadBlock 检查当前请求的角色是否可以读取块，不可读时设置返回结果并返回 true。
+func RefuseToReadBlock(c *gin.Context, id string, result *gulu.Result) bool {
+	if canReadBlock(GetGinContextRole(c), GetGinContextPublishScope(c), id) {
+		return false
+	}
+
+	refuseByACL(result)
+	return true
+}
+
+// RefuseToReadDoc 检查当前请求的角色是否可以读取文档或文件夹，不可读时设置返回结果并返回 true。
+func RefuseToReadDoc(c *gin.Context, boxID, p string, result *gulu.Result) bool {
+	if isDocVisible(GetGinContextRole(c), GetGinContextPublishScope(c), boxID, p) {
+		return false
+	}
+
+	refuseByACL(result)
+	return true
+}
+
+// RefuseToWriteBlock 检查当前请求的角色是否可以修改块，不可修改时设置返回结果并返回 true。
+func RefuseToWriteBlock(c *gin.Context, id string, result *gulu.Result) bool {
+	if CanWriteBlock(GetGinContextRole(c), id) {
+		return false
+	}
+
+	refuseByACL(result)
+	return true
+}
+
+// RefuseToReadAttributeView 检查当前请求是否可以读取属性视图，受限时需要可以读取属性视图的镜像块，
+// blockID 不为空时只检查该镜像块，否则只要有一个镜像块可读即可。不可读时设置返回结果并返回 true。
+func RefuseToReadAttributeView(c *gin.Context, avID, blockID string, result *gulu.Result) bool {
+	filter := GetReadableFilter(c)
+	if nil == filter {
+		return false
+	}
+
+	mirrorBlockIDs := treenode.GetMirrorAttrViewBlockIDs(avID)
+	if "" != blockID {
+		if gulu.Str.Contains(blockID, mirrorBlockIDs) && filter.CanReadBlock(blockID) {
+			return false
+		}
+	} else if 0 < len(FilterBlockIDs(c, mirrorBlockIDs)) {
+		return false
+	}
+
+	refuseByACL(result)
+	return true
+}
+
+// RefuseRestricted 拒绝受限请求访问无法逐块检查读取权限的接口，拒绝时设置返回结果并返回 true。
+func RefuseRestricted(c *gin.Context, result *gulu.Result) bool {
+	if !isRestricted(GetGinContextRole(c), GetGinContextPublishScope(c)) {
+		return false
+	}
+
+	refuseByACL(result)
+	return true
+}
+
+// canReadDoc 判断角色和发布服务访问范围是否都允许读取文档。
+func canReadDoc(role Role, scope *PublishScope, boxID, p string) bool {
+	return CanReadDoc(role, boxID, p) && scope.canRead(boxID, p)
+}
+
+func isDocVisible(role Role, scope *PublishScope, boxID, p string) bool {
+	return IsDocVisible(role, boxID, p) && scope.isVisible(boxID, p)
+}
+
+func canReadBlock(role Role, scope *PublishScope, id string) bool {
+	if !IsACLRestricted(role) && nil == scope {
+		return true
+	}
+
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return nil == scope
+	}
+	return canReadDoc(role, scope, bt.BoxID, bt.Path)
+}
+
+func isRestricted(role Role, scope *PublishScope) bool {
+	return IsACLRestricted(role) || nil != scope
+}
+
+func refuseByACL(result *gulu.Result) {
+	result.Code = http.StatusForbidden
+	result.Msg = http.StatusText(http.StatusForbidden)
+}
+
+func FilterNotebooksByRole(c *gin.Context, boxes []*Box) (ret []*Box) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return boxes
+	}
+
+	ret = []*Box{}
+	for _, box := range boxes {
+		if isDocVisible(role, scope, box.ID, "/") {
+			ret = append(ret, box)
+		}
+	}
+	return
+}
+
+func FilterFilesByRole(c *gin.Context, boxID string, files []*File) (ret []*File) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return files
+	}
+
+	ret = []*File{}
+	for _, file := range files {
+		if isDocVisible(role, scope, boxID, file.Path) {
+			ret = append(ret, file)
+		}
+	}
+	return
+}
+
+func FilterBlocksByRole(c *gin.Context, blocks []*Block) (ret []*Block) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return blocks
+	}
+
+	ret = []*Block{}
+	for _, block := range blocks {
+		if canReadDoc(role, scope, block.Box, block.Path) {
+			ret = append(ret, block)
+		}
+	}
+	return
+}
+
+// FilterPathsByRole 过滤反链面板中的路径，Path.ID 为引用所在文档的 ID。
+func FilterPathsByRole(c *gin.Context, paths []*Path) (ret []*Path) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return paths
+	}
+
+	var ids []string
+	for _, p := range paths {
+		ids = append(ids, p.ID)
+	}
+	bts := treenode.GetBlockTrees(ids)
+
+	ret = []*Path{}
+	for _, p := range paths {
+		if bt := bts[p.ID]; nil == bt {
+			if nil != scope { // 发布服务只返回能确定在访问范围内的路径
+				continue
+			}
+		} else if !canReadDoc(role, scope, bt.BoxID, bt.Path) {
+			continue
+		}
+		ret = append(ret, p)
+	}
+	return
+}
+
+func FilterGraphByRole(c *gin.Context, nodes []*GraphNode, links []*GraphLink) (retNodes []*GraphNode, retLinks []*GraphLink) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return nodes, links
+	}
+
+	visible := map[string]bool{}
+	retNodes = []*GraphNode{}
+	for _, node := range nodes {
+		if "" != node.Box && !canReadDoc(role, scope, node.Box, node.Path) {
+			continue
+		}
+
+		if "" == node.Box && !canReadBlock(role, scope, node.ID) { // 标签节点没有 Box
+			continue
+		}
+
+		visible[node.ID] = true
+		retNodes = append(retNodes, node)
+	}
+
+	retLinks = []*GraphLink{}
+	for _, link := range links {
+		if visible[link.From] && visible[link.To] {
+			retLinks = append(retLinks, link)
+		}
+	}
+	return
+}
+
+// SQLReadableFunc 返回只读 SQL 查询中判断文档是否可见的函数，角色不受约束时返回 nil。
+func SQLReadableFunc(c *gin.Context) func(boxID, p string) bool {
+	if filter := GetReadableFilter(c); nil != filter {
+		return filter.CanRead
+	}
+	return nil
+}
+
+// ReadableFilter 描述受限请求可以读取的文档范围，用于在查询和遍历时过滤。
+type ReadableFilter struct {
+	role  Role
+	scope *PublishScope
+}
+
+// GetReadableFilter 获取当前请求的读取过滤器，角色和访问范围都不受限时返回 nil。
+func GetReadableFilter(c *gin.Context) *ReadableFilter {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return nil
+	}
+	return &ReadableFilter{role: role, scope: scope}
+}
+
+func (filter *ReadableFilter) CanRead(boxID, p string) bool {
+	if nil == filter {
+		return true
+	}
+	return canReadDoc(filter.role, filter.scope, boxID, p)
+}
+
+func (filter *ReadableFilter) CanReadBlock(id string) bool {
+	if nil == filter {
+		return true
+	}
+	return canReadBlock(filter.role, filter.scope, id)
+}
+
+// sqlCondition 生成以 " AND " 开头的查询条件，pathCol 为文档路径字段名，不受限时返回空字符串。
+// 条件和 GetPermission 一致：路径最长的规则生效，没有命中规则时使用角色的默认权限。
+func (filter *ReadableFilter) sqlCondition(pathCol string) (ret string) {
+	if nil == filter {
+		return
+	}
+
+	if IsACLRestricted(filter.role) {
+		var rules []*conf.ACLRule
+		for _, rule := range Conf.ACL.Rules {
+			if Role(rule.Role) == filter.role {
+				rules = append(rules, rule)
+			}
+		}
+		sort.SliceStable(rules, func(i, j int) bool { return len(aclPath(rules[i].Path)) > len(aclPath(rules[j].Path)) })
+
+		buf := bytes.Buffer{}
+		buf.WriteString(" AND (CASE")
+		for _, rule := range rules {
+			buf.WriteString(" WHEN box = '" + sqlQuote(rule.Box) + "'" + aclPathSQLCondition(pathCol, aclPath(rule.Path)))
+			buf.WriteString(" THEN " + strconv.Itoa(int(parsePermission(rule.Permission))))
+		}
+		buf.WriteString(" ELSE " + strconv.Itoa(int(defaultPermission(filter.role))) + " END) >= " + strconv.Itoa(int(PermissionRead)))
+		ret += buf.String()
+	}
+
+	if nil != filter.scope {
+		var conds []string
+		for _, box := range filter.scope.boxes {
+			conds = append(conds, "box = '"+sqlQuote(box)+"'")
+		}
+		for _, doc := range filter.scope.docs {
+			conds = append(conds, "(box = '"+sqlQuote(doc.BoxID)+"'"+aclPathSQLCondition(pathCol, aclPath(doc.Path))+")")
+		}
+		if 1 > len(conds) {
+			ret += " AND 0"
+		} else {
+			ret += " AND (" + strings.Join(conds, " OR ") + ")"
+		}
+	}
+	return
+}
+
+// aclPathSQLCondition 生成判断文档路径字段是否是 base 自身或者 base 下级路径的条件。
+func aclPathSQLCondition(pathCol, base string) string {
+	if "" == base {
+		return ""
+	}
+
+	n := utf8.RuneCountInString(base) + 1
+	base = sqlQuote(base)
+	return " AND (" + pathCol + " = '" + base + ".sy' OR substr(" + pathCol + ", 1, " + strconv.Itoa(n) + ") = '" + base + "/')"
+}
+
+func sqlQuote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// FilterDocPaths 过滤当前请求不可读的文档路径，路径不包含笔记本 ID。
+func FilterDocPaths(c *gin.Context, paths []string) (ret []string) {
+	filter := GetReadableFilter(c)
+	if nil == filter {
+		return paths
+	}
+
+	ret = []string{}
+	for _, p := range paths {
+		if bt := treenode.GetBlockTreeByPath(p); nil != bt && filter.CanRead(bt.BoxID, bt.Path) {
+			ret = append(ret, p)
+		}
+	}
+	return
+}
+
+// selectBlocksRawStmt 在受访问控制约束的只读连接上执行用户提供的块查询语句。
+// 语句中的 box、path 列不可信，只取查询结果中的块 ID，然后按 ID 重新获取块并再次检查。
+func (filter *ReadableFilter) selectBlocksRawStmt(stmt string, page, pageSize int) (ret []*sql.Block) {
+	if 1 > page {
+		page = 1
+	}
+	result, err := sql.QueryReadonly(context.Background(), &sql.ReadonlyQuery{Stmt: stmt, Limit: page * pageSize, Readable: filter.CanRead})
+	if err != nil {
+		logging.LogWarnf("query readable blocks [%s] failed: %s", stmt, err)
+		return
+	}
+
+	var ids []string
+	for i, row := range result.Rows {
+		if i < (page-1)*pageSize {
+			continue
+		}
+
+		switch id := row["id"].(type) {
+		case string:
+			ids = append(ids, id)
+		case []byte:
+			ids = append(ids, string(id))
+		}
+	}
+	for _, b := range sql.GetBlocks(ids) {
+		if nil != b && filter.CanRead(b.Box, b.Path) {
+			ret = append(ret, b)
+		}
+	}
+	return
+}
+
+// FilterBlockIDs 过滤当前请求不可读的块 ID。
+func FilterBlockIDs(c *gin.Context, ids []string) (ret []string) {
+	filter := GetReadableFilter(c)
+	if nil == filter {
+		return ids
+	}
+
+	bts := treenode.GetBlockTrees(ids)
+	ret = []string{}
+	for _, id := range ids {
+		if bt := bts[id]; nil != bt && filter.CanRead(bt.BoxID, bt.Path) {
+			ret = append(ret, id)
+		}
+	}
+	return
+}
+
+// FilterDocMapsByRole 过滤文档搜索结果，通过 box 和 path 字段判断文档是否可见。
+func FilterDocMapsByRole(c *gin.Context, docs []map[string]string) (ret []map[string]string) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return docs
+	}
+
+	ret = []map[string]string{}
+	for _, doc := range docs {
+		if isDocVisible(role, scope, doc["box"], doc["path"]) {
+			ret = append(ret, doc)
+		}
+	}
+	return
+}
+
+func FilterRecentDocsByRole(c *gin.Context, docs []*RecentDoc) (ret []*RecentDoc) {
+	role, scope := GetGinContextRole(c), GetGinContextPublishScope(c)
+	if !isRestricted(role, scope) {
+		return docs
+	}
+
+	ret = []*RecentDoc{}
+	for _, doc := range docs {
+		if canReadBlock(role, scope, doc.RootID) {
+			ret = append(ret, doc)
+		}
+	}
+	return
+}
+
+// CanReadAsset 判断资源文件是否可以读取，受限时只有被可读文档引用的资源文件才可以读取。
+func CanReadAsset(c *gin.Context, relativePath string) bool {
+	filter := GetReadableFilter(c)
+	if nil == filter {
+		return true
+	}
+	return filter.readableAssets()[relativePath]
+}
+
+// RefuseToReadAsset 检查当前请求是否可以读取资源文件，不可读时设置返回结果并返回 true。
+func RefuseToReadAsset(c *gin.Context, relativePath string, result *gulu.Result) bool {
+	if CanReadAsset(c, relativePath) {
+		return false
+	}
+
+	refuseByACL(result)
+	return true
+}
+
+// 受限请求可读的资源文件路径，键为过滤条件，避免每次请求资源文件都查询数据库
+var readableAssetsCache = gcache.New(30*time.Second, time.Minute)
+
+// readableAssets 一次查询出可读文档引用的所有资源文件路径。
+func (filter *ReadableFilter) readableAssets() (ret map[string]bool) {
+	cond := filter.sqlCondition("docpath")
+	if cached, ok := readableAssetsCache.Get(cond); ok {
+		return cached.(map[string]bool)
+	}
+
+	ret = map[string]bool{}
+	stmt := "SELECT DISTINCT path FROM assets WHERE 1" + cond
+	result, err := sql.QueryNoLimit(stmt)
+	if err != nil {
+		logging.LogErrorf("query readable assets [%s] failed: %s", stmt, err)
+		return
+	}
+	for _, row := range result {
+		if p, ok := row["path"].(string); ok {
+			ret[p] = true
+		}
+	}
+	readableAssetsCache.SetDefault(cond, ret)
+	return
+}
+
+// assetContentCondition 生成资源文件内容搜索的过滤条件，只能搜索可读文档引用的资源文件。
+func (filter *ReadableFilter) assetContentCondition() string {
+	var paths []string
+	for p := range filter.readableAssets() {
+		paths = append(paths, "'"+sqlQuote(p)+"'")
+	}
+	if 1 > len(paths) {
+		return " AND 0"
+	}
+	sort.Strings(paths)
+	return " AND path IN (" + strings.Join(paths, ", ") + ")"
+}
+
+// checkACL 在应用事务中的操作之前检查操作涉及的块是否可写。
+func (tx *Transaction) checkACL() *TxErr {
+	if RoleAdministrator == tx.Role {
+		return nil
+	}
+
+	if PermissionWrite > defaultPermission(tx.Role) {
+		return &TxErr{code: TxErrCodeAccessDenied, msg: "read-only role"}
+	}
+
+	if !IsACLRestricted(tx.Role) {
+		return nil
+	}
+
+	for _, op := range tx.DoOperations {
+		if "create" == op.Action {
+			tree, ok := op.Data.(*parse.Tree)
+			if !ok {
+				// 无法确定创建的文档位置时拒绝，避免绕过下面按块检查的逻辑
+				logging.LogWarnf("refuse to create doc with invalid data [%T] by ACL", op.Data)
+				return &TxErr{code: TxErrCodeAccessDenied}
+			}
+			if !CanWriteDoc(tx.Role, tree.Box, tree.Path) {
+				logging.LogWarnf("refuse to create doc [%s/%s] by ACL", tree.Box, tree.Path)
+				return &TxErr{code: TxErrCodeAccessDenied, id: tree.ID}
+			}
+			continue
+		}
+
+		for _, id := range operationBlockIDs(op) {
+			if !CanWriteBlock(tx.Role, id) {
+				logging.LogWarnf("refuse to perform operation [%s] on block [%s] by ACL", op.Action, id)
+				return &TxErr{code: TxErrCodeAccessDenied, id: id}
+			}
+		}
+	}
+	return nil
+}
+
+func operationBlockIDs(op *Operation) (ret []string) {
+	for _, id := range []string{op.ID, op.ParentID, op.PreviousID, op.NextID, op.BlockID} {
+		if "" != id {
+			ret = append(ret, id)
+		}
+	}
+	ret = append(ret, op.BlockIDs...)
+	ret = append(ret, op.SrcIDs...)
+	if "" != op.AvID {
+		// 属性视图不属于某个笔记本，需要对所有镜像块所在的文档都可写
+		ret = append(ret, treenode.GetMirrorAttrViewBlockIDs(op.AvID)...)
+	}
+	return
+}
+
+func aclPath(p string) string {
+	p = strings.TrimSuffix(p, ".sy")
+	p = strings.TrimSuffix(p, "/")
+	return p
+}
+
+// isACLSubPath 判断 p 是否是 base 自身或者 base 的下级路径，base 为空时表示笔记本根路径。
+func isACLSubPath(base, p string) bool {
+	if "" == base {
+		return true
+	}
+	return p == base || strings.HasPrefix(p, base+"/")
+}
+
+// RefuseToPerformTransactions 在事务入队前检查当前请求的角色是否可以执行这些事务，不可执行时设置返回结果并返回 true。
+func RefuseToPerformTransactions(transactions []*Transaction, result *gulu.Result) bool {
+	for _, tx := range transactions {
+		if txErr := tx.checkACL(); nil != txErr {
+			refuseByACL(result)
+			return true
+		}
+	}
+	return false
+}
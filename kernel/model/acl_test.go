@@ -0,0 +1,102 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// TestReadableFilterSQLCondition 检查查询条件和 CanRead 的判断结果一致。
+func TestReadableFilterSQLCondition(t *testing.T) {
+	oldConf := Conf
+	defer func() { Conf = oldConf }()
+	Conf = &AppConf{ACL: &conf.ACL{Enable: true, Rules: []*conf.ACLRule{
+		{Role: uint(RoleReader), Box: "box1", Path: "", Permission: "none"},
+		{Role: uint(RoleReader), Box: "box1", Path: "/a.sy", Permission: "read"},
+		{Role: uint(RoleReader), Box: "box1", Path: "/a/b.sy", Permission: "none"},
+		{Role: uint(RoleReader), Box: "box1", Path: "/a/b/c.sy", Permission: "read"},
+		{Role: uint(RoleReader), Box: "box'2", Path: "/x'y.sy", Permission: "none"},
+		{Role: uint(RoleEditor), Box: "box1", Path: "", Permission: "write"},
+	}}}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("CREATE TABLE blocks (box TEXT, path TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	docs := [][2]string{
+		{"box1", "/a.sy"},
+		{"box1", "/ab.sy"},
+		{"box1", "/a/b.sy"},
+		{"box1", "/a/bc.sy"},
+		{"box1", "/a/b/c.sy"},
+		{"box1", "/a/b/c/d.sy"},
+		{"box1", "/a/b/e.sy"},
+		{"box1", "/z.sy"},
+		{"box'2", "/x'y.sy"},
+		{"box'2", "/x'y/z.sy"},
+		{"box'2", "/x.sy"},
+		{"box3", "/a.sy"},
+	}
+	for _, doc := range docs {
+		if _, err = db.Exec("INSERT INTO blocks VALUES (?, ?)", doc[0], doc[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filters := map[string]*ReadableFilter{
+		"reader":         {role: RoleReader},
+		"editor":         {role: RoleEditor},
+		"scope":          {role: RoleAdministrator, scope: &PublishScope{boxes: []string{"box3"}, docs: []*treenode.BlockTree{{BoxID: "box1", Path: "/a/b.sy"}}}},
+		"empty scope":    {role: RoleAdministrator, scope: &PublishScope{}},
+		"reader & scope": {role: RoleReader, scope: &PublishScope{docs: []*treenode.BlockTree{{BoxID: "box1", Path: "/a.sy"}}}},
+	}
+	for name, filter := range filters {
+		rows, err := db.Query("SELECT box, path FROM blocks WHERE 1" + filter.sqlCondition("path"))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		got := map[[2]string]bool{}
+		for rows.Next() {
+			var doc [2]string
+			if err = rows.Scan(&doc[0], &doc[1]); err != nil {
+				t.Fatal(err)
+			}
+			got[doc] = true
+		}
+		rows.Close()
+
+		for _, doc := range docs {
+			if expected := filter.CanRead(doc[0], doc[1]); expected != got[doc] {
+				t.Errorf("%s: doc [%s%s] expected readable [%v], got [%v]", name, doc[0], doc[1], expected, got[doc])
+			}
+		}
+	}
+
+	var filter *ReadableFilter
+	if "" != filter.sqlCondition("path") {
+		t.Errorf("nil filter should not add condition")
+	}
+}
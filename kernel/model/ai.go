@@ -23,20 +23,29 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
-func ChatGPT(msg string) (ret string) {
-	if !isOpenAIAPIEnabled() {
+// AIStream 描述了流式推送的目标，生成的内容只推送给发起请求的应用会话。
+type AIStream struct {
+	ID      string // 前端用于区分不同请求的 ID，为空时自动生成
+	App     string
+	Session string
+}
+
+// ChatGPT 对话，开启了流式推送并且请求指定了应用时，生成的内容会通过 WebSocket 推送 aiStream 事件。
+func ChatGPT(msg string, stream *AIStream) (ret string) {
+	if !isAIEnabled(conf.AIActionChat) {
 		return
 	}
 
-	return chatGPT(msg, false)
+	return chatGPT(msg, stream, false)
 }
 
-func ChatGPTWithAction(ids []string, action string) (ret string) {
-	if !isOpenAIAPIEnabled() {
+func ChatGPTWithAction(ids []string, action string, stream *AIStream) (ret string) {
+	if !isAIEnabled(action) {
 		return
 	}
 
@@ -47,20 +56,20 @@ func ChatGPTWithAction(ids []string, action string) (ret string) {
 	}
 
 	msg := getBlocksContent(ids)
-	ret = chatGPTWithAction(msg, action, false)
+	ret = chatGPTWithAction(msg, action, stream, false)
 	return
 }
 
 var cachedContextMsg []string
 
-func chatGPT(msg string, cloud bool) (ret string) {
+func chatGPT(msg string, stream *AIStream, cloud bool) (ret string) {
 	if "Clear context" == strings.TrimSpace(msg) {
 		// AI clear context action https://github.com/siyuan-note/siyuan/issues/10255
 		cachedContextMsg = nil
 		return
 	}
 
-	ret, retCtxMsgs, err := chatGPTContinueWrite(msg, cachedContextMsg, cloud)
+	ret, retCtxMsgs, err := chatGPTContinueWrite(msg, cachedContextMsg, conf.AIActionChat, stream, cloud)
 	if err != nil {
 		return
 	}
@@ -68,19 +77,19 @@ func chatGPT(msg string, cloud bool) (ret string) {
 	return
 }
 
-func chatGPTWithAction(msg string, action string, cloud bool) (ret string) {
+func chatGPTWithAction(msg string, action string, stream *AIStream, cloud bool) (ret string) {
 	action = strings.TrimSpace(action)
 	if "" != action {
 		msg = action + ":\n\n" + msg
 	}
-	ret, _, err := chatGPTContinueWrite(msg, nil, cloud)
+	ret, _, err := chatGPTContinueWrite(msg, nil, action, stream, cloud)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret string, retContextMsgs []string, err error) {
+func chatGPTContinueWrite(msg string, contextMsgs []string, action string, stream *AIStream, cloud bool) (ret string, retContextMsgs []string, err error) {
 	util.PushEndlessProgress("Requesting...")
 	defer util.ClearPushProgress(100)
 
@@ -92,12 +101,24 @@ func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret str
 	if cloud {
 		gpt = &CloudGPT{}
 	} else {
-		gpt = &OpenAIGPT{c: util.NewOpenAIClient(Conf.AI.OpenAI.APIKey, Conf.AI.OpenAI.APIProxy, Conf.AI.OpenAI.APIBaseURL, Conf.AI.OpenAI.APIUserAgent, Conf.AI.OpenAI.APIVersion, Conf.AI.OpenAI.APIProvider)}
+		gpt = newGPT(Conf.AI.ResolveProvider(action))
+	}
+
+	var onDelta func(delta string)
+	if Conf.AI.Stream && !cloud && nil != stream && "" != stream.App {
+		streamID := stream.ID
+		if "" == streamID {
+			streamID = ast.NewNodeID()
+		}
+		onDelta = func(delta string) {
+			util.PushAIStream(stream.App, stream.Session, streamID, delta, false)
+		}
+		defer util.PushAIStream(stream.App, stream.Session, streamID, "", true)
 	}
 
 	buf := &bytes.Buffer{}
 	for i := 0; i < Conf.AI.OpenAI.APIMaxContexts; i++ {
-		part, stop, chatErr := gpt.chat(msg, contextMsgs)
+		part, stop, chatErr := gpt.chat(msg, contextMsgs, onDelta)
 		buf.WriteString(part)
 
 		if stop || nil != chatErr {
@@ -115,8 +136,9 @@ func chatGPTContinueWrite(msg string, contextMsgs []string, cloud bool) (ret str
 	return
 }
 
-func isOpenAIAPIEnabled() bool {
-	if "" == Conf.AI.OpenAI.APIKey {
+func isAIEnabled(action string) bool {
+	provider, _ := Conf.AI.ResolveProvider(action)
+	if conf.AIProviderTypeOllama != provider.Type && "" == provider.APIKey {
 		util.PushMsg(Conf.Language(193), 5000)
 		return false
 	}
@@ -164,20 +186,68 @@ func getBlocksContent(ids []string) string {
 }
 
 type GPT interface {
-	chat(msg string, contextMsgs []string) (partRet string, stop bool, err error)
+	// chat 请求模型，onDelta 不为 nil 时使用流式接口并在收到内容片段时回调
+	chat(msg string, contextMsgs []string, onDelta func(delta string)) (partRet string, stop bool, err error)
+}
+
+func newGPT(provider *conf.AIProvider, model string) GPT {
+	param := &util.LLMParam{
+		APIKey:       provider.APIKey,
+		APIBaseURL:   provider.APIBaseURL,
+		APIProxy:     provider.APIProxy,
+		APIUserAgent: provider.APIUserAgent,
+		APIVersion:   provider.APIVersion,
+		Model:        model,
+		MaxTokens:    provider.APIMaxTokens,
+		Temperature:  provider.APITemperature,
+		Timeout:      provider.APITimeout,
+	}
+
+	switch provider.Type {
+	case conf.AIProviderTypeOllama:
+		return &OllamaGPT{param: param}
+	case conf.AIProviderTypeAnthropic:
+		return &AnthropicGPT{param: param}
+	default:
+		return &OpenAIGPT{
+			c:     util.NewOpenAIClient(provider.APIKey, provider.APIProxy, provider.APIBaseURL, provider.APIUserAgent, provider.APIVersion, provider.APIProvider),
+			param: param,
+		}
+	}
 }
 
 type OpenAIGPT struct {
-	c *openai.Client
+	c     *openai.Client
+	param *util.LLMParam
+}
+
+func (gpt *OpenAIGPT) chat(msg string, contextMsgs []string, onDelta func(delta string)) (partRet string, stop bool, err error) {
+	if nil != onDelta {
+		return util.ChatGPTStream(msg, contextMsgs, gpt.c, gpt.param.Model, gpt.param.MaxTokens, gpt.param.Temperature, gpt.param.Timeout, onDelta)
+	}
+	return util.ChatGPT(msg, contextMsgs, gpt.c, gpt.param.Model, gpt.param.MaxTokens, gpt.param.Temperature, gpt.param.Timeout)
+}
+
+type OllamaGPT struct {
+	param *util.LLMParam
+}
+
+func (gpt *OllamaGPT) chat(msg string, contextMsgs []string, onDelta func(delta string)) (partRet string, stop bool, err error) {
+	return util.ChatOllama(msg, contextMsgs, gpt.param, onDelta)
+}
+
+type AnthropicGPT struct {
+	param *util.LLMParam
 }
 
-func (gpt *OpenAIGPT) chat(msg string, contextMsgs []string) (partRet string, stop bool, err error) {
-	return util.ChatGPT(msg, contextMsgs, gpt.c, Conf.AI.OpenAI.APIModel, Conf.AI.OpenAI.APIMaxTokens, Conf.AI.OpenAI.APITemperature, Conf.AI.OpenAI.APITimeout)
+func (gpt *AnthropicGPT) chat(msg string, contextMsgs []string, onDelta func(delta string)) (partRet string, stop bool, err error) {
+	return util.ChatAnthropic(msg, contextMsgs, gpt.param, onDelta)
 }
 
 type CloudGPT struct {
 }
 
-func (gpt *CloudGPT) chat(msg string, contextMsgs []string) (partRet string, stop bool, err error) {
+func (gpt *CloudGPT) chat(msg string, contextMsgs []string, onDelta func(delta string)) (partRet string, stop bool, err error) {
+	// 云端服务不支持流式接口
 	return CloudChatGPT(msg, contextMsgs)
 }
@@ -90,21 +90,31 @@ func GetAssetContent(id, query string, queryMethod int) (ret *AssetContent) {
 //
 // method：0：关键字，1：查询语法，2：SQL，3：正则表达式
 // orderBy: 0：按相关度降序，1：按相关度升序，2：按更新时间升序，3：按更新时间降序
-func FullTextSearchAssetContent(query string, types map[string]bool, method, orderBy, page, pageSize int) (ret []*AssetContent, matchedAssetCount, pageCount int) {
+func FullTextSearchAssetContent(query string, types map[string]bool, method, orderBy, page, pageSize int, readableFilter *ReadableFilter) (ret []*AssetContent, matchedAssetCount, pageCount int) {
 	query = strings.TrimSpace(query)
+	var assetFilter string
+	if nil != readableFilter {
+		if 2 == method {
+			// 资源文件内容和块不在同一个数据库中，无法约束查询语句，受限请求不支持 SQL 搜索
+			ret = []*AssetContent{}
+			return
+		}
+		assetFilter = readableFilter.assetContentCondition()
+	}
+
 	beforeLen := 36
 	orderByClause := buildAssetContentOrderBy(orderBy)
 	switch method {
 	case 1: // 查询语法
-		filter := buildAssetContentTypeFilter(types)
+		filter := buildAssetContentTypeFilter(types) + assetFilter
 		ret, matchedAssetCount = fullTextSearchAssetContentByQuerySyntax(query, filter, orderByClause, beforeLen, page, pageSize)
 	case 2: // SQL
 		ret, matchedAssetCount = searchAssetContentBySQL(query, beforeLen, page, pageSize)
 	case 3: // 正则表达式
-		typeFilter := buildAssetContentTypeFilter(types)
+		typeFilter := buildAssetContentTypeFilter(types) + assetFilter
 		ret, matchedAssetCount = fullTextSearchAssetContentByRegexp(query, typeFilter, orderByClause, beforeLen, page, pageSize)
 	default: // 关键字
-		filter := buildAssetContentTypeFilter(types)
+		filter := buildAssetContentTypeFilter(types) + assetFilter
 		ret, matchedAssetCount = fullTextSearchAssetContentByKeyword(query, filter, orderByClause, beforeLen, page, pageSize)
 	}
 	pageCount = (matchedAssetCount + pageSize - 1) / pageSize
@@ -423,8 +423,13 @@ func netAssets2LocalAssets0(tree *parse.Tree, onlyImg bool, originalURL string,
 	return
 }
 
-func SearchAssetsByName(keyword string, exts []string) (ret []*cache.Asset) {
+// SearchAssetsByName 按名称搜索资源文件，filter 为受限请求的读取过滤器，为空时不过滤。
+func SearchAssetsByName(keyword string, exts []string, filter *ReadableFilter) (ret []*cache.Asset) {
 	ret = []*cache.Asset{}
+	var readableAssets map[string]bool
+	if nil != filter {
+		readableAssets = filter.readableAssets()
+	}
 	var keywords []string
 	keywords = append(keywords, keyword)
 	if "" != keyword {
@@ -433,6 +438,10 @@ func SearchAssetsByName(keyword string, exts []string) (ret []*cache.Asset) {
 	pathHitCount := map[string]int{}
 	filterByExt := 0 < len(exts)
 	for _, asset := range cache.GetAssets() {
+		if nil != readableAssets && !readableAssets[asset.Path] {
+			continue
+		}
+
 		if filterByExt {
 			ext := filepath.Ext(asset.HName)
 			includeExt := false
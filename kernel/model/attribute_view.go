@@ -71,6 +71,7 @@ func RemoveUnusedAttributeView(id string) {
 		util.PushErrMsg(fmt.Sprintf("%s", err), 7000)
 		return
 	}
+	sql.RemoveAttributeViewQueue(id)
 
 	IncSync()
 
@@ -121,6 +122,7 @@ func RemoveUnusedAttributeViews() (ret []string) {
 				util.PushErrMsg(fmt.Sprintf("%s", removeErr), 7000)
 				return
 			}
+			sql.RemoveAttributeViewQueue(id)
 		}
 		ret = append(ret, absPath)
 	}
@@ -686,7 +688,7 @@ func syncAttrViewTableColWidth(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -974,7 +976,7 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 
 	switch newLayout {
 	case av.LayoutTypeTable:
-		if view.Name == av.GetAttributeViewI18n("gallery") || view.Name == av.GetAttributeViewI18n("kanban") {
+		if isAttrViewLayoutDefaultName(view.Name) {
 			view.Name = av.GetAttributeViewI18n("table")
 		}
 
@@ -983,18 +985,11 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 		}
 
 		view.Table = av.NewLayoutTable()
-		switch oldLayout {
-		case av.LayoutTypeGallery:
-			for _, field := range view.Gallery.CardFields {
-				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
-			}
-		case av.LayoutTypeKanban:
-			for _, field := range view.Kanban.Fields {
-				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
-			}
+		for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: fieldID}})
 		}
 	case av.LayoutTypeGallery:
-		if view.Name == av.GetAttributeViewI18n("table") || view.Name == av.GetAttributeViewI18n("kanban") {
+		if isAttrViewLayoutDefaultName(view.Name) {
 			view.Name = av.GetAttributeViewI18n("gallery")
 		}
 
@@ -1003,18 +998,11 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 		}
 
 		view.Gallery = av.NewLayoutGallery()
-		switch oldLayout {
-		case av.LayoutTypeTable:
-			for _, col := range view.Table.Columns {
-				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: col.ID}})
-			}
-		case av.LayoutTypeKanban:
-			for _, field := range view.Kanban.Fields {
-				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: field.ID}})
-			}
+		for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+			view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: fieldID}})
 		}
 	case av.LayoutTypeKanban:
-		if view.Name == av.GetAttributeViewI18n("table") || view.Name == av.GetAttributeViewI18n("gallery") {
+		if isAttrViewLayoutDefaultName(view.Name) {
 			view.Name = av.GetAttributeViewI18n("kanban")
 		}
 
@@ -1023,15 +1011,8 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 		}
 
 		view.Kanban = av.NewLayoutKanban()
-		switch oldLayout {
-		case av.LayoutTypeTable:
-			for _, col := range view.Table.Columns {
-				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: col.ID}})
-			}
-		case av.LayoutTypeGallery:
-			for _, field := range view.Gallery.CardFields {
-				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: field.ID}})
-			}
+		for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+			view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: fieldID}})
 		}
 
 		if !view.IsGroupView() {
@@ -1039,6 +1020,41 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 			group := &av.ViewGroup{Field: preferredGroupKey.ID}
 			setAttributeViewGroup(attrView, view, group)
 		}
+	case av.LayoutTypeCalendar:
+		if isAttrViewLayoutDefaultName(view.Name) {
+			view.Name = av.GetAttributeViewI18n("calendar")
+		}
+
+		if nil != view.Calendar {
+			break
+		}
+
+		view.Calendar = av.NewLayoutCalendar()
+		for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+			view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: &av.BaseField{ID: fieldID}})
+		}
+		if preferredDateKey := getAttrViewPreferredDateKey(attrView); nil != preferredDateKey {
+			view.Calendar.DateKeyID = preferredDateKey.ID
+		}
+	case av.LayoutTypeTimeline:
+		if isAttrViewLayoutDefaultName(view.Name) {
+			view.Name = av.GetAttributeViewI18n("timeline")
+		}
+
+		if nil != view.Timeline {
+			break
+		}
+
+		view.Timeline = av.NewLayoutTimeline()
+		for _, fieldID := range getAttrViewLayoutFieldIDs(view, oldLayout) {
+			view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: &av.BaseField{ID: fieldID}})
+		}
+		if preferredDateKey := getAttrViewPreferredDateKey(attrView); nil != preferredDateKey {
+			view.Timeline.DateKeyID = preferredDateKey.ID
+		}
+	default:
+		err = av.ErrWrongLayoutType
+		return
 	}
 
 	blockIDs := treenode.GetMirrorAttrViewBlockIDs(avID)
@@ -1084,6 +1100,65 @@ func ChangeAttrViewLayout(blockID, avID string, newLayout av.LayoutType) (err er
 	return
 }
 
+// isAttrViewLayoutDefaultName 判断视图名称是否是布局的默认名称，切换布局时默认名称需要跟随布局变化。
+func isAttrViewLayoutDefaultName(name string) bool {
+	for _, layout := range []string{"table", "gallery", "kanban", "calendar", "timeline"} {
+		if name == av.GetAttributeViewI18n(layout) {
+			return true
+		}
+	}
+	return false
+}
+
+// getAttrViewLayoutFieldIDs 获取视图指定布局的字段 ID 列表。
+func getAttrViewLayoutFieldIDs(view *av.View, layout av.LayoutType) (ret []string) {
+	switch layout {
+	case av.LayoutTypeTable:
+		if nil != view.Table {
+			for _, col := range view.Table.Columns {
+				ret = append(ret, col.ID)
+			}
+		}
+	case av.LayoutTypeGallery:
+		if nil != view.Gallery {
+			for _, field := range view.Gallery.CardFields {
+				ret = append(ret, field.ID)
+			}
+		}
+	case av.LayoutTypeKanban:
+		if nil != view.Kanban {
+			for _, field := range view.Kanban.Fields {
+				ret = append(ret, field.ID)
+			}
+		}
+	case av.LayoutTypeCalendar:
+		if nil != view.Calendar {
+			for _, field := range view.Calendar.Fields {
+				ret = append(ret, field.ID)
+			}
+		}
+	case av.LayoutTypeTimeline:
+		if nil != view.Timeline {
+			for _, field := range view.Timeline.Fields {
+				ret = append(ret, field.ID)
+			}
+		}
+	}
+	return
+}
+
+// getAttrViewPreferredDateKey 获取日历和时间线布局默认使用的日期字段，优先使用日期字段，其次使用创建时间和更新时间字段。
+func getAttrViewPreferredDateKey(attrView *av.AttributeView) (ret *av.Key) {
+	for _, typ := range []av.KeyType{av.KeyTypeDate, av.KeyTypeCreated, av.KeyTypeUpdated} {
+		for _, kv := range attrView.KeyValues {
+			if typ == kv.Key.Type {
+				return kv.Key
+			}
+		}
+	}
+	return
+}
+
 func (tx *Transaction) doSetAttrViewWrapField(operation *Operation) (ret *TxErr) {
 	err := setAttrViewWrapField(operation)
 	if err != nil {
@@ -1120,6 +1195,16 @@ func setAttrViewWrapField(operation *Operation) (err error) {
 		for _, field := range view.Kanban.Fields {
 			field.Wrap = allFieldWrap
 		}
+	case av.LayoutTypeCalendar:
+		view.Calendar.WrapField = allFieldWrap
+		for _, field := range view.Calendar.Fields {
+			field.Wrap = allFieldWrap
+		}
+	case av.LayoutTypeTimeline:
+		view.Timeline.WrapField = allFieldWrap
+		for _, field := range view.Timeline.Fields {
+			field.Wrap = allFieldWrap
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1152,6 +1237,10 @@ func setAttrViewShowIcon(operation *Operation) (err error) {
 		view.Gallery.ShowIcon = operation.Data.(bool)
 	case av.LayoutTypeKanban:
 		view.Kanban.ShowIcon = operation.Data.(bool)
+	case av.LayoutTypeCalendar:
+		view.Calendar.ShowIcon = operation.Data.(bool)
+	case av.LayoutTypeTimeline:
+		view.Timeline.ShowIcon = operation.Data.(bool)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1224,6 +1313,10 @@ func setAttrViewDisplayFieldName(operation *Operation) (err error) {
 		view.Gallery.DisplayFieldName = operation.Data.(bool)
 	case av.LayoutTypeKanban:
 		view.Kanban.DisplayFieldName = operation.Data.(bool)
+	case av.LayoutTypeCalendar:
+		view.Calendar.DisplayFieldName = operation.Data.(bool)
+	case av.LayoutTypeTimeline:
+		view.Timeline.DisplayFieldName = operation.Data.(bool)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1286,6 +1379,246 @@ func setAttrViewCardSize(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doSetAttrViewDateKey(operation *Operation) (ret *TxErr) {
+	err := setAttrViewDateKey(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewDateKey(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if "" != operation.KeyID {
+		key, getErr := attrView.GetKey(operation.KeyID)
+		if nil != getErr {
+			err = getErr
+			return
+		}
+
+		if av.KeyTypeDate != key.Type && av.KeyTypeCreated != key.Type && av.KeyTypeUpdated != key.Type {
+			err = fmt.Errorf("key [%s] is not a date key", operation.KeyID)
+			return
+		}
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeCalendar:
+		view.Calendar.DateKeyID = operation.KeyID
+	case av.LayoutTypeTimeline:
+		view.Timeline.DateKeyID = operation.KeyID
+	default:
+		return
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewCalendarMode(operation *Operation) (ret *TxErr) {
+	err := setAttrViewCalendarMode(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewCalendarMode(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeCalendar != view.LayoutType {
+		return
+	}
+
+	view.Calendar.Mode = av.CalendarMode(operation.Data.(float64))
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewCalendarStartWeekday(operation *Operation) (ret *TxErr) {
+	err := setAttrViewCalendarStartWeekday(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewCalendarStartWeekday(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeCalendar != view.LayoutType {
+		return
+	}
+
+	startWeekday := int(operation.Data.(float64))
+	if 0 > startWeekday || 6 < startWeekday {
+		startWeekday = 1
+	}
+	view.Calendar.StartWeekday = startWeekday
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewCalendarCurrent(operation *Operation) (ret *TxErr) {
+	err := setAttrViewCalendarCurrent(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewCalendarCurrent(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeCalendar != view.LayoutType {
+		return
+	}
+
+	// 为 0 时回到今天
+	view.Calendar.Current = int64(operation.Data.(float64))
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewTimelineScale(operation *Operation) (ret *TxErr) {
+	err := setAttrViewTimelineScale(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttrViewTimelineScale(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeTimeline != view.LayoutType {
+		return
+	}
+
+	view.Timeline.Scale = av.TimelineScale(operation.Data.(float64))
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doMoveAttrViewItemDate(operation *Operation) (ret *TxErr) {
+	err := moveAttributeViewItemDate(tx, operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// moveAttributeViewItemDate 在日历或时间线上拖动项目时更新项目的日期字段值。
+// operation.Data 为 {"start": 毫秒时间戳, "end": 毫秒时间戳}，未传 end 时保持原有的时长。
+func moveAttributeViewItemDate(tx *Transaction, operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	var dateKeyID string
+	switch view.LayoutType {
+	case av.LayoutTypeCalendar:
+		dateKeyID = view.Calendar.DateKeyID
+	case av.LayoutTypeTimeline:
+		dateKeyID = view.Timeline.DateKeyID
+	default:
+		err = av.ErrWrongLayoutType
+		return
+	}
+
+	key, err := attrView.GetKey(dateKeyID)
+	if err != nil {
+		return
+	}
+
+	if av.KeyTypeDate != key.Type {
+		// 创建时间和更新时间字段不能修改
+		err = fmt.Errorf("key [%s] is not editable", key.ID)
+		return
+	}
+
+	data, ok := operation.Data.(map[string]interface{})
+	if !ok || nil == data["start"] {
+		err = fmt.Errorf("invalid date data [%v]", operation.Data)
+		return
+	}
+
+	start := int64(data["start"].(float64))
+	date := &av.ValueDate{Content: start, IsNotEmpty: true}
+	if oldVal := attrView.GetValue(dateKeyID, operation.ID); nil != oldVal && nil != oldVal.Date && oldVal.Date.IsNotEmpty {
+		date.IsNotTime = oldVal.Date.IsNotTime
+		if oldVal.Date.HasEndDate && oldVal.Date.IsNotEmpty2 {
+			date.HasEndDate = true
+			date.IsNotEmpty2 = true
+			date.Content2 = start + oldVal.Date.Content2 - oldVal.Date.Content
+		}
+	}
+	if nil != data["end"] {
+		if end := int64(data["end"].(float64)); end > start {
+			date.HasEndDate = true
+			date.IsNotEmpty2 = true
+			date.Content2 = end
+		} else {
+			date.HasEndDate = false
+			date.IsNotEmpty2 = false
+			date.Content2 = 0
+		}
+	}
+	if nil != data["isNotTime"] {
+		date.IsNotTime = data["isNotTime"].(bool)
+	}
+
+	_, err = updateAttributeViewValue(tx, attrView, dateKeyID, operation.ID, map[string]interface{}{"date": date})
+	return
+}
+
 func (tx *Transaction) doSetAttrViewCoverFromAssetKeyID(operation *Operation) (ret *TxErr) {
 	err := setAttrViewCoverFromAssetKeyID(operation)
 	if err != nil {
@@ -2005,7 +2338,9 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 
 		rangeStart, rangeEnd = group.Range.NumStart, group.Range.NumStart+group.Range.NumStep
 		sort.SliceStable(items, func(i, j int) bool {
-			return items[i].GetValue(group.Field).Number.Content < items[j].GetValue(group.Field).Number.Content
+			ni, _ := getGroupValueNumber(items[i].GetValue(group.Field))
+			nj, _ := getGroupValueNumber(items[j].GetValue(group.Field))
+			return ni < nj
 		})
 	case av.GroupMethodDateDay, av.GroupMethodDateWeek, av.GroupMethodDateMonth, av.GroupMethodDateYear, av.GroupMethodDateRelative:
 		if av.KeyTypeCreated == groupKey.Type {
@@ -2020,6 +2355,12 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 			sort.SliceStable(items, func(i, j int) bool {
 				return items[i].GetValue(group.Field).Date.Content < items[j].GetValue(group.Field).Date.Content
 			})
+		} else if av.KeyTypeFormula == groupKey.Type {
+			sort.SliceStable(items, func(i, j int) bool {
+				ti, _ := getGroupValueTime(items[i].GetValue(group.Field))
+				tj, _ := getGroupValueTime(items[j].GetValue(group.Field))
+				return ti.Before(tj)
+			})
 		}
 	}
 
@@ -2064,28 +2405,32 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 
 			groupVal = value.String(false)
 		case av.GroupMethodRangeNum:
-			if group.Range.NumStart > value.Number.Content || group.Range.NumEnd < value.Number.Content {
+			num, ok := getGroupValueNumber(value)
+			if !ok {
+				// 公式计算结果不是数字
+				groupVal = groupValueDefault
+				break
+			}
+
+			if group.Range.NumStart > num || group.Range.NumEnd < num {
 				groupVal = groupValueNotInRange
 				break
 			}
 
-			for rangeEnd <= group.Range.NumEnd && rangeEnd <= value.Number.Content {
+			for rangeEnd <= group.Range.NumEnd && rangeEnd <= num {
 				rangeStart += group.Range.NumStep
 				rangeEnd += group.Range.NumStep
 			}
 
-			if rangeStart <= value.Number.Content && rangeEnd > value.Number.Content {
+			if rangeStart <= num && rangeEnd > num {
 				groupVal = fmt.Sprintf("%s - %s", strconv.FormatFloat(rangeStart, 'f', -1, 64), strconv.FormatFloat(rangeEnd, 'f', -1, 64))
 			}
 		case av.GroupMethodDateDay, av.GroupMethodDateWeek, av.GroupMethodDateMonth, av.GroupMethodDateYear, av.GroupMethodDateRelative:
-			var contentTime time.Time
-			switch value.Type {
-			case av.KeyTypeDate:
-				contentTime = time.UnixMilli(value.Date.Content)
-			case av.KeyTypeCreated:
-				contentTime = time.UnixMilli(value.Created.Content)
-			case av.KeyTypeUpdated:
-				contentTime = time.UnixMilli(value.Updated.Content)
+			contentTime, ok := getGroupValueTime(value)
+			if !ok {
+				// 公式计算结果不是日期
+				groupItemsMap[groupValueDefault] = append(groupItemsMap[groupValueDefault], item)
+				continue
 			}
 			switch group.Method {
 			case av.GroupMethodDateDay:
@@ -2161,6 +2506,12 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 		case av.LayoutTypeKanban:
 			v = av.NewKanbanView()
 			v.Kanban = av.NewLayoutKanban()
+		case av.LayoutTypeCalendar:
+			v = av.NewCalendarView()
+			v.Calendar = av.NewLayoutCalendar()
+		case av.LayoutTypeTimeline:
+			v = av.NewTimelineView()
+			v.Timeline = av.NewLayoutTimeline()
 		default:
 			logging.LogWarnf("unknown layout type [%s] for group view", view.LayoutType)
 			return
@@ -2207,6 +2558,39 @@ func genAttrViewGroups(view *av.View, attrView *av.AttributeView) {
 	setAttrViewGroupStates(view, groupStates)
 }
 
+// getGroupValueNumber 获取用于分组的数字，公式字段使用计算结果。
+func getGroupValueNumber(value *av.Value) (ret float64, ok bool) {
+	value = value.Unwrap()
+	if nil == value || av.KeyTypeNumber != value.Type || nil == value.Number {
+		return
+	}
+	return value.Number.Content, true
+}
+
+// getGroupValueTime 获取用于分组的时间，公式字段使用计算结果。
+func getGroupValueTime(value *av.Value) (ret time.Time, ok bool) {
+	value = value.Unwrap()
+	if nil == value {
+		return
+	}
+
+	switch value.Type {
+	case av.KeyTypeDate:
+		if nil != value.Date {
+			return time.UnixMilli(value.Date.Content), true
+		}
+	case av.KeyTypeCreated:
+		if nil != value.Created {
+			return time.UnixMilli(value.Created.Content), true
+		}
+	case av.KeyTypeUpdated:
+		if nil != value.Updated {
+			return time.UnixMilli(value.Updated.Content), true
+		}
+	}
+	return
+}
+
 // GroupState 用于临时记录每个分组视图的状态，以便后面重新生成分组后可以恢复这些状态。
 type GroupState struct {
 	ID      string
@@ -2658,6 +3042,10 @@ func updateAttributeViewColRelation(operation *Operation) (err error) {
 				v.Gallery.CardFields = append(v.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
 			case av.LayoutTypeKanban:
 				v.Kanban.Fields = append(v.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
+			case av.LayoutTypeCalendar:
+				v.Calendar.Fields = append(v.Calendar.Fields, &av.ViewCalendarField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
+			case av.LayoutTypeTimeline:
+				v.Timeline.Fields = append(v.Timeline.Fields, &av.ViewTimelineField{BaseField: &av.BaseField{ID: operation.BackRelationKeyID}})
 			}
 		}
 
@@ -2875,6 +3263,10 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		view = av.NewGalleryView()
 	case av.LayoutTypeKanban:
 		view = av.NewKanbanView()
+	case av.LayoutTypeCalendar:
+		view = av.NewCalendarView()
+	case av.LayoutTypeTimeline:
+		view = av.NewTimelineView()
 	}
 
 	view.ID = operation.ID
@@ -2963,6 +3355,42 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		view.Kanban.FillColBackgroundColor = masterView.Kanban.FillColBackgroundColor
 		view.Kanban.ShowIcon = masterView.Kanban.ShowIcon
 		view.Kanban.WrapField = masterView.Kanban.WrapField
+	case av.LayoutTypeCalendar:
+		for _, field := range masterView.Calendar.Fields {
+			view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{
+				BaseField: &av.BaseField{
+					ID:     field.ID,
+					Wrap:   field.Wrap,
+					Hidden: field.Hidden,
+					Desc:   field.Desc,
+				},
+			})
+		}
+
+		view.Calendar.DateKeyID = masterView.Calendar.DateKeyID
+		view.Calendar.Mode = masterView.Calendar.Mode
+		view.Calendar.StartWeekday = masterView.Calendar.StartWeekday
+		view.Calendar.Current = masterView.Calendar.Current
+		view.Calendar.DisplayFieldName = masterView.Calendar.DisplayFieldName
+		view.Calendar.ShowIcon = masterView.Calendar.ShowIcon
+		view.Calendar.WrapField = masterView.Calendar.WrapField
+	case av.LayoutTypeTimeline:
+		for _, field := range masterView.Timeline.Fields {
+			view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{
+				BaseField: &av.BaseField{
+					ID:     field.ID,
+					Wrap:   field.Wrap,
+					Hidden: field.Hidden,
+					Desc:   field.Desc,
+				},
+			})
+		}
+
+		view.Timeline.DateKeyID = masterView.Timeline.DateKeyID
+		view.Timeline.Scale = masterView.Timeline.Scale
+		view.Timeline.DisplayFieldName = masterView.Timeline.DisplayFieldName
+		view.Timeline.ShowIcon = masterView.Timeline.ShowIcon
+		view.Timeline.WrapField = masterView.Timeline.WrapField
 	}
 
 	view.ItemIDs = masterView.ItemIDs
@@ -3032,6 +3460,10 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 			for _, field := range firstView.Kanban.Fields {
 				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+			for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: fieldID}})
+			}
 		}
 	case av.LayoutTypeGallery:
 		view = av.NewGalleryView()
@@ -3048,6 +3480,10 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 			for _, field := range firstView.Kanban.Fields {
 				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+			for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+				view.Gallery.CardFields = append(view.Gallery.CardFields, &av.ViewGalleryCardField{BaseField: &av.BaseField{ID: fieldID}})
+			}
 		}
 	case av.LayoutTypeKanban:
 		view = av.NewKanbanView()
@@ -3064,6 +3500,26 @@ func addAttrViewView(avID, viewID, blockID string, layout av.LayoutType) (err er
 			for _, field := range firstView.Kanban.Fields {
 				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: field.ID}})
 			}
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+			for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+				view.Kanban.Fields = append(view.Kanban.Fields, &av.ViewKanbanField{BaseField: &av.BaseField{ID: fieldID}})
+			}
+		}
+	case av.LayoutTypeCalendar:
+		view = av.NewCalendarView()
+		for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+			view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: &av.BaseField{ID: fieldID}})
+		}
+		if preferredDateKey := getAttrViewPreferredDateKey(attrView); nil != preferredDateKey {
+			view.Calendar.DateKeyID = preferredDateKey.ID
+		}
+	case av.LayoutTypeTimeline:
+		view = av.NewTimelineView()
+		for _, fieldID := range getAttrViewLayoutFieldIDs(firstView, firstView.LayoutType) {
+			view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: &av.BaseField{ID: fieldID}})
+		}
+		if preferredDateKey := getAttrViewPreferredDateKey(attrView); nil != preferredDateKey {
+			view.Timeline.DateKeyID = preferredDateKey.ID
 		}
 	default:
 		err = av.ErrWrongLayoutType
@@ -3411,7 +3867,7 @@ func setAttributeViewColumnCalc(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -3621,7 +4077,13 @@ func addAttributeViewBlock(now int64, avID, dbBlockID, viewID, groupID, previous
 	}
 
 	regenAttrViewGroups(attrView)
-	err = av.SaveAttributeView(attrView)
+	if err = av.SaveAttributeView(attrView); nil == err && webhookSubscribed(webhookEventAvRowAdded) {
+		webhookEvt := newWebhookEventByBlockID(webhookEventAvRowAdded, addingBoundBlockID)
+		webhookEvt.Data["avID"] = avID
+		webhookEvt.Data["itemID"] = addingItemID
+		webhookEvt.Data["detached"] = isDetached
+		fireWebhookEvent(webhookEvt)
+	}
 	return
 }
 
@@ -3634,8 +4096,8 @@ func fillDefaultValue(attrView *av.AttributeView, view, groupView *av.View, prev
 			continue
 		}
 
-		if av.KeyTypeRollup == newValue.Type {
-			// 汇总字段的值是渲染时计算的，不需要添加到数据存储中
+		if av.KeyTypeRollup == newValue.Type || av.KeyTypeFormula == newValue.Type {
+			// 汇总和公式字段的值是渲染时计算的，不需要添加到数据存储中
 			continue
 		}
 
@@ -3927,6 +4389,38 @@ func duplicateAttributeViewKey(operation *Operation) (err error) {
 					break
 				}
 			}
+		case av.LayoutTypeCalendar:
+			for i, field := range view.Calendar.Fields {
+				if field.ID == key.ID {
+					view.Calendar.Fields = append(view.Calendar.Fields[:i+1], append([]*av.ViewCalendarField{
+						{
+							BaseField: &av.BaseField{
+								ID:     copyKey.ID,
+								Wrap:   field.Wrap,
+								Hidden: field.Hidden,
+								Desc:   field.Desc,
+							},
+						},
+					}, view.Calendar.Fields[i+1:]...)...)
+					break
+				}
+			}
+		case av.LayoutTypeTimeline:
+			for i, field := range view.Timeline.Fields {
+				if field.ID == key.ID {
+					view.Timeline.Fields = append(view.Timeline.Fields[:i+1], append([]*av.ViewTimelineField{
+						{
+							BaseField: &av.BaseField{
+								ID:     copyKey.ID,
+								Wrap:   field.Wrap,
+								Hidden: field.Hidden,
+								Desc:   field.Desc,
+							},
+						},
+					}, view.Timeline.Fields[i+1:]...)...)
+					break
+				}
+			}
 		}
 	}
 
@@ -3961,7 +4455,7 @@ func setAttributeViewColWidth(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -4015,6 +4509,22 @@ func setAttributeViewColWrap(operation *Operation) (err error) {
 			allFieldWrap = allFieldWrap && field.Wrap
 		}
 		view.Kanban.WrapField = allFieldWrap
+	case av.LayoutTypeCalendar:
+		for _, field := range view.Calendar.Fields {
+			if field.ID == operation.ID {
+				field.Wrap = newWrap
+			}
+			allFieldWrap = allFieldWrap && field.Wrap
+		}
+		view.Calendar.WrapField = allFieldWrap
+	case av.LayoutTypeTimeline:
+		for _, field := range view.Timeline.Fields {
+			if field.ID == operation.ID {
+				field.Wrap = newWrap
+			}
+			allFieldWrap = allFieldWrap && field.Wrap
+		}
+		view.Timeline.WrapField = allFieldWrap
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -4062,6 +4572,20 @@ func setAttributeViewColHidden(operation *Operation) (err error) {
 				break
 			}
 		}
+	case av.LayoutTypeCalendar:
+		for _, field := range view.Calendar.Fields {
+			if field.ID == operation.ID {
+				field.Hidden = operation.Data.(bool)
+				break
+			}
+		}
+	case av.LayoutTypeTimeline:
+		for _, field := range view.Timeline.Fields {
+			if field.ID == operation.ID {
+				field.Hidden = operation.Data.(bool)
+				break
+			}
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -4095,7 +4619,7 @@ func setAttributeViewColPin(operation *Operation) (err error) {
 				break
 			}
 		}
-	case av.LayoutTypeGallery, av.LayoutTypeKanban:
+	case av.LayoutTypeGallery, av.LayoutTypeKanban, av.LayoutTypeCalendar, av.LayoutTypeTimeline:
 		return
 	}
 
@@ -4184,7 +4708,7 @@ func sortAttributeViewRow(operation *Operation) (err error) {
 		if groupView := view.GetGroupByID(operation.GroupID); nil != groupView {
 			groupKey := view.GetGroupKey(attrView)
 			isAcrossGroup := operation.GroupID != operation.TargetGroupID
-			if isAcrossGroup && (av.KeyTypeTemplate == groupKey.Type || av.KeyTypeCreated == groupKey.Type || av.KeyTypeUpdated == groupKey.Type) {
+			if isAcrossGroup && (av.KeyTypeTemplate == groupKey.Type || av.KeyTypeCreated == groupKey.Type || av.KeyTypeUpdated == groupKey.Type || av.KeyTypeFormula == groupKey.Type) {
 				// 这些字段类型不支持跨分组移动，因为它们的值是自动计算生成的
 				return
 			}
@@ -4358,6 +4882,48 @@ func SortAttributeViewViewKey(avID, blockID, keyID, previousKeyID string) (err e
 			}
 		}
 		view.Kanban.Fields = util.InsertElem(view.Kanban.Fields, previousIndex, field)
+	case av.LayoutTypeCalendar:
+		var field *av.ViewCalendarField
+		for i, calendarField := range view.Calendar.Fields {
+			if calendarField.ID == keyID {
+				field = calendarField
+				curIndex = i
+				break
+			}
+		}
+		if nil == field {
+			return
+		}
+
+		view.Calendar.Fields = append(view.Calendar.Fields[:curIndex], view.Calendar.Fields[curIndex+1:]...)
+		for i, calendarField := range view.Calendar.Fields {
+			if calendarField.ID == previousKeyID {
+				previousIndex = i + 1
+				break
+			}
+		}
+		view.Calendar.Fields = util.InsertElem(view.Calendar.Fields, previousIndex, field)
+	case av.LayoutTypeTimeline:
+		var field *av.ViewTimelineField
+		for i, timelineField := range view.Timeline.Fields {
+			if timelineField.ID == keyID {
+				field = timelineField
+				curIndex = i
+				break
+			}
+		}
+		if nil == field {
+			return
+		}
+
+		view.Timeline.Fields = append(view.Timeline.Fields[:curIndex], view.Timeline.Fields[curIndex+1:]...)
+		for i, timelineField := range view.Timeline.Fields {
+			if timelineField.ID == previousKeyID {
+				previousIndex = i + 1
+				break
+			}
+		}
+		view.Timeline.Fields = util.InsertElem(view.Timeline.Fields, previousIndex, field)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -4466,7 +5032,7 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 	switch keyTyp {
 	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeFormula:
 
 		key := av.NewKey(keyID, keyName, keyIcon, keyTyp)
 		if av.KeyTypeRollup == keyTyp {
@@ -4481,8 +5047,8 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 				newField.Wrap = view.Table.WrapField
 
 				if "" == previousKeyID {
-					if av.LayoutTypeGallery == currentView.LayoutType || av.LayoutTypeKanban == currentView.LayoutType {
-						// 如果当前视图是卡片或看板视图则添加到最后
+					if av.LayoutTypeTable != currentView.LayoutType {
+						// 如果当前视图不是表格视图则添加到最后
 						view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: newField})
 					} else {
 						view.Table.Columns = append([]*av.ViewTableColumn{{BaseField: newField}}, view.Table.Columns...)
@@ -4541,6 +5107,46 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 					}
 				}
 			}
+
+			if nil != view.Calendar {
+				newField.Wrap = view.Calendar.WrapField
+
+				if "" == previousKeyID {
+					view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: newField})
+				} else {
+					added := false
+					for i, field := range view.Calendar.Fields {
+						if field.ID == previousKeyID {
+							view.Calendar.Fields = append(view.Calendar.Fields[:i+1], append([]*av.ViewCalendarField{{BaseField: newField}}, view.Calendar.Fields[i+1:]...)...)
+							added = true
+							break
+						}
+					}
+					if !added {
+						view.Calendar.Fields = append(view.Calendar.Fields, &av.ViewCalendarField{BaseField: newField})
+					}
+				}
+			}
+
+			if nil != view.Timeline {
+				newField.Wrap = view.Timeline.WrapField
+
+				if "" == previousKeyID {
+					view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: newField})
+				} else {
+					added := false
+					for i, field := range view.Timeline.Fields {
+						if field.ID == previousKeyID {
+							view.Timeline.Fields = append(view.Timeline.Fields[:i+1], append([]*av.ViewTimelineField{{BaseField: newField}}, view.Timeline.Fields[i+1:]...)...)
+							added = true
+							break
+						}
+					}
+					if !added {
+						view.Timeline.Fields = append(view.Timeline.Fields, &av.ViewTimelineField{BaseField: newField})
+					}
+				}
+			}
 		}
 	}
 
@@ -4578,6 +5184,32 @@ func updateAttributeViewColTemplate(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doUpdateAttrViewColFormula(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColFormula(operation)
+	if err != nil {
+		return &TxErr{code: TxErrHandleAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColFormula(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID == operation.ID && av.KeyTypeFormula == keyValues.Key.Type {
+			keyValues.Key.Formula = strings.TrimSpace(operation.Data.(string))
+			break
+		}
+	}
+
+	regenAttrViewGroups(attrView)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func (tx *Transaction) doUpdateAttrViewColNumberFormat(operation *Operation) (ret *TxErr) {
 	err := updateAttributeViewColNumberFormat(operation)
 	if err != nil {
@@ -4626,7 +5258,7 @@ func updateAttributeViewColumn(operation *Operation) (err error) {
 	switch colType {
 	case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeFormula:
 		for _, keyValues := range attrView.KeyValues {
 			if keyValues.Key.ID == operation.ID {
 				keyValues.Key.Name = strings.TrimSpace(operation.Name)
@@ -4760,6 +5392,20 @@ func RemoveAttributeViewKey(avID, keyID string, removeRelationDest bool) (err er
 									break
 								}
 							}
+						case av.LayoutTypeCalendar:
+							for i, field := range view.Calendar.Fields {
+								if field.ID == removedKey.Relation.BackKeyID {
+									view.Calendar.Fields = append(view.Calendar.Fields[:i], view.Calendar.Fields[i+1:]...)
+									break
+								}
+							}
+						case av.LayoutTypeTimeline:
+							for i, field := range view.Timeline.Fields {
+								if field.ID == removedKey.Relation.BackKeyID {
+									view.Timeline.Fields = append(view.Timeline.Fields[:i], view.Timeline.Fields[i+1:]...)
+									break
+								}
+							}
 						}
 					}
 				}
@@ -4813,6 +5459,30 @@ func RemoveAttributeViewKey(avID, keyID string, removeRelationDest bool) (err er
 				}
 			}
 		}
+
+		if nil != view.Calendar {
+			for i, field := range view.Calendar.Fields {
+				if field.ID == keyID {
+					view.Calendar.Fields = append(view.Calendar.Fields[:i], view.Calendar.Fields[i+1:]...)
+					break
+				}
+			}
+			if view.Calendar.DateKeyID == keyID {
+				view.Calendar.DateKeyID = ""
+			}
+		}
+
+		if nil != view.Timeline {
+			for i, field := range view.Timeline.Fields {
+				if field.ID == keyID {
+					view.Timeline.Fields = append(view.Timeline.Fields[:i], view.Timeline.Fields[i+1:]...)
+					break
+				}
+			}
+			if view.Timeline.DateKeyID == keyID {
+				view.Timeline.DateKeyID = ""
+			}
+		}
 	}
 
 	for _, view := range attrView.Views {
@@ -5043,6 +5713,7 @@ func updateAttributeViewValue(tx *Transaction, attrView *av.AttributeView, keyID
 			}
 		}
 	}
+	oldContent := val.String(false)
 	data, err := gulu.JSON.MarshalJSON(valueData)
 	if err != nil {
 		logging.LogErrorf("marshal value [%+v] failed: %s", valueData, err)
@@ -5178,6 +5849,23 @@ func updateAttributeViewValue(tx *Transaction, attrView *av.AttributeView, keyID
 	}
 
 	refreshRelatedSrcAvs(avID, tx)
+
+	if newContent := val.String(false); oldContent != newContent {
+		var boundBlockID string
+		if nil != blockVal && !blockVal.IsDetached && nil != blockVal.Block {
+			boundBlockID = blockVal.Block.ID
+		}
+		fireAutomationEvent(&AutomationEvent{Type: "av", ID: boundBlockID, AvID: avID, ItemID: itemID, Name: keyID, Value: newContent, OldValue: oldContent})
+		if webhookSubscribed(webhookEventAvValueChanged) {
+			webhookEvt := newWebhookEventByBlockID(webhookEventAvValueChanged, boundBlockID)
+			webhookEvt.Data["avID"] = avID
+			webhookEvt.Data["itemID"] = itemID
+			webhookEvt.Data["keyID"] = keyID
+			webhookEvt.Data["value"] = newContent
+			webhookEvt.Data["oldValue"] = oldContent
+			fireWebhookEvent(webhookEvt)
+		}
+	}
 	return
 }
 
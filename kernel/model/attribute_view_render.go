@@ -110,7 +110,7 @@ func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView,
 		}
 	}
 
-	// 如果是按模板分组则需要重新生成分组
+	// 如果是按模板或公式分组则需要重新生成分组
 	if isGroupByTemplate(attrView, view) {
 		genAttrViewGroups(view, attrView) // 仅重新生成一个视图的分组以提升性能
 		av.SaveAttributeView(attrView)
@@ -181,6 +181,10 @@ func renderAttributeViewGroups(viewable av.Viewable, attrView *av.AttributeView,
 			groupView.Gallery.CardFields = nil
 		case av.LayoutTypeKanban:
 			groupView.Kanban.Fields = nil
+		case av.LayoutTypeCalendar:
+			groupView.Calendar.Fields = nil
+		case av.LayoutTypeTimeline:
+			groupView.Timeline.Fields = nil
 		}
 	}
 	viewable.SetGroups(groups)
@@ -372,7 +376,7 @@ func isGroupByTemplate(attrView *av.AttributeView, view *av.View) bool {
 	if nil == groupKey {
 		return false
 	}
-	return av.KeyTypeTemplate == groupKey.Type
+	return av.KeyTypeTemplate == groupKey.Type || av.KeyTypeFormula == groupKey.Type
 }
 
 func renderViewableInstance(viewable av.Viewable, view *av.View, attrView *av.AttributeView, page, pageSize int) (err error) {
@@ -429,6 +433,25 @@ func renderViewableInstance(viewable av.Viewable, view *av.View, attrView *av.At
 			end = len(kanban.Cards)
 		}
 		kanban.Cards = kanban.Cards[start:end]
+	case av.LayoutTypeCalendar:
+		// 日历不分页，仅保留当前月或周范围内的卡片
+		calendar := viewable.(*av.Calendar)
+		calendar.CardCount = len(calendar.Cards)
+		calendar.BuildGrid(time.Now())
+	case av.LayoutTypeTimeline:
+		timeline := viewable.(*av.Timeline)
+		timeline.CardCount = len(timeline.Cards)
+		timeline.BuildRange(time.Now())
+		timeline.PageSize = view.PageSize
+		if 1 > pageSize {
+			pageSize = timeline.PageSize
+		}
+		start := (page - 1) * pageSize
+		end := start + pageSize
+		if len(timeline.Cards) < end {
+			end = len(timeline.Cards)
+		}
+		timeline.Cards = timeline.Cards[start:end]
 	}
 	return
 }
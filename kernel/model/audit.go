@@ -0,0 +1,377 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	auditArgsContextKey = "auditArgs"
+	auditTxContextKey   = "auditTx"
+	auditMaxIDs         = 64  // 单条审计日志最多记录的块 ID 数
+	auditMaxDiffLen     = 256 // 精简变更内容的最大长度
+	auditMaxArgLen      = 64  // 请求参数中单个字符串值的最大长度
+	auditMaxBodySize    = 1 << 20
+)
+
+// Audit 在写操作 API 执行完成后记录审计日志，提交事务的接口在事务执行时按操作单独记录。
+//
+// 路由上的 CheckReadonly 会标记写操作，这里再按照接口名标记没有经过 CheckReadonly 的写操作。
+func Audit(c *gin.Context) {
+	if isMutatingRequest(c) {
+		markAuditRequest(c)
+	}
+
+	c.Next()
+
+	if c.IsAborted() {
+		// 认证失败或者只读模式下被拒绝的请求没有修改数据
+		return
+	}
+	if _, exists := c.Get(auditTxContextKey); exists {
+		return
+	}
+	args, exists := c.Get(auditArgsContextKey)
+	if !exists {
+		return
+	}
+
+	endpoint := c.Request.URL.Path
+
+	audit := &sql.Audit{
+		ID:       ast.NewNodeID(),
+		Created:  time.Now().UnixMilli(),
+		Actor:    GetGinContextMember(c),
+		Role:     int(GetGinContextRole(c)),
+		IP:       c.ClientIP(),
+		Endpoint: endpoint,
+		Action:   path.Base(endpoint),
+	}
+
+	argsMap, _ := args.(map[string]interface{})
+	var ids []string
+	collectAuditArgIDs(argsMap, &ids, &audit.Box)
+	setAuditBlocks(audit, ids)
+	if 0 < len(argsMap) {
+		data, _ := gulu.JSON.MarshalJSON(compactAuditArg("", argsMap))
+		audit.Diff = truncateAudit(string(data))
+	}
+	sql.AppendAudits(audit)
+}
+
+// SetTransactionsRequest 记录提交事务的请求，事务执行时按操作记录审计日志，请求本身不再单独记录。
+func SetTransactionsRequest(c *gin.Context, transactions []*Transaction) {
+	role, member := GetGinContextRole(c), GetGinContextMember(c)
+	for _, tx := range transactions {
+		tx.Role = role
+		tx.Member = member
+		tx.ClientIP = c.ClientIP()
+		tx.Endpoint = c.Request.URL.Path
+	}
+	c.Set(auditTxContextKey, true)
+}
+
+// isMutatingRequest 判断请求是否为写操作，读取数据的接口和 ControlConcurrency 中的判断一致，
+// 另外排除了不修改工作空间数据的接口。
+func isMutatingRequest(c *gin.Context) bool {
+	reqPath := c.Request.URL.Path
+	if !strings.HasPrefix(reqPath, "/api/") || isReadRequest(reqPath) || websocket.IsWebSocketUpgrade(c.Request) {
+		return false
+	}
+
+	for _, prefix := range []string{"/api/ai/", "/api/lute/", "/api/notification/", "/api/petal/", "/api/audit/"} {
+		if strings.HasPrefix(reqPath, prefix) {
+			return false
+		}
+	}
+
+	function := path.Base(reqPath)
+	for _, prefix := range []string{"check", "query", "read", "batchGet", "resolve", "stat", "export", "preview", "process", "diff",
+		"openRepoSnapshotDoc", "updateRecentDoc", "batchUpdateRecentDoc", "version", "currentTime", "bootProgress", "uiproc", "exit", "login", "logout"} {
+		if strings.HasPrefix(function, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// markAuditRequest 标记当前请求为写操作，并暂存请求参数用于审计。
+func markAuditRequest(c *gin.Context) {
+	if !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		return
+	}
+	if _, exists := c.Get(auditArgsContextKey); exists {
+		return
+	}
+
+	args := map[string]interface{}{}
+	if strings.Contains(c.ContentType(), "json") && nil != c.Request.Body && auditMaxBodySize >= c.Request.ContentLength {
+		// 分块传输时 ContentLength 为 -1，需要限制读取的大小
+		body := c.Request.Body
+		data, err := io.ReadAll(io.LimitReader(body, auditMaxBodySize+1))
+		if auditMaxBodySize < len(data) {
+			// 请求体过大时不解析参数，剩余的请求体留给接口读取
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(data), body), body}
+			c.Set(auditArgsContextKey, args)
+			return
+		}
+
+		body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		if nil == err && 0 < len(data) {
+			if err = gulu.JSON.UnmarshalJSON(data, &args); err != nil {
+				args = map[string]interface{}{}
+			}
+		}
+	}
+	c.Set(auditArgsContextKey, args)
+}
+
+// buildAudits 在事务执行前按操作生成审计日志，需要在执行前解析块所在的文档，否则删除操作无法定位。
+//
+// 所有事务都在这里记录，内核内部生成的事务（比如自动化规则、CalDAV 写回）接口为空。
+func (tx *Transaction) buildAudits() (ret []*sql.Audit) {
+	undoOps := map[string]*Operation{}
+	for _, op := range tx.UndoOperations {
+		if "" != op.ID && ("update" == op.Action || "setAttrs" == op.Action) {
+			undoOps[op.Action+op.ID] = op
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	for _, op := range tx.DoOperations {
+		audit := &sql.Audit{
+			ID:       ast.NewNodeID(),
+			Created:  now,
+			Actor:    tx.Member,
+			Role:     int(tx.Role),
+			IP:       tx.ClientIP,
+			Endpoint: tx.Endpoint,
+			Action:   op.Action,
+		}
+		setAuditBlocks(audit, operationBlockIDs(op))
+
+		switch op.Action {
+		case "update", "setAttrs":
+			var oldData string
+			if undoOp := undoOps[op.Action+op.ID]; nil != undoOp {
+				oldData, _ = undoOp.Data.(string)
+			}
+			newData, _ := op.Data.(string)
+			if "update" == op.Action {
+				luteEngine := util.NewLute()
+				oldData = strings.TrimSpace(luteEngine.BlockDOM2StdMd(strings.ReplaceAll(oldData, editor.FrontEndCaret, "")))
+				newData = strings.TrimSpace(luteEngine.BlockDOM2StdMd(strings.ReplaceAll(newData, editor.FrontEndCaret, "")))
+			}
+			audit.Diff = compactDiff(oldData, newData)
+		default:
+			if data, ok := op.Data.(string); ok {
+				audit.Diff = truncateAudit(data)
+			} else if nil != op.Data {
+				data, _ := gulu.JSON.MarshalJSON(op.Data)
+				audit.Diff = truncateAudit(string(data))
+			}
+		}
+		ret = append(ret, audit)
+	}
+	return
+}
+
+// setAuditBlocks 设置审计日志涉及的块、文档和笔记本。
+func setAuditBlocks(audit *sql.Audit, ids []string) {
+	ids = gulu.Str.RemoveDuplicatedElem(ids)
+	if auditMaxIDs < len(ids) {
+		ids = ids[:auditMaxIDs]
+	}
+
+	var rootIDs []string
+	for _, bt := range treenode.GetBlockTrees(ids) {
+		rootIDs = append(rootIDs, bt.RootID)
+		if "" == audit.Box {
+			audit.Box = bt.BoxID
+		}
+	}
+	rootIDs = gulu.Str.RemoveDuplicatedElem(rootIDs)
+	sort.Strings(rootIDs)
+	audit.RootIDs = strings.Join(rootIDs, ",")
+	audit.BlockIDs = strings.Join(ids, ",")
+}
+
+func collectAuditArgIDs(arg interface{}, ids *[]string, box *string) {
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if s, ok := val.(string); ok && ("notebook" == key || "box" == key || "toNotebook" == key) && ast.IsNodeIDPattern(s) {
+				if "" == *box {
+					*box = s
+				}
+				continue
+			}
+			collectAuditArgIDs(val, ids, box)
+		}
+	case []interface{}:
+		for _, val := range v {
+			collectAuditArgIDs(val, ids, box)
+		}
+	case string:
+		if ast.IsNodeIDPattern(v) {
+			*ids = append(*ids, v)
+		} else if strings.HasSuffix(v, ".sy") {
+			if id := util.GetTreeID(v); ast.IsNodeIDPattern(id) {
+				*ids = append(*ids, id)
+			}
+		}
+	}
+}
+
+// compactAuditArg 截断请求参数中较长的字符串并屏蔽密码、令牌等敏感字段。
+func compactAuditArg(key string, arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		ret := map[string]interface{}{}
+		for k, val := range v {
+			ret[k] = compactAuditArg(k, val)
+		}
+		return ret
+	case []interface{}:
+		var ret []interface{}
+		for _, val := range v {
+			ret = append(ret, compactAuditArg(key, val))
+		}
+		return ret
+	case string:
+		if isSensitiveAuditArg(key) {
+			return "******"
+		}
+		return gulu.Str.SubStr(v, auditMaxArgLen)
+	}
+	return arg
+}
+
+func isSensitiveAuditArg(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"password", "passwd", "token", "secret", "authcode", "accesskey", "apikey"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// compactDiff 去掉新旧内容的公共前后缀，生成形如 -"旧" +"新" 的精简变更内容。
+func compactDiff(oldData, newData string) string {
+	if oldData == newData {
+		return ""
+	}
+
+	oldRunes, newRunes := []rune(oldData), []rune(newData)
+	prefix := 0
+	for prefix < len(oldRunes) && prefix < len(newRunes) && oldRunes[prefix] == newRunes[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldRunes)-prefix && suffix < len(newRunes)-prefix && oldRunes[len(oldRunes)-1-suffix] == newRunes[len(newRunes)-1-suffix] {
+		suffix++
+	}
+	removed := string(oldRunes[prefix : len(oldRunes)-suffix])
+	added := string(newRunes[prefix : len(newRunes)-suffix])
+
+	half := auditMaxDiffLen / 2
+	var buf strings.Builder
+	if "" != removed {
+		buf.WriteString("-" + strconv.Quote(gulu.Str.SubStr(removed, half)))
+	}
+	if "" != added {
+		if 0 < buf.Len() {
+			buf.WriteString(" ")
+		}
+		buf.WriteString("+" + strconv.Quote(gulu.Str.SubStr(added, half)))
+	}
+	return buf.String()
+}
+
+func truncateAudit(s string) string {
+	if auditMaxDiffLen < utf8.RuneCountInString(s) {
+		return gulu.Str.SubStr(s, auditMaxDiffLen) + "..."
+	}
+	return s
+}
+
+// QueryAudits 查询审计日志，actors 为 nil 时不过滤操作者。
+func QueryAudits(from, to int64, actors []string, box string, page, pageSize int) (ret []*sql.Audit, total int) {
+	sql.FlushAuditQueue()
+	return sql.QueryAudits(from, to, actors, box, page, pageSize)
+}
+
+// ExportAudits 导出审计日志为 CSV 文件，返回导出文件的访问路径。
+func ExportAudits(from, to int64, actors []string, box string) (ret string, err error) {
+	audits, _ := QueryAudits(from, to, actors, box, 0, 0)
+
+	exportFolder := filepath.Join(util.TempDir, "export", "audit")
+	if err = os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("mkdir [%s] failed: %s", exportFolder, err)
+		return
+	}
+
+	name := "audit-" + time.Now().Format("20060102150405") + ".csv"
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF") // 写入 BOM 以便 Excel 正确识别 UTF-8 编码
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"id", "created", "actor", "role", "ip", "endpoint", "action", "box", "root_ids", "block_ids", "diff"})
+	for _, a := range audits {
+		created := time.UnixMilli(a.Created).Format("2006-01-02 15:04:05.000")
+		writer.Write([]string{a.ID, created, a.Actor, strconv.Itoa(a.Role), a.IP, a.Endpoint, a.Action, a.Box, a.RootIDs, a.BlockIDs, a.Diff})
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		logging.LogErrorf("write audits csv failed: %s", err)
+		return
+	}
+
+	exportPath := filepath.Join(exportFolder, name)
+	if err = os.WriteFile(exportPath, buf.Bytes(), 0644); err != nil {
+		logging.LogErrorf("write file [%s] failed: %s", exportPath, err)
+		return
+	}
+	ret = "/export/audit/" + url.PathEscape(name)
+	return
+}
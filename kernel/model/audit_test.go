@@ -0,0 +1,60 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMarkAuditRequestChunked(t *testing.T) {
+	newContext := func(body string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/filetree/renameDoc", io.NopCloser(strings.NewReader(body)))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.ContentLength = -1 // 分块传输
+		return c
+	}
+
+	body := `{"id":"20230101000000-abcdefg","title":"foo"}`
+	c := newContext(body)
+	markAuditRequest(c)
+	args, _ := c.Get(auditArgsContextKey)
+	if "foo" != args.(map[string]interface{})["title"] {
+		t.Errorf("unexpected audit args %v", args)
+	}
+	if data, _ := io.ReadAll(c.Request.Body); body != string(data) {
+		t.Errorf("request body changed [%s]", data)
+	}
+
+	// 超过大小限制的请求体不解析，接口仍然可以读取完整的请求体
+	body = `{"data":"` + strings.Repeat("a", auditMaxBodySize) + `"}`
+	c = newContext(body)
+	markAuditRequest(c)
+	args, _ = c.Get(auditArgsContextKey)
+	if 0 != len(args.(map[string]interface{})) {
+		t.Errorf("oversized body should not be parsed")
+	}
+	if data, _ := io.ReadAll(c.Request.Body); body != string(data) {
+		t.Errorf("request body truncated, got [%d] bytes", len(data))
+	}
+}
@@ -18,12 +18,18 @@ package model
 
 import (
 	"crypto/rand"
+	"errors"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 type Account struct {
@@ -46,7 +52,12 @@ const (
 	sub = "publish"
 	aud = "siyuan-kernel"
 
-	ClaimsKeyRole string = "role"
+	memberIss = "siyuan-kernel"
+	memberSub = "member"
+
+	ClaimsKeyRole         string = "role"
+	ClaimsKeyMember       string = "member"
+	ClaimsKeyPublishShare string = "share"
 )
 
 var (
@@ -54,7 +65,8 @@ var (
 	sessionsMap = SessionsMap{}
 	sessionLock = sync.Mutex{}
 
-	jwtKey = make([]byte, 32)
+	jwtKey     = make([]byte, 32)
+	jwtKeyOnce = sync.Once{}
 )
 
 func GetBasicAuthAccount(username string) *Account {
@@ -97,10 +109,8 @@ func InitAccounts() {
 }
 
 func InitJWT() {
-	if _, err := rand.Read(jwtKey); err != nil {
-		logging.LogErrorf("generate JWT signing key failed: %s", err)
-		return
-	}
+	// 签名密钥只加载一次，重启发布服务时不轮换，避免已签发的帐号登录令牌失效
+	jwtKeyOnce.Do(loadJWTKey)
 
 	for username, account := range accountsMap {
 		// REF: https://golang-jwt.github.io/jwt/usage/create/
@@ -124,17 +134,81 @@ func InitJWT() {
 	}
 }
 
+// loadJWTKey 从工作空间配置目录加载 JWT 签名密钥，不存在时生成并保存。
+func loadJWTKey() {
+	keyPath := filepath.Join(util.ConfDir, "jwt.key")
+	if data, err := os.ReadFile(keyPath); err == nil && len(data) == len(jwtKey) {
+		copy(jwtKey, data)
+		return
+	} else if err != nil && !os.IsNotExist(err) {
+		logging.LogErrorf("read JWT signing key [%s] failed: %s", keyPath, err)
+	}
+
+	if _, err := rand.Read(jwtKey); err != nil {
+		logging.LogErrorf("generate JWT signing key failed: %s", err)
+		return
+	}
+	if err := os.WriteFile(keyPath, jwtKey, 0600); err != nil {
+		logging.LogErrorf("save JWT signing key [%s] failed: %s", keyPath, err)
+	}
+}
+
+// NewMemberJWT 为通过帐号密码登录的帐号签发 JWT，帐号的角色以请求时的配置为准，不信任令牌中的角色。
+func NewMemberJWT(member string) (string, error) {
+	t := jwt.NewWithClaims(
+		jwt.SigningMethodHS256,
+		jwt.MapClaims{
+			"iss": memberIss,
+			"sub": memberSub,
+			"aud": aud,
+			"jti": member,
+			"exp": time.Now().Add(30 * 24 * time.Hour).Unix(),
+
+			ClaimsKeyMember: member,
+		},
+	)
+	return t.SignedString(jwtKey)
+}
+
+// NewPublishShareJWT 为分享链接的匿名访问者签发只读令牌，令牌有效期不超过分享的过期时间。
+func NewPublishShareJWT(share *conf.PublishShare) (string, error) {
+	claims := jwt.MapClaims{
+		"iss": iss,
+		"sub": sub,
+		"aud": aud,
+		"jti": "",
+		"exp": time.Now().Add(24 * time.Hour).Unix(),
+
+		ClaimsKeyRole:         RoleReader,
+		ClaimsKeyPublishShare: share.ID,
+	}
+	if 0 < share.Expired && share.Expired/1000 < claims["exp"].(int64) {
+		claims["exp"] = share.Expired / 1000
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+}
+
 func ParseJWT(tokenString string) (*jwt.Token, error) {
 	// REF: https://golang-jwt.github.io/jwt/usage/parse/
-	return jwt.Parse(
+	token, err := jwt.Parse(
 		tokenString,
 		func(token *jwt.Token) (interface{}, error) {
 			return jwtKey, nil
 		},
-		jwt.WithIssuer(iss),
-		jwt.WithSubject(sub),
 		jwt.WithAudience(aud),
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 发布服务和帐号登录签发的令牌使用不同的签发者
+	claims := GetTokenClaims(token)
+	tokenIssuer, _ := claims["iss"].(string)
+	tokenSubject, _ := claims["sub"].(string)
+	if (iss != tokenIssuer || sub != tokenSubject) && (memberIss != tokenIssuer || memberSub != tokenSubject) {
+		return nil, errors.New("invalid token issuer or subject")
+	}
+	return token, nil
 }
 
 func ParseXAuthToken(r *http.Request) *jwt.Token {
@@ -160,6 +234,14 @@ func GetClaimRole(claims jwt.MapClaims) Role {
 	return RoleVisitor
 }
 
+// GetClaimMember 获取令牌中的帐号名，发布服务令牌不包含帐号。
+func GetClaimMember(claims jwt.MapClaims) string {
+	if member, ok := claims[ClaimsKeyMember].(string); ok {
+		return member
+	}
+	return ""
+}
+
 // IsPublishServiceToken 检查 token 是否来自发布服务
 func IsPublishServiceToken(token *jwt.Token) bool {
 	if token == nil || !token.Valid {
@@ -0,0 +1,674 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const automationMaxRuns = 100 // 每条自动化规则最多保留的运行日志数
+
+// AutomationEvent 描述触发自动化的事件，动作中的模板可以使用这些字段。
+type AutomationEvent struct {
+	Type     string `json:"type"`     // cron、attr、doc、av、manual
+	ID       string `json:"id"`       // 块 ID，数据库事件中为条目绑定的块 ID，非绑定条目为空
+	RootID   string `json:"rootID"`   // 文档 ID
+	Box      string `json:"box"`      // 笔记本 ID
+	Path     string `json:"path"`     // 文档数据路径
+	HPath    string `json:"hPath"`    // 文档人类可读路径
+	Name     string `json:"name"`     // 属性名、数据库字段 ID 或者文档标题
+	Value    string `json:"value"`    // 变更后的值
+	OldValue string `json:"oldValue"` // 变更前的值
+	AvID     string `json:"avID"`     // 数据库 ID
+	ItemID   string `json:"itemID"`   // 数据库条目 ID
+	Time     int64  `json:"time"`
+}
+
+type AutomationRun struct {
+	ID      string                    `json:"id"`
+	RuleID  string                    `json:"ruleID"`
+	Created int64                     `json:"created"`
+	DryRun  bool                      `json:"dryRun"`
+	Success bool                      `json:"success"`
+	Event   *AutomationEvent          `json:"event"`
+	Actions []*AutomationActionResult `json:"actions"`
+}
+
+type AutomationActionResult struct {
+	Type string `json:"type"`
+	Msg  string `json:"msg"`
+	Err  string `json:"err"`
+}
+
+var (
+	automationRunsLock     = sync.Mutex{}
+	automationCronLastTime string
+)
+
+// AutomationCronJob 检查定时触发的自动化，同一分钟内只执行一次。
+func AutomationCronJob() {
+	if nil == Conf || nil == Conf.Automation || !Conf.Automation.Enable || util.ReadOnly {
+		return
+	}
+
+	now := time.Now()
+	minute := now.Format("200601021504")
+	if minute == automationCronLastTime {
+		return
+	}
+	automationCronLastTime = minute
+
+	for _, rule := range Conf.Automation.Rules {
+		if !rule.Enabled || nil == rule.Trigger || "cron" != rule.Trigger.Type {
+			continue
+		}
+
+		schedule, err := parseCron(rule.Trigger.Cron)
+		if err != nil {
+			logging.LogWarnf("parse automation [%s] cron [%s] failed: %s", rule.ID, rule.Trigger.Cron, err)
+			continue
+		}
+		if schedule.match(now) {
+			queueAutomationRule(rule, &AutomationEvent{Type: "cron", Time: now.UnixMilli()})
+		}
+	}
+}
+
+func fireAutomationEvent(evt *AutomationEvent) {
+	if nil == Conf || nil == Conf.Automation || !Conf.Automation.Enable || util.ReadOnly || isAutomationOrigin(evt) {
+		return
+	}
+
+	var rules []*conf.AutomationRule
+	for _, rule := range Conf.Automation.Rules {
+		if !rule.Enabled || nil == rule.Trigger || evt.Type != rule.Trigger.Type {
+			continue
+		}
+
+		trigger := rule.Trigger
+		switch evt.Type {
+		case "attr":
+			if trigger.Name != evt.Name || ("" != trigger.Value && trigger.Value != evt.Value) {
+				continue
+			}
+		case "av":
+			if trigger.AvID != evt.AvID || trigger.KeyID != evt.Name || ("" != trigger.Value && trigger.Value != evt.Value) {
+				continue
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if 1 > len(rules) {
+		return
+	}
+
+	evt.Time = time.Now().UnixMilli()
+	if "" == evt.RootID && "" != evt.ID {
+		// 块属性和数据库事件需要查找块所在的文档
+		if bt := treenode.GetBlockTree(evt.ID); nil != bt {
+			evt.RootID, evt.Box, evt.Path, evt.HPath = bt.RootID, bt.BoxID, bt.Path, bt.HPath
+		}
+	}
+
+	for _, rule := range rules {
+		trigger := rule.Trigger
+		if "" != trigger.Box && trigger.Box != evt.Box {
+			continue
+		}
+		if "" != trigger.Path {
+			prefix := strings.TrimSuffix(trigger.Path, "/")
+			if evt.HPath != prefix && !strings.HasPrefix(evt.HPath, prefix+"/") {
+				continue
+			}
+		}
+
+		queueAutomationRule(rule, evt)
+	}
+}
+
+type automationTask struct {
+	rule *conf.AutomationRule
+	evt  *AutomationEvent
+}
+
+var (
+	// automationTasks 待执行的自动化，由固定数量的 worker 执行，队列满时丢弃，避免事件风暴时无限制地创建协程
+	automationTasks     = make(chan *automationTask, 256)
+	automationTasksOnce = sync.Once{}
+)
+
+func queueAutomationRule(rule *conf.AutomationRule, evt *AutomationEvent) {
+	automationTasksOnce.Do(func() {
+		for range 4 {
+			go func() {
+				for task := range automationTasks {
+					runAutomationRule(task.rule, task.evt, task.rule.DryRun)
+				}
+			}()
+		}
+	})
+
+	select {
+	case automationTasks <- &automationTask{rule: rule, evt: evt}:
+	default:
+		logging.LogWarnf("automation queue is full, skip automation [%s] triggered by [%s]", rule.ID, evt.Type)
+	}
+}
+
+var (
+	// automationTargets 记录自动化动作正在修改的块 ID 和文档路径（笔记本 ID + 人类可读路径）
+	// 这些目标上产生的事件来自自动化本身，不再触发自动化，避免循环触发
+	automationTargets     = map[string]int{}
+	automationTargetsLock = sync.Mutex{}
+)
+
+func markAutomationTarget(target string) (unmark func()) {
+	automationTargetsLock.Lock()
+	automationTargets[target]++
+	automationTargetsLock.Unlock()
+	return func() {
+		automationTargetsLock.Lock()
+		defer automationTargetsLock.Unlock()
+		if automationTargets[target]--; 1 > automationTargets[target] {
+			delete(automationTargets, target)
+		}
+	}
+}
+
+func isAutomationOrigin(evt *AutomationEvent) bool {
+	automationTargetsLock.Lock()
+	defer automationTargetsLock.Unlock()
+
+	if 0 < automationTargets[evt.ID] {
+		return true
+	}
+
+	if "doc" == evt.Type {
+		// 按路径创建文档时会同时创建不存在的上级文档
+		docPath := evt.Box + evt.HPath
+		for target := range automationTargets {
+			if target == docPath || strings.HasPrefix(target, docPath+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fireAttrAutomations(id string, oldAttrs, newAttrs map[string]string) {
+	for name, value := range newAttrs {
+		if oldValue := oldAttrs[name]; oldValue != value {
+			fireAutomationEvent(&AutomationEvent{Type: "attr", ID: id, Name: name, Value: html.UnescapeAttrVal(value), OldValue: html.UnescapeAttrVal(oldValue)})
+		}
+	}
+	for name, oldValue := range oldAttrs {
+		if _, ok := newAttrs[name]; !ok {
+			fireAutomationEvent(&AutomationEvent{Type: "attr", ID: id, Name: name, OldValue: html.UnescapeAttrVal(oldValue)})
+		}
+	}
+}
+
+// RunAutomation 手动执行自动化规则。
+func RunAutomation(id string, dryRun bool) (ret *AutomationRun, err error) {
+	rule := getAutomationRule(id)
+	if nil == rule {
+		err = errors.New("automation not found")
+		return
+	}
+
+	ret = runAutomationRule(rule, &AutomationEvent{Type: "manual", Time: time.Now().UnixMilli()}, dryRun || rule.DryRun)
+	return
+}
+
+func runAutomationRule(rule *conf.AutomationRule, evt *AutomationEvent, dryRun bool) (ret *AutomationRun) {
+	defer logging.Recover()
+
+	ret = &AutomationRun{ID: ast.NewNodeID(), RuleID: rule.ID, Created: time.Now().UnixMilli(), DryRun: dryRun, Success: true, Event: evt, Actions: []*AutomationActionResult{}}
+	for _, action := range rule.Actions {
+		result := &AutomationActionResult{Type: action.Type}
+		msg, err := execAutomationAction(action, evt, dryRun)
+		result.Msg = msg
+		ret.Actions = append(ret.Actions, result)
+		if err != nil {
+			// 动作失败后不再执行后续动作
+			result.Err = err.Error()
+			ret.Success = false
+			logging.LogWarnf("automation [%s] action [%s] failed: %s", rule.ID, action.Type, err)
+			break
+		}
+	}
+
+	appendAutomationRun(ret)
+	return
+}
+
+func execAutomationAction(action *conf.AutomationAction, evt *AutomationEvent, dryRun bool) (msg string, err error) {
+	render := func(s string) string {
+		if nil != err {
+			return ""
+		}
+		var ret string
+		ret, err = renderAutomationText(s, evt)
+		return ret
+	}
+	id := render(action.ID)
+	if "" == id {
+		id = evt.ID
+	}
+
+	switch action.Type {
+	case "createDoc":
+		hPath := render(action.Path)
+		if nil != err {
+			return
+		}
+		if nil == Conf.Box(action.Box) || "" == hPath {
+			err = errors.New("invalid notebook or path")
+			return
+		}
+		var tplPath string
+		if "" != action.Template {
+			tplPath = filepath.Join(util.DataDir, "templates", action.Template)
+			if !util.IsSubPath(filepath.Join(util.DataDir, "templates"), tplPath) || !filelock.IsExist(tplPath) {
+				err = errors.New("template [" + action.Template + "] not found")
+				return
+			}
+		}
+		msg = fmt.Sprintf("create doc [%s%s]", action.Box, hPath)
+		if dryRun {
+			return
+		}
+
+		defer markAutomationTarget(action.Box + hPath)()
+		var docID string
+		if docID, err = CreateWithMarkdown("", action.Box, hPath, "", "", "", false, ""); err != nil {
+			return
+		}
+		msg += " [" + docID + "]"
+		if "" != tplPath {
+			err = applyAutomationTemplate(docID, tplPath)
+		}
+	case "appendDailyNote":
+		content := render(action.Content)
+		if nil != err {
+			return
+		}
+		if nil == Conf.Box(action.Box) {
+			err = ErrBoxNotFound
+			return
+		}
+		msg = fmt.Sprintf("append to daily note of [%s]", action.Box)
+		if dryRun {
+			return
+		}
+
+		if dailyNoteHPath := getDailyNoteHPath(action.Box); "" != dailyNoteHPath {
+			defer markAutomationTarget(action.Box + dailyNoteHPath)()
+		}
+		var p string
+		if p, _, err = CreateDailyNote(action.Box); err != nil {
+			return
+		}
+		dom := util.NewLute().Md2BlockDOM(content, true)
+		PerformTransactions(&[]*Transaction{{DoOperations: []*Operation{{Action: "appendInsert", Data: dom, ParentID: util.GetTreeID(p)}}}})
+		FlushTxQueue()
+	case "setAttrs":
+		attrs := map[string]string{}
+		for name, value := range action.Attrs {
+			attrs[name] = render(value)
+		}
+		if nil != err {
+			return
+		}
+		if "" == id {
+			err = errors.New("no target block")
+			return
+		}
+		data, _ := gulu.JSON.MarshalJSON(attrs)
+		msg = fmt.Sprintf("set attrs of [%s] to %s", id, data)
+		if dryRun {
+			return
+		}
+		defer markAutomationTarget(id)()
+		err = SetBlockAttrs(id, attrs)
+	case "moveDoc":
+		hPath := render(action.Path)
+		if nil != err {
+			return
+		}
+		if "" == id {
+			id = evt.RootID
+		}
+		bt := treenode.GetBlockTree(id)
+		if nil == bt {
+			err = ErrBlockNotFound
+			return
+		}
+		if nil == Conf.Box(action.Box) {
+			err = ErrBoxNotFound
+			return
+		}
+		toPath := "/"
+		if "" != hPath && "/" != hPath {
+			root := treenode.GetBlockTreeRootByHPath(action.Box, hPath)
+			if nil == root {
+				err = errors.New("target doc [" + hPath + "] not found")
+				return
+			}
+			toPath = root.Path
+		}
+		msg = fmt.Sprintf("move doc [%s] to [%s%s]", bt.RootID, action.Box, hPath)
+		if dryRun {
+			return
+		}
+		err = MoveDocs([]string{bt.Path}, action.Box, toPath, nil)
+	case "query":
+		stmt, url := render(action.SQL), render(action.URL)
+		if nil != err {
+			return
+		}
+		var rows []map[string]interface{}
+		if rows, err = sql.Query(stmt, Conf.Search.Limit); err != nil {
+			return
+		}
+		msg = fmt.Sprintf("query [%d] rows", len(rows))
+		if "" == url {
+			return
+		}
+		msg += ", post to [" + url + "]"
+		if dryRun {
+			return
+		}
+		err = postAutomationPayload(url, map[string]interface{}{"event": evt, "rows": rows})
+	case "webhook":
+		url := render(action.URL)
+		if nil != err {
+			return
+		}
+		msg = "post to [" + url + "]"
+		if dryRun {
+			return
+		}
+		err = postAutomationPayload(url, map[string]interface{}{"event": evt})
+	default:
+		err = errors.New("unknown action [" + action.Type + "]")
+	}
+	return
+}
+
+// applyAutomationTemplate 渲染模板并替换新建文档的内容。
+func applyAutomationTemplate(docID, tplPath string) (err error) {
+	FlushTxQueue()
+
+	_, dom, err := RenderTemplate(tplPath, docID, false)
+	if err != nil {
+		return
+	}
+
+	tree, err := LoadTreeByBlockID(docID)
+	if err != nil {
+		return
+	}
+
+	ops := []*Operation{{Action: "appendInsert", Data: dom, ParentID: docID}}
+	if first := tree.Root.FirstChild; nil != first && ast.NodeParagraph == first.Type && nil == first.Next && "" == first.Text() {
+		// 移除新建文档时生成的空段落
+		ops = append(ops, &Operation{Action: "delete", ID: first.ID})
+	}
+	PerformTransactions(&[]*Transaction{{DoOperations: ops}})
+	FlushTxQueue()
+	return
+}
+
+func renderAutomationText(text string, evt *AutomationEvent) (ret string, err error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tplFuncMap := filesys.BuiltInTemplateFuncs()
+	sql.SQLTemplateFuncs(&tplFuncMap)
+	tpl, err := template.New("").Funcs(tplFuncMap).Parse(text)
+	if err != nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err = tpl.Execute(buf, evt); err != nil {
+		return
+	}
+	ret = buf.String()
+	return
+}
+
+func postAutomationPayload(url string, payload interface{}) (err error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return errors.New("invalid url [" + url + "]")
+	}
+
+	resp, err := httpclient.NewBrowserRequest().SetBodyJsonMarshal(payload).Post(url)
+	if err != nil {
+		return
+	}
+	if 200 > resp.StatusCode || 300 <= resp.StatusCode {
+		err = errors.New("post to [" + url + "] failed: " + resp.Status)
+	}
+	return
+}
+
+func getAutomationRule(id string) *conf.AutomationRule {
+	for _, rule := range Conf.Automation.Rules {
+		if id == rule.ID {
+			return rule
+		}
+	}
+	return nil
+}
+
+var automationTriggerTypes = []string{"cron", "attr", "doc", "av"}
+var automationActionTypes = []string{"createDoc", "appendDailyNote", "setAttrs", "moveDoc", "query", "webhook"}
+
+// SetAutomation 校验并保存自动化配置。
+func SetAutomation(automation *conf.Automation) (err error) {
+	if nil == automation.Rules {
+		automation.Rules = []*conf.AutomationRule{}
+	}
+
+	for _, rule := range automation.Rules {
+		if "" == rule.ID {
+			rule.ID = ast.NewNodeID()
+		}
+		if nil == rule.Trigger || !gulu.Str.Contains(rule.Trigger.Type, automationTriggerTypes) {
+			return errors.New("invalid trigger of automation [" + rule.Name + "]")
+		}
+		switch rule.Trigger.Type {
+		case "cron":
+			if _, err = parseCron(rule.Trigger.Cron); err != nil {
+				return errors.New("invalid cron of automation [" + rule.Name + "]: " + err.Error())
+			}
+		case "attr":
+			if "" == rule.Trigger.Name {
+				return errors.New("invalid attribute name of automation [" + rule.Name + "]")
+			}
+		case "av":
+			if "" == rule.Trigger.AvID || "" == rule.Trigger.KeyID {
+				return errors.New("invalid database field of automation [" + rule.Name + "]")
+			}
+		}
+		for _, action := range rule.Actions {
+			if !gulu.Str.Contains(action.Type, automationActionTypes) {
+				return errors.New("invalid action [" + action.Type + "] of automation [" + rule.Name + "]")
+			}
+		}
+	}
+
+	Conf.Automation = automation
+	Conf.Save()
+	return
+}
+
+func GetAutomationRuns(ruleID string) (ret []*AutomationRun) {
+	ret = []*AutomationRun{}
+	if !ast.IsNodeIDPattern(ruleID) {
+		return
+	}
+
+	automationRunsLock.Lock()
+	defer automationRunsLock.Unlock()
+	return getAutomationRuns(ruleID)
+}
+
+// getAutomationRuns 读取运行日志，调用方需要持有 automationRunsLock。
+func getAutomationRuns(ruleID string) (ret []*AutomationRun) {
+	ret = []*AutomationRun{}
+	data, err := os.ReadFile(automationRunsPath(ruleID))
+	if err != nil {
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal automation runs failed: %s", err)
+		ret = []*AutomationRun{}
+	}
+	return
+}
+
+func appendAutomationRun(run *AutomationRun) {
+	// 读取和写入需要在同一次加锁中完成，否则并发执行的运行日志会互相覆盖
+	automationRunsLock.Lock()
+	defer automationRunsLock.Unlock()
+
+	runs := getAutomationRuns(run.RuleID)
+	runs = append([]*AutomationRun{run}, runs...)
+	if automationMaxRuns < len(runs) {
+		runs = runs[:automationMaxRuns]
+	}
+
+	p := automationRunsPath(run.RuleID)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		logging.LogErrorf("create automation runs dir failed: %s", err)
+		return
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(runs, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal automation runs failed: %s", err)
+		return
+	}
+	if err = gulu.File.WriteFileSafer(p, data, 0644); err != nil {
+		logging.LogErrorf("write automation runs failed: %s", err)
+	}
+}
+
+func automationRunsPath(ruleID string) string {
+	return filepath.Join(util.ConfDir, "automation", ruleID+".json")
+}
+
+// cronSchedule 为标准的五段式定时表达式：分 时 日 月 周，支持 *、列表、范围和步长。
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (ret *cronSchedule, err error) {
+	fields := strings.Fields(expr)
+	if 5 != len(fields) {
+		err = errors.New("cron expression must have 5 fields")
+		return
+	}
+
+	ret = &cronSchedule{domAny: "*" == fields[2], dowAny: "*" == fields[4]}
+	bounds := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	masks := []*uint64{&ret.minute, &ret.hour, &ret.dom, &ret.month, &ret.dow}
+	for i, field := range fields {
+		if *masks[i], err = parseCronField(field, bounds[i][0], bounds[i][1]); err != nil {
+			return
+		}
+	}
+	if 0 != ret.dow&(1<<7) { // 7 和 0 都表示周日
+		ret.dow |= 1
+	}
+	return
+}
+
+func parseCronField(field string, min, max int) (ret uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); 0 <= idx {
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || 1 > step {
+				return 0, errors.New("invalid step [" + part + "]")
+			}
+			part = part[:idx]
+		}
+
+		from, to := min, max
+		if "*" != part {
+			bounds := strings.SplitN(part, "-", 2)
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.New("invalid value [" + part + "]")
+			}
+			to = from
+			if 2 == len(bounds) {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.New("invalid value [" + part + "]")
+				}
+			} else if 1 < step {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, errors.New("value out of range [" + part + "]")
+		}
+
+		for i := from; i <= to; i += step {
+			ret |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func (s *cronSchedule) match(t time.Time) bool {
+	if 0 == s.minute&(1<<uint(t.Minute())) || 0 == s.hour&(1<<uint(t.Hour())) || 0 == s.month&(1<<uint(t.Month())) {
+		return false
+	}
+
+	domMatch := 0 != s.dom&(1<<uint(t.Day()))
+	dowMatch := 0 != s.dow&(1<<uint(t.Weekday()))
+	if s.domAny || s.dowAny {
+		// 日和周只限制了其中一个时两者都需要满足
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
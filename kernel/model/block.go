@@ -81,6 +81,9 @@ type RiffCard struct {
 	Lapses     uint64     `json:"lapses"`
 	State      fsrs.State `json:"state"`
 	LastReview time.Time  `json:"lastReview"`
+	Type       string     `json:"type"`
+	Key        string     `json:"key"`
+	Orphaned   bool       `json:"orphaned"`
 }
 
 func (block *Block) IsContainerBlock() bool {
@@ -395,7 +398,7 @@ func IsBlockFolded(id string) (isFolded, isRoot bool) {
 	return
 }
 
-func RecentUpdatedBlocks() (ret []*Block) {
+func RecentUpdatedBlocks(filter *ReadableFilter) (ret []*Block) {
 	ret = []*Block{}
 
 	sqlStmt := "SELECT * FROM blocks WHERE type = 'p' AND length > 1"
@@ -412,6 +415,7 @@ func RecentUpdatedBlocks() (ret []*Block) {
 		}
 		sqlStmt += buf.String()
 	}
+	sqlStmt += filter.sqlCondition("path")
 
 	sqlStmt += " ORDER BY updated DESC"
 	sqlBlocks := sql.SelectBlocksRawStmt(sqlStmt, 1, 16)
@@ -857,17 +861,18 @@ func GetBlockDOMs(ids []string) (ret map[string]string) {
 	return
 }
 
-func GetBlockDOMWithEmbed(id string) (ret string) {
+func GetBlockDOMWithEmbed(id string, filter *ReadableFilter) (ret string) {
 	if "" == id {
 		return
 	}
 
-	doms := GetBlockDOMsWithEmbed([]string{id})
+	doms := GetBlockDOMsWithEmbed([]string{id}, filter)
 	ret = doms[id]
 	return
 }
 
-func GetBlockDOMsWithEmbed(ids []string) (ret map[string]string) {
+// GetBlockDOMsWithEmbed 获取块的 DOM 并展开其中的嵌入块，filter 为受限请求的读取过滤器，为空时不过滤。
+func GetBlockDOMsWithEmbed(ids []string, filter *ReadableFilter) (ret map[string]string) {
 	ret = map[string]string{}
 	if 0 == len(ids) {
 		return
@@ -881,7 +886,7 @@ func GetBlockDOMsWithEmbed(ids []string) (ret map[string]string) {
 			continue
 		}
 
-		resolveEmbedContent(node, luteEngine)
+		resolveEmbedContent(node, luteEngine, filter)
 
 		// 处理折叠标题
 		ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
@@ -904,7 +909,7 @@ func GetBlockDOMsWithEmbed(ids []string) (ret map[string]string) {
 	return
 }
 
-func resolveEmbedContent(n *ast.Node, luteEngine *lute.Lute) {
+func resolveEmbedContent(n *ast.Node, luteEngine *lute.Lute, filter *ReadableFilter) {
 	ast.Walk(n, func(node *ast.Node, entering bool) ast.WalkStatus {
 		if !entering || ast.NodeBlockQueryEmbed != node.Type {
 			return ast.WalkContinue
@@ -920,7 +925,12 @@ func resolveEmbedContent(n *ast.Node, luteEngine *lute.Lute) {
 		stmt = strings.ReplaceAll(stmt, editor.IALValEscNewLine, "\n")
 
 		// 执行查询获取嵌入的块
-		sqlBlocks := sql.SelectBlocksRawStmt(stmt, 1, Conf.Search.Limit)
+		var sqlBlocks []*sql.Block
+		if nil != filter {
+			sqlBlocks = filter.selectBlocksRawStmt(stmt, 1, Conf.Search.Limit)
+		} else {
+			sqlBlocks = sql.SelectBlocksRawStmt(stmt, 1, Conf.Search.Limit)
+		}
 
 		// 收集所有嵌入块的内容 HTML
 		var embedContents []string
@@ -261,6 +261,12 @@ func setNodeAttrs0(node *ast.Node, nameValues map[string]string) (oldAttrs map[s
 	if oldAttrs["tags"] != newAttrs["tags"] {
 		ReloadTag()
 	}
+
+	fireAttrAutomations(node.ID, oldAttrs, newAttrs)
+
+	if oldAttrs[NodeAttrRiffOcclusions] != newAttrs[NodeAttrRiffOcclusions] {
+		refreshBlockFlashcards(node)
+	}
 	return
 }
 
@@ -152,12 +152,22 @@ func (s Bookmarks) Len() int           { return len(s) }
 func (s Bookmarks) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s Bookmarks) Less(i, j int) bool { return s[i].Name < s[j].Name }
 
-func BookmarkLabels() (ret []string) {
-	ret = sql.QueryBookmarkLabels()
+// BookmarkLabels 获取书签名称，filter 为受限请求的读取过滤器，为空时不过滤。
+func BookmarkLabels(filter *ReadableFilter) (ret []string) {
+	if nil == filter {
+		ret = sql.QueryBookmarkLabels()
+		return
+	}
+
+	ret = []string{}
+	for _, bookmark := range *BuildBookmark(filter) {
+		ret = append(ret, string(bookmark.Name))
+	}
 	return
 }
 
-func BuildBookmark() (ret *Bookmarks) {
+// BuildBookmark 构建书签面板，filter 为受限请求的读取过滤器，为空时不过滤。
+func BuildBookmark(filter *ReadableFilter) (ret *Bookmarks) {
 	FlushTxQueue()
 	sql.FlushQueue()
 
@@ -168,6 +178,10 @@ func BuildBookmark() (ret *Bookmarks) {
 	blocks := fromSQLBlocks(&sqlBlocks, "", 0)
 	luteEngine := NewLute()
 	for _, block := range blocks {
+		if !filter.CanRead(block.Box, block.Path) {
+			continue
+		}
+
 		if "" != block.Name {
 			// Blocks in the bookmark panel display their name instead of content https://github.com/siyuan-note/siyuan/issues/8514
 			block.Content = block.Name
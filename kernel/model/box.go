@@ -831,6 +831,7 @@ func fullReindex() {
 	for _, openedBox := range openedBoxes {
 		indexBox(openedBox.ID)
 	}
+	indexAttributeViews()
 	LoadFlashcards()
 	debug.FreeOSMemory()
 }
@@ -667,6 +667,10 @@ func (b *CalDavBackend) CalendarHomeSetPath(ctx context.Context) (string, error)
 func (b *CalDavBackend) CreateCalendar(ctx context.Context, calendar *caldav.Calendar) (err error) {
 	// logging.LogDebugf("CalDAV CreateCalendar -> calendar: %#v", calendar)
 	calendar.Path = PathCleanWithSlash(calendar.Path)
+	if isNotebookCalendarPath(calendar.Path) {
+		err = ErrorCalDavCalendarPathInvalid
+		return
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -684,6 +688,10 @@ func (b *CalDavBackend) ListCalendars(ctx context.Context) (calendars_ []caldav.
 	}
 
 	calendars_, err = calendars.ListCalendars()
+	if err != nil {
+		return
+	}
+	calendars_ = append(calendars_, listNotebookCalendars()...)
 	// logging.LogDebugf("CalDAV ListCalendars <- calendars: %#v, err: %s", calendars_, err)
 	return
 }
@@ -691,6 +699,9 @@ func (b *CalDavBackend) ListCalendars(ctx context.Context) (calendars_ []caldav.
 func (b *CalDavBackend) GetCalendar(ctx context.Context, calendarPath string) (calendar *caldav.Calendar, err error) {
 	// logging.LogDebugf("CalDAV GetCalendar -> calendarPath: %s", calendarPath)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if isNotebookCalendarPath(calendarPath) {
+		return getNotebookCalendar(calendarPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -704,6 +715,10 @@ func (b *CalDavBackend) GetCalendar(ctx context.Context, calendarPath string) (c
 func (b *CalDavBackend) DeleteCalendar(ctx context.Context, calendarPath string) (err error) {
 	// logging.LogDebugf("CalDAV DeleteCalendar -> calendarPath: %s", calendarPath)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if isNotebookCalendarPath(calendarPath) {
+		err = ErrorCalDavCalendarPathInvalid
+		return
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -717,6 +732,9 @@ func (b *CalDavBackend) DeleteCalendar(ctx context.Context, calendarPath string)
 func (b *CalDavBackend) PutCalendarObject(ctx context.Context, objectPath string, calendar *ical.Calendar, opts *caldav.PutCalendarObjectOptions) (calendarObject *caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV PutCalendarObject -> objectPath: %s, opts: %#v", objectPath, opts)
 	objectPath = PathCleanWithSlash(objectPath)
+	if isNotebookCalendarPath(path.Dir(objectPath)) {
+		return putNotebookCalendarObject(objectPath, calendar)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -730,6 +748,9 @@ func (b *CalDavBackend) PutCalendarObject(ctx context.Context, objectPath string
 func (b *CalDavBackend) ListCalendarObjects(ctx context.Context, calendarPath string, req *caldav.CalendarCompRequest) (calendarObjects []caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV ListCalendarObjects -> calendarPath: %s, req: %#v", calendarPath, req)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if isNotebookCalendarPath(calendarPath) {
+		return listNotebookCalendarObjects(calendarPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -743,6 +764,9 @@ func (b *CalDavBackend) ListCalendarObjects(ctx context.Context, calendarPath st
 func (b *CalDavBackend) GetCalendarObject(ctx context.Context, objectPath string, req *caldav.CalendarCompRequest) (calendarObject *caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV GetCalendarObject -> objectPath: %s, req: %#v", objectPath, req)
 	objectPath = PathCleanWithSlash(objectPath)
+	if isNotebookCalendarPath(path.Dir(objectPath)) {
+		return getNotebookCalendarObject(objectPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -756,6 +780,9 @@ func (b *CalDavBackend) GetCalendarObject(ctx context.Context, objectPath string
 func (b *CalDavBackend) QueryCalendarObjects(ctx context.Context, calendarPath string, query *caldav.CalendarQuery) (calendarObjects []caldav.CalendarObject, err error) {
 	// logging.LogDebugf("CalDAV QueryCalendarObjects -> calendarPath: %s, query: %#v", calendarPath, query)
 	calendarPath = PathCleanWithSlash(calendarPath)
+	if isNotebookCalendarPath(calendarPath) {
+		return queryNotebookCalendarObjects(calendarPath, query)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
@@ -769,6 +796,9 @@ func (b *CalDavBackend) QueryCalendarObjects(ctx context.Context, calendarPath s
 func (b *CalDavBackend) DeleteCalendarObject(ctx context.Context, objectPath string) (err error) {
 	// logging.LogDebugf("CalDAV DeleteCalendarObject -> objectPath: %s", objectPath)
 	objectPath = PathCleanWithSlash(objectPath)
+	if isNotebookCalendarPath(path.Dir(objectPath)) {
+		return deleteNotebookCalendarObject(objectPath)
+	}
 
 	if err = calendars.Load(); err != nil {
 		return
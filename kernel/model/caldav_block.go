@@ -0,0 +1,541 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/araddon/dateparse"
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 笔记本虚拟日历：每个已打开的笔记本对应一个日历，设置了提醒的块映射为 VEVENT，设置了提醒的任务列表项映射为 VTODO，提醒时间作为截止时间
+// 内核提醒优先于云端提醒，重复规则映射为 RRULE，并附带一个在提醒时间触发的 VALARM
+
+const (
+	CalDavNotebookCalendarPathPrefix = CalDavHomeSetPath + "/notebook-"
+
+	NodeAttrReminder = "custom-reminder-wechat" // 块提醒时间
+
+	calDavBlockProductID = "-//SiYuan//Notebook Calendar//EN"
+	calDavBlockMaxCount  = 10240
+
+	calDavToDoCompleted   = "COMPLETED"
+	calDavToDoNeedsAction = "NEEDS-ACTION"
+)
+
+var (
+	ErrorCalDavBlockObjectCreateUnsupported = errors.New("CalDAV: creating objects in notebook calendars is not supported")
+
+	checkedTaskMarkdownRegexp = regexp.MustCompile(`^\s*(?:[*+-]|\d+[.)])\s+\[[xX]\]`)
+)
+
+// isNotebookCalendarPath 判断日历路径是否为笔记本虚拟日历。
+func isNotebookCalendarPath(calendarPath string) bool {
+	return strings.HasPrefix(calendarPath, CalDavNotebookCalendarPathPrefix) && calDavPathDepth_Calendar == GetCalDavPathDepth(calendarPath)
+}
+
+func notebookCalendarPath(boxID string) string {
+	return CalDavNotebookCalendarPathPrefix + boxID
+}
+
+func notebookCalendarBox(calendarPath string) (box *Box, err error) {
+	boxID := strings.TrimPrefix(calendarPath, CalDavNotebookCalendarPathPrefix)
+	box = Conf.Box(boxID)
+	if nil == box {
+		err = ErrorCalDavCalendarNotFound
+	}
+	return
+}
+
+func notebookCalendar(box *Box) *caldav.Calendar {
+	return &caldav.Calendar{
+		Path:                  notebookCalendarPath(box.ID),
+		Name:                  box.Name,
+		Description:           "SiYuan notebook " + box.Name,
+		MaxResourceSize:       calendarMaxResourceSize,
+		SupportedComponentSet: calendarSupportedComponentSet,
+	}
+}
+
+func listNotebookCalendars() (ret []caldav.Calendar) {
+	for _, box := range Conf.GetOpenedBoxes() {
+		ret = append(ret, *notebookCalendar(box))
+	}
+	return
+}
+
+func getNotebookCalendar(calendarPath string) (calendar *caldav.Calendar, err error) {
+	box, err := notebookCalendarBox(calendarPath)
+	if err != nil {
+		return
+	}
+	calendar = notebookCalendar(box)
+	return
+}
+
+func listNotebookCalendarObjects(calendarPath string) (calendarObjects []caldav.CalendarObject, err error) {
+	box, err := notebookCalendarBox(calendarPath)
+	if err != nil {
+		return
+	}
+
+	FlushTxQueue()
+	sql.FlushQueue()
+	reminders := getReminderMap()
+	var reminderIDs []string
+	for id := range reminders {
+		if ast.IsNodeIDPattern(id) {
+			reminderIDs = append(reminderIDs, "'"+id+"'")
+		}
+	}
+	stmt := "SELECT * FROM blocks WHERE box = '" + box.ID + "' AND (ial LIKE '%" + NodeAttrReminder + "=%'"
+	if 0 < len(reminderIDs) {
+		stmt += " OR id IN (" + strings.Join(reminderIDs, ",") + ")"
+	}
+	stmt += ")"
+	blocks := sql.SelectBlocksRawStmt(stmt, 1, calDavBlockMaxCount)
+	var ids []string
+	for _, block := range blocks {
+		ids = append(ids, block.ID)
+	}
+	attrs := sql.BatchGetBlockAttrs(ids)
+	for _, block := range blocks {
+		calendarObject := blockCalendarObject(calendarPath, block, attrs[block.ID], reminders[block.ID])
+		if nil == calendarObject {
+			continue
+		}
+		calendarObjects = append(calendarObjects, *calendarObject)
+	}
+	return
+}
+
+func getNotebookCalendarObject(objectPath string) (calendarObject *caldav.CalendarObject, err error) {
+	calendarPath, block, err := getNotebookCalendarObjectBlock(objectPath)
+	if err != nil {
+		return
+	}
+
+	calendarObject = blockCalendarObject(calendarPath, block, sql.GetBlockAttrs(block.ID), GetReminder(block.ID))
+	if nil == calendarObject {
+		err = ErrorCalDavCalendarObjectNotFound
+	}
+	return
+}
+
+func queryNotebookCalendarObjects(calendarPath string, query *caldav.CalendarQuery) (calendarObjects []caldav.CalendarObject, err error) {
+	calendarObjects, err = listNotebookCalendarObjects(calendarPath)
+	if err != nil {
+		return
+	}
+
+	calendarObjects, err = caldav.Filter(query, calendarObjects)
+	return
+}
+
+// putNotebookCalendarObject 将客户端对 VTODO/VEVENT 的修改写回块。
+func putNotebookCalendarObject(objectPath string, calendarData *ical.Calendar) (calendarObject *caldav.CalendarObject, err error) {
+	_, block, err := getNotebookCalendarObjectBlock(objectPath)
+	if err != nil {
+		if errors.Is(err, ErrorCalDavCalendarObjectNotFound) {
+			err = ErrorCalDavBlockObjectCreateUnsupported
+		}
+		return
+	}
+
+	for _, comp := range calendarData.Children {
+		switch comp.Name {
+		case ical.CompToDo:
+			if !isTaskListItemBlock(block) {
+				continue
+			}
+
+			checked := nil != comp.Props.Get(ical.PropCompleted)
+			if prop := comp.Props.Get(ical.PropStatus); nil != prop {
+				checked = calDavToDoCompleted == strings.ToUpper(prop.Value)
+			}
+			if checked != isTaskListItemChecked(block) {
+				if err = setTaskListItemChecked(block.ID, checked); err != nil {
+					return
+				}
+			}
+
+			due := ""
+			if prop := comp.Props.Get(ical.PropDue); nil != prop {
+				due = formatCalDavBlockTime(prop)
+			}
+			if err = putTaskCalendarDue(block.ID, due); err != nil {
+				return
+			}
+		case ical.CompEvent:
+			if reminder := GetReminder(block.ID); nil != reminder {
+				if err = putReminderCalendarEvent(reminder, comp); err != nil {
+					return
+				}
+				continue
+			}
+
+			timed := "0"
+			if prop := comp.Props.Get(ical.PropDateTimeStart); nil != prop {
+				timed = formatCalDavBlockTime(prop)
+			}
+			if !sameCalDavBlockTime(timed, sql.GetBlockAttrs(block.ID)[NodeAttrReminder]) {
+				if err = SetBlockReminder(block.ID, timed); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	calendarObject, err = getNotebookCalendarObject(objectPath)
+	return
+}
+
+// deleteNotebookCalendarObject 取消块提醒，不会删除块本身。
+func deleteNotebookCalendarObject(objectPath string) (err error) {
+	_, block, err := getNotebookCalendarObjectBlock(objectPath)
+	if err != nil {
+		return
+	}
+
+	if nil != GetReminder(block.ID) {
+		return RemoveReminder(block.ID)
+	}
+	if "" != sql.GetBlockAttrs(block.ID)[NodeAttrReminder] {
+		return SetBlockReminder(block.ID, "0")
+	}
+	return
+}
+
+func getNotebookCalendarObjectBlock(objectPath string) (calendarPath string, block *sql.Block, err error) {
+	calendarPath, objectFileName := path.Split(objectPath)
+	calendarPath = PathCleanWithSlash(calendarPath)
+	if util.Ext(objectFileName) != ICalendarFileExt {
+		err = ErrorCalDavCalendarObjectPathInvalid
+		return
+	}
+
+	box, err := notebookCalendarBox(calendarPath)
+	if err != nil {
+		return
+	}
+
+	FlushTxQueue()
+	sql.FlushQueue()
+	id := strings.TrimSuffix(objectFileName, ICalendarFileExt)
+	block = sql.GetBlock(id)
+	if nil == block || block.Box != box.ID {
+		block = nil
+		err = ErrorCalDavCalendarObjectNotFound
+	}
+	return
+}
+
+// blockCalendarObject 将块转换为日历对象，存在提醒的任务列表项使用 VTODO，提醒时间作为任务的日期（截止时间），其他存在提醒的块使用 VEVENT。
+func blockCalendarObject(calendarPath string, block *sql.Block, attrs map[string]string, reminder *Reminder) (ret *caldav.CalendarObject) {
+	updated, err := time.ParseInLocation("20060102150405", block.Updated, time.Local)
+	if err != nil {
+		updated = time.Now()
+	}
+	// 修改内核提醒不会更新块，修改时间取块和提醒中较晚的一个
+	modTime := updated
+	if nil != reminder {
+		if reminderUpdated := time.UnixMilli(reminder.Updated); reminderUpdated.After(modTime) {
+			modTime = reminderUpdated
+		}
+	}
+
+	summary := block.Content
+	if "" == summary {
+		summary = block.FContent
+	}
+	summary = gulu.Str.SubStr(summary, 128)
+
+	if nil != reminder && "" != reminder.Content {
+		summary = gulu.Str.SubStr(reminder.Content, 128)
+	}
+
+	var comp *ical.Component
+	if timed := attrs[NodeAttrReminder]; isTaskListItemBlock(block) && (nil != reminder || "" != timed) {
+		// 任务列表项的提醒时间作为截止时间
+		comp = ical.NewComponent(ical.CompToDo)
+		if nil != reminder {
+			due := calDavLocalTime(time.UnixMilli(reminder.Start))
+			comp.Props.SetDateTime(ical.PropDue, due)
+			if "" != reminder.RRule {
+				// 重复的待办需要 DTSTART
+				comp.Props.SetDateTime(ical.PropDateTimeStart, due)
+				setCalDavRRule(comp, reminder.RRule)
+			}
+			appendCalDavAlarm(comp, summary)
+		} else if !setCalDavBlockTime(comp.Props, ical.PropDue, timed) {
+			return
+		}
+		if isTaskListItemChecked(block) {
+			comp.Props.SetText(ical.PropStatus, calDavToDoCompleted)
+			comp.Props.SetDateTime(ical.PropCompleted, updated.UTC())
+		} else {
+			comp.Props.SetText(ical.PropStatus, calDavToDoNeedsAction)
+		}
+	} else if nil != reminder {
+		comp = ical.NewComponent(ical.CompEvent)
+		// 重复提醒需要按照本地时区展开，使用 UTC 时跨越夏令时后的提醒时间会偏移一小时
+		comp.Props.SetDateTime(ical.PropDateTimeStart, calDavLocalTime(time.UnixMilli(reminder.Start)))
+		if "" != reminder.RRule {
+			setCalDavRRule(comp, reminder.RRule)
+		}
+		appendCalDavAlarm(comp, summary)
+	} else if timed := attrs[NodeAttrReminder]; "" != timed {
+		comp = ical.NewComponent(ical.CompEvent)
+		if !setCalDavBlockTime(comp.Props, ical.PropDateTimeStart, timed) {
+			return
+		}
+	} else {
+		return
+	}
+
+	comp.Props.SetText(ical.PropUID, block.ID)
+	comp.Props.SetDateTime(ical.PropDateTimeStamp, modTime.UTC())
+	comp.Props.SetDateTime(ical.PropLastModified, modTime.UTC())
+	comp.Props.SetText(ical.PropSummary, summary)
+	if "" != block.HPath {
+		comp.Props.SetText(ical.PropDescription, block.HPath)
+	}
+	comp.Props.SetURI(ical.PropURL, &url.URL{Scheme: "siyuan", Host: "blocks", Path: "/" + block.ID})
+
+	data := ical.NewCalendar()
+	data.Props.SetText(ical.PropProductID, calDavBlockProductID)
+	data.Props.SetText(ical.PropVersion, "2.0")
+	data.Children = append(data.Children, comp)
+
+	buf := bytes.Buffer{}
+	if err = ical.NewEncoder(&buf).Encode(data); err != nil {
+		logging.LogErrorf("encode block [%s] calendar object failed: %s", block.ID, err)
+		return
+	}
+
+	ret = &caldav.CalendarObject{
+		Path:          PathJoinWithSlash(calendarPath, block.ID+ICalendarFileExt),
+		ModTime:       modTime,
+		ContentLength: int64(buf.Len()),
+		ETag:          fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())),
+		Data:          data,
+	}
+	return
+}
+
+func setCalDavRRule(comp *ical.Component, rule string) {
+	// RRULE 的值不能按照文本转义
+	prop := ical.NewProp(ical.PropRecurrenceRule)
+	prop.Value = rule
+	comp.Props.Set(prop)
+}
+
+// appendCalDavAlarm 添加一个在提醒时间触发的 VALARM。
+func appendCalDavAlarm(comp *ical.Component, summary string) {
+	alarm := ical.NewComponent(ical.CompAlarm)
+	alarm.Props.SetText(ical.PropAction, "DISPLAY")
+	trigger := ical.NewProp(ical.PropTrigger)
+	trigger.Value = "PT0S"
+	alarm.Props.Set(trigger)
+	alarm.Props.SetText(ical.PropDescription, summary)
+	comp.Children = append(comp.Children, alarm)
+}
+
+func setCalDavBlockTime(props ical.Props, name, value string) bool {
+	t, err := dateparse.ParseIn(value, time.Now().Location())
+	if err != nil {
+		logging.LogWarnf("parse block time [%s] failed: %s", value, err)
+		return false
+	}
+
+	if 0 == t.Hour() && 0 == t.Minute() && 0 == t.Second() && 10 >= len(strings.TrimSpace(value)) {
+		props.SetDate(name, t)
+	} else {
+		props.SetDateTime(name, calDavLocalTime(t))
+	}
+	return true
+}
+
+// calDavLocalTime 将时间转换到本地时区，编码时带有 TZID 参数。无法确定本地时区的 IANA 名称时使用 UTC。
+// TZID 使用 IANA 时区名称，客户端按照名称解析时区（RFC 7809），不再附带 VTIMEZONE。
+func calDavLocalTime(t time.Time) time.Time {
+	if loc := calDavTimezone(); nil != loc {
+		return t.In(loc)
+	}
+	return t.UTC()
+}
+
+var calDavTimezone = sync.OnceValue(func() *time.Location {
+	// 移动端启动时通过 SetTimezone 设置了带有名称的本地时区
+	names := []string{time.Local.String(), strings.TrimPrefix(os.Getenv("TZ"), ":")}
+	if localtime, err := filepath.EvalSymlinks("/etc/localtime"); nil == err {
+		if _, name, found := strings.Cut(localtime, "zoneinfo/"); found {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		if "" == name || "Local" == name || "UTC" == name {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); nil == err {
+			return loc
+		}
+	}
+	return nil
+})
+
+func formatCalDavBlockTime(prop *ical.Prop) string {
+	t, err := prop.DateTime(time.Now().Location())
+	if err != nil {
+		logging.LogWarnf("parse calendar time [%s] failed: %s", prop.Value, err)
+		return ""
+	}
+
+	if ical.ValueDate == prop.ValueType() {
+		return t.Format("2006-01-02")
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func sameCalDavBlockTime(t1, t2 string) bool {
+	if t1 == t2 {
+		return true
+	}
+
+	parsed1, err1 := dateparse.ParseIn(t1, time.Now().Location())
+	parsed2, err2 := dateparse.ParseIn(t2, time.Now().Location())
+	return nil == err1 && nil == err2 && parsed1.Equal(parsed2)
+}
+
+func isTaskListItemBlock(block *sql.Block) bool {
+	return "i" == block.Type && "t" == block.SubType
+}
+
+func isTaskListItemChecked(block *sql.Block) bool {
+	return checkedTaskMarkdownRegexp.MatchString(block.Markdown)
+}
+
+// setTaskListItemChecked 通过事务勾选或者取消勾选任务列表项。
+func setTaskListItemChecked(id string, checked bool) (err error) {
+	FlushTxQueue()
+
+	tree, err := LoadTreeByBlockID(id)
+	if err != nil {
+		return
+	}
+
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node || ast.NodeListItem != node.Type || nil == node.ListData || 3 != node.ListData.Typ {
+		return errors.New(fmt.Sprintf(Conf.Language(15), id))
+	}
+
+	luteEngine := util.NewLute()
+	undoData := luteEngine.RenderNodeBlockDOM(node)
+	node.ListData.Checked = checked
+	for c := node.FirstChild; nil != c; c = c.Next {
+		if ast.NodeTaskListItemMarker == c.Type {
+			c.TaskListItemChecked = checked
+			break
+		}
+		if ast.NodeParagraph == c.Type && nil != c.FirstChild && ast.NodeTaskListItemMarker == c.FirstChild.Type {
+			c.FirstChild.TaskListItemChecked = checked
+			break
+		}
+	}
+	data := luteEngine.RenderNodeBlockDOM(node)
+
+	transactions := []*Transaction{{
+		DoOperations:   []*Operation{{Action: "update", ID: id, Data: data}},
+		UndoOperations: []*Operation{{Action: "update", ID: id, Data: undoData}},
+	}}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+	return
+}
+
+// putTaskCalendarDue 将客户端对 VTODO 截止时间的修改写回块提醒，截止时间被删除时取消提醒。
+// 没有提醒的任务列表项使用内核提醒，不需要订阅云端服务。
+func putTaskCalendarDue(blockID, due string) (err error) {
+	if reminder := GetReminder(blockID); nil != reminder {
+		if "" == due {
+			return RemoveReminder(blockID)
+		}
+		if !sameCalDavBlockTime(due, time.UnixMilli(reminder.Start).Format("2006-01-02 15:04:05")) {
+			_, err = SetReminder(blockID, reminder.Content, due, reminder.RRule)
+		}
+		return
+	}
+
+	if timed := sql.GetBlockAttrs(blockID)[NodeAttrReminder]; "" != timed {
+		if "" == due {
+			due = "0"
+		}
+		if !sameCalDavBlockTime(due, timed) {
+			err = SetBlockReminder(blockID, due)
+		}
+		return
+	}
+
+	if "" != due {
+		_, err = SetReminder(blockID, "", due, "")
+	}
+	return
+}
+
+// putReminderCalendarEvent 将客户端对 VEVENT 开始时间和重复规则的修改写回内核提醒，开始时间被删除时取消提醒。
+func putReminderCalendarEvent(reminder *Reminder, comp *ical.Component) (err error) {
+	prop := comp.Props.Get(ical.PropDateTimeStart)
+	if nil == prop {
+		return RemoveReminder(reminder.BlockID)
+	}
+
+	start := formatCalDavBlockTime(prop)
+	rule := ""
+	if rruleProp := comp.Props.Get(ical.PropRecurrenceRule); nil != rruleProp {
+		rule = rruleProp.Value
+	}
+	if sameCalDavBlockTime(start, time.UnixMilli(reminder.Start).Format("2006-01-02 15:04:05")) && rule == reminder.RRule {
+		return
+	}
+
+	_, err = SetReminder(reminder.BlockID, reminder.Content, start, rule)
+	return
+}
@@ -802,6 +802,10 @@ func (b *CardDavBackend) ListAddressBooks(ctx context.Context) (addressBooks []c
 	}
 
 	addressBooks, err = contacts.ListAddressBooks()
+	if err != nil {
+		return
+	}
+	addressBooks = append(addressBooks, listAttributeViewAddressBooks()...)
 	// logging.LogDebugf("CardDAV ListAddressBooks <- addressBooks: %#v, err: %s", addressBooks, err)
 	return
 }
@@ -809,6 +813,9 @@ func (b *CardDavBackend) ListAddressBooks(ctx context.Context) (addressBooks []c
 func (b *CardDavBackend) GetAddressBook(ctx context.Context, bookPath string) (addressBook *carddav.AddressBook, err error) {
 	// logging.LogDebugf("CardDAV GetAddressBook -> bookPath: %s", bookPath)
 	bookPath = PathCleanWithSlash(bookPath)
+	if isAttributeViewBookPath(bookPath) {
+		return getAttributeViewAddressBook(bookPath)
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
@@ -822,6 +829,10 @@ func (b *CardDavBackend) GetAddressBook(ctx context.Context, bookPath string) (a
 func (b *CardDavBackend) CreateAddressBook(ctx context.Context, addressBook *carddav.AddressBook) (err error) {
 	// logging.LogDebugf("CardDAV CreateAddressBook -> addressBook: %#v", addressBook)
 	addressBook.Path = PathCleanWithSlash(addressBook.Path)
+	if isAttributeViewBookPath(addressBook.Path) {
+		err = ErrorCardDavBookPathInvalid
+		return
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
@@ -835,6 +846,9 @@ func (b *CardDavBackend) CreateAddressBook(ctx context.Context, addressBook *car
 func (b *CardDavBackend) DeleteAddressBook(ctx context.Context, bookPath string) (err error) {
 	// logging.LogDebugf("CardDAV DeleteAddressBook -> bookPath: %s", bookPath)
 	bookPath = PathCleanWithSlash(bookPath)
+	if isAttributeViewBookPath(bookPath) {
+		return UnbindAttributeViewAddressBook(strings.TrimPrefix(bookPath, CardDavAttributeViewBookPathPrefix))
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
@@ -848,6 +862,9 @@ func (b *CardDavBackend) DeleteAddressBook(ctx context.Context, bookPath string)
 func (b *CardDavBackend) GetAddressObject(ctx context.Context, addressPath string, req *carddav.AddressDataRequest) (addressObject *carddav.AddressObject, err error) {
 	// logging.LogDebugf("CardDAV GetAddressObject -> addressPath: %s, req: %#v", addressPath, req)
 	addressPath = PathCleanWithSlash(addressPath)
+	if isAttributeViewBookPath(path.Dir(addressPath)) {
+		return getAttributeViewAddressObject(addressPath, req)
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
@@ -861,6 +878,9 @@ func (b *CardDavBackend) GetAddressObject(ctx context.Context, addressPath strin
 func (b *CardDavBackend) ListAddressObjects(ctx context.Context, bookPath string, req *carddav.AddressDataRequest) (addressObjects []carddav.AddressObject, err error) {
 	// logging.LogDebugf("CardDAV ListAddressObjects -> bookPath: %s, req: %#v", bookPath, req)
 	bookPath = PathCleanWithSlash(bookPath)
+	if isAttributeViewBookPath(bookPath) {
+		return listAttributeViewAddressObjects(bookPath, req)
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
@@ -874,12 +894,29 @@ func (b *CardDavBackend) ListAddressObjects(ctx context.Context, bookPath string
 func (b *CardDavBackend) QueryAddressObjects(ctx context.Context, urlPath string, query *carddav.AddressBookQuery) (addressObjects []carddav.AddressObject, err error) {
 	// logging.LogDebugf("CardDAV QueryAddressObjects -> urlPath: %s, query: %#v", urlPath, query)
 	urlPath = PathCleanWithSlash(urlPath)
+	switch GetCardDavPathDepth(urlPath) {
+	case cardDavPathDepth_AddressBook:
+		if isAttributeViewBookPath(urlPath) {
+			return queryAttributeViewAddressObjects(urlPath, query)
+		}
+	case cardDavPathDepth_Address:
+		if isAttributeViewBookPath(path.Dir(urlPath)) {
+			return queryAttributeViewAddressObjects(urlPath, query)
+		}
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
 	}
 
 	addressObjects, err = contacts.QueryAddressObjects(urlPath, query)
+	if err != nil {
+		return
+	}
+	if GetCardDavPathDepth(urlPath) <= cardDavPathDepth_HomeSet {
+		avAddressObjects, _ := queryAttributeViewAddressObjects(urlPath, query)
+		addressObjects = append(addressObjects, avAddressObjects...)
+	}
 	// logging.LogDebugf("CardDAV QueryAddressObjects <- addressObjects: %#v, err: %s", addressObjects, err)
 	return
 }
@@ -887,6 +924,9 @@ func (b *CardDavBackend) QueryAddressObjects(ctx context.Context, urlPath string
 func (b *CardDavBackend) PutAddressObject(ctx context.Context, addressPath string, card vcard.Card, opts *carddav.PutAddressObjectOptions) (addressObject *carddav.AddressObject, err error) {
 	// logging.LogDebugf("CardDAV PutAddressObject -> addressPath: %s, card: %#v, opts: %#v", addressPath, card, opts)
 	addressPath = PathCleanWithSlash(addressPath)
+	if isAttributeViewBookPath(path.Dir(addressPath)) {
+		return putAttributeViewAddressObject(addressPath, card)
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
@@ -900,6 +940,9 @@ func (b *CardDavBackend) PutAddressObject(ctx context.Context, addressPath strin
 func (b *CardDavBackend) DeleteAddressObject(ctx context.Context, addressPath string) (err error) {
 	// logging.LogDebugf("CardDAV DeleteAddressObject -> addressPath: %s", addressPath)
 	addressPath = PathCleanWithSlash(addressPath)
+	if isAttributeViewBookPath(path.Dir(addressPath)) {
+		return deleteAttributeViewAddressObject(addressPath)
+	}
 
 	if err = contacts.Load(); err != nil {
 		return
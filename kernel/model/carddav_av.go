@@ -0,0 +1,660 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/emersion/go-vcard"
+	"github.com/emersion/go-webdav/carddav"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+// 数据库通讯录：绑定到属性视图的通讯录，每一行对应一个 vCard，主键列映射为姓名，邮箱、电话和链接列分别映射为 EMAIL、TEL 和 URL
+
+const (
+	CardDavAttributeViewBookPathPrefix = CardDavHomeSetPath + "/av-"
+
+	CardDavAttributeViewBooksFilePath = CardDavHomeSetPath + "/attribute-views.json"
+
+	// vCard 字段参数，记录字段对应的属性视图列 ID，用于写回时按列映射
+	vCardParamAttributeViewKey = "X-SIYUAN-KEY"
+)
+
+var (
+	ErrorCardDavAttributeViewBookBound = errors.New("CardDAV: attribute view is already bound to an address book")
+
+	attributeViewBooks     []*AttributeViewAddressBook
+	attributeViewBooksLock = sync.Mutex{}
+
+	// 已加载的绑定关系文件路径和修改时间，切换工作空间或者同步后文件变化时重新加载
+	attributeViewBooksPath    string
+	attributeViewBooksModTime int64
+)
+
+// AttributeViewAddressBook 描述了通讯录与属性视图的绑定关系。
+type AttributeViewAddressBook struct {
+	AvID    string                      `json:"avID"`
+	Name    string                      `json:"name"`
+	Created int64                       `json:"created"`
+	Items   []*AttributeViewAddressItem `json:"items,omitempty"` // 客户端新建的 vCard 与条目的映射
+}
+
+// AttributeViewAddressItem 记录客户端新建 vCard 时使用的文件名和 UID，该条目后续仍然使用原路径和 UID 提供给客户端。
+type AttributeViewAddressItem struct {
+	ItemID   string `json:"itemID"`
+	FileName string `json:"fileName"` // 不含扩展名
+	UID      string `json:"uid"`
+}
+
+func (book *AttributeViewAddressBook) Path() string {
+	return CardDavAttributeViewBookPathPrefix + book.AvID
+}
+
+func (book *AttributeViewAddressBook) addressBook() *carddav.AddressBook {
+	return &carddav.AddressBook{
+		Path:                 book.Path(),
+		Name:                 book.Name,
+		Description:          "SiYuan database " + book.Name,
+		MaxResourceSize:      addressBookMaxResourceSize,
+		SupportedAddressData: addressBookSupportedAddressData,
+	}
+}
+
+func GetAttributeViewAddressBooks() (ret []*AttributeViewAddressBook) {
+	attributeViewBooksLock.Lock()
+	defer attributeViewBooksLock.Unlock()
+
+	loadAttributeViewBooks()
+	ret = append(ret, attributeViewBooks...)
+	return
+}
+
+// BindAttributeViewAddressBook 将属性视图绑定为通讯录，名称为空时使用属性视图名称。
+func BindAttributeViewAddressBook(avID, name string) (ret *AttributeViewAddressBook, err error) {
+	attributeViewBooksLock.Lock()
+	defer attributeViewBooksLock.Unlock()
+
+	loadAttributeViewBooks()
+	for _, book := range attributeViewBooks {
+		if book.AvID == avID {
+			err = ErrorCardDavAttributeViewBookBound
+			return
+		}
+	}
+
+	if !av.IsAttributeViewExist(avID) {
+		err = ErrorCardDavBookNotFound
+		return
+	}
+
+	name = strings.TrimSpace(name)
+	if "" == name {
+		name, _ = av.GetAttributeViewName(avID)
+	}
+	if "" == name {
+		name = avID
+	}
+
+	ret = &AttributeViewAddressBook{AvID: avID, Name: name, Created: time.Now().UnixMilli()}
+	attributeViewBooks = append(attributeViewBooks, ret)
+	err = saveAttributeViewBooks()
+	return
+}
+
+func UnbindAttributeViewAddressBook(avID string) (err error) {
+	attributeViewBooksLock.Lock()
+	defer attributeViewBooksLock.Unlock()
+
+	loadAttributeViewBooks()
+	for i, book := range attributeViewBooks {
+		if book.AvID == avID {
+			attributeViewBooks = append(attributeViewBooks[:i], attributeViewBooks[i+1:]...)
+			return saveAttributeViewBooks()
+		}
+	}
+	return ErrorCardDavBookNotFound
+}
+
+func loadAttributeViewBooks() {
+	filePath := DavPath2DirectoryPath(CardDavAttributeViewBooksFilePath)
+	var modTime int64
+	if info, statErr := os.Stat(filePath); nil == statErr {
+		modTime = info.ModTime().UnixNano()
+	}
+	if nil != attributeViewBooks && filePath == attributeViewBooksPath && modTime == attributeViewBooksModTime {
+		return
+	}
+
+	attributeViewBooks = []*AttributeViewAddressBook{}
+	attributeViewBooksPath, attributeViewBooksModTime = filePath, modTime
+	if 0 == modTime {
+		return
+	}
+
+	data, err := filelock.ReadFile(filePath)
+	if err != nil {
+		logging.LogErrorf("read attribute view address books [%s] failed: %s", filePath, err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &attributeViewBooks); err != nil {
+		logging.LogErrorf("unmarshal attribute view address books [%s] failed: %s", filePath, err)
+	}
+}
+
+func saveAttributeViewBooks() (err error) {
+	filePath := DavPath2DirectoryPath(CardDavAttributeViewBooksFilePath)
+	data, err := gulu.JSON.MarshalIndentJSON(attributeViewBooks, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal attribute view address books failed: %s", err)
+		return
+	}
+
+	if err = os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		logging.LogErrorf("create directory [%s] failed: %s", path.Dir(filePath), err)
+		return
+	}
+	if err = filelock.WriteFile(filePath, data); err != nil {
+		logging.LogErrorf("write attribute view address books [%s] failed: %s", filePath, err)
+		return
+	}
+	if info, statErr := os.Stat(filePath); nil == statErr {
+		attributeViewBooksPath, attributeViewBooksModTime = filePath, info.ModTime().UnixNano()
+	}
+	return
+}
+
+// loadedAttributeViewBook 返回当前加载的绑定关系，绑定关系文件可能已经重新加载，调用方需要持有 attributeViewBooksLock。
+func loadedAttributeViewBook(avID string) *AttributeViewAddressBook {
+	loadAttributeViewBooks()
+	for _, book := range attributeViewBooks {
+		if book.AvID == avID {
+			return book
+		}
+	}
+	return nil
+}
+
+// getAttributeViewAddressItem 返回条目对应的客户端文件名和 UID，没有映射时返回 nil。
+func getAttributeViewAddressItem(book *AttributeViewAddressBook, match func(item *AttributeViewAddressItem) bool) (ret *AttributeViewAddressItem) {
+	attributeViewBooksLock.Lock()
+	defer attributeViewBooksLock.Unlock()
+
+	if book = loadedAttributeViewBook(book.AvID); nil == book {
+		return
+	}
+	for _, item := range book.Items {
+		if match(item) {
+			ret = &AttributeViewAddressItem{ItemID: item.ItemID, FileName: item.FileName, UID: item.UID}
+			return
+		}
+	}
+	return
+}
+
+func setAttributeViewAddressItem(book *AttributeViewAddressBook, item *AttributeViewAddressItem) {
+	attributeViewBooksLock.Lock()
+	defer attributeViewBooksLock.Unlock()
+
+	if book = loadedAttributeViewBook(book.AvID); nil == book {
+		return
+	}
+
+	for i, it := range book.Items {
+		if it.ItemID == item.ItemID {
+			book.Items[i] = item
+			saveAttributeViewBooks()
+			return
+		}
+	}
+	book.Items = append(book.Items, item)
+	saveAttributeViewBooks()
+}
+
+func removeAttributeViewAddressItem(book *AttributeViewAddressBook, itemID string) {
+	attributeViewBooksLock.Lock()
+	defer attributeViewBooksLock.Unlock()
+
+	if book = loadedAttributeViewBook(book.AvID); nil == book {
+		return
+	}
+
+	for i, item := range book.Items {
+		if item.ItemID == itemID {
+			book.Items = append(book.Items[:i], book.Items[i+1:]...)
+			saveAttributeViewBooks()
+			return
+		}
+	}
+}
+
+func isAttributeViewBookPath(bookPath string) bool {
+	return strings.HasPrefix(bookPath, CardDavAttributeViewBookPathPrefix) && cardDavPathDepth_AddressBook == GetCardDavPathDepth(bookPath)
+}
+
+func getAttributeViewBook(bookPath string) (ret *AttributeViewAddressBook, err error) {
+	avID := strings.TrimPrefix(bookPath, CardDavAttributeViewBookPathPrefix)
+	for _, book := range GetAttributeViewAddressBooks() {
+		if book.AvID == avID {
+			ret = book
+			return
+		}
+	}
+	err = ErrorCardDavBookNotFound
+	return
+}
+
+func listAttributeViewAddressBooks() (ret []carddav.AddressBook) {
+	for _, book := range GetAttributeViewAddressBooks() {
+		ret = append(ret, *book.addressBook())
+	}
+	return
+}
+
+func getAttributeViewAddressBook(bookPath string) (addressBook *carddav.AddressBook, err error) {
+	book, err := getAttributeViewBook(bookPath)
+	if err != nil {
+		return
+	}
+	addressBook = book.addressBook()
+	return
+}
+
+func listAttributeViewAddressObjects(bookPath string, req *carddav.AddressDataRequest) (addressObjects []carddav.AddressObject, err error) {
+	book, err := getAttributeViewBook(bookPath)
+	if err != nil {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(book.AvID)
+	if err != nil {
+		return
+	}
+
+	blockKeyValues := attrView.GetBlockKeyValues()
+	if nil == blockKeyValues {
+		return
+	}
+
+	for _, blockVal := range blockKeyValues.Values {
+		address := attributeViewAddressObject(book, attrView, blockVal.BlockID)
+		if nil == address {
+			continue
+		}
+		addressObjects = append(addressObjects, *AddressPropsFilter(address, req))
+	}
+	return
+}
+
+func queryAttributeViewAddressObjects(urlPath string, query *carddav.AddressBookQuery) (addressObjects []carddav.AddressObject, err error) {
+	switch GetCardDavPathDepth(urlPath) {
+	case cardDavPathDepth_Root, cardDavPathDepth_Principals, cardDavPathDepth_UserPrincipal, cardDavPathDepth_HomeSet:
+		for _, book := range GetAttributeViewAddressBooks() {
+			objects, _ := listAttributeViewAddressObjects(book.Path(), &query.DataRequest)
+			addressObjects = append(addressObjects, objects...)
+		}
+	case cardDavPathDepth_AddressBook:
+		if addressObjects, err = listAttributeViewAddressObjects(urlPath, &query.DataRequest); err != nil {
+			return
+		}
+	case cardDavPathDepth_Address:
+		if address, _ := getAttributeViewAddressObject(urlPath, &query.DataRequest); nil != address {
+			addressObjects = append(addressObjects, *address)
+		}
+	default:
+		err = ErrorCardDavPathInvalid
+		return
+	}
+
+	addressObjects, err = carddav.Filter(query, addressObjects)
+	return
+}
+
+func getAttributeViewAddressObject(addressPath string, req *carddav.AddressDataRequest) (addressObject *carddav.AddressObject, err error) {
+	book, itemID, err := parseAttributeViewAddressPath(addressPath)
+	if err != nil {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(book.AvID)
+	if err != nil {
+		return
+	}
+
+	address := attributeViewAddressObject(book, attrView, itemID)
+	if nil == address {
+		err = ErrorCardDavAddressNotFound
+		return
+	}
+	addressObject = AddressPropsFilter(address, req)
+	return
+}
+
+// putAttributeViewAddressObject 将 vCard 的修改按列写回属性视图，不存在的行作为非绑定块新建。
+func putAttributeViewAddressObject(addressPath string, card vcard.Card) (addressObject *carddav.AddressObject, err error) {
+	book, itemID, err := parseAttributeViewAddressPath(addressPath)
+	if err != nil {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(book.AvID)
+	if err != nil {
+		return
+	}
+
+	blockKey := attrView.GetBlockKey()
+	if nil == blockKey {
+		err = ErrorCardDavBookNotFound
+		return
+	}
+
+	cardValues := attributeViewCardValues(attrView, card)
+	blockVal := attrView.GetBlockValue(itemID)
+	fileName, uid := strings.TrimSuffix(path.Base(addressPath), VCardFileExt), strings.TrimSpace(card.Value(vcard.FieldUID))
+	if nil == blockVal && "" != uid {
+		// 客户端使用新的文件名上传了已有的 vCard
+		if item := getAttributeViewAddressItem(book, func(item *AttributeViewAddressItem) bool { return uid == item.UID }); nil != item {
+			itemID = item.ItemID
+		} else if nil != attrView.GetBlockValue(uid) {
+			itemID = uid
+		}
+		if blockVal = attrView.GetBlockValue(itemID); nil != blockVal {
+			setAttributeViewAddressItem(book, &AttributeViewAddressItem{ItemID: itemID, FileName: fileName, UID: uid})
+		}
+	}
+	if nil == blockVal {
+		if !ast.IsNodeIDPattern(itemID) {
+			itemID = ast.NewNodeID()
+		}
+		if fileName != itemID || ("" != uid && uid != itemID) {
+			// 记录客户端使用的文件名和 UID，后续按原路径和 UID 提供
+			setAttributeViewAddressItem(book, &AttributeViewAddressItem{ItemID: itemID, FileName: fileName, UID: uid})
+		}
+
+		rowValues := []*av.Value{{KeyID: blockKey.ID, BlockID: itemID, Block: &av.ValueBlock{Content: vCardFormattedName(card)}}}
+		for keyID, content := range cardValues {
+			if "" == content {
+				continue
+			}
+			rowValues = append(rowValues, attributeViewCardValue(attrView, keyID, content))
+		}
+		if err = AppendAttributeViewDetachedBlocksWithValues(book.AvID, [][]*av.Value{rowValues}); err != nil {
+			return
+		}
+	} else {
+		var values []interface{}
+		if name := vCardFormattedName(card); nil != blockVal.Block && "" != name && name != strings.TrimSpace(blockVal.Block.Content) {
+			// 绑定块的行修改姓名时设置为静态锚文本，不修改绑定的块
+			values = append(values, map[string]interface{}{"keyID": blockKey.ID, "itemID": itemID, "value": map[string]interface{}{"block": map[string]interface{}{"content": name}}})
+		}
+		for keyID, content := range cardValues {
+			key, _ := attrView.GetKey(keyID)
+			if nil == key {
+				continue
+			}
+			if content == attributeViewValueContent(key.Type, attrView.GetValue(keyID, itemID)) {
+				continue
+			}
+
+			field := attributeViewValueField(key.Type)
+			values = append(values, map[string]interface{}{"keyID": keyID, "itemID": itemID, "value": map[string]interface{}{field: map[string]interface{}{"content": content}}})
+		}
+		if 1 > len(values) {
+			addressObject = attributeViewAddressObject(book, attrView, itemID)
+			return
+		}
+		if err = BatchUpdateAttributeViewCells(nil, book.AvID, values); err != nil {
+			return
+		}
+	}
+	ReloadAttrView(book.AvID)
+
+	if attrView, err = av.ParseAttributeView(book.AvID); err != nil {
+		return
+	}
+	addressObject = attributeViewAddressObject(book, attrView, itemID)
+	if nil == addressObject {
+		err = ErrorCardDavAddressNotFound
+	}
+	return
+}
+
+func deleteAttributeViewAddressObject(addressPath string) (err error) {
+	book, itemID, err := parseAttributeViewAddressPath(addressPath)
+	if err != nil {
+		return
+	}
+
+	if err = RemoveAttributeViewBlock([]string{itemID}, book.AvID); err != nil {
+		return
+	}
+	removeAttributeViewAddressItem(book, itemID)
+	ReloadAttrView(book.AvID)
+	return
+}
+
+func parseAttributeViewAddressPath(addressPath string) (book *AttributeViewAddressBook, itemID string, err error) {
+	bookPath, addressFileName := path.Split(addressPath)
+	bookPath = PathCleanWithSlash(bookPath)
+	if VCardFileExt != path.Ext(addressFileName) {
+		err = ErrorCardDavAddressFileExtensionNameInvalid
+		return
+	}
+
+	if book, err = getAttributeViewBook(bookPath); err != nil {
+		return
+	}
+	fileName := strings.TrimSuffix(addressFileName, VCardFileExt)
+	itemID = fileName
+	if item := getAttributeViewAddressItem(book, func(item *AttributeViewAddressItem) bool { return fileName == item.FileName }); nil != item {
+		itemID = item.ItemID
+	}
+	return
+}
+
+// attributeViewAddressObject 将属性视图中的一行转换为 vCard。
+func attributeViewAddressObject(book *AttributeViewAddressBook, attrView *av.AttributeView, itemID string) (ret *carddav.AddressObject) {
+	blockVal := attrView.GetBlockValue(itemID)
+	if nil == blockVal {
+		return
+	}
+
+	fileName, uid := itemID, itemID
+	if item := getAttributeViewAddressItem(book, func(item *AttributeViewAddressItem) bool { return itemID == item.ItemID }); nil != item {
+		fileName = item.FileName
+		if "" != item.UID {
+			uid = item.UID
+		}
+	}
+
+	name := ""
+	if nil != blockVal.Block {
+		name = strings.TrimSpace(blockVal.Block.Content)
+	}
+
+	card := vcard.Card{}
+	card.SetValue(vcard.FieldUID, uid)
+	card.SetValue(vcard.FieldFormattedName, name)
+	card.SetName(&vcard.Name{GivenName: name})
+	updated := blockVal.UpdatedAt
+	for _, keyValues := range attrView.KeyValues {
+		field := attributeViewCardField(keyValues.Key.Type)
+		if "" == field {
+			continue
+		}
+
+		val := keyValues.GetValue(itemID)
+		if nil == val {
+			continue
+		}
+		if updated < val.UpdatedAt {
+			updated = val.UpdatedAt
+		}
+
+		content := attributeViewValueContent(keyValues.Key.Type, val)
+		if "" == content {
+			continue
+		}
+
+		params := vcard.Params{}
+		params.Set(vcard.ParamType, keyValues.Key.Name)
+		params.Set(vCardParamAttributeViewKey, keyValues.Key.ID)
+		card.Add(field, &vcard.Field{Value: content, Params: params})
+	}
+	modTime := time.UnixMilli(updated)
+	card.SetRevision(modTime)
+	vcard.ToV4(card)
+
+	buf := bytes.Buffer{}
+	if err := vcard.NewEncoder(&buf).Encode(card); err != nil {
+		logging.LogErrorf("encode attribute view [%s] item [%s] vCard failed: %s", attrView.ID, itemID, err)
+		return
+	}
+
+	ret = &carddav.AddressObject{
+		Path:          PathJoinWithSlash(book.Path(), fileName+VCardFileExt),
+		ModTime:       modTime,
+		ContentLength: int64(buf.Len()),
+		ETag:          fmt.Sprintf("%s-%x", modTime.Format(time.RFC3339), buf.Len()),
+		Card:          card,
+	}
+	return
+}
+
+// attributeViewCardValues 按列解析 vCard 字段，优先使用字段参数中记录的列 ID，否则按同类型列的顺序映射。
+func attributeViewCardValues(attrView *av.AttributeView, card vcard.Card) (ret map[string]string) {
+	ret = map[string]string{}
+	keysByField := map[string][]*av.Key{}
+	for _, keyValues := range attrView.KeyValues {
+		if field := attributeViewCardField(keyValues.Key.Type); "" != field {
+			keysByField[field] = append(keysByField[field], keyValues.Key)
+			ret[keyValues.Key.ID] = ""
+		}
+	}
+
+	for field, keys := range keysByField {
+		var unmapped []*vcard.Field
+		for _, f := range card[field] {
+			keyID := f.Params.Get(vCardParamAttributeViewKey)
+			if _, ok := ret[keyID]; ok && "" == ret[keyID] {
+				ret[keyID] = f.Value
+				continue
+			}
+			unmapped = append(unmapped, f)
+		}
+
+		for _, key := range keys {
+			if 1 > len(unmapped) {
+				break
+			}
+			if "" == ret[key.ID] {
+				ret[key.ID] = unmapped[0].Value
+				unmapped = unmapped[1:]
+			}
+		}
+	}
+	return
+}
+
+func attributeViewCardValue(attrView *av.AttributeView, keyID, content string) (ret *av.Value) {
+	ret = &av.Value{KeyID: keyID}
+	key, _ := attrView.GetKey(keyID)
+	if nil == key {
+		return
+	}
+
+	switch key.Type {
+	case av.KeyTypeEmail:
+		ret.Email = &av.ValueEmail{Content: content}
+	case av.KeyTypePhone:
+		ret.Phone = &av.ValuePhone{Content: content}
+	case av.KeyTypeURL:
+		ret.URL = &av.ValueURL{Content: content}
+	}
+	return
+}
+
+func attributeViewCardField(keyType av.KeyType) string {
+	switch keyType {
+	case av.KeyTypeEmail:
+		return vcard.FieldEmail
+	case av.KeyTypePhone:
+		return vcard.FieldTelephone
+	case av.KeyTypeURL:
+		return vcard.FieldURL
+	}
+	return ""
+}
+
+// attributeViewValueField 返回值类型对应的 av.Value JSON 字段名。
+func attributeViewValueField(keyType av.KeyType) string {
+	switch keyType {
+	case av.KeyTypeEmail:
+		return "email"
+	case av.KeyTypePhone:
+		return "phone"
+	case av.KeyTypeURL:
+		return "url"
+	}
+	return ""
+}
+
+func attributeViewValueContent(keyType av.KeyType, val *av.Value) string {
+	if nil == val {
+		return ""
+	}
+
+	switch keyType {
+	case av.KeyTypeEmail:
+		if nil != val.Email {
+			return strings.TrimSpace(val.Email.Content)
+		}
+	case av.KeyTypePhone:
+		if nil != val.Phone {
+			return strings.TrimSpace(val.Phone.Content)
+		}
+	case av.KeyTypeURL:
+		if nil != val.URL {
+			return strings.TrimSpace(val.URL.Content)
+		}
+	}
+	return ""
+}
+
+func vCardFormattedName(card vcard.Card) (ret string) {
+	ret = strings.TrimSpace(card.PreferredValue(vcard.FieldFormattedName))
+	if "" != ret {
+		return
+	}
+
+	if name := card.Name(); nil != name {
+		ret = strings.TrimSpace(strings.Join([]string{name.HonorificPrefix, name.GivenName, name.AdditionalName, name.FamilyName, name.HonorificSuffix}, " "))
+		ret = strings.Join(strings.Fields(ret), " ")
+	}
+	return
+}
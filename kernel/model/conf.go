@@ -77,6 +77,10 @@ type AppConf struct {
 	Api            *conf.API        `json:"api"`            // API
 	Repo           *conf.Repo       `json:"repo"`           // 数据仓库
 	Publish        *conf.Publish    `json:"publish"`        // 发布服务
+	ACL            *conf.ACL        `json:"acl"`            // 访问控制
+	Members        *conf.Members    `json:"members"`        // 帐号
+	Automation     *conf.Automation `json:"automation"`     // 自动化
+	Webhooks       *conf.Webhooks   `json:"webhooks"`       // Webhook
 	OpenHelp       bool             `json:"openHelp"`       // 启动后是否需要打开用户指南
 	ShowChangelog  bool             `json:"showChangelog"`  // 是否显示版本更新日志
 	CloudRegion    int              `json:"cloudRegion"`    // 云端区域，0：中国大陆，1：北美
@@ -443,10 +447,35 @@ func InitConf() {
 	if nil == Conf.Publish {
 		Conf.Publish = conf.NewPublish()
 	}
+	if nil == Conf.Publish.Shares {
+		Conf.Publish.Shares = []*conf.PublishShare{}
+	}
 	if Conf.OpenHelp && Conf.Publish.Enable {
 		Conf.OpenHelp = false
 	}
 
+	if nil == Conf.ACL {
+		Conf.ACL = conf.NewACL()
+	}
+
+	if nil == Conf.Members {
+		Conf.Members = conf.NewMembers()
+	}
+
+	if nil == Conf.Automation {
+		Conf.Automation = conf.NewAutomation()
+	}
+	if nil == Conf.Automation.Rules {
+		Conf.Automation.Rules = []*conf.AutomationRule{}
+	}
+
+	if nil == Conf.Webhooks {
+		Conf.Webhooks = conf.NewWebhooks()
+	}
+	if nil == Conf.Webhooks.Hooks {
+		Conf.Webhooks.Hooks = []*conf.Webhook{}
+	}
+
 	if nil == Conf.Repo {
 		Conf.Repo = conf.NewRepo()
 	}
@@ -557,6 +586,18 @@ func InitConf() {
 		Conf.AI.OpenAI.APIMaxContexts = 7
 	}
 
+	Conf.AI.FixProviders()
+	for _, provider := range Conf.AI.Providers {
+		logging.LogInfof("AI provider [%s] enabled\n"+
+			"    type=%s\n"+
+			"    baseURL=%s\n"+
+			"    model=%s",
+			provider.Name,
+			provider.Type,
+			provider.APIBaseURL,
+			provider.APIModel)
+	}
+
 	if "" != Conf.AI.OpenAI.APIKey {
 		logging.LogInfof("OpenAI API enabled\n"+
 			"    userAgent=%s\n"+
@@ -752,6 +793,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int) {
 
 	// Improve indexing completeness when exiting https://github.com/siyuan-note/siyuan/issues/12039
 	sql.FlushQueue()
+	sql.FlushAuditQueue()
 
 	util.IsExiting.Store(true)
 	waitSecondForExecInstallPkg := false
@@ -998,6 +1040,7 @@ func InitBoxes() {
 			indexBox(box.ID)
 		}
 	}
+	indexAttributeViews()
 
 	logging.LogInfof("tree/block count [%d/%d]", treenode.CountTrees(), blockCount)
 }
@@ -1043,6 +1086,9 @@ func GetMaskedConf() (ret *AppConf, err error) {
 	if "" != ret.AccessAuthCode {
 		ret.AccessAuthCode = MaskedAccessAuthCode
 	}
+	if nil != ret.Members {
+		maskMembers(ret.Members.Accounts)
+	}
 	return
 }
 
@@ -1054,6 +1100,10 @@ func HideConfSecret(c *AppConf) {
 	c.Flashcard = &conf.Flashcard{}
 	c.ServerAddrs = []string{}
 	c.Publish = &conf.Publish{}
+	c.ACL = &conf.ACL{}
+	c.Members = &conf.Members{}
+	c.Automation = &conf.Automation{}
+	c.Webhooks = &conf.Webhooks{}
 	c.Repo = &conf.Repo{}
 	c.Sync = &conf.Sync{}
 	c.System.AppDir = ""
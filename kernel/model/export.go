@@ -54,7 +54,6 @@ import (
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
-	"github.com/siyuan-note/riff"
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
@@ -1090,67 +1089,8 @@ func ExportHTML(id, savePath string, pdf, image, keepFold, merge bool) (name, do
 	}
 
 	if !pdf && "" != savePath { // 导出 HTML 需要复制静态资源
-		srcs := []string{"stage/build/export", "stage/protyle"}
-		for _, src := range srcs {
-			from := filepath.Join(util.WorkingDir, src)
-			to := filepath.Join(savePath, src)
-			if err := filelock.Copy(from, to); err != nil {
-				logging.LogErrorf("copy stage from [%s] to [%s] failed: %s", from, savePath, err)
-				return
-			}
-		}
-
-		theme := Conf.Appearance.ThemeLight
-		if 1 == Conf.Appearance.Mode {
-			theme = Conf.Appearance.ThemeDark
-		}
-		// 复制主题文件夹
-		srcs = []string{"themes/" + theme}
-		appearancePath := util.AppearancePath
-		if util.IsSymlinkPath(util.AppearancePath) {
-			// Support for symlinked theme folder when exporting HTML https://github.com/siyuan-note/siyuan/issues/9173
-			var readErr error
-			appearancePath, readErr = filepath.EvalSymlinks(util.AppearancePath)
-			if nil != readErr {
-				logging.LogErrorf("readlink [%s] failed: %s", util.AppearancePath, readErr)
-				return
-			}
-		}
-		for _, src := range srcs {
-			from := filepath.Join(appearancePath, src)
-			to := filepath.Join(savePath, "appearance", src)
-			if err := filelock.Copy(from, to); err != nil {
-				logging.LogErrorf("copy appearance from [%s] to [%s] failed: %s", from, savePath, err)
-			}
-		}
-
-		// 只复制图标文件夹中的 icon.js 文件
-		iconName := Conf.Appearance.Icon
-		// 如果使用的不是内建图标（ant 或 material），需要复制 material 作为后备
-		if iconName != "ant" && iconName != "material" && iconName != "" {
-			srcIconFile := filepath.Join(appearancePath, "icons", "material", "icon.js")
-			toIconDir := filepath.Join(savePath, "appearance", "icons", "material")
-			if err := os.MkdirAll(toIconDir, 0755); err != nil {
-				logging.LogErrorf("mkdir [%s] failed: %s", toIconDir, err)
-				return
-			}
-			toIconFile := filepath.Join(toIconDir, "icon.js")
-			if err := filelock.Copy(srcIconFile, toIconFile); err != nil {
-				logging.LogWarnf("copy icon file from [%s] to [%s] failed: %s", srcIconFile, toIconFile, err)
-			}
-		}
-		// 复制当前使用的图标文件
-		if iconName != "" {
-			srcIconFile := filepath.Join(appearancePath, "icons", iconName, "icon.js")
-			toIconDir := filepath.Join(savePath, "appearance", "icons", iconName)
-			if err := os.MkdirAll(toIconDir, 0755); err != nil {
-				logging.LogErrorf("mkdir [%s] failed: %s", toIconDir, err)
-				return
-			}
-			toIconFile := filepath.Join(toIconDir, "icon.js")
-			if err := filelock.Copy(srcIconFile, toIconFile); err != nil {
-				logging.LogWarnf("copy icon file from [%s] to [%s] failed: %s", srcIconFile, toIconFile, err)
-			}
+		if err := copyExportHTMLStaticFiles(savePath); err != nil {
+			return
 		}
 
 		// 复制自定义表情图片
@@ -1182,6 +1122,73 @@ func ExportHTML(id, savePath string, pdf, image, keepFold, merge bool) (name, do
 	return
 }
 
+// copyExportHTMLStaticFiles 复制导出 HTML 需要的静态资源、主题和图标文件。
+func copyExportHTMLStaticFiles(savePath string) error {
+	srcs := []string{"stage/build/export", "stage/protyle"}
+	for _, src := range srcs {
+		from := filepath.Join(util.WorkingDir, src)
+		to := filepath.Join(savePath, src)
+		if err := filelock.Copy(from, to); err != nil {
+			logging.LogErrorf("copy stage from [%s] to [%s] failed: %s", from, savePath, err)
+			return err
+		}
+	}
+
+	theme := Conf.Appearance.ThemeLight
+	if 1 == Conf.Appearance.Mode {
+		theme = Conf.Appearance.ThemeDark
+	}
+	// 复制主题文件夹
+	srcs = []string{"themes/" + theme}
+	appearancePath := util.AppearancePath
+	if util.IsSymlinkPath(util.AppearancePath) {
+		// Support for symlinked theme folder when exporting HTML https://github.com/siyuan-note/siyuan/issues/9173
+		var readErr error
+		appearancePath, readErr = filepath.EvalSymlinks(util.AppearancePath)
+		if nil != readErr {
+			logging.LogErrorf("readlink [%s] failed: %s", util.AppearancePath, readErr)
+			return readErr
+		}
+	}
+	for _, src := range srcs {
+		from := filepath.Join(appearancePath, src)
+		to := filepath.Join(savePath, "appearance", src)
+		if err := filelock.Copy(from, to); err != nil {
+			logging.LogErrorf("copy appearance from [%s] to [%s] failed: %s", from, savePath, err)
+		}
+	}
+
+	// 只复制图标文件夹中的 icon.js 文件
+	iconName := Conf.Appearance.Icon
+	// 如果使用的不是内建图标（ant 或 material），需要复制 material 作为后备
+	if iconName != "ant" && iconName != "material" && iconName != "" {
+		srcIconFile := filepath.Join(appearancePath, "icons", "material", "icon.js")
+		toIconDir := filepath.Join(savePath, "appearance", "icons", "material")
+		if err := os.MkdirAll(toIconDir, 0755); err != nil {
+			logging.LogErrorf("mkdir [%s] failed: %s", toIconDir, err)
+			return err
+		}
+		toIconFile := filepath.Join(toIconDir, "icon.js")
+		if err := filelock.Copy(srcIconFile, toIconFile); err != nil {
+			logging.LogWarnf("copy icon file from [%s] to [%s] failed: %s", srcIconFile, toIconFile, err)
+		}
+	}
+	// 复制当前使用的图标文件
+	if iconName != "" {
+		srcIconFile := filepath.Join(appearancePath, "icons", iconName, "icon.js")
+		toIconDir := filepath.Join(savePath, "appearance", "icons", iconName)
+		if err := os.MkdirAll(toIconDir, 0755); err != nil {
+			logging.LogErrorf("mkdir [%s] failed: %s", toIconDir, err)
+			return err
+		}
+		toIconFile := filepath.Join(toIconDir, "icon.js")
+		if err := filelock.Copy(srcIconFile, toIconFile); err != nil {
+			logging.LogWarnf("copy icon file from [%s] to [%s] failed: %s", srcIconFile, toIconFile, err)
+		}
+	}
+	return nil
+}
+
 func prepareExportTree(bt *treenode.BlockTree) (ret *parse.Tree) {
 	luteEngine := NewLute()
 	ret, _ = filesys.LoadTree(bt.BoxID, bt.Path, luteEngine)
@@ -2051,7 +2058,7 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 
 	// 导出闪卡 Export related flashcard data when exporting .sy.zip https://github.com/siyuan-note/siyuan/issues/9372
 	exportStorageRiffDir := filepath.Join(exportDir, "storage", "riff")
-	deck, loadErr := riff.LoadDeck(exportStorageRiffDir, builtinDeckID, Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights)
+	deck, loadErr := loadDeck(exportStorageRiffDir, builtinDeckID)
 	if nil != loadErr {
 		logging.LogErrorf("load deck [%s] failed: %s", name, loadErr)
 	} else {
@@ -3760,6 +3767,16 @@ func getAttrViewTable(attrView *av.AttributeView, view *av.View, query string) (
 		for _, field := range view.Kanban.Fields {
 			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
 		}
+	case av.LayoutTypeCalendar:
+		view.Table = av.NewLayoutTable()
+		for _, field := range view.Calendar.Fields {
+			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
+		}
+	case av.LayoutTypeTimeline:
+		view.Table = av.NewLayoutTable()
+		for _, field := range view.Timeline.Fields {
+			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{BaseField: &av.BaseField{ID: field.ID}})
+		}
 	}
 
 	depth := 1
@@ -0,0 +1,685 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/html/atom"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type epubChapter struct {
+	ID       string
+	Title    string
+	Path     string
+	Headings []*epubHeading
+	Children []*epubChapter
+
+	mathML bool
+	remote bool
+	body   string
+}
+
+type epubHeading struct {
+	ID       string
+	Title    string
+	Level    int
+	Children []*epubHeading
+}
+
+type epubAsset struct {
+	ID        string
+	Href      string
+	MediaType string
+	absPath   string
+}
+
+// ExportEPUB 导出 EPUB 3 电子书，不依赖 Pandoc。
+func ExportEPUB(id string) (name, zipPath string) {
+	defer util.ClearPushProgress(100)
+
+	baseName, trees := prepareExportBookTrees(id)
+	if 1 > len(trees) {
+		return
+	}
+
+	exportFolder := filepath.Join(util.TempDir, "export", baseName+".epub")
+	os.RemoveAll(exportFolder)
+	if err := os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+
+	// 记录每个块所在的章节，用于将块引转换为章节内链接
+	blockChapters := map[string]string{}
+	for _, tree := range trees {
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if entering && "" != n.ID {
+				blockChapters[n.ID] = tree.ID
+			}
+			return ast.WalkContinue
+		})
+	}
+
+	luteEngine := NewLute()
+	renderOptions := *luteEngine.RenderOptions
+	renderOptions.KramdownIALIDRenderName = "data-node-id"
+	chapters := map[string]*epubChapter{}
+	assets := map[string]*epubAsset{}
+	var spine []*epubChapter
+	for i, tree := range trees {
+		chapter := &epubChapter{ID: tree.ID, Title: tree.Root.IALAttr("title"), Path: "text/" + tree.ID + ".xhtml"}
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering {
+				return ast.WalkContinue
+			}
+
+			// 标题渲染时使用第一个属性作为块 ID，这里确保 id 属性排在第一位
+			for i, attr := range n.KramdownIAL {
+				if "id" == attr[0] && 0 < i {
+					n.KramdownIAL[0], n.KramdownIAL[i] = n.KramdownIAL[i], n.KramdownIAL[0]
+					break
+				}
+			}
+			if ast.NodeHeading != n.Type {
+				return ast.WalkContinue
+			}
+
+			// 文档标题占用一级标题，文档内的标题依次降级
+			if 6 > n.HeadingLevel {
+				n.HeadingLevel++
+			}
+			if !n.ParentIs(ast.NodeBlockquote) && !n.ParentIs(ast.NodeCallout) && !n.ParentIs(ast.NodeListItem) {
+				chapter.Headings = append(chapter.Headings, &epubHeading{ID: n.ID, Title: strings.TrimSpace(n.Text()), Level: n.HeadingLevel})
+			}
+			return ast.WalkContinue
+		})
+		chapter.Headings = nestEPUBHeadings(chapter.Headings)
+
+		renderer := render.NewHtmlRenderer(tree, &renderOptions, luteEngine.ParseOptions)
+		chapter.body = cleanEPUBChapterHTML(string(renderer.Render()), chapter, blockChapters, assets)
+		chapters[chapter.ID] = chapter
+		spine = append(spine, chapter)
+		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", i+1, len(trees), chapter.Title)))
+	}
+
+	// 按照文档树组织目录
+	var toc []*epubChapter
+	for _, tree := range trees {
+		chapter := chapters[tree.ID]
+		parent := findExportParentTree(tree, chapters)
+		if nil == parent {
+			toc = append(toc, chapter)
+			continue
+		}
+		parent.Children = append(parent.Children, chapter)
+	}
+
+	epubPath := filepath.Join(exportFolder, util.FilterFileName(baseName)+".epub")
+	if err := writeEPUB(epubPath, id, baseName, spine, toc, assets); nil != err {
+		logging.LogErrorf("write epub [%s] failed: %s", epubPath, err)
+		return
+	}
+
+	zipPath = zipExportFolder(exportFolder)
+	name = trees[0].ID
+	return
+}
+
+func writeEPUB(epubPath, id, title string, spine, toc []*epubChapter, assets map[string]*epubAsset) (err error) {
+	f, err := os.Create(epubPath)
+	if nil != err {
+		return
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	// mimetype 必须是第一个条目并且不能压缩
+	mimetype, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if nil != err {
+		return
+	}
+	if _, err = mimetype.Write([]byte("application/epub+zip")); nil != err {
+		return
+	}
+
+	lang := strings.ReplaceAll(Conf.Lang, "_", "-")
+	if "" == lang {
+		lang = "en"
+	}
+
+	entries := map[string][]byte{}
+	entries["META-INF/container.xml"] = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)
+	entries["OEBPS/style.css"] = []byte(epubStyle)
+
+	opf := bytes.Buffer{}
+	opf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	opf.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" xml:lang="` + lang + `">` + "\n")
+	opf.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	opf.WriteString(`    <dc:identifier id="uid">urn:siyuan:` + id + `</dc:identifier>` + "\n")
+	opf.WriteString(`    <dc:title>` + html.EscapeString(title) + `</dc:title>` + "\n")
+	opf.WriteString(`    <dc:language>` + lang + `</dc:language>` + "\n")
+	opf.WriteString(`    <meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + `</meta>` + "\n")
+	opf.WriteString("  </metadata>\n  <manifest>\n")
+	opf.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	opf.WriteString(`    <item id="css" href="style.css" media-type="text/css"/>` + "\n")
+	for _, chapter := range spine {
+		var properties []string
+		if chapter.mathML {
+			properties = append(properties, "mathml")
+		}
+		if chapter.remote {
+			properties = append(properties, "remote-resources")
+		}
+		opf.WriteString(`    <item id="c` + chapter.ID + `" href="` + chapter.Path + `" media-type="application/xhtml+xml"`)
+		if 0 < len(properties) {
+			opf.WriteString(` properties="` + strings.Join(properties, " ") + `"`)
+		}
+		opf.WriteString("/>\n")
+		entries["OEBPS/"+chapter.Path] = []byte(epubXHTML(chapter.Title, lang, "../", `<section id="`+chapter.ID+`"><h1>`+html.EscapeString(chapter.Title)+"</h1>\n"+chapter.body+"</section>"))
+	}
+
+	var assetHrefs []string
+	for href := range assets {
+		assetHrefs = append(assetHrefs, href)
+	}
+	sort.Strings(assetHrefs)
+	for _, href := range assetHrefs {
+		asset := assets[href]
+		opf.WriteString(`    <item id="` + asset.ID + `" href="` + html.EscapeString(asset.Href) + `" media-type="` + asset.MediaType + `"/>` + "\n")
+	}
+	opf.WriteString("  </manifest>\n  <spine>\n")
+	for _, chapter := range spine {
+		opf.WriteString(`    <itemref idref="c` + chapter.ID + `"/>` + "\n")
+	}
+	opf.WriteString("  </spine>\n</package>\n")
+	entries["OEBPS/content.opf"] = opf.Bytes()
+
+	nav := bytes.Buffer{}
+	nav.WriteString(`<nav epub:type="toc" id="toc"><h1>` + html.EscapeString(title) + "</h1>\n")
+	writeEPUBNavChapters(&nav, toc)
+	nav.WriteString("</nav>")
+	entries["OEBPS/nav.xhtml"] = []byte(epubXHTML(title, lang, "", nav.String()))
+
+	var names []string
+	for entryName := range entries {
+		names = append(names, entryName)
+	}
+	sort.Strings(names)
+	for _, entryName := range names {
+		entry, createErr := w.Create(entryName)
+		if nil != createErr {
+			return createErr
+		}
+		if _, err = entry.Write(entries[entryName]); nil != err {
+			return
+		}
+	}
+
+	for _, href := range assetHrefs {
+		asset := assets[href]
+		data, readErr := os.ReadFile(asset.absPath)
+		if nil != readErr {
+			logging.LogWarnf("read asset [%s] failed: %s", asset.absPath, readErr)
+			continue
+		}
+
+		entry, createErr := w.Create("OEBPS/" + asset.Href)
+		if nil != createErr {
+			return createErr
+		}
+		if _, err = entry.Write(data); nil != err {
+			return
+		}
+	}
+	return w.Close()
+}
+
+func writeEPUBNavChapters(buf *bytes.Buffer, chapters []*epubChapter) {
+	buf.WriteString("<ol>\n")
+	for _, chapter := range chapters {
+		writeEPUBNavChapter(buf, chapter)
+	}
+	buf.WriteString("</ol>\n")
+}
+
+func writeEPUBNavChapter(buf *bytes.Buffer, chapter *epubChapter) {
+	buf.WriteString(`<li><a href="` + chapter.Path + `">` + html.EscapeString(chapter.Title) + "</a>")
+	if 0 < len(chapter.Headings) || 0 < len(chapter.Children) {
+		buf.WriteString("\n<ol>\n")
+		writeEPUBNavHeadings(buf, chapter.Path, chapter.Headings)
+		for _, child := range chapter.Children {
+			writeEPUBNavChapter(buf, child)
+		}
+		buf.WriteString("</ol>\n")
+	}
+	buf.WriteString("</li>\n")
+}
+
+func writeEPUBNavHeadings(buf *bytes.Buffer, chapterPath string, headings []*epubHeading) {
+	for _, heading := range headings {
+		buf.WriteString(`<li><a href="` + chapterPath + "#" + heading.ID + `">` + html.EscapeString(heading.Title) + "</a>")
+		if 0 < len(heading.Children) {
+			buf.WriteString("\n<ol>\n")
+			writeEPUBNavHeadings(buf, chapterPath, heading.Children)
+			buf.WriteString("</ol>\n")
+		}
+		buf.WriteString("</li>\n")
+	}
+}
+
+func nestEPUBHeadings(headings []*epubHeading) (ret []*epubHeading) {
+	var stack []*epubHeading
+	for _, heading := range headings {
+		for 0 < len(stack) && stack[len(stack)-1].Level >= heading.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if 0 < len(stack) {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, heading)
+		} else {
+			ret = append(ret, heading)
+		}
+		stack = append(stack, heading)
+	}
+	return
+}
+
+func epubXHTML(title, lang, base, body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="` + lang + `" xml:lang="` + lang + `">
+<head>
+<meta charset="UTF-8"/>
+<title>` + html.EscapeString(title) + `</title>
+<link rel="stylesheet" type="text/css" href="` + base + `style.css"/>
+</head>
+<body>
+` + body + `
+</body>
+</html>
+`
+}
+
+// cleanEPUBChapterHTML 将导出的 HTML 整理为 XHTML：移除非标准属性、转换块引链接和公式、收集资源文件。
+func cleanEPUBChapterHTML(htmlStr string, chapter *epubChapter, blockChapters map[string]string, assets map[string]*epubAsset) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(htmlStr), body)
+	if nil != err {
+		logging.LogErrorf("parse chapter [%s] html failed: %s", chapter.ID, err)
+		return ""
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+
+	ids := map[string]bool{chapter.ID: true}
+	var unwraps, removes []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if html.ElementNode == n.Type && "math" != n.Namespace {
+			var tex string
+			displayMode := "div" == n.Data
+			if "language-math" == htmlAttr(n, "class") {
+				tex = htmlTextContent(n)
+			} else if "math" == htmlAttr(n, "data-subtype") {
+				tex = htmlAttr(n, "data-content")
+			}
+			if tex = strings.TrimSpace(tex); "" != tex {
+				mathML := util.LaTeX2MathML(tex, displayMode)
+				mathNodes, parseErr := html.ParseFragment(strings.NewReader(mathML), body)
+				if nil == parseErr && 0 < len(mathNodes) {
+					for _, mathNode := range mathNodes {
+						n.InsertBefore(mathNode)
+					}
+					if displayMode {
+						wrap := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div, Attr: []*html.Attribute{{Key: "class", Val: "math"}}}
+						n.InsertBefore(wrap)
+						for _, mathNode := range mathNodes {
+							mathNode.Unlink()
+							wrap.AppendChild(mathNode)
+						}
+					}
+					removes = append(removes, n)
+					chapter.mathML = true
+					return
+				}
+			}
+
+			var attrs []*html.Attribute
+			nodeID := htmlAttr(n, "data-node-id")
+			for _, attr := range n.Attr {
+				if !epubAllowedAttrs[attr.Key] || ("id" == attr.Key && "" != nodeID) {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			if "" != nodeID {
+				attrs = append(attrs, &html.Attribute{Key: "id", Val: nodeID})
+			}
+			n.Attr = attrs
+
+			// XHTML 中的 id 不能重复，嵌入块中可能出现重复的块
+			for i := 0; i < len(n.Attr); i++ {
+				if "id" == n.Attr[i].Key {
+					if ids[n.Attr[i].Val] {
+						n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+						i--
+						continue
+					}
+					ids[n.Attr[i].Val] = true
+				}
+			}
+
+			switch n.DataAtom {
+			case atom.A:
+				href := htmlAttr(n, "href")
+				if strings.HasPrefix(href, "siyuan://blocks/") {
+					defID := strings.TrimPrefix(href, "siyuan://blocks/")
+					if idx := strings.IndexAny(defID, "?#"); 0 < idx {
+						defID = defID[:idx]
+					}
+
+					if rootID := blockChapters[defID]; "" != rootID {
+						href = rootID + ".xhtml"
+						if defID != rootID {
+							href += "#" + defID
+						}
+						setHtmlAttr(n, "href", href)
+					} else {
+						// 引用的块不在导出范围内
+						unwraps = append(unwraps, n)
+					}
+				} else if !isRemoteExportLink(href) && !strings.HasPrefix(href, "#") {
+					unwraps = append(unwraps, n)
+				}
+			case atom.Img, atom.Video, atom.Audio, atom.Source:
+				src := htmlAttr(n, "src")
+				if isRemoteExportLink(src) {
+					chapter.remote = true
+					break
+				}
+
+				asset := addEPUBAsset(src, assets)
+				if nil == asset {
+					removes = append(removes, n)
+					break
+				}
+				setHtmlAttr(n, "src", "../"+epubEscapeHref(asset.Href))
+			case atom.Iframe, atom.Object, atom.Embed, atom.Script, atom.Style:
+				removes = append(removes, n)
+				return
+			}
+		}
+
+		for c := n.FirstChild; nil != c; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+
+	for _, n := range removes {
+		n.Unlink()
+	}
+	for _, n := range unwraps {
+		for c := n.FirstChild; nil != c; c = n.FirstChild {
+			c.Unlink()
+			n.InsertBefore(c)
+		}
+		n.Unlink()
+	}
+
+	buf := bytes.Buffer{}
+	for c := body.FirstChild; nil != c; c = c.NextSibling {
+		if err = html.Render(&buf, c); nil != err {
+			logging.LogErrorf("render chapter [%s] html failed: %s", chapter.ID, err)
+			return ""
+		}
+	}
+	return buf.String()
+}
+
+var epubAllowedAttrs = map[string]bool{
+	"id": true, "class": true, "style": true, "href": true, "src": true, "alt": true, "title": true,
+	"colspan": true, "rowspan": true, "align": true, "start": true, "type": true, "checked": true, "disabled": true,
+	"lang": true, "dir": true, "width": true, "height": true, "controls": true,
+}
+
+func addEPUBAsset(src string, assets map[string]*epubAsset) *epubAsset {
+	if unescaped, err := url.PathUnescape(src); nil == err {
+		src = unescaped
+	}
+	if idx := strings.Index(src, "?"); 0 < idx {
+		src = src[:idx]
+	}
+	if !strings.HasPrefix(src, "assets/") {
+		return nil
+	}
+
+	if asset := assets[src]; nil != asset {
+		return asset
+	}
+
+	absPath, err := GetAssetAbsPath(src)
+	if nil != err {
+		logging.LogWarnf("get asset [%s] abs path failed: %s", src, err)
+		return nil
+	}
+
+	mediaType := mime.TypeByExtension(strings.ToLower(path.Ext(src)))
+	if idx := strings.Index(mediaType, ";"); 0 < idx {
+		mediaType = mediaType[:idx]
+	}
+	if "" == mediaType {
+		mediaType = "application/octet-stream"
+	}
+	asset := &epubAsset{ID: fmt.Sprintf("a%d", len(assets)+1), Href: src, MediaType: mediaType, absPath: absPath}
+	assets[src] = asset
+	return asset
+}
+
+func epubEscapeHref(href string) string {
+	parts := strings.Split(href, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func isRemoteExportLink(dest string) bool {
+	return strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") || strings.HasPrefix(dest, "mailto:")
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if key == attr.Key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func setHtmlAttr(n *html.Node, key, val string) {
+	for i, attr := range n.Attr {
+		if key == attr.Key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, &html.Attribute{Key: key, Val: val})
+}
+
+func htmlTextContent(n *html.Node) string {
+	buf := strings.Builder{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if html.TextNode == n.Type {
+			buf.WriteString(n.Data)
+		}
+		for c := n.FirstChild; nil != c; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return buf.String()
+}
+
+// prepareExportBookTrees 准备导出电子书和排版文档的文档树，引用的文档也会一并导出，块引转换为块链。
+func prepareExportBookTrees(id string) (baseName string, ret []*parse.Tree) {
+	block := treenode.GetBlockTree(id)
+	if nil == block {
+		logging.LogErrorf("not found block [%s]", id)
+		return
+	}
+	box := Conf.Box(block.BoxID)
+	if nil == box {
+		logging.LogErrorf("not found box [%s]", block.BoxID)
+		return
+	}
+
+	baseName = path.Base(block.HPath)
+	if "." == baseName || "/" == baseName {
+		baseName = block.RootID
+	}
+	baseName = util.FilterFileName(baseName)
+
+	docPaths := []string{block.Path}
+	if Conf.Export.IncludeSubDocs {
+		docFiles := box.ListFiles(strings.TrimSuffix(block.Path, ".sy"))
+		for _, docFile := range docFiles {
+			docPaths = append(docPaths, docFile.path)
+		}
+	}
+
+	_, trees, _ := prepareExportTrees(docPaths)
+	var related []*parse.Tree
+	added := map[string]bool{}
+	for _, p := range docPaths {
+		rootID := util.GetTreeID(p)
+		if tree := trees[rootID]; nil != tree && !added[rootID] {
+			ret = append(ret, tree)
+			added[rootID] = true
+		}
+	}
+	for rootID, tree := range trees {
+		if !added[rootID] {
+			related = append(related, tree)
+		}
+	}
+	sort.Slice(related, func(i, j int) bool { return related[i].HPath < related[j].HPath })
+	ret = append(ret, related...)
+
+	treeCache := map[string]*parse.Tree{}
+	for _, tree := range ret {
+		treeCache[tree.ID] = tree
+	}
+	for i, tree := range ret {
+		ret[i] = exportTree(tree, false, false, true,
+			2, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
+			Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
+			Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
+			false, Conf.Export.InlineMemo, false, false, treeCache)
+	}
+	return
+}
+
+// findExportParentTree 查找文档在导出范围内最近的上级文档。
+func findExportParentTree[T any](tree *parse.Tree, exported map[string]T) (ret T) {
+	p := strings.TrimSuffix(tree.Path, ".sy")
+	for {
+		p = path.Dir(p)
+		if "/" == p || "." == p {
+			return
+		}
+		if parent, ok := exported[path.Base(p)]; ok {
+			return parent
+		}
+	}
+}
+
+func zipExportFolder(exportFolder string) (zipPath string) {
+	zipPath = exportFolder + ".zip"
+	zip, err := gulu.Zip.Create(zipPath)
+	if err != nil {
+		logging.LogErrorf("create export zip [%s] failed: %s", exportFolder, err)
+		return ""
+	}
+
+	entries, err := os.ReadDir(exportFolder)
+	if err != nil {
+		logging.LogErrorf("read export folder [%s] failed: %s", exportFolder, err)
+		return ""
+	}
+
+	for _, entry := range entries {
+		entryName := entry.Name()
+		entryPath := filepath.Join(exportFolder, entryName)
+		if gulu.File.IsDir(entryPath) {
+			err = zip.AddDirectory(entryName, entryPath)
+		} else {
+			err = zip.AddEntry(entryName, entryPath)
+		}
+		if err != nil {
+			logging.LogErrorf("add entry [%s] to zip failed: %s", entryName, err)
+			return ""
+		}
+	}
+
+	if err = zip.Close(); err != nil {
+		logging.LogErrorf("close export zip failed: %s", err)
+	}
+
+	os.RemoveAll(exportFolder)
+	zipPath = "/export/" + url.PathEscape(filepath.Base(zipPath))
+	return
+}
+
+const epubStyle = `body { font-family: serif; line-height: 1.6; }
+h1, h2, h3, h4, h5, h6 { font-family: sans-serif; line-height: 1.3; }
+img, video { max-width: 100%; }
+pre { white-space: pre-wrap; background: #f6f8fa; padding: 0.5em; }
+code { font-family: monospace; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 0.25em solid #ccc; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; }
+div.math { text-align: center; margin: 0.5em 0; }
+li.vditor-task { list-style: none; }
+`
@@ -0,0 +1,460 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// siteDoc 是静态站点中的一个页面，同时也是导航树的节点。
+type siteDoc struct {
+	ID       string
+	Box      string
+	Path     string
+	Title    string
+	Children []*siteDoc
+}
+
+type siteBacklink struct {
+	ID    string
+	Title string
+}
+
+type sitePage struct {
+	ID        string
+	Title     string
+	Lang      string
+	Mode      int
+	Theme     string
+	ThemeMode string
+	Icon      string
+	Nav       []*siteDoc
+	Content   template.HTML
+	Backlinks []*siteBacklink
+
+	ThemeLight, ThemeDark                   string
+	CodeBlockThemeLight, CodeBlockThemeDark string
+	FontSize                                int
+	PlantUMLServePath, KaTexMacros          string
+}
+
+type siteSearchEntry struct {
+	ID      string `json:"i"`
+	RootID  string `json:"r"`
+	Content string `json:"c"`
+}
+
+// ExportSite 将笔记本或者文档及其下级文档导出为可离线浏览的静态站点，p 为 "/" 时导出整个笔记本。
+//
+// 每个文档导出为一个以文档 ID 命名的页面，块引用改写为页面锚点，只复制被引用的资源文件，并使用 blocks 表生成客户端搜索索引。
+func ExportSite(boxID, p string) (zipPath string) {
+	util.PushEndlessProgress(Conf.Language(65))
+	defer util.ClearPushProgress(100)
+
+	box := Conf.Box(boxID)
+	if nil == box {
+		logging.LogErrorf("not found box [%s]", boxID)
+		return
+	}
+
+	var nav []*siteDoc
+	baseName := box.Name
+	if "/" == p || "" == p {
+		nav = listSiteDocs(box.ID, "/")
+	} else {
+		bt := treenode.GetBlockTree(util.GetTreeID(p))
+		if nil == bt {
+			logging.LogErrorf("not found doc [%s/%s]", boxID, p)
+			return
+		}
+
+		root := &siteDoc{ID: bt.ID, Box: bt.BoxID, Path: bt.Path, Title: path.Base(bt.HPath)}
+		root.Children = listSiteDocs(box.ID, bt.Path)
+		nav = []*siteDoc{root}
+		baseName = root.Title
+	}
+
+	var docs []*siteDoc
+	collectSiteDocs(nav, &docs)
+	if 1 > len(docs) {
+		return
+	}
+
+	exportFolder := filepath.Join(util.TempDir, "export", "site-"+util.FilterFileName(baseName)+"-"+util.CurrentTimeSecondsStr())
+	os.RemoveAll(exportFolder)
+	if err := os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("create export site folder failed: %s", err)
+		return
+	}
+
+	if err := copyExportHTMLStaticFiles(exportFolder); err != nil {
+		return
+	}
+
+	pages := map[string]bool{}
+	var rootIDs []string
+	for _, doc := range docs {
+		pages[doc.ID] = true
+		rootIDs = append(rootIDs, doc.ID)
+	}
+
+	tpl, err := template.New("site").Parse(siteTemplate)
+	if err != nil {
+		logging.LogErrorf("parse site template failed: %s", err)
+		return
+	}
+
+	backlinks := sql.QueryRefRootBlocksByDefRootIDs(rootIDs)
+	luteEngine := NewLute()
+	luteEngine.SetFootnotes(true)
+	luteEngine.RenderOptions.ProtyleContenteditable = false
+	luteEngine.SetProtyleMarkNetImg(false)
+	luteEngine.SetSanitize(false)
+	for i, doc := range docs {
+		bt := treenode.GetBlockTree(doc.ID)
+		if nil == bt {
+			continue
+		}
+
+		tree := prepareExportTree(bt)
+		if nil == tree {
+			continue
+		}
+
+		tree = exportTree(tree, true, false, true,
+			2, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
+			Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
+			Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
+			true, Conf.Export.InlineMemo, true, false, map[string]*parse.Tree{})
+		rewriteSiteLinks(tree, pages)
+		copySiteAssets(tree, exportFolder)
+
+		renderer := render.NewProtyleExportRenderer(tree, luteEngine.RenderOptions, luteEngine.ParseOptions)
+		page := newSitePage(doc, nav)
+		page.Content = template.HTML(renderer.Render())
+		page.Backlinks = siteBacklinks(doc.ID, backlinks[doc.ID], pages)
+
+		buf := bytes.Buffer{}
+		if err = tpl.Execute(&buf, page); err != nil {
+			logging.LogErrorf("render site page [%s] failed: %s", doc.ID, err)
+			continue
+		}
+		pagePath := filepath.Join(exportFolder, doc.ID+".html")
+		if err = gulu.File.WriteFileSafer(pagePath, buf.Bytes(), 0644); err != nil {
+			logging.LogErrorf("write site page [%s] failed: %s", pagePath, err)
+			continue
+		}
+
+		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", i+1, len(docs), doc.Title)))
+	}
+
+	if err = writeSiteSearchIndex(exportFolder, rootIDs); err != nil {
+		return
+	}
+
+	index := "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><meta http-equiv=\"refresh\" content=\"0; url=" + docs[0].ID + ".html\"></head><body></body></html>"
+	if err = gulu.File.WriteFileSafer(filepath.Join(exportFolder, "index.html"), []byte(index), 0644); err != nil {
+		logging.LogErrorf("write site index failed: %s", err)
+		return
+	}
+
+	zipPath = exportFolder + ".zip"
+	zip, err := gulu.Zip.Create(zipPath)
+	if err != nil {
+		logging.LogErrorf("create export site zip [%s] failed: %s", exportFolder, err)
+		return ""
+	}
+	zipCallback := func(filename string) {
+		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(253), filename))
+	}
+	if err = zip.AddDirectory(filepath.Base(exportFolder), exportFolder, zipCallback); err != nil {
+		logging.LogErrorf("create export site zip [%s] failed: %s", exportFolder, err)
+		return ""
+	}
+	if err = zip.Close(); err != nil {
+		logging.LogErrorf("close export site zip failed: %s", err)
+		return ""
+	}
+
+	os.RemoveAll(exportFolder)
+	zipPath = "/export/" + url.PathEscape(filepath.Base(zipPath))
+	return
+}
+
+// listSiteDocs 按文档树排序列出 p 下的文档及其所有下级文档。
+func listSiteDocs(boxID, p string) (ret []*siteDoc) {
+	files, _, err := ListDocTree(boxID, p, util.SortModeUnassigned, false, false, 102400)
+	if err != nil {
+		logging.LogErrorf("list doc tree [%s/%s] failed: %s", boxID, p, err)
+		return
+	}
+
+	for _, file := range files {
+		doc := &siteDoc{ID: file.ID, Box: boxID, Path: file.Path, Title: strings.TrimSuffix(file.Name, ".sy")}
+		if 0 < file.SubFileCount {
+			doc.Children = listSiteDocs(boxID, file.Path)
+		}
+		ret = append(ret, doc)
+	}
+	return
+}
+
+func collectSiteDocs(nav []*siteDoc, docs *[]*siteDoc) {
+	for _, doc := range nav {
+		*docs = append(*docs, doc)
+		collectSiteDocs(doc.Children, docs)
+	}
+}
+
+// rewriteSiteLinks 将指向块的链接改写为站点页面锚点，指向站点以外文档的链接转换为普通文本。
+func rewriteSiteLinks(tree *parse.Tree, pages map[string]bool) {
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeTextMark != n.Type || !n.IsTextMarkType("a") || !strings.HasPrefix(n.TextMarkAHref, "siyuan://blocks/") {
+			return ast.WalkContinue
+		}
+
+		defID := strings.TrimPrefix(n.TextMarkAHref, "siyuan://blocks/")
+		if bt := treenode.GetBlockTree(defID); nil != bt && pages[bt.RootID] {
+			n.TextMarkAHref = bt.RootID + ".html"
+			if defID != bt.RootID {
+				n.TextMarkAHref += "#" + defID
+			}
+			return ast.WalkContinue
+		}
+
+		var types []string
+		for _, typ := range strings.Fields(n.TextMarkType) {
+			if "a" != typ {
+				types = append(types, typ)
+			}
+		}
+		n.TextMarkType = strings.Join(types, " ")
+		n.TextMarkAHref = ""
+		if "" == n.TextMarkType {
+			n.Type = ast.NodeText
+			n.Tokens = []byte(n.TextMarkTextContent)
+		}
+		return ast.WalkContinue
+	})
+}
+
+// copySiteAssets 只复制文档中引用的资源文件和自定义表情。
+func copySiteAssets(tree *parse.Tree, exportFolder string) {
+	for _, asset := range getAssetsLinkDests(tree.Root, false) {
+		if strings.Contains(asset, "?") {
+			asset = asset[:strings.LastIndex(asset, "?")]
+		}
+
+		targetAbsPath := filepath.Join(exportFolder, asset)
+		if gulu.File.IsExist(targetAbsPath) {
+			continue
+		}
+
+		srcAbsPath, err := GetAssetAbsPath(asset)
+		if err != nil {
+			logging.LogWarnf("resolve path of asset [%s] failed: %s", asset, err)
+			continue
+		}
+		if err = filelock.Copy(srcAbsPath, targetAbsPath); err != nil {
+			logging.LogWarnf("copy asset from [%s] to [%s] failed: %s", srcAbsPath, targetAbsPath, err)
+		}
+	}
+
+	for _, emoji := range emojisInTree(tree) {
+		from := filepath.Join(util.DataDir, emoji)
+		to := filepath.Join(exportFolder, emoji)
+		if err := filelock.Copy(from, to); err != nil {
+			logging.LogErrorf("copy emojis from [%s] to [%s] failed: %s", from, to, err)
+		}
+	}
+}
+
+func siteBacklinks(rootID string, refRoots []*sql.Block, pages map[string]bool) (ret []*siteBacklink) {
+	added := map[string]bool{}
+	for _, refRoot := range refRoots {
+		if rootID == refRoot.ID || !pages[refRoot.ID] || added[refRoot.ID] {
+			continue
+		}
+
+		added[refRoot.ID] = true
+		ret = append(ret, &siteBacklink{ID: refRoot.ID, Title: refRoot.Content})
+	}
+	return
+}
+
+// writeSiteSearchIndex 使用 blocks 表生成搜索索引，索引写入脚本文件以便通过 file:// 协议离线打开时也能加载。
+func writeSiteSearchIndex(exportFolder string, rootIDs []string) (err error) {
+	entries := []*siteSearchEntry{}
+	stmt := "SELECT * FROM blocks WHERE root_id IN ('" + strings.Join(rootIDs, "','") + "') AND content != '' AND type NOT IN ('l', 'b', 's')"
+	for _, block := range sql.SelectBlocksRawStmtNoParse(stmt, 1024*1024) {
+		entries = append(entries, &siteSearchEntry{ID: block.ID, RootID: block.RootID, Content: gulu.Str.SubStr(block.Content, 512)})
+	}
+
+	data, err := gulu.JSON.MarshalJSON(entries)
+	if err != nil {
+		logging.LogErrorf("marshal site search index failed: %s", err)
+		return
+	}
+
+	indexPath := filepath.Join(exportFolder, "search.js")
+	data = append(append([]byte("window.siyuanSiteSearch = "), data...), ';')
+	if err = gulu.File.WriteFileSafer(indexPath, data, 0644); err != nil {
+		logging.LogErrorf("write site search index [%s] failed: %s", indexPath, err)
+	}
+	return
+}
+
+func newSitePage(doc *siteDoc, nav []*siteDoc) *sitePage {
+	theme, themeMode := Conf.Appearance.ThemeLight, "light"
+	if 1 == Conf.Appearance.Mode {
+		theme, themeMode = Conf.Appearance.ThemeDark, "dark"
+	}
+	return &sitePage{
+		ID:                  doc.ID,
+		Title:               doc.Title,
+		Lang:                Conf.Appearance.Lang,
+		Mode:                Conf.Appearance.Mode,
+		Theme:               theme,
+		ThemeMode:           themeMode,
+		ThemeLight:          Conf.Appearance.ThemeLight,
+		ThemeDark:           Conf.Appearance.ThemeDark,
+		Icon:                Conf.Appearance.Icon,
+		Nav:                 nav,
+		CodeBlockThemeLight: Conf.Appearance.CodeBlockThemeLight,
+		CodeBlockThemeDark:  Conf.Appearance.CodeBlockThemeDark,
+		FontSize:            Conf.Editor.FontSize,
+		PlantUMLServePath:   Conf.Editor.PlantUMLServePath,
+		KaTexMacros:         Conf.Editor.KaTexMacros,
+	}
+}
+
+const siteTemplate = `{{define "nav"}}<ul>{{range .}}<li><a href="{{.ID}}.html">{{.Title}}</a>{{if .Children}}{{template "nav" .Children}}{{end}}</li>{{end}}</ul>{{end}}<!DOCTYPE html>
+<html lang="{{.Lang}}" data-theme-mode="{{.ThemeMode}}" data-light-theme="{{.ThemeLight}}" data-dark-theme="{{.ThemeDark}}">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0"/>
+    <link rel="stylesheet" type="text/css" id="baseStyle" href="stage/build/export/base.css"/>
+    <link rel="stylesheet" type="text/css" id="themeDefaultStyle" href="appearance/themes/{{.Theme}}/theme.css"/>
+    <script src="stage/protyle/js/protyle-html.js"></script>
+    <title>{{.Title}}</title>
+    <style>
+        body {margin: 0;display: flex;font-family: var(--b3-font-family);background-color: var(--b3-theme-background);color: var(--b3-theme-on-background)}
+        .site-nav {width: 280px;flex-shrink: 0;height: 100vh;overflow: auto;position: sticky;top: 0;padding: 16px;box-sizing: border-box;border-right: 1px solid var(--b3-border-color);font-size: 14px}
+        .site-nav ul {list-style: none;padding-left: 12px;margin: 0}
+        .site-nav a {color: var(--b3-theme-on-background);text-decoration: none;display: block;padding: 2px 0}
+        .site-nav a.site-nav--current {color: var(--b3-theme-primary);font-weight: bold}
+        .site-search {width: 100%;box-sizing: border-box;margin-bottom: 8px;padding: 4px 8px}
+        .site-main {flex: 1;min-width: 0;padding: 16px 32px}
+        .site-backlinks {max-width: 800px;margin: 32px auto 0;padding-top: 16px;border-top: 1px solid var(--b3-border-color)}
+    </style>
+</head>
+<body data-id="{{.ID}}">
+<nav class="site-nav">
+    <input class="site-search" type="search" placeholder="Search">
+    <ul class="site-search__results"></ul>
+    <div class="site-tree">{{template "nav" .Nav}}</div>
+</nav>
+<main class="site-main">
+    <div class="protyle-wysiwyg" style="max-width: 800px;margin: 0 auto;" id="preview">{{.Content}}</div>
+    {{if .Backlinks}}<div class="site-backlinks"><h3>Backlinks</h3><ul>{{range .Backlinks}}<li><a href="{{.ID}}.html">{{.Title}}</a></li>{{end}}</ul></div>{{end}}
+</main>
+{{if .Icon}}<script src="appearance/icons/{{.Icon}}/icon.js"></script>{{end}}
+<script src="stage/build/export/protyle-method.js"></script>
+<script src="stage/protyle/js/lute/lute.min.js"></script>
+<script src="search.js"></script>
+<script>
+    window.siyuan = {
+        config: {
+            appearance: {mode: {{.Mode}}, codeBlockThemeDark: {{.CodeBlockThemeDark}}, codeBlockThemeLight: {{.CodeBlockThemeLight}}},
+            editor: {codeLineWrap: true, fontSize: {{.FontSize}}, codeLigatures: false, plantUMLServePath: {{.PlantUMLServePath}}, codeSyntaxHighlightLineNum: false, katexMacros: {{.KaTexMacros}}}
+        },
+        languages: {copy: "Copy"}
+    };
+    const previewElement = document.getElementById("preview");
+    Protyle.highlightRender(previewElement, "stage/protyle");
+    Protyle.mathRender(previewElement, "stage/protyle", false);
+    Protyle.mermaidRender(previewElement, "stage/protyle");
+    Protyle.flowchartRender(previewElement, "stage/protyle");
+    Protyle.graphvizRender(previewElement, "stage/protyle");
+    Protyle.chartRender(previewElement, "stage/protyle");
+    Protyle.mindmapRender(previewElement, "stage/protyle");
+    Protyle.abcRender(previewElement, "stage/protyle");
+    Protyle.htmlRender(previewElement);
+    Protyle.plantumlRender(previewElement, "stage/protyle");
+
+    const currentID = document.body.getAttribute("data-id");
+    document.querySelectorAll(".site-tree a").forEach((item) => {
+        if (item.getAttribute("href") === currentID + ".html") {
+            item.classList.add("site-nav--current");
+        }
+    });
+    const scrollToHash = () => {
+        if (!location.hash) {
+            return;
+        }
+        const blockElement = document.querySelector('[data-node-id="' + decodeURIComponent(location.hash.substring(1)) + '"]');
+        if (blockElement) {
+            blockElement.scrollIntoView();
+        }
+    };
+    scrollToHash();
+    window.addEventListener("hashchange", scrollToHash);
+
+    const searchInput = document.querySelector(".site-search");
+    const searchResults = document.querySelector(".site-search__results");
+    searchInput.addEventListener("input", () => {
+        const keyword = searchInput.value.trim().toLowerCase();
+        searchResults.innerHTML = "";
+        if (!keyword) {
+            return;
+        }
+        let count = 0;
+        for (const item of window.siyuanSiteSearch) {
+            if (64 <= count) {
+                break;
+            }
+            if (-1 === item.c.toLowerCase().indexOf(keyword)) {
+                continue;
+            }
+            const link = document.createElement("a");
+            link.href = item.r + ".html" + (item.i === item.r ? "" : "#" + item.i);
+            link.textContent = 64 < item.c.length ? item.c.substring(0, 64) + "..." : item.c;
+            const li = document.createElement("li");
+            li.appendChild(link);
+            searchResults.appendChild(li);
+            count++;
+        }
+    });
+</script>
+</body>
+</html>`
@@ -0,0 +1,514 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/lex"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const typstMitexVersion = "0.2.5"
+
+// typstMathPreamble 为公式渲染需要的前导代码，导出内容不依赖当前机器的包缓存和网络。
+// 无法下载 mitex 包时可以按照注释替换为按 LaTeX 源码输出公式的定义。
+const typstMathPreamble = "#import \"@preview/mitex:" + typstMitexVersion + "\": *\n" +
+	"// Without access to the Typst package registry, replace the import above with the two lines below to show math as LaTeX source:\n" +
+	"// #let mitex(tex) = block(raw(lang: \"latex\", tex))\n" +
+	"// #let mi(tex) = raw(lang: \"latex\", tex)\n"
+
+// ExportTypst 导出 Typst 源文件，合并导出的文档和资源文件打包为 zip，用于在无界面的服务器上排版打印。
+func ExportTypst(id string) (name, zipPath string) {
+	defer util.ClearPushProgress(100)
+
+	baseName, trees := prepareExportBookTrees(id)
+	if 1 > len(trees) {
+		return
+	}
+
+	exportFolder := filepath.Join(util.TempDir, "export", baseName+".typ")
+	os.RemoveAll(exportFolder)
+	if err := os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+
+	w := &typstWriter{exportFolder: exportFolder, blocks: map[string]bool{}, anchors: map[string]bool{}, assets: map[string]bool{}}
+	docLevels := map[string]int{}
+	for _, tree := range trees {
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering {
+				return ast.WalkContinue
+			}
+
+			if "" != n.ID {
+				w.blocks[n.ID] = true
+			}
+			if ast.NodeTextMark == n.Type && n.IsTextMarkType("a") && strings.HasPrefix(n.TextMarkAHref, "siyuan://blocks/") {
+				w.anchors[strings.TrimPrefix(n.TextMarkAHref, "siyuan://blocks/")] = true
+			}
+			return ast.WalkContinue
+		})
+
+		level := 1
+		if parent := findExportParentTree(tree, docLevels); 0 < parent {
+			level = parent + 1
+		}
+		docLevels[tree.ID] = level
+	}
+
+	body := bytes.Buffer{}
+	for i, tree := range trees {
+		w.tree = tree
+		w.level = docLevels[tree.ID]
+		title := tree.Root.IALAttr("title")
+		body.WriteString(strings.Repeat("=", min(w.level, 6)) + " " + typstEscape(title) + " <b-" + tree.ID + ">\n\n")
+		w.buf = &body
+		w.renderChildren(tree.Root)
+		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", i+1, len(trees), title)))
+	}
+
+	preamble := bytes.Buffer{}
+	preamble.WriteString("#set document(title: " + typstString(baseName) + ")\n")
+	preamble.WriteString("#set page(paper: \"a4\", numbering: \"1\")\n")
+	if lang := strings.Split(Conf.Lang, "_")[0]; "" != lang {
+		preamble.WriteString("#set text(lang: " + typstString(lang) + ")\n")
+	}
+	preamble.WriteString("#set heading(numbering: none)\n")
+	if w.math {
+		preamble.WriteString(typstMathPreamble)
+	}
+	preamble.WriteString("\n#outline()\n#pagebreak()\n\n")
+
+	typPath := filepath.Join(exportFolder, baseName+".typ")
+	if err := filelock.WriteFile(typPath, append(preamble.Bytes(), body.Bytes()...)); nil != err {
+		logging.LogErrorf("write typst file [%s] failed: %s", typPath, err)
+		return
+	}
+
+	zipPath = zipExportFolder(exportFolder)
+	name = trees[0].ID
+	return
+}
+
+type typstWriter struct {
+	buf          *bytes.Buffer
+	tree         *parse.Tree
+	level        int             // 当前文档的标题层级
+	exportFolder string          // 导出目录
+	blocks       map[string]bool // 导出范围内的块
+	anchors      map[string]bool // 被引用的块，需要生成标签
+	assets       map[string]bool // 已经复制的资源文件
+	math         bool            // 是否包含公式
+}
+
+func (w *typstWriter) renderChildren(n *ast.Node) {
+	for c := n.FirstChild; nil != c; c = c.Next {
+		w.renderBlock(c)
+	}
+}
+
+func (w *typstWriter) renderBlock(n *ast.Node) {
+	anchor := "" != n.ID && w.anchors[n.ID] && ast.NodeHeading != n.Type
+	if anchor {
+		w.buf.WriteString("#metadata(none) <b-" + n.ID + ">\n")
+	}
+
+	switch n.Type {
+	case ast.NodeParagraph:
+		w.renderInlines(n)
+		w.buf.WriteString("\n\n")
+	case ast.NodeHeading:
+		level := min(w.level+n.HeadingLevel, 6)
+		w.buf.WriteString(strings.Repeat("=", level) + " ")
+		w.renderInlines(n)
+		w.buf.WriteString(" <b-" + n.ID + ">\n\n")
+	case ast.NodeBlockquote:
+		w.buf.WriteString("#quote(block: true)[\n")
+		w.renderChildren(n)
+		w.buf.WriteString("]\n\n")
+	case ast.NodeCallout:
+		title := n.CalloutTitle
+		if "" == title {
+			title = n.CalloutType
+		}
+		w.buf.WriteString("#block(fill: luma(245), stroke: (left: 2pt + luma(160)), inset: 8pt, width: 100%)[\n")
+		w.buf.WriteString("#strong[" + typstEscape(html.UnescapeString(title)) + "]\n\n")
+		w.renderChildren(n)
+		w.buf.WriteString("]\n\n")
+	case ast.NodeList:
+		w.renderList(n)
+	case ast.NodeThematicBreak:
+		w.buf.WriteString("#line(length: 100%)\n\n")
+	case ast.NodeCodeBlock:
+		code := n.ChildByType(ast.NodeCodeBlockCode)
+		if nil == code {
+			break
+		}
+		var language string
+		if info := code.Previous; nil != info && 0 < len(info.CodeBlockInfo) {
+			language = string(lex.Split(info.CodeBlockInfo, lex.ItemSpace)[0])
+		}
+		w.buf.WriteString("#raw(block: true")
+		if "" != language {
+			w.buf.WriteString(", lang: " + typstString(language))
+		}
+		w.buf.WriteString(", " + typstString(strings.TrimSuffix(string(code.Tokens), "\n")) + ")\n\n")
+	case ast.NodeMathBlock:
+		if content := n.ChildByType(ast.NodeMathBlockContent); nil != content {
+			w.math = true
+			w.buf.WriteString("#mitex(" + typstString(strings.TrimSpace(string(content.Tokens))) + ")\n\n")
+		}
+	case ast.NodeTable:
+		w.renderTable(n)
+	case ast.NodeSuperBlock, ast.NodeBlockQueryEmbed:
+		w.renderChildren(n)
+	case ast.NodeIFrame, ast.NodeVideo, ast.NodeAudio, ast.NodeWidget:
+		if src := treenode.GetNodeSrcTokens(n); isRemoteExportLink(src) {
+			w.buf.WriteString("#link(" + typstString(src) + ")\n\n")
+		}
+	case ast.NodeFootnotesDefBlock, ast.NodeKramdownBlockIAL, ast.NodeHTMLBlock, ast.NodeBlockQueryEmbedScript:
+		// 脚注定义在引用处内联输出，其他节点忽略
+	default:
+		if nil != n.FirstChild && ast.NodeDocument != n.Type {
+			w.renderChildren(n)
+		}
+	}
+}
+
+func (w *typstWriter) renderList(n *ast.Node) {
+	fn := "list"
+	if 1 == n.ListData.Typ {
+		fn = "enum"
+	}
+	w.buf.WriteString("#" + fn + "(")
+	if "enum" == fn && 1 < n.ListData.Start {
+		w.buf.WriteString("start: " + strconv.Itoa(n.ListData.Start) + ", ")
+	}
+	if 3 == n.ListData.Typ {
+		w.buf.WriteString("marker: none, ")
+	}
+	if n.ListData.Tight {
+		w.buf.WriteString("tight: true,\n")
+	} else {
+		w.buf.WriteString("tight: false,\n")
+	}
+	for li := n.FirstChild; nil != li; li = li.Next {
+		if ast.NodeListItem != li.Type {
+			continue
+		}
+		w.buf.WriteString("[")
+		if 3 == li.ListData.Typ {
+			if li.ListData.Checked {
+				w.buf.WriteString("☑ ")
+			} else {
+				w.buf.WriteString("☐ ")
+			}
+		}
+		for c := li.FirstChild; nil != c; c = c.Next {
+			if ast.NodeTaskListItemMarker == c.Type {
+				continue
+			}
+			if ast.NodeParagraph == c.Type && li.ListData.Tight {
+				w.renderInlines(c)
+				if nil != c.Next {
+					w.buf.WriteString("\n\n")
+				}
+				continue
+			}
+			w.renderBlock(c)
+		}
+		w.buf.WriteString("],\n")
+	}
+	w.buf.WriteString(")\n\n")
+}
+
+func (w *typstWriter) renderTable(n *ast.Node) {
+	var aligns []string
+	for _, align := range n.TableAligns {
+		switch align {
+		case 2:
+			aligns = append(aligns, "center")
+		case 3:
+			aligns = append(aligns, "right")
+		default:
+			aligns = append(aligns, "left")
+		}
+	}
+	if 1 > len(aligns) {
+		return
+	}
+
+	w.buf.WriteString("#table(columns: " + strconv.Itoa(len(aligns)) + ", align: (" + strings.Join(aligns, ", ") + ",),\n")
+	for row := n.FirstChild; nil != row; row = row.Next {
+		if ast.NodeTableHead == row.Type {
+			w.buf.WriteString("table.header(")
+			if nil != row.FirstChild {
+				w.renderTableRow(row.FirstChild)
+			}
+			w.buf.WriteString("),\n")
+			continue
+		}
+		if ast.NodeTableRow == row.Type {
+			w.renderTableRow(row)
+			w.buf.WriteString("\n")
+		}
+	}
+	w.buf.WriteString(")\n\n")
+}
+
+func (w *typstWriter) renderTableRow(row *ast.Node) {
+	for cell := row.FirstChild; nil != cell; cell = cell.Next {
+		if ast.NodeTableCell != cell.Type {
+			continue
+		}
+		w.buf.WriteString("[")
+		w.renderInlines(cell)
+		w.buf.WriteString("], ")
+	}
+}
+
+func (w *typstWriter) renderInlines(n *ast.Node) {
+	for c := n.FirstChild; nil != c; c = c.Next {
+		w.renderInline(c)
+	}
+}
+
+func (w *typstWriter) renderInline(n *ast.Node) {
+	switch n.Type {
+	case ast.NodeText:
+		text := typstEscape(string(n.Tokens))
+		w.buf.WriteString(strings.ReplaceAll(text, "\n", " \\\n"))
+	case ast.NodeBackslashContent:
+		w.buf.WriteString(typstEscape(string(n.Tokens)))
+	case ast.NodeBr, ast.NodeHardBreak:
+		w.buf.WriteString(" \\\n")
+	case ast.NodeSoftBreak:
+		w.buf.WriteString("\n")
+	case ast.NodeCodeSpan:
+		if content := n.ChildByType(ast.NodeCodeSpanContent); nil != content {
+			w.buf.WriteString("#raw(" + typstString(string(content.Tokens)) + ");")
+		}
+	case ast.NodeInlineMath:
+		if content := n.ChildByType(ast.NodeInlineMathContent); nil != content {
+			w.math = true
+			w.buf.WriteString("#mi(" + typstString(string(content.Tokens)) + ");")
+		}
+	case ast.NodeEmoji:
+		if unicode := n.ChildByType(ast.NodeEmojiUnicode); nil != unicode {
+			w.buf.Write(unicode.Tokens)
+		} else if alias := n.ChildByType(ast.NodeEmojiAlias); nil != alias {
+			w.buf.WriteString(typstEscape(string(alias.Tokens)))
+		}
+	case ast.NodeImage:
+		w.renderImage(n)
+	case ast.NodeLink:
+		dest := n.ChildByType(ast.NodeLinkDest)
+		text := n.ChildByType(ast.NodeLinkText)
+		var label string
+		if nil != text {
+			label = string(text.Tokens)
+		}
+		if nil == dest {
+			w.buf.WriteString(typstEscape(label))
+			break
+		}
+		w.renderLink(string(dest.Tokens), typstEscape(label))
+	case ast.NodeTextMark:
+		w.renderTextMark(n)
+	case ast.NodeStrong, ast.NodeEmphasis, ast.NodeStrikethrough, ast.NodeMark, ast.NodeSup, ast.NodeSub:
+		fn := map[ast.NodeType]string{ast.NodeStrong: "strong", ast.NodeEmphasis: "emph", ast.NodeStrikethrough: "strike",
+			ast.NodeMark: "highlight", ast.NodeSup: "super", ast.NodeSub: "sub"}[n.Type]
+		w.buf.WriteString("#" + fn + "[")
+		w.renderInlines(n)
+		w.buf.WriteString("];")
+	case ast.NodeFootnotesRef:
+		_, def := w.tree.FindFootnotesDef(n.Tokens)
+		if nil == def {
+			break
+		}
+		w.buf.WriteString("#footnote[")
+		for c := def.FirstChild; nil != c; c = c.Next {
+			if ast.NodeParagraph == c.Type {
+				w.renderInlines(c)
+			}
+		}
+		w.buf.WriteString("];")
+	case ast.NodeKramdownSpanIAL, ast.NodeInlineHTML:
+	default:
+		w.renderInlines(n)
+	}
+}
+
+func (w *typstWriter) renderTextMark(n *ast.Node) {
+	content := html.UnescapeString(n.TextMarkTextContent)
+	ret := typstEscape(content)
+	if n.IsTextMarkType("code") || n.IsTextMarkType("kbd") {
+		ret = "#raw(" + typstString(content) + ")"
+	}
+	if n.IsTextMarkType("inline-math") {
+		w.math = true
+		ret = "#mi(" + typstString(html.UnescapeString(n.TextMarkInlineMathContent)) + ")"
+	}
+	if n.IsTextMarkType("tag") {
+		ret = typstEscape("#" + content + "#")
+	}
+
+	for _, typ := range strings.Split(n.TextMarkType, " ") {
+		switch typ {
+		case "strong":
+			ret = "#strong[" + ret + "]"
+		case "em":
+			ret = "#emph[" + ret + "]"
+		case "u":
+			ret = "#underline[" + ret + "]"
+		case "s":
+			ret = "#strike[" + ret + "]"
+		case "mark":
+			ret = "#highlight[" + ret + "]"
+		case "sup":
+			ret = "#super[" + ret + "]"
+		case "sub":
+			ret = "#sub[" + ret + "]"
+		}
+	}
+
+	if n.IsTextMarkType("a") {
+		w.renderLink(n.TextMarkAHref, ret)
+		return
+	}
+	w.buf.WriteString(ret)
+	if strings.HasPrefix(ret, "#") {
+		w.buf.WriteString(";")
+	}
+}
+
+func (w *typstWriter) renderLink(href, label string) {
+	if strings.HasPrefix(href, "siyuan://blocks/") {
+		defID := strings.TrimPrefix(href, "siyuan://blocks/")
+		if w.blocks[defID] {
+			w.buf.WriteString("#link(<b-" + defID + ">)[" + label + "];")
+			return
+		}
+		w.buf.WriteString(label)
+		return
+	}
+
+	if strings.HasPrefix(href, "assets/") {
+		if dest := w.copyAsset(href); "" != dest {
+			w.buf.WriteString("#link(" + typstString(dest) + ")[" + label + "];")
+			return
+		}
+	}
+
+	if "" == label {
+		label = typstEscape(href)
+	}
+	w.buf.WriteString("#link(" + typstString(href) + ")[" + label + "];")
+}
+
+func (w *typstWriter) renderImage(n *ast.Node) {
+	dest := n.ChildByType(ast.NodeLinkDest)
+	if nil == dest {
+		return
+	}
+	src := string(dest.Tokens)
+	var alt string
+	if text := n.ChildByType(ast.NodeLinkText); nil != text {
+		alt = string(text.Tokens)
+	}
+
+	if isRemoteExportLink(src) {
+		// Typst 不支持远程图片，转换为链接
+		if "" == alt {
+			alt = src
+		}
+		w.buf.WriteString("#link(" + typstString(src) + ")[" + typstEscape(alt) + "];")
+		return
+	}
+
+	local := w.copyAsset(src)
+	if "" == local {
+		w.buf.WriteString(typstEscape(alt))
+		return
+	}
+	switch strings.ToLower(path.Ext(local)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp":
+		w.buf.WriteString("#box(image(" + typstString(local) + ", alt: " + typstString(alt) + "));")
+	default:
+		w.buf.WriteString("#link(" + typstString(local) + ")[" + typstEscape(alt) + "];")
+	}
+}
+
+// copyAsset 将资源文件复制到导出目录下，返回在导出目录下的相对路径。
+func (w *typstWriter) copyAsset(src string) string {
+	src = string(html.DecodeDestination([]byte(src)))
+	if idx := strings.Index(src, "?"); 0 < idx {
+		src = src[:idx]
+	}
+	if !strings.HasPrefix(src, "assets/") {
+		return ""
+	}
+	if w.assets[src] {
+		return src
+	}
+
+	absPath, err := GetAssetAbsPath(src)
+	if nil != err {
+		logging.LogWarnf("get asset [%s] abs path failed: %s", src, err)
+		return ""
+	}
+	if err = filelock.Copy(absPath, filepath.Join(w.exportFolder, src)); nil != err {
+		logging.LogErrorf("copy asset from [%s] failed: %s", absPath, err)
+		return ""
+	}
+	w.assets[src] = true
+	return src
+}
+
+// typstEscape 转义 Typst 标记语法中的特殊字符。
+func typstEscape(text string) string {
+	buf := strings.Builder{}
+	for _, r := range text {
+		switch r {
+		case '\\', '*', '_', '`', '$', '#', '@', '<', '>', '[', ']', '~', '=', '-', '+', '/', '"':
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// typstString 生成 Typst 字符串字面量。
+func typstString(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\\\")
+	text = strings.ReplaceAll(text, "\"", "\\\"")
+	return "\"" + text + "\""
+}
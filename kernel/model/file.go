@@ -1090,6 +1090,33 @@ func CreateWithMarkdown(tags, boxID, hPath, md, parentID, id string, withMath bo
 
 const DailyNoteAttrPrefix = "custom-dailynote-"
 
+// getDailyNoteHPath 返回笔记本今天的日记路径，未配置日记路径时返回空。
+func getDailyNoteHPath(boxID string) string {
+	box := Conf.Box(boxID)
+	if nil == box {
+		return ""
+	}
+
+	boxConf := box.GetConf()
+	if "" == boxConf.DailyNoteSavePath || "/" == boxConf.DailyNoteSavePath {
+		return ""
+	}
+
+	hPath, err := renderDailyNoteHPath(boxConf.DailyNoteSavePath)
+	if err != nil {
+		return ""
+	}
+	return hPath
+}
+
+func renderDailyNoteHPath(savePath string) (ret string, err error) {
+	if ret, err = RenderGoTemplate(savePath); err != nil {
+		return
+	}
+	ret = util.TrimSpaceInPath(ret)
+	return
+}
+
 func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 	createDocLock.Lock()
 	defer createDocLock.Unlock()
@@ -1106,14 +1133,13 @@ func CreateDailyNote(boxID string) (p string, existed bool, err error) {
 		return
 	}
 
-	hPath, err := RenderGoTemplate(boxConf.DailyNoteSavePath)
+	hPath, err := renderDailyNoteHPath(boxConf.DailyNoteSavePath)
 	if err != nil {
 		return
 	}
 
 	FlushTxQueue()
 
-	hPath = util.TrimSpaceInPath(hPath)
 	existRoot := treenode.GetBlockTreeRootByHPath(box.ID, hPath)
 	if nil != existRoot {
 		existed = true
@@ -1512,6 +1538,13 @@ func moveDoc(fromBox *Box, fromPath string, toBox *Box, toPath string, luteEngin
 	util.PushEvent(evt)
 
 	refreshDocInfo(fromParentTree)
+
+	webhookEvt := newWebhookEventByTree(webhookEventDocMoved, tree)
+	webhookEvt.Data["fromBox"] = fromBox.ID
+	webhookEvt.Data["fromPath"] = fromPath
+	webhookEvt.Data["toBox"] = toBox.ID
+	webhookEvt.Data["toPath"] = newPath
+	fireWebhookEvent(webhookEvt)
 	return
 }
 
@@ -1619,6 +1652,10 @@ func removeDoc(box *Box, p string, luteEngine *lute.Lute) {
 
 	refreshParentDocInfo(tree)
 	task.AppendTask(task.DatabaseIndex, removeDoc0, tree, childrenDir)
+
+	webhookEvt := newWebhookEventByTree(webhookEventDocRemoved, tree)
+	webhookEvt.IDs = allRemoveRootIDs
+	fireWebhookEvent(webhookEvt)
 }
 
 func removeDoc0(tree *parse.Tree, childrenDir string) {
@@ -1685,6 +1722,11 @@ func RenameDoc(boxID, p, title string) (err error) {
 	box.renameSubTrees(tree)
 	updateRefTextRenameDoc(tree)
 	IncSync()
+
+	webhookEvt := newWebhookEventByTree(webhookEventDocRenamed, tree)
+	webhookEvt.Data["oldTitle"] = oldTitle
+	webhookEvt.Data["title"] = title
+	fireWebhookEvent(webhookEvt)
 	return
 }
 
@@ -1799,6 +1841,11 @@ func createDoc(boxID, p, title, dom string) (tree *parse.Tree, err error) {
 	transaction := &Transaction{DoOperations: []*Operation{{Action: "create", Data: tree}}}
 	PerformTransactions(&[]*Transaction{transaction})
 	FlushTxQueue()
+
+	fireAutomationEvent(&AutomationEvent{Type: "doc", ID: id, RootID: id, Box: boxID, Path: p, HPath: hPath, Name: title})
+	webhookEvt := newWebhookEventByTree(webhookEventDocCreated, tree)
+	webhookEvt.Data["title"] = title
+	fireWebhookEvent(webhookEvt)
 	return
 }
 
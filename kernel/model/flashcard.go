@@ -237,9 +237,9 @@ func countTreeFlashcard(rootID string, deck *riff.Deck, deckBlockIDs []string) (
 		return
 	}
 
-	newFlashCards := deck.GetNewCardsByBlockIDs(blockIDs)
+	newFlashCards := filterOrphanedFlashcards(deck.GetNewCardsByBlockIDs(blockIDs))
 	newFlashcardCount = len(newFlashCards)
-	newDueFlashcards := deck.GetDueCardsByBlockIDs(blockIDs)
+	newDueFlashcards := filterOrphanedFlashcards(deck.GetDueCardsByBlockIDs(blockIDs))
 	dueFlashcardCount = len(newDueFlashcards)
 	return
 }
@@ -255,9 +255,9 @@ func countBoxFlashcard(boxID string, deck *riff.Deck, deckBlockIDs []string) (ne
 		return
 	}
 
-	newFlashCards := deck.GetNewCardsByBlockIDs(blockIDs)
+	newFlashCards := filterOrphanedFlashcards(deck.GetNewCardsByBlockIDs(blockIDs))
 	newFlashcardCount = len(newFlashCards)
-	newDueFlashcards := deck.GetDueCardsByBlockIDs(blockIDs)
+	newDueFlashcards := filterOrphanedFlashcards(deck.GetDueCardsByBlockIDs(blockIDs))
 	dueFlashcardCount = len(newDueFlashcards)
 	return
 }
@@ -437,6 +437,8 @@ func getCardsBlocks(cards []riff.Card, page, pageSize int) (blocks []*Block, tot
 
 		b.RiffCardID = cards[i].ID()
 		b.RiffCard = getRiffCard(cards[i].(*riff.FSRSCard).C)
+		variant := getFlashcardVariant(b.RiffCardID)
+		b.RiffCard.Type, b.RiffCard.Key, b.RiffCard.Orphaned = variant.Type, variant.Key, variant.Orphaned
 	}
 	return
 }
@@ -499,6 +501,14 @@ func ReviewFlashcard(deckID, cardID string, rating riff.Rating, reviewedCardIDs
 		return
 	}
 
+	if webhookSubscribed(webhookEventFlashcardReviewed) {
+		webhookEvt := newWebhookEventByBlockID(webhookEventFlashcardReviewed, card.BlockID())
+		webhookEvt.Data["deckID"] = deckID
+		webhookEvt.Data["cardID"] = cardID
+		webhookEvt.Data["rating"] = rating
+		fireWebhookEvent(webhookEvt)
+	}
+
 	_, unreviewedCount, _, _ := getDueFlashcards(deckID, reviewedCardIDs)
 	if 1 > unreviewedCount {
 		// 该卡包中没有待复习的卡片了，说明最后一张卡片已经复习完了，清空撤销缓存和跳过缓存
@@ -533,6 +543,8 @@ type Flashcard struct {
 	State      riff.State             `json:"state"`
 	LastReview int64                  `json:"lastReview"`
 	NextDues   map[riff.Rating]string `json:"nextDues"`
+	Type       string                 `json:"type"`
+	Key        string                 `json:"key"`
 }
 
 func newFlashcard(card riff.Card, deckID string, now time.Time) *Flashcard {
@@ -541,6 +553,7 @@ func newFlashcard(card riff.Card, deckID string, now time.Time) *Flashcard {
 		nextDues[rating] = strings.TrimSpace(util.HumanizeDiffTime(due, now, Conf.Lang))
 	}
 
+	variant := getFlashcardVariant(card.ID())
 	return &Flashcard{
 		DeckID:     deckID,
 		CardID:     card.ID(),
@@ -550,6 +563,8 @@ func newFlashcard(card riff.Card, deckID string, now time.Time) *Flashcard {
 		State:      card.GetState(),
 		LastReview: card.GetLastReview().UnixMilli(),
 		NextDues:   nextDues,
+		Type:       variant.Type,
+		Key:        variant.Key,
 	}
 }
 
@@ -848,6 +863,7 @@ func removeFlashcardsByBlockIDs(blockIDs []string, deck *riff.Deck) {
 	for _, card := range cards {
 		deck.RemoveCard(card.ID())
 	}
+	removeFlashcardVariants(cards)
 	err := deck.Save()
 	if err != nil {
 		logging.LogErrorf("save deck [%s] failed: %s", deck.ID, err)
@@ -891,6 +907,7 @@ func (tx *Transaction) doAddFlashcards(operation *Operation) (ret *TxErr) {
 	}
 
 	trees := map[string]*parse.Tree{}
+	blockVariants := map[string][]*FlashcardVariant{}
 	for _, blockID := range blockIDs {
 		rootID := blockRoots[blockID]
 
@@ -908,6 +925,7 @@ func (tx *Transaction) doAddFlashcards(operation *Operation) (ret *TxErr) {
 			continue
 		}
 
+		blockVariants[blockID] = computeFlashcardVariants(node)
 		oldAttrs := parse.IAL2Map(node.KramdownIAL)
 
 		deckAttrs := node.IALAttr(NodeAttrRiffDecks)
@@ -932,13 +950,13 @@ func (tx *Transaction) doAddFlashcards(operation *Operation) (ret *TxErr) {
 	}
 
 	for _, blockID := range blockIDs {
-		cards := deck.GetCardsByBlockID(blockID)
-		if 0 < len(cards) {
-			// 一个块只能添加生成一张闪卡 https://github.com/siyuan-note/siyuan/issues/7476
-			continue
+		variants := blockVariants[blockID]
+		if 1 > len(variants) {
+			variants = []*FlashcardVariant{{Type: FlashcardTypeBasic}}
 		}
 
-		deck.AddCard(ast.NewNodeID(), blockID)
+		// 一个块可以按填空、反向和图片遮挡生成多张闪卡
+		syncBlockFlashcards(deck, blockID, variants)
 	}
 
 	if err := deck.Save(); err != nil {
@@ -956,6 +974,7 @@ func LoadFlashcards() {
 	}
 
 	Decks = map[string]*riff.Deck{}
+	resetFlashcardVariants()
 
 	entries, err := os.ReadDir(riffSavePath)
 	if err != nil {
@@ -966,7 +985,7 @@ func LoadFlashcards() {
 		name := entry.Name()
 		if strings.HasSuffix(name, ".deck") {
 			deckID := strings.TrimSuffix(name, ".deck")
-			deck, loadErr := riff.LoadDeck(riffSavePath, deckID, Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights)
+			deck, loadErr := loadDeck(riffSavePath, deckID)
 			if nil != loadErr {
 				logging.LogErrorf("load deck [%s] failed: %s", name, loadErr)
 				continue
@@ -1008,6 +1027,10 @@ func RemoveDeck(deckID string) (err error) {
 
 	waitForSyncingStorages()
 
+	if deck := Decks[deckID]; nil != deck {
+		removeFlashcardVariants(deck.GetCardsByBlockIDs(deck.GetBlockIDs()))
+	}
+
 	riffSavePath := getRiffDir()
 	deckPath := filepath.Join(riffSavePath, deckID+".deck")
 	if filelock.IsExist(deckPath) {
@@ -1023,6 +1046,10 @@ func RemoveDeck(deckID string) (err error) {
 		}
 	}
 
+	if err = removeDeckFSRS(deckID); err != nil {
+		return
+	}
+
 	LoadFlashcards()
 	return
 }
@@ -1043,7 +1070,7 @@ func createDeck(name string) (deck *riff.Deck, err error) {
 
 func createDeck0(name string, deckID string) (deck *riff.Deck, err error) {
 	riffSavePath := getRiffDir()
-	deck, err = riff.LoadDeck(riffSavePath, deckID, Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights)
+	deck, err = loadDeck(riffSavePath, deckID)
 	if err != nil {
 		logging.LogErrorf("load deck [%s] failed: %s", deckID, err)
 		return
@@ -1094,24 +1121,23 @@ func getDeckDueCards(deck *riff.Deck, reviewedCardIDs, blockIDs []string, newCar
 	ret = []riff.Card{}
 	var retNew, retOld []riff.Card
 
-	dues := deck.Dues()
-	toChecks := map[string]riff.Card{}
+	dues := filterOrphanedFlashcards(deck.Dues())
+	var toChecks []riff.Card
+	var toCheckBlockIDs []string
 	for _, c := range dues {
 		if 0 < len(blockIDs) && !gulu.Str.Contains(c.BlockID(), blockIDs) {
 			continue
 		}
 
-		toChecks[c.BlockID()] = c
+		// 一个块可能生成多张闪卡，这里需要保留所有闪卡
+		toChecks = append(toChecks, c)
+		toCheckBlockIDs = append(toCheckBlockIDs, c.BlockID())
 	}
-	var toCheckBlockIDs []string
 	var tmp []riff.Card
-	for bID, _ := range toChecks {
-		toCheckBlockIDs = append(toCheckBlockIDs, bID)
-	}
-	checkResult := treenode.ExistBlockTrees(toCheckBlockIDs)
-	for bID, exists := range checkResult {
-		if exists {
-			tmp = append(tmp, toChecks[bID])
+	checkResult := treenode.ExistBlockTrees(gulu.Str.RemoveDuplicatedElem(toCheckBlockIDs))
+	for _, c := range toChecks {
+		if checkResult[c.BlockID()] {
+			tmp = append(tmp, c)
 		}
 	}
 	dues = tmp
@@ -0,0 +1,610 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 本地 FSRS 参数优化器：读取 riff 复习日志，按照 go-fsrs 的记忆模型重放每张卡片的复习过程，
+// 以复习状态下的回忆结果为样本，最小化预测可提取性的对数损失来拟合 19 个权重
+
+const fsrsMinOptimizeReviews = 64 // 优化至少需要的复习样本数
+
+var fsrsParam = fsrs.DefaultParam() // 遗忘曲线的衰减和系数是固定的
+
+// FSRS 各个权重的取值范围
+var fsrsWeightBounds = [19][2]float64{
+	{0.01, 100}, {0.01, 100}, {0.01, 100}, {0.01, 100},
+	{1, 10}, {0.001, 4}, {0.001, 4}, {0.001, 0.75},
+	{0, 4.5}, {0, 0.8}, {0.001, 3.5},
+	{0.001, 5}, {0.001, 0.25}, {0.001, 0.9}, {0, 4},
+	{0, 1}, {1, 6}, {0, 2}, {0, 2},
+}
+
+// FSRSOptimizeResult 描述了 FSRS 参数优化结果。
+type FSRSOptimizeResult struct {
+	DeckID            string  `json:"deckID"`            // 卡包 ID，为空表示所有卡包
+	RequestRetention  float64 `json:"requestRetention"`  // 期望保留率
+	MaximumInterval   int     `json:"maximumInterval"`   // 最大间隔天数
+	Weights           string  `json:"weights"`           // 优化后的权重
+	Cards             int     `json:"cards"`             // 参与优化的卡片数
+	Reviews           int     `json:"reviews"`           // 参与优化的复习样本数
+	ActualRetention   float64 `json:"actualRetention"`   // 复习日志中实际的保留率
+	ExpectedRetention float64 `json:"expectedRetention"` // 优化后的参数预测的保留率
+	LossBefore        float64 `json:"lossBefore"`        // 优化前的对数损失
+	LossAfter         float64 `json:"lossAfter"`         // 优化后的对数损失
+	RMSEBefore        float64 `json:"rmseBefore"`        // 优化前按预测值分桶的均方根误差
+	RMSEAfter         float64 `json:"rmseAfter"`         // 优化后按预测值分桶的均方根误差
+	Applied           bool    `json:"applied"`           // 是否已经应用
+}
+
+type fsrsReview struct {
+	rating  riff.Rating
+	state   riff.State
+	elapsed float64
+}
+
+// OptimizeFSRS 使用卡包 deckID 的复习日志优化 FSRS 参数，deckID 为空时使用所有卡包的复习日志。
+// apply 为 true 时将优化结果应用到该卡包上（deckID 为空时应用到全局参数）。
+func OptimizeFSRS(deckID string, apply bool) (ret *FSRSOptimizeResult, err error) {
+	deckLock.Lock()
+	waitForSyncingStorages()
+	var deck *riff.Deck
+	if "" != deckID {
+		deck = Decks[deckID]
+		if nil == deck {
+			deckLock.Unlock()
+			err = fmt.Errorf("not found deck [%s]", deckID)
+			return
+		}
+	}
+	logs, err := loadRiffLogs()
+	if nil != deck {
+		var deckLogs []*riff.Log
+		for _, log := range logs {
+			if nil != deck.GetCard(log.CardID) {
+				deckLogs = append(deckLogs, log)
+			}
+		}
+		logs = deckLogs
+	}
+	deckLock.Unlock()
+	if err != nil {
+		return
+	}
+
+	requestRetention, maximumInterval, weights := getDeckFSRSParams(deckID)
+	current, err := parseFSRSWeights(weights)
+	if err != nil {
+		return
+	}
+
+	items := buildFSRSItems(logs)
+	samples := 0
+	for _, item := range items {
+		for _, review := range item[1:] {
+			if riff.Review == review.state {
+				samples++
+			}
+		}
+	}
+	if fsrsMinOptimizeReviews > samples {
+		err = fmt.Errorf("not enough review logs to optimize, at least [%d] reviews are required, given [%d]", fsrsMinOptimizeReviews, samples)
+		return
+	}
+
+	start := time.Now()
+	optimized := optimizeFSRSWeights(items, current)
+	before := evalFSRSWeights(items, current)
+	after := evalFSRSWeights(items, optimized)
+	if after.loss > before.loss {
+		// 优化结果不如当前参数时保留当前参数
+		optimized, after = current, before
+	}
+	logging.LogInfof("optimized fsrs weights of deck [%s] with [%d] reviews in [%s], loss [%.4f -> %.4f]", deckID, after.samples, time.Since(start), before.loss, after.loss)
+
+	ret = &FSRSOptimizeResult{
+		DeckID:            deckID,
+		RequestRetention:  requestRetention,
+		MaximumInterval:   maximumInterval,
+		Weights:           formatFSRSWeights(optimized),
+		Cards:             len(items),
+		Reviews:           after.samples,
+		ActualRetention:   after.actual,
+		ExpectedRetention: after.expected,
+		LossBefore:        before.loss,
+		LossAfter:         after.loss,
+		RMSEBefore:        before.rmse,
+		RMSEAfter:         after.rmse,
+	}
+	if apply {
+		if err = SetDeckFSRS(deckID, requestRetention, maximumInterval, ret.Weights); err != nil {
+			return
+		}
+		ret.Applied = true
+	}
+	return
+}
+
+// GetDeckFSRS 获取卡包 deckID 使用的 FSRS 参数，custom 表示卡包是否单独设置了参数。
+func GetDeckFSRS(deckID string) (ret *conf.FlashcardFSRS, custom bool) {
+	if deckFSRS := loadDeckFSRS(getRiffDir(), deckID); nil != deckFSRS {
+		return deckFSRS, true
+	}
+
+	ret = &conf.FlashcardFSRS{
+		RequestRetention: Conf.Flashcard.RequestRetention,
+		MaximumInterval:  Conf.Flashcard.MaximumInterval,
+		Weights:          Conf.Flashcard.Weights,
+	}
+	return
+}
+
+// SetDeckFSRS 设置卡包 deckID 使用的 FSRS 参数，deckID 为空时设置全局参数，weights 为空时移除卡包单独设置的参数。
+func SetDeckFSRS(deckID string, requestRetention float64, maximumInterval int, weights string) (err error) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	if "" != deckID && nil == Decks[deckID] {
+		err = fmt.Errorf("not found deck [%s]", deckID)
+		return
+	}
+
+	if "" != deckID && "" == weights {
+		if err = removeDeckFSRS(deckID); err != nil {
+			return
+		}
+	} else {
+		if err = checkFSRSParams(requestRetention, maximumInterval, weights); err != nil {
+			return
+		}
+
+		if "" == deckID {
+			Conf.Flashcard.RequestRetention = requestRetention
+			Conf.Flashcard.MaximumInterval = maximumInterval
+			Conf.Flashcard.Weights = weights
+			Conf.Save()
+		} else {
+			if err = saveDeckFSRS(deckID, &conf.FlashcardFSRS{
+				RequestRetention: requestRetention,
+				MaximumInterval:  maximumInterval,
+				Weights:          weights,
+				Updated:          time.Now().UnixMilli(),
+			}); err != nil {
+				return
+			}
+		}
+	}
+
+	// 卡包加载时根据参数创建调度器，所以需要重新加载
+	if "" == deckID {
+		LoadFlashcards()
+		return
+	}
+
+	deck, err := loadDeck(getRiffDir(), deckID)
+	if err != nil {
+		logging.LogErrorf("reload deck [%s] failed: %s", deckID, err)
+		return
+	}
+	Decks[deckID] = deck
+	return
+}
+
+func loadDeck(saveDir, deckID string) (*riff.Deck, error) {
+	requestRetention, maximumInterval, weights := Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights
+	if deckFSRS := loadDeckFSRS(saveDir, deckID); nil != deckFSRS {
+		requestRetention, maximumInterval, weights = deckFSRS.RequestRetention, deckFSRS.MaximumInterval, deckFSRS.Weights
+	}
+	return riff.LoadDeck(saveDir, deckID, requestRetention, maximumInterval, weights)
+}
+
+func getDeckFSRSParams(deckID string) (requestRetention float64, maximumInterval int, weights string) {
+	deckFSRS, _ := GetDeckFSRS(deckID)
+	return deckFSRS.RequestRetention, deckFSRS.MaximumInterval, deckFSRS.Weights
+}
+
+// 卡包单独的 FSRS 参数保存在 riff/{deckID}.fsrs.json 中，和卡包一起同步，没有该文件的卡包使用全局参数
+
+func getDeckFSRSPath(saveDir, deckID string) string {
+	return filepath.Join(saveDir, deckID+".fsrs.json")
+}
+
+func loadDeckFSRS(saveDir, deckID string) (ret *conf.FlashcardFSRS) {
+	if "" == deckID {
+		return
+	}
+
+	p := getDeckFSRSPath(saveDir, deckID)
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	data, err := filelock.ReadFile(p)
+	if err != nil {
+		logging.LogErrorf("read fsrs params of deck [%s] failed: %s", deckID, err)
+		return
+	}
+
+	deckFSRS := &conf.FlashcardFSRS{}
+	if err = gulu.JSON.UnmarshalJSON(data, deckFSRS); err != nil {
+		logging.LogErrorf("unmarshal fsrs params of deck [%s] failed: %s", deckID, err)
+		return
+	}
+	if nil != checkFSRSParams(deckFSRS.RequestRetention, deckFSRS.MaximumInterval, deckFSRS.Weights) {
+		logging.LogWarnf("invalid fsrs params of deck [%s], use global params instead", deckID)
+		return
+	}
+	return deckFSRS
+}
+
+func saveDeckFSRS(deckID string, deckFSRS *conf.FlashcardFSRS) (err error) {
+	data, err := gulu.JSON.MarshalIndentJSON(deckFSRS, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal fsrs params of deck [%s] failed: %s", deckID, err)
+		return
+	}
+
+	if err = filelock.WriteFile(getDeckFSRSPath(getRiffDir(), deckID), data); err != nil {
+		logging.LogErrorf("write fsrs params of deck [%s] failed: %s", deckID, err)
+	}
+	return
+}
+
+func removeDeckFSRS(deckID string) (err error) {
+	p := getDeckFSRSPath(getRiffDir(), deckID)
+	if !filelock.IsExist(p) {
+		return
+	}
+
+	if err = filelock.Remove(p); err != nil {
+		logging.LogErrorf("remove fsrs params of deck [%s] failed: %s", deckID, err)
+	}
+	return
+}
+
+func checkFSRSParams(requestRetention float64, maximumInterval int, weights string) (err error) {
+	if 0 >= requestRetention || 1 <= requestRetention {
+		return fmt.Errorf("invalid request retention [%v]", requestRetention)
+	}
+	if 0 >= maximumInterval || 36500 < maximumInterval {
+		return fmt.Errorf("invalid maximum interval [%d]", maximumInterval)
+	}
+	_, err = parseFSRSWeights(weights)
+	return
+}
+
+func parseFSRSWeights(weights string) (ret [19]float64, err error) {
+	parts := strings.Split(weights, ",")
+	if 19 != len(parts) {
+		err = fmt.Errorf("fsrs weights length must be [19], given [%d]", len(parts))
+		return
+	}
+
+	for i, part := range parts {
+		if ret[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			err = fmt.Errorf("fsrs weights contain invalid number [%s]", part)
+			return
+		}
+	}
+	return
+}
+
+func formatFSRSWeights(weights [19]float64) string {
+	var buf []string
+	for _, w := range weights {
+		buf = append(buf, strconv.FormatFloat(math.Round(w*10000)/10000, 'f', -1, 64))
+	}
+	return strings.Join(buf, ", ")
+}
+
+// loadRiffLogs 加载所有复习日志，日志按月存放在 riff/logs/yyyyMM.msgpack 中，所有卡包共用。
+func loadRiffLogs() (ret []*riff.Log, err error) {
+	logsDir := filepath.Join(getRiffDir(), "logs")
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".msgpack") {
+			continue
+		}
+
+		p := filepath.Join(logsDir, entry.Name())
+		data, readErr := filelock.ReadFile(p)
+		if nil != readErr {
+			logging.LogErrorf("read review logs [%s] failed: %s", p, readErr)
+			continue
+		}
+
+		var logs []*riff.Log
+		if unmarshalErr := msgpack.Unmarshal(data, &logs); nil != unmarshalErr {
+			logging.LogErrorf("unmarshal review logs [%s] failed: %s", p, unmarshalErr)
+			continue
+		}
+		ret = append(ret, logs...)
+	}
+	return
+}
+
+// buildFSRSItems 将复习日志按卡片分组并按复习时间排序，每组以新卡复习开始，重置或者撤销后会重新开始一组。
+func buildFSRSItems(logs []*riff.Log) (ret [][]fsrsReview) {
+	cardLogs := map[string][]*riff.Log{}
+	for _, log := range logs {
+		if riff.Again > log.Rating || riff.Easy < log.Rating {
+			continue
+		}
+		cardLogs[log.CardID] = append(cardLogs[log.CardID], log)
+	}
+
+	cardIDs := make([]string, 0, len(cardLogs))
+	for cardID := range cardLogs {
+		cardIDs = append(cardIDs, cardID)
+	}
+	sort.Strings(cardIDs)
+
+	for _, cardID := range cardIDs {
+		logs := cardLogs[cardID]
+		sort.SliceStable(logs, func(i, j int) bool { return logs[i].Reviewed < logs[j].Reviewed })
+
+		var item []fsrsReview
+		for _, log := range logs {
+			if riff.New == log.State {
+				if 1 < len(item) {
+					ret = append(ret, item)
+				}
+				item = nil
+			} else if nil == item {
+				// 没有新卡复习记录的卡片无法确定初始状态
+				continue
+			}
+			item = append(item, fsrsReview{rating: log.Rating, state: log.State, elapsed: float64(log.ElapsedDays)})
+		}
+		if 1 < len(item) {
+			ret = append(ret, item)
+		}
+	}
+	return
+}
+
+type fsrsEvaluation struct {
+	loss     float64 // 平均对数损失
+	rmse     float64
+	samples  int
+	actual   float64
+	expected float64
+}
+
+// evalFSRSWeights 按照 go-fsrs 的调度模型重放复习过程并评估参数。
+func evalFSRSWeights(items [][]fsrsReview, w [19]float64) (ret *fsrsEvaluation) {
+	ret = &fsrsEvaluation{}
+	const bins = 20
+	var binCount, binPredicted, binActual [bins]float64
+	var recalled float64
+	for _, item := range items {
+		replayFSRSItem(item, &w, func(r float64, recall bool) {
+			y := 0.0
+			if recall {
+				y = 1
+				recalled++
+			}
+			ret.loss += fsrsLogLoss(r, y)
+			ret.expected += r
+			ret.samples++
+
+			bin := int(r * bins)
+			if bins <= bin {
+				bin = bins - 1
+			}
+			binCount[bin]++
+			binPredicted[bin] += r
+			binActual[bin] += y
+		})
+	}
+	if 1 > ret.samples {
+		return
+	}
+
+	n := float64(ret.samples)
+	ret.loss /= n
+	ret.expected /= n
+	ret.actual = recalled / n
+	var sum float64
+	for i := 0; i < bins; i++ {
+		if 0 < binCount[i] {
+			diff := binPredicted[i]/binCount[i] - binActual[i]/binCount[i]
+			sum += binCount[i] * diff * diff
+		}
+	}
+	ret.rmse = math.Sqrt(sum / n)
+	return
+}
+
+// replayFSRSItem 重放一张卡片的复习过程，每次处于复习状态的复习都会以预测的可提取性和实际是否记住回调 sample。
+func replayFSRSItem(item []fsrsReview, w *[19]float64, sample func(r float64, recall bool)) {
+	var s, d float64
+	for i, review := range item {
+		rating := float64(review.rating)
+		if 0 == i {
+			s = math.Max(w[int(review.rating)-1], 0.1)
+			d = fsrsInitDifficulty(w, rating)
+			continue
+		}
+
+		nextD := fsrsNextDifficulty(w, d, rating)
+		if riff.Review != review.state {
+			// 学习和重新学习阶段使用短期稳定性
+			s = s * math.Exp(w[17]*(rating-3+w[18]))
+			d = nextD
+			continue
+		}
+
+		r := math.Pow(1+fsrsParam.Factor*review.elapsed/s, fsrsParam.Decay)
+		if nil != sample {
+			sample(r, riff.Again < review.rating)
+		}
+		if riff.Again == review.rating {
+			sMin := s / math.Exp(w[17]*w[18])
+			s = math.Min(sMin, w[11]*math.Pow(d, -w[12])*(math.Pow(s+1, w[13])-1)*math.Exp((1-r)*w[14]))
+		} else {
+			hardPenalty, easyBonus := 1.0, 1.0
+			if riff.Hard == review.rating {
+				hardPenalty = w[15]
+			} else if riff.Easy == review.rating {
+				easyBonus = w[16]
+			}
+			s = s * (1 + math.Exp(w[8])*(11-d)*math.Pow(s, -w[9])*(math.Exp((1-r)*w[10])-1)*hardPenalty*easyBonus)
+		}
+		s = math.Max(s, 0.01)
+		d = nextD
+	}
+}
+
+func fsrsInitDifficulty(w *[19]float64, rating float64) float64 {
+	return math.Min(math.Max(w[4]-math.Exp(w[5]*(rating-1))+1, 1), 10)
+}
+
+func fsrsNextDifficulty(w *[19]float64, d, rating float64) float64 {
+	deltaD := -w[6] * (rating - 3)
+	nextD := d + (10-d)*deltaD/9
+	nextD = w[7]*fsrsInitDifficulty(w, 4) + (1-w[7])*nextD
+	return math.Min(math.Max(nextD, 1), 10)
+}
+
+func fsrsLogLoss(r, y float64) float64 {
+	r = math.Min(math.Max(r, 1e-6), 1-1e-6)
+	return -(y*math.Log(r) + (1-y)*math.Log(1-r))
+}
+
+// optimizeFSRSWeights 使用 Adam 在归一化后的参数空间中做小批量梯度下降，梯度使用前向差分计算，
+// 损失中加入了向初始参数回归的 L2 正则项，避免样本较少时过拟合。
+func optimizeFSRSWeights(items [][]fsrsReview, init [19]float64) (ret [19]float64) {
+	const (
+		batchSize = 512
+		minSteps  = 200
+		maxEpochs = 50
+		lr        = 0.01
+		beta1     = 0.9
+		beta2     = 0.999
+		eps       = 1e-8
+		h         = 1e-4
+		l2        = 8.0
+	)
+
+	var u, u0 [19]float64
+	for i := range init {
+		lo, hi := fsrsWeightBounds[i][0], fsrsWeightBounds[i][1]
+		u0[i] = math.Min(math.Max((init[i]-lo)/(hi-lo), 0), 1)
+	}
+	u = u0
+	toWeights := func(u [19]float64) (w [19]float64) {
+		for i := range u {
+			lo, hi := fsrsWeightBounds[i][0], fsrsWeightBounds[i][1]
+			w[i] = lo + math.Min(math.Max(u[i], 0), 1)*(hi-lo)
+		}
+		return
+	}
+	batchLoss := func(batch [][]fsrsReview, u [19]float64, total int) float64 {
+		w := toWeights(u)
+		var loss float64
+		var n int
+		for _, item := range batch {
+			replayFSRSItem(item, &w, func(r float64, recall bool) {
+				y := 0.0
+				if recall {
+					y = 1
+				}
+				loss += fsrsLogLoss(r, y)
+				n++
+			})
+		}
+		if 1 > n {
+			return 0
+		}
+		var penalty float64
+		for i := range u {
+			penalty += (u[i] - u0[i]) * (u[i] - u0[i])
+		}
+		return loss/float64(n) + l2*penalty/float64(total)
+	}
+
+	total := 0
+	for _, item := range items {
+		for _, review := range item[1:] {
+			if riff.Review == review.state {
+				total++
+			}
+		}
+	}
+
+	shuffled := make([][]fsrsReview, len(items))
+	copy(shuffled, items)
+	random := rand.New(rand.NewSource(1))
+	batches := (len(shuffled) + batchSize - 1) / batchSize
+	epochs := int(math.Min(math.Max(5, math.Ceil(float64(minSteps)/float64(batches))), maxEpochs))
+
+	var m, v [19]float64
+	step := 0
+	for epoch := 0; epoch < epochs; epoch++ {
+		random.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		for start := 0; start < len(shuffled); start += batchSize {
+			end := int(math.Min(float64(start+batchSize), float64(len(shuffled))))
+			batch := shuffled[start:end]
+
+			step++
+			base := batchLoss(batch, u, total)
+			var grads [19]float64
+			for i := range u {
+				next := u
+				next[i] += h
+				grads[i] = (batchLoss(batch, next, total) - base) / h
+			}
+			for i, grad := range grads {
+				m[i] = beta1*m[i] + (1-beta1)*grad
+				v[i] = beta2*v[i] + (1-beta2)*grad*grad
+				mHat := m[i] / (1 - math.Pow(beta1, float64(step)))
+				vHat := v[i] / (1 - math.Pow(beta2, float64(step)))
+				u[i] = math.Min(math.Max(u[i]-lr*mHat/(math.Sqrt(vHat)+eps), 0), 1)
+			}
+		}
+	}
+	ret = toWeights(u)
+	return
+}
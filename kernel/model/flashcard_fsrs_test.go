@@ -0,0 +1,126 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestReplayFSRSItem(t *testing.T) {
+	w, err := parseFSRSWeights(conf.DefaultFSRSWeights())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := []fsrsReview{
+		{rating: riff.Good, state: riff.New},
+		{rating: riff.Good, state: riff.Learning},
+		{rating: riff.Good, state: riff.Review, elapsed: 3},
+		{rating: riff.Again, state: riff.Review, elapsed: 30},
+		{rating: riff.Good, state: riff.Relearning},
+	}
+
+	var rs []float64
+	var recalls []bool
+	replayFSRSItem(item, &w, func(r float64, recall bool) {
+		rs = append(rs, r)
+		recalls = append(recalls, recall)
+	})
+
+	// 只有处于复习状态的复习才会产生样本
+	if 2 != len(rs) {
+		t.Fatalf("expected 2 samples, got %d", len(rs))
+	}
+	if !recalls[0] || recalls[1] {
+		t.Fatalf("unexpected recalls %v", recalls)
+	}
+
+	// 第一次复习前的稳定性为新卡评分 Good 对应的初始稳定性，学习阶段按照短期稳定性更新
+	s := w[2] * math.Exp(w[17]*(3-3+w[18]))
+	expected := math.Pow(1+fsrsParam.Factor*3/s, fsrsParam.Decay)
+	if 1e-9 < math.Abs(expected-rs[0]) {
+		t.Fatalf("expected retrievability %v, got %v", expected, rs[0])
+	}
+	for _, r := range rs {
+		if 0 >= r || 1 <= r {
+			t.Fatalf("retrievability out of range: %v", r)
+		}
+	}
+
+	replayFSRSItem(item, &w, nil)
+}
+
+func TestOptimizeFSRSWeights(t *testing.T) {
+	init, err := parseFSRSWeights(conf.DefaultFSRSWeights())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按照遗忘更快的参数生成复习日志，优化后的参数应该更符合日志
+	truth := init
+	truth[2] /= 4
+	truth[8] /= 2
+
+	items := genFSRSItems(&truth, 400, 8)
+	before := evalFSRSWeights(items, init)
+	optimized := optimizeFSRSWeights(items, init)
+	after := evalFSRSWeights(items, optimized)
+	if after.samples != before.samples || 1 > after.samples {
+		t.Fatalf("unexpected samples %d, %d", before.samples, after.samples)
+	}
+	if after.loss > before.loss {
+		t.Fatalf("loss increased [%v -> %v]", before.loss, after.loss)
+	}
+	if math.Abs(after.expected-after.actual) > math.Abs(before.expected-before.actual) {
+		t.Fatalf("expected retention drifted away from actual [%v -> %v, actual %v]", before.expected, after.expected, after.actual)
+	}
+
+	for i, w := range optimized {
+		if w < fsrsWeightBounds[i][0] || w > fsrsWeightBounds[i][1] {
+			t.Fatalf("weight [%d] out of bounds: %v", i, w)
+		}
+	}
+
+	// 损失应该接近生成日志的参数的损失
+	if best := evalFSRSWeights(items, truth).loss; after.loss > best+0.01 {
+		t.Fatalf("loss [%v] is far from the loss of truth weights [%v]", after.loss, best)
+	}
+}
+
+// genFSRSItems 按照参数 w 模拟复习过程，根据预测的可提取性随机决定是否记住。
+func genFSRSItems(w *[19]float64, cards, reviews int) (ret [][]fsrsReview) {
+	random := rand.New(rand.NewSource(7))
+	for i := 0; i < cards; i++ {
+		item := []fsrsReview{{rating: riff.Good, state: riff.New}}
+		for j := 0; j < reviews; j++ {
+			probe := fsrsReview{rating: riff.Good, state: riff.Review, elapsed: float64(1 + random.Intn(30))}
+			var r float64
+			replayFSRSItem(append(item, probe), w, func(sample float64, recall bool) { r = sample })
+			if random.Float64() >= r {
+				probe.rating = riff.Again
+			}
+			item = append(item, probe)
+		}
+		ret = append(ret, item)
+	}
+	return
+}
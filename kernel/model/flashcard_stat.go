@@ -0,0 +1,406 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+	"github.com/siyuan-note/riff"
+)
+
+const (
+	flashcardMatureDays     = 21  // 复习间隔达到该天数的卡片视为已熟练
+	flashcardReviewTimeSpan = 300 // 两次复习间隔超过该秒数时视为新的复习会话，不计入复习耗时
+)
+
+// FlashcardStatFilter 描述了闪卡统计的过滤条件，为空的条件不参与过滤。
+type FlashcardStatFilter struct {
+	DeckID string `json:"deckID"` // 卡包
+	BoxID  string `json:"boxID"`  // 笔记本
+	RootID string `json:"rootID"` // 文档树（包含子文档）
+}
+
+func (filter *FlashcardStatFilter) isEmpty() bool {
+	return "" == filter.DeckID && "" == filter.BoxID && "" == filter.RootID
+}
+
+type FlashcardReviewDay struct {
+	Date    string `json:"date"` // yyyy-MM-dd
+	Reviews int    `json:"reviews"`
+	Again   int    `json:"again"`
+	Hard    int    `json:"hard"`
+	Good    int    `json:"good"`
+	Easy    int    `json:"easy"`
+	Time    int64  `json:"time"` // 估算的复习耗时，单位：秒
+}
+
+type FlashcardRetention struct {
+	DeckID    string  `json:"deckID,omitempty"`
+	DeckName  string  `json:"deckName,omitempty"`
+	Interval  string  `json:"interval,omitempty"` // 间隔天数分桶，比如 "4-7"
+	Reviews   int     `json:"reviews"`
+	Recalled  int     `json:"recalled"`
+	Retention float64 `json:"retention"`
+}
+
+// FlashcardReviewStat 描述了根据复习日志计算的统计。
+type FlashcardReviewStat struct {
+	Start              string                `json:"start"` // yyyy-MM-dd
+	End                string                `json:"end"`   // yyyy-MM-dd
+	Days               []*FlashcardReviewDay `json:"days"`  // 有复习的日期，按日期升序
+	Reviews            int                   `json:"reviews"`
+	Lapses             int                   `json:"lapses"`    // 复习状态下评分为 Again 的次数
+	Retention          float64               `json:"retention"` // 复习状态下的保留率
+	DeckRetentions     []*FlashcardRetention `json:"deckRetentions"`
+	IntervalRetentions []*FlashcardRetention `json:"intervalRetentions"`
+	TotalTime          int64                 `json:"totalTime"`   // 估算的复习总耗时，单位：秒
+	AverageTime        float64               `json:"averageTime"` // 估算的平均每次复习耗时，单位：秒
+}
+
+type FlashcardStateCount struct {
+	State riff.State `json:"state"`
+	Name  string     `json:"name"`
+	Count int        `json:"count"`
+}
+
+type FlashcardForecastDay struct {
+	Date string `json:"date"` // yyyy-MM-dd
+	Due  int    `json:"due"`
+}
+
+type FlashcardLapseCount struct {
+	Lapses string `json:"lapses"` // 遗忘次数分桶，比如 "3-4"
+	Count  int    `json:"count"`
+}
+
+type FlashcardLeech struct {
+	DeckID  string `json:"deckID"`
+	CardID  string `json:"cardID"`
+	BlockID string `json:"blockID"`
+	Lapses  uint64 `json:"lapses"`
+	Reps    uint64 `json:"reps"`
+}
+
+// FlashcardCardStat 描述了根据卡片当前状态计算的统计。
+type FlashcardCardStat struct {
+	Cards             int                     `json:"cards"`
+	States            []*FlashcardStateCount  `json:"states"` // 按 riff.State 统计的卡片数
+	Young             int                     `json:"young"`  // 复习状态且间隔小于 21 天
+	Mature            int                     `json:"mature"` // 复习状态且间隔不小于 21 天
+	Overdue           int                     `json:"overdue"`
+	Forecast          []*FlashcardForecastDay `json:"forecast"`
+	Lapses            int                     `json:"lapses"` // 所有卡片的遗忘次数之和
+	LapseDistribution []*FlashcardLapseCount  `json:"lapseDistribution"`
+	Leeches           []*FlashcardLeech       `json:"leeches"` // 遗忘次数最多的卡片
+}
+
+var (
+	flashcardIntervalBuckets = [][2]uint64{{0, 0}, {1, 1}, {2, 3}, {4, 7}, {8, 14}, {15, 30}, {31, 90}, {91, 180}, {181, 365}, {366, math.MaxUint64}}
+	flashcardLapseBuckets    = [][2]uint64{{0, 0}, {1, 1}, {2, 2}, {3, 4}, {5, 7}, {8, math.MaxUint64}}
+	flashcardStateNames      = []string{"new", "learning", "review", "relearning"}
+)
+
+type flashcardStatCard struct {
+	deckID string
+	card   riff.Card
+}
+
+// GetFlashcardReviewStat 统计最近 days 天的复习日志。
+func GetFlashcardReviewStat(filter *FlashcardStatFilter, days int) (ret *FlashcardReviewStat, err error) {
+	if 1 > days {
+		days = 365
+	}
+
+	deckLock.Lock()
+	waitForSyncingStorages()
+	cards, err := getFlashcardStatCards(filter)
+	deckNames := map[string]string{}
+	for deckID, deck := range Decks {
+		deckNames[deckID] = deck.Name
+	}
+	deckLock.Unlock()
+	if err != nil {
+		return
+	}
+
+	logs, err := loadRiffLogs()
+	if err != nil {
+		return
+	}
+	sort.SliceStable(logs, func(i, j int) bool { return logs[i].Reviewed < logs[j].Reviewed })
+
+	// 复习日志中没有记录耗时，这里使用同一会话中相邻两次复习的时间差估算
+	reviewTimes := map[*riff.Log]int64{}
+	for i := 1; i < len(logs); i++ {
+		if span := logs[i].Reviewed - logs[i-1].Reviewed; 0 <= span && flashcardReviewTimeSpan >= span {
+			reviewTimes[logs[i]] = span
+		}
+	}
+
+	now := time.Now()
+	startDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -days+1)
+	ret = &FlashcardReviewStat{
+		Start:              startDay.Format("2006-01-02"),
+		End:                now.Format("2006-01-02"),
+		Days:               []*FlashcardReviewDay{},
+		DeckRetentions:     []*FlashcardRetention{},
+		IntervalRetentions: []*FlashcardRetention{},
+	}
+
+	reviewDays := map[string]*FlashcardReviewDay{}
+	deckRetentions := map[string]*FlashcardRetention{}
+	intervalRetentions := make([]*FlashcardRetention, len(flashcardIntervalBuckets))
+	for i, bucket := range flashcardIntervalBuckets {
+		intervalRetentions[i] = &FlashcardRetention{Interval: formatFlashcardBucket(bucket)}
+	}
+	var recalled int
+	for _, log := range logs {
+		reviewed := time.Unix(log.Reviewed, 0)
+		if reviewed.Before(startDay) {
+			continue
+		}
+
+		statCard := cards[log.CardID]
+		if nil == statCard && !filter.isEmpty() {
+			continue
+		}
+
+		date := reviewed.Format("2006-01-02")
+		day := reviewDays[date]
+		if nil == day {
+			day = &FlashcardReviewDay{Date: date}
+			reviewDays[date] = day
+			ret.Days = append(ret.Days, day)
+		}
+		day.Reviews++
+		switch log.Rating {
+		case riff.Again:
+			day.Again++
+		case riff.Hard:
+			day.Hard++
+		case riff.Good:
+			day.Good++
+		case riff.Easy:
+			day.Easy++
+		}
+		day.Time += reviewTimes[log]
+		ret.Reviews++
+		ret.TotalTime += reviewTimes[log]
+
+		if riff.Review != log.State {
+			continue
+		}
+
+		// 只有复习状态下的复习才能反映记忆保持情况
+		isRecalled := riff.Again != log.Rating
+		if isRecalled {
+			recalled++
+		} else {
+			ret.Lapses++
+		}
+
+		if nil != statCard {
+			deckRetention := deckRetentions[statCard.deckID]
+			if nil == deckRetention {
+				deckRetention = &FlashcardRetention{DeckID: statCard.deckID, DeckName: deckNames[statCard.deckID]}
+				deckRetentions[statCard.deckID] = deckRetention
+				ret.DeckRetentions = append(ret.DeckRetentions, deckRetention)
+			}
+			deckRetention.Reviews++
+			if isRecalled {
+				deckRetention.Recalled++
+			}
+		}
+
+		for i, bucket := range flashcardIntervalBuckets {
+			if bucket[0] <= log.ElapsedDays && log.ElapsedDays <= bucket[1] {
+				intervalRetentions[i].Reviews++
+				if isRecalled {
+					intervalRetentions[i].Recalled++
+				}
+				break
+			}
+		}
+	}
+
+	if reviews := ret.Lapses + recalled; 0 < reviews {
+		ret.Retention = float64(recalled) / float64(reviews)
+	}
+	if 0 < ret.Reviews {
+		ret.AverageTime = float64(ret.TotalTime) / float64(ret.Reviews)
+	}
+	for _, deckRetention := range ret.DeckRetentions {
+		deckRetention.Retention = float64(deckRetention.Recalled) / float64(deckRetention.Reviews)
+	}
+	sort.Slice(ret.DeckRetentions, func(i, j int) bool { return ret.DeckRetentions[i].Reviews > ret.DeckRetentions[j].Reviews })
+	for _, intervalRetention := range intervalRetentions {
+		if 0 < intervalRetention.Reviews {
+			intervalRetention.Retention = float64(intervalRetention.Recalled) / float64(intervalRetention.Reviews)
+		}
+		ret.IntervalRetentions = append(ret.IntervalRetentions, intervalRetention)
+	}
+	return
+}
+
+// GetFlashcardCardStat 统计卡片的状态分布、遗忘次数和未来 days 天的到期预测。
+func GetFlashcardCardStat(filter *FlashcardStatFilter, days int) (ret *FlashcardCardStat, err error) {
+	if 1 > days {
+		days = 30
+	}
+
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	waitForSyncingStorages()
+
+	cards, err := getFlashcardStatCards(filter)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	ret = &FlashcardCardStat{
+		States:            []*FlashcardStateCount{},
+		Forecast:          []*FlashcardForecastDay{},
+		LapseDistribution: []*FlashcardLapseCount{},
+		Leeches:           []*FlashcardLeech{},
+	}
+	for i, name := range flashcardStateNames {
+		ret.States = append(ret.States, &FlashcardStateCount{State: riff.State(i), Name: name})
+	}
+	for i := 0; i < days; i++ {
+		ret.Forecast = append(ret.Forecast, &FlashcardForecastDay{Date: today.AddDate(0, 0, i).Format("2006-01-02")})
+	}
+	for _, bucket := range flashcardLapseBuckets {
+		ret.LapseDistribution = append(ret.LapseDistribution, &FlashcardLapseCount{Lapses: formatFlashcardBucket(bucket)})
+	}
+
+	for _, statCard := range cards {
+		c, ok := statCard.card.Impl().(*fsrs.Card)
+		if !ok {
+			continue
+		}
+
+		ret.Cards++
+		if int(c.State) < len(ret.States) {
+			ret.States[c.State].Count++
+		}
+		if fsrs.Review == c.State {
+			if flashcardMatureDays <= c.ScheduledDays {
+				ret.Mature++
+			} else {
+				ret.Young++
+			}
+		}
+
+		if fsrs.New != c.State {
+			due := time.Date(c.Due.Year(), c.Due.Month(), c.Due.Day(), 0, 0, 0, 0, now.Location())
+			if due.Before(today) {
+				ret.Overdue++
+			} else if i := int(math.Round(due.Sub(today).Hours() / 24)); 0 <= i && i < days {
+				ret.Forecast[i].Due++
+			}
+		}
+
+		ret.Lapses += int(c.Lapses)
+		for i, bucket := range flashcardLapseBuckets {
+			if bucket[0] <= c.Lapses && c.Lapses <= bucket[1] {
+				ret.LapseDistribution[i].Count++
+				break
+			}
+		}
+		if 0 < c.Lapses {
+			ret.Leeches = append(ret.Leeches, &FlashcardLeech{DeckID: statCard.deckID, CardID: statCard.card.ID(), BlockID: statCard.card.BlockID(), Lapses: c.Lapses, Reps: c.Reps})
+		}
+	}
+
+	sort.Slice(ret.Leeches, func(i, j int) bool {
+		if ret.Leeches[i].Lapses == ret.Leeches[j].Lapses {
+			return ret.Leeches[i].Reps > ret.Leeches[j].Reps
+		}
+		return ret.Leeches[i].Lapses > ret.Leeches[j].Lapses
+	})
+	if 20 < len(ret.Leeches) {
+		ret.Leeches = ret.Leeches[:20]
+	}
+	return
+}
+
+// getFlashcardStatCards 获取过滤条件范围内的卡片，调用方需要持有 deckLock。
+func getFlashcardStatCards(filter *FlashcardStatFilter) (ret map[string]*flashcardStatCard, err error) {
+	ret = map[string]*flashcardStatCard{}
+
+	var blockIDs []string
+	filterBlocks := false
+	if "" != filter.RootID {
+		_, blockIDs = getTreeSubTreeChildBlocks(filter.RootID)
+		filterBlocks = true
+	}
+	if "" != filter.BoxID {
+		boxBlockIDsMap, boxBlockIDs := getBoxBlocks(filter.BoxID)
+		if filterBlocks {
+			var intersection []string
+			for _, blockID := range blockIDs {
+				if boxBlockIDsMap[blockID] {
+					intersection = append(intersection, blockID)
+				}
+			}
+			blockIDs = intersection
+		} else {
+			blockIDs = boxBlockIDs
+		}
+		filterBlocks = true
+	}
+
+	for deckID, deck := range Decks {
+		if "" != filter.DeckID && deckID != filter.DeckID {
+			continue
+		}
+
+		deckBlockIDs := blockIDs
+		if !filterBlocks {
+			deckBlockIDs = deck.GetBlockIDs()
+		}
+		if 1 > len(deckBlockIDs) {
+			continue
+		}
+
+		for _, card := range deck.GetCardsByBlockIDs(deckBlockIDs) {
+			ret[card.ID()] = &flashcardStatCard{deckID: deckID, card: card}
+		}
+	}
+
+	if "" != filter.DeckID && nil == Decks[filter.DeckID] {
+		err = fmt.Errorf("not found deck [%s]", filter.DeckID)
+	}
+	return
+}
+
+func formatFlashcardBucket(bucket [2]uint64) string {
+	if bucket[0] == bucket[1] {
+		return fmt.Sprintf("%d", bucket[0])
+	}
+	if math.MaxUint64 == bucket[1] {
+		return fmt.Sprintf("%d+", bucket[0])
+	}
+	return fmt.Sprintf("%d-%d", bucket[0], bucket[1])
+}
@@ -0,0 +1,417 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/html"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// 闪卡类型，一个块可以生成多张闪卡，每张闪卡在卡包中独立调度
+const (
+	FlashcardTypeBasic     = "basic"     // 正反面
+	FlashcardTypeCloze     = "cloze"     // 填空 {{c1::...}}，每个编号生成一张闪卡
+	FlashcardTypeForward   = "forward"   // term :: definition 正向
+	FlashcardTypeReverse   = "reverse"   // term :: definition 反向
+	FlashcardTypeOcclusion = "occlusion" // 图片遮挡，每个遮挡矩形生成一张闪卡
+)
+
+// NodeAttrRiffOcclusions 图片遮挡矩形，值为 FlashcardOcclusion 数组的 JSON。
+const NodeAttrRiffOcclusions = "custom-riff-occlusions"
+
+type FlashcardVariant struct {
+	Type     string `json:"type"`
+	Key      string `json:"key"`                // 填空编号 c1、c2 或者遮挡矩形 ID
+	Orphaned bool   `json:"orphaned,omitempty"` // 块内容中已经不存在对应的填空或遮挡，暂停复习
+}
+
+func (variant *FlashcardVariant) id() string {
+	return variant.Type + ":" + variant.Key
+}
+
+// FlashcardOcclusion 描述图片上的一个遮挡矩形，坐标和尺寸为相对图片宽高的比例（0-1）。
+type FlashcardOcclusion struct {
+	ID    string  `json:"id"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	W     float64 `json:"w"`
+	H     float64 `json:"h"`
+	Label string  `json:"label"`
+}
+
+var (
+	clozeRegexp   = regexp.MustCompile(`\{\{c(\d+)::`)
+	reverseRegexp = regexp.MustCompile(`(?s)^\s*(\S.*?)\s+::\s+(\S.*?)\s*$`)
+)
+
+// computeFlashcardVariants 根据块内容计算需要生成的闪卡。
+func computeFlashcardVariants(node *ast.Node) (ret []*FlashcardVariant) {
+	if occlusions := getFlashcardOcclusions(node); 0 < len(occlusions) {
+		for _, occlusion := range occlusions {
+			ret = append(ret, &FlashcardVariant{Type: FlashcardTypeOcclusion, Key: occlusion.ID})
+		}
+		return
+	}
+
+	content := sql.NodeStaticContent(node, nil, false, false, false)
+
+	var clozeIndexes []int
+	clozeIndexSet := map[int]bool{}
+	for _, match := range clozeRegexp.FindAllStringSubmatch(content, -1) {
+		idx, _ := strconv.Atoi(match[1])
+		if 1 > idx || clozeIndexSet[idx] {
+			continue
+		}
+		clozeIndexSet[idx] = true
+		clozeIndexes = append(clozeIndexes, idx)
+	}
+	if 0 < len(clozeIndexes) {
+		sort.Ints(clozeIndexes)
+		for _, idx := range clozeIndexes {
+			ret = append(ret, &FlashcardVariant{Type: FlashcardTypeCloze, Key: "c" + strconv.Itoa(idx)})
+		}
+		return
+	}
+
+	if reverseRegexp.MatchString(content) {
+		ret = append(ret, &FlashcardVariant{Type: FlashcardTypeForward})
+		ret = append(ret, &FlashcardVariant{Type: FlashcardTypeReverse})
+		return
+	}
+
+	ret = append(ret, &FlashcardVariant{Type: FlashcardTypeBasic})
+	return
+}
+
+func getFlashcardOcclusions(node *ast.Node) (ret []*FlashcardOcclusion) {
+	val := node.IALAttr(NodeAttrRiffOcclusions)
+	if "" == val {
+		return
+	}
+
+	hasImg := false
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && ast.NodeImage == n.Type {
+			hasImg = true
+			return ast.WalkStop
+		}
+		return ast.WalkContinue
+	})
+	if !hasImg {
+		return
+	}
+
+	var occlusions []*FlashcardOcclusion
+	if err := gulu.JSON.UnmarshalJSON([]byte(html.UnescapeAttrVal(val)), &occlusions); err != nil {
+		logging.LogWarnf("parse block [%s] occlusions failed: %s", node.ID, err)
+		return
+	}
+
+	var ids []string
+	for i, occlusion := range occlusions {
+		if nil == occlusion || 0 >= occlusion.W || 0 >= occlusion.H {
+			continue
+		}
+		if occlusion.ID = strings.TrimSpace(occlusion.ID); "" == occlusion.ID {
+			occlusion.ID = strconv.Itoa(i + 1)
+		}
+		if gulu.Str.Contains(occlusion.ID, ids) {
+			continue
+		}
+		ids = append(ids, occlusion.ID)
+		ret = append(ret, occlusion)
+	}
+	return
+}
+
+// syncBlockFlashcards 按闪卡类型同步块在卡包中的闪卡：添加缺少的闪卡，块内容中已经不存在的填空或遮挡对应的闪卡标记为孤立。
+// 孤立的闪卡暂停复习并保留复习记录，对应的填空或遮挡恢复后继续使用，只有显式移除闪卡时才会删除。
+// 返回值表示卡包是否需要保存，调用方需要持有 deckLock。
+func syncBlockFlashcards(deck *riff.Deck, blockID string, variants []*FlashcardVariant) (changed bool) {
+	flashcardVariantsLock.Lock()
+	defer flashcardVariantsLock.Unlock()
+
+	cardVariants := getFlashcardVariants()
+	orphaned := func(cardID string) bool {
+		variant := cardVariants[cardID]
+		return nil != variant && variant.Orphaned
+	}
+
+	existCards := map[string]riff.Card{}
+	for _, card := range deck.GetCardsByBlockID(blockID) {
+		variant := cardVariants[card.ID()]
+		if nil == variant {
+			// 没有类型记录的闪卡是旧版本生成的正反面闪卡
+			variant = &FlashcardVariant{Type: FlashcardTypeBasic}
+		}
+
+		if exist := existCards[variant.id()]; nil != exist && (!orphaned(exist.ID()) || orphaned(card.ID())) {
+			continue
+		}
+		existCards[variant.id()] = card
+	}
+
+	variantsChanged := false
+	setVariant := func(cardID string, variant *FlashcardVariant) {
+		if FlashcardTypeBasic == variant.Type {
+			delete(cardVariants, cardID)
+		} else {
+			cardVariants[cardID] = &FlashcardVariant{Type: variant.Type, Key: variant.Key}
+		}
+		variantsChanged = true
+	}
+
+	keep := map[string]bool{}
+	for _, variant := range variants {
+		if card := existCards[variant.id()]; nil != card && !keep[card.ID()] {
+			keep[card.ID()] = true
+			if orphaned(card.ID()) {
+				setVariant(card.ID(), variant)
+			}
+			continue
+		}
+
+		// 正反面和正向闪卡之间切换时复用原闪卡，保留复习记录
+		var card riff.Card
+		if counterpart := flashcardTypeCounterpart(variant.Type); "" != counterpart {
+			card = existCards[(&FlashcardVariant{Type: counterpart}).id()]
+		}
+		if nil != card && !keep[card.ID()] {
+			keep[card.ID()] = true
+			setVariant(card.ID(), variant)
+			continue
+		}
+
+		cardID := ast.NewNodeID()
+		deck.AddCard(cardID, blockID)
+		keep[cardID] = true
+		if FlashcardTypeBasic != variant.Type {
+			setVariant(cardID, variant)
+		}
+		changed = true
+	}
+
+	for _, card := range deck.GetCardsByBlockID(blockID) {
+		if keep[card.ID()] || orphaned(card.ID()) {
+			continue
+		}
+
+		variant := cardVariants[card.ID()]
+		if nil == variant {
+			variant = &FlashcardVariant{Type: FlashcardTypeBasic}
+		}
+		cardVariants[card.ID()] = &FlashcardVariant{Type: variant.Type, Key: variant.Key, Orphaned: true}
+		variantsChanged = true
+	}
+
+	if variantsChanged {
+		saveFlashcardVariants()
+	}
+	return
+}
+
+func flashcardTypeCounterpart(typ string) string {
+	switch typ {
+	case FlashcardTypeBasic:
+		return FlashcardTypeForward
+	case FlashcardTypeForward:
+		return FlashcardTypeBasic
+	}
+	return ""
+}
+
+var (
+	refreshFlashcardTimers     = map[string]*time.Timer{}
+	refreshFlashcardTimersLock = sync.Mutex{}
+)
+
+// refreshBlockFlashcards 在块内容或者遮挡属性变更后同步该块在所有卡包中的闪卡。
+// 连续编辑同一个块时只在停止编辑一段时间后同步一次，同步时重新加载块计算闪卡类型，避免使用过时的块内容。
+func refreshBlockFlashcards(node *ast.Node) {
+	for ; nil != node; node = node.Parent {
+		if "" != node.IALAttr(NodeAttrRiffDecks) {
+			break
+		}
+	}
+	if nil == node {
+		return
+	}
+
+	blockID := node.ID
+	refreshFlashcardTimersLock.Lock()
+	defer refreshFlashcardTimersLock.Unlock()
+	if timer := refreshFlashcardTimers[blockID]; nil != timer {
+		timer.Reset(refreshFlashcardDelay)
+		return
+	}
+
+	refreshFlashcardTimers[blockID] = time.AfterFunc(refreshFlashcardDelay, func() {
+		refreshFlashcardTimersLock.Lock()
+		delete(refreshFlashcardTimers, blockID)
+		refreshFlashcardTimersLock.Unlock()
+
+		refreshBlockFlashcards0(blockID)
+	})
+}
+
+const refreshFlashcardDelay = 2 * time.Second
+
+func refreshBlockFlashcards0(blockID string) {
+	deckLock.Lock()
+	defer deckLock.Unlock()
+
+	if isSyncingStorages() {
+		return
+	}
+
+	tree, err := LoadTreeByBlockID(blockID)
+	if err != nil {
+		return
+	}
+	node := treenode.GetNodeInTree(tree, blockID)
+	if nil == node {
+		return
+	}
+
+	deckIDs := strings.Split(node.IALAttr(NodeAttrRiffDecks), ",")
+	variants := computeFlashcardVariants(node)
+	for _, deckID := range gulu.Str.RemoveDuplicatedElem(deckIDs) {
+		deck := Decks[deckID]
+		if nil == deck || 1 > len(deck.GetCardsByBlockID(blockID)) {
+			continue
+		}
+
+		if !syncBlockFlashcards(deck, blockID, variants) {
+			continue
+		}
+
+		if err = deck.Save(); err != nil {
+			logging.LogErrorf("save deck [%s] failed: %s", deckID, err)
+		}
+	}
+}
+
+func getFlashcardVariant(cardID string) (ret *FlashcardVariant) {
+	flashcardVariantsLock.Lock()
+	defer flashcardVariantsLock.Unlock()
+
+	ret = getFlashcardVariants()[cardID]
+	if nil == ret {
+		ret = &FlashcardVariant{Type: FlashcardTypeBasic}
+	}
+	return
+}
+
+// filterOrphanedFlashcards 过滤掉孤立的闪卡，孤立的闪卡不参与复习。
+func filterOrphanedFlashcards(cards []riff.Card) (ret []riff.Card) {
+	flashcardVariantsLock.Lock()
+	defer flashcardVariantsLock.Unlock()
+
+	cardVariants := getFlashcardVariants()
+	for _, card := range cards {
+		if variant := cardVariants[card.ID()]; nil != variant && variant.Orphaned {
+			continue
+		}
+		ret = append(ret, card)
+	}
+	return
+}
+
+func removeFlashcardVariants(cards []riff.Card) {
+	flashcardVariantsLock.Lock()
+	defer flashcardVariantsLock.Unlock()
+
+	cardVariants := getFlashcardVariants()
+	changed := false
+	for _, card := range cards {
+		if _, ok := cardVariants[card.ID()]; ok {
+			delete(cardVariants, card.ID())
+			changed = true
+		}
+	}
+	if changed {
+		saveFlashcardVariants()
+	}
+}
+
+var (
+	// flashcardVariants <cardID, variant> 闪卡类型，正反面闪卡不记录
+	flashcardVariants     map[string]*FlashcardVariant
+	flashcardVariantsLock = sync.Mutex{}
+)
+
+func resetFlashcardVariants() {
+	flashcardVariantsLock.Lock()
+	defer flashcardVariantsLock.Unlock()
+	flashcardVariants = nil
+}
+
+func getFlashcardVariants() map[string]*FlashcardVariant {
+	if nil != flashcardVariants {
+		return flashcardVariants
+	}
+
+	flashcardVariants = map[string]*FlashcardVariant{}
+	dataPath := filepath.Join(getRiffDir(), "variants.json")
+	if !filelock.IsExist(dataPath) {
+		return flashcardVariants
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read storage [riff variants] failed: %s", err)
+		return flashcardVariants
+	}
+
+	if err = gulu.JSON.UnmarshalJSON(data, &flashcardVariants); err != nil {
+		logging.LogErrorf("unmarshal storage [riff variants] failed: %s", err)
+		flashcardVariants = map[string]*FlashcardVariant{}
+	}
+	return flashcardVariants
+}
+
+func saveFlashcardVariants() {
+	dirPath := getRiffDir()
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		logging.LogErrorf("create storage [riff] dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalIndentJSON(flashcardVariants, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal storage [riff variants] failed: %s", err)
+		return
+	}
+
+	if err = filelock.WriteFile(filepath.Join(dirPath, "variants.json"), data); err != nil {
+		logging.LogErrorf("write storage [riff variants] failed: %s", err)
+	}
+}
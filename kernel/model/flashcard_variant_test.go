@@ -0,0 +1,177 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestComputeFlashcardVariants(t *testing.T) {
+	luteEngine := util.NewLute()
+	cases := []struct {
+		name       string
+		markdown   string
+		occlusions string
+		expected   []string
+	}{
+		{"basic", "foo bar", "", []string{"basic:"}},
+		{"cloze", "foo {{c2::foo}} and {{c1::bar}} and {{c2::baz}}", "", []string{"cloze:c1", "cloze:c2"}},
+		{"cloze zero", "foo {{c0::foo}} bar", "", []string{"basic:"}},
+		{"reverse", "term :: definition", "", []string{"forward:", "reverse:"}},
+		{"not reverse", "term::definition", "", []string{"basic:"}},
+		{"occlusion", "![foo](assets/foo.png)", `[{"id":"a","x":0.1,"y":0.1,"w":0.2,"h":0.2},{"x":0.5,"y":0.5,"w":0.1,"h":0.1},{"id":"a","x":0,"y":0,"w":0.1,"h":0.1},{"id":"b","x":0,"y":0,"w":0,"h":0.1}]`, []string{"occlusion:a", "occlusion:2"}},
+		{"occlusion without image", "foo", `[{"id":"a","x":0.1,"y":0.1,"w":0.2,"h":0.2}]`, []string{"basic:"}},
+		{"invalid occlusion", "![foo](assets/foo.png)", `[`, []string{"basic:"}},
+	}
+
+	for _, c := range cases {
+		tree := parse.Parse("", []byte(c.markdown), luteEngine.ParseOptions)
+		node := tree.Root.FirstChild
+		if "" != c.occlusions {
+			node.SetIALAttr(NodeAttrRiffOcclusions, c.occlusions)
+		}
+
+		var got []string
+		for _, variant := range computeFlashcardVariants(node) {
+			got = append(got, variant.id())
+		}
+		if !equalStrings(got, c.expected) {
+			t.Errorf("[%s] expected %v, got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestSyncBlockFlashcards(t *testing.T) {
+	dataDir := util.DataDir
+	util.DataDir = t.TempDir()
+	flashcardVariants = map[string]*FlashcardVariant{}
+	defer func() {
+		util.DataDir = dataDir
+		flashcardVariants = nil
+	}()
+
+	deck, err := riff.LoadDeck(t.TempDir(), "test", 0.9, 36500, "")
+	if err != nil {
+		t.Fatalf("load deck failed: %s", err)
+	}
+
+	blockID := ast.NewNodeID()
+	cards := func() map[string]string {
+		ret := map[string]string{}
+		for _, card := range deck.GetCardsByBlockID(blockID) {
+			variant := getFlashcardVariant(card.ID())
+			id := variant.id()
+			if variant.Orphaned {
+				id += "!"
+			}
+			ret[card.ID()] = id
+		}
+		return ret
+	}
+	cardOf := func(cards map[string]string, id string) string {
+		for cardID, variantID := range cards {
+			if variantID == id {
+				return cardID
+			}
+		}
+		return ""
+	}
+	cloze := func(keys ...string) (ret []*FlashcardVariant) {
+		for _, key := range keys {
+			ret = append(ret, &FlashcardVariant{Type: FlashcardTypeCloze, Key: key})
+		}
+		return
+	}
+
+	if !syncBlockFlashcards(deck, blockID, []*FlashcardVariant{{Type: FlashcardTypeBasic}}) {
+		t.Fatalf("expected deck changed")
+	}
+	step0 := cards()
+	basicCardID := cardOf(step0, "basic:")
+	if 1 != len(step0) || "" == basicCardID {
+		t.Fatalf("unexpected cards %v", step0)
+	}
+
+	// 正反面切换为正向和反向时复用原闪卡
+	syncBlockFlashcards(deck, blockID, []*FlashcardVariant{{Type: FlashcardTypeForward}, {Type: FlashcardTypeReverse}})
+	step1 := cards()
+	if 2 != len(step1) || basicCardID != cardOf(step1, "forward:") || "" == cardOf(step1, "reverse:") {
+		t.Fatalf("unexpected cards %v", step1)
+	}
+
+	// 编辑为填空后原闪卡只标记为孤立，不会被删除
+	syncBlockFlashcards(deck, blockID, cloze("c1", "c2"))
+	step2 := cards()
+	if 4 != len(step2) || basicCardID != cardOf(step2, "forward:!") || "" == cardOf(step2, "reverse:!") {
+		t.Fatalf("unexpected cards %v", step2)
+	}
+	c2CardID := cardOf(step2, "cloze:c2")
+	if "" == cardOf(step2, "cloze:c1") || "" == c2CardID {
+		t.Fatalf("unexpected cards %v", step2)
+	}
+
+	// 删除填空后对应的闪卡标记为孤立
+	if syncBlockFlashcards(deck, blockID, cloze("c1")) {
+		t.Fatalf("expected deck unchanged")
+	}
+	step3 := cards()
+	if 4 != len(step3) || c2CardID != cardOf(step3, "cloze:c2!") {
+		t.Fatalf("unexpected cards %v", step3)
+	}
+	if dues := filterOrphanedFlashcards(deck.Dues()); 1 != len(dues) || cardOf(step3, "cloze:c1") != dues[0].ID() {
+		t.Fatalf("expected only the c1 card due, got %d cards", len(dues))
+	}
+
+	// 恢复填空后继续使用原闪卡
+	if syncBlockFlashcards(deck, blockID, cloze("c1", "c2")) {
+		t.Fatalf("expected deck unchanged")
+	}
+	step4 := cards()
+	if 4 != len(step4) || c2CardID != cardOf(step4, "cloze:c2") {
+		t.Fatalf("unexpected cards %v", step4)
+	}
+
+	// 恢复为正反面时复用孤立的正向闪卡
+	syncBlockFlashcards(deck, blockID, []*FlashcardVariant{{Type: FlashcardTypeBasic}})
+	step5 := cards()
+	if 4 != len(step5) || basicCardID != cardOf(step5, "basic:") || c2CardID != cardOf(step5, "cloze:c2!") {
+		t.Fatalf("unexpected cards %v", step5)
+	}
+
+	// 显式移除闪卡时删除所有闪卡和类型记录
+	removeFlashcardsByBlockIDs([]string{blockID}, deck)
+	if 0 < len(deck.GetCardsByBlockID(blockID)) || 0 < len(getFlashcardVariants()) {
+		t.Fatalf("expected all cards removed")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
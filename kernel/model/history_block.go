@@ -0,0 +1,361 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// blockHistorySnapshotLimit 为块历史最多回溯的数据快照数量。
+const blockHistorySnapshotLimit = 32
+
+type BlockHistory struct {
+	Created int64  `json:"created"` // 毫秒时间戳
+	Source  string `json:"source"`  // current 当前版本，history 数据历史，snapshot 数据快照
+	Op      string `json:"op"`      // 数据历史的操作类型，数据快照为快照备注
+	Path    string `json:"path"`    // 数据历史文件路径或者数据快照文件 ID
+	Deleted bool   `json:"deleted"` // 该版本的文档中不存在这个块
+	Content string `json:"content"`
+}
+
+type BlockBlame struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	Updated string `json:"updated"`
+	Since   int64  `json:"since"` // 内容保持不变的最早版本时间，0 表示在所有历史版本之后才变更
+	Op      string `json:"op"`
+	Source  string `json:"source"`
+	Path    string `json:"path"`
+}
+
+type docVersion struct {
+	created int64
+	source  string
+	op      string
+	path    string
+	tree    *parse.Tree
+	sigs    map[string]string
+}
+
+func (v *docVersion) blockSig(id string, luteEngine *lute.Lute) (ret string, ok bool) {
+	if ret, ok = v.sigs[id]; ok {
+		// 空签名表示该版本中不存在这个块
+		return ret, "" != ret
+	}
+
+	node := treenode.GetNodeInTree(v.tree, id)
+	if nil == node {
+		v.sigs[id] = ""
+		return "", false
+	}
+	ret = treenode.FormatNode(node, luteEngine)
+	v.sigs[id] = ret
+	return ret, true
+}
+
+// GetBlockHistory 获取块的历史版本时间线，相同内容的连续版本只保留最早的一个。
+//
+// 块已经被删除时需要通过 rootID 指定所在的文档。
+func GetBlockHistory(id, rootID string) (ret []*BlockHistory, err error) {
+	ret = []*BlockHistory{}
+	FlushTxQueue()
+
+	box, p := "", ""
+	if bt := treenode.GetBlockTree(id); nil != bt {
+		rootID, box, p = bt.RootID, bt.BoxID, bt.Path
+	} else if bt = treenode.GetBlockTree(rootID); nil != bt {
+		box, p = bt.BoxID, bt.Path
+	}
+	if "" == rootID {
+		err = ErrBlockNotFound
+		return
+	}
+
+	luteEngine := util.NewLute()
+	versions := getDocVersions(rootID, box, p, luteEngine)
+	if tree, _ := LoadTreeByBlockID(rootID); nil != tree {
+		current := &docVersion{created: util.CurrentTimeMillis(), source: "current", tree: tree, sigs: map[string]string{}}
+		if node := treenode.GetNodeInTree(tree, id); nil != node {
+			if updated, parseErr := time.ParseInLocation("20060102150405", node.IALAttr("updated"), time.Local); nil == parseErr {
+				current.created = updated.UnixMilli()
+			}
+		}
+		versions = append(versions, current)
+	}
+
+	prevSig := "-"
+	for _, v := range versions {
+		sig, ok := v.blockSig(id, luteEngine)
+		if sig == prevSig {
+			continue
+		}
+		prevSig = sig
+
+		item := &BlockHistory{Created: v.created, Source: v.source, Op: v.op, Path: v.path, Deleted: !ok}
+		if ok {
+			item.Content = treenode.ExportNodeStdMd(treenode.GetNodeInTree(v.tree, id), luteEngine)
+		}
+		ret = append(ret, item)
+	}
+
+	// 第一个版本中不存在该块时不需要展示
+	if 0 < len(ret) && ret[0].Deleted {
+		ret = ret[1:]
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Created > ret[j].Created
+	})
+	return
+}
+
+// GetDocBlame 获取文档中每个块最后一次变更所在的历史版本。
+func GetDocBlame(rootID string) (ret []*BlockBlame, err error) {
+	ret = []*BlockBlame{}
+	FlushTxQueue()
+
+	tree, err := LoadTreeByBlockID(rootID)
+	if err != nil {
+		return
+	}
+
+	luteEngine := util.NewLute()
+	versions := getDocVersions(tree.ID, tree.Box, tree.Path, luteEngine)
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || "" == n.ID || ast.NodeDocument == n.Type {
+			return ast.WalkContinue
+		}
+
+		blame := &BlockBlame{
+			ID:      n.ID,
+			Type:    treenode.TypeAbbr(n.Type.String()),
+			Content: getNodeRefText0(n, 64, true),
+			Updated: n.IALAttr("updated"),
+		}
+		sig := treenode.FormatNode(n, luteEngine)
+		for i := len(versions) - 1; 0 <= i; i-- {
+			v := versions[i]
+			if vSig, ok := v.blockSig(n.ID, luteEngine); !ok || vSig != sig {
+				break
+			}
+			blame.Since, blame.Op, blame.Source, blame.Path = v.created, v.op, v.source, v.path
+		}
+		ret = append(ret, blame)
+		return ast.WalkContinue
+	})
+	return
+}
+
+// RollbackBlockHistory 将单个块回滚到数据历史或者数据快照中的版本。
+func RollbackBlockHistory(id, source, path string) (err error) {
+	luteEngine := util.NewLute()
+	var versionTree *parse.Tree
+	switch source {
+	case "history":
+		if !util.IsAbsPathInWorkspace(path) {
+			err = errors.New("Path [" + path + "] is not in workspace")
+			return
+		}
+		versionTree, err = loadTree(path, luteEngine)
+	case "snapshot":
+		if 1 > len(Conf.Repo.Key) {
+			err = errors.New(Conf.Language(26))
+			return
+		}
+		repo, repoErr := newRepository()
+		if nil != repoErr {
+			err = repoErr
+			return
+		}
+		versionTree, err = openSnapshotTree(repo, path, luteEngine)
+	default:
+		err = errors.New("invalid history source")
+	}
+	if err != nil {
+		return
+	}
+
+	node := treenode.GetNodeInTree(versionTree, id)
+	if nil == node {
+		err = ErrBlockNotFound
+		return
+	}
+	if ast.NodeDocument == node.Type {
+		err = errors.New("use doc history rollback for documents")
+		return
+	}
+
+	FlushTxQueue()
+
+	tree, err := LoadTreeByBlockID(versionTree.Root.ID)
+	if err != nil {
+		return
+	}
+	generateOpTypeHistory(tree, HistoryOpUpdate)
+
+	current := treenode.GetNodeInTree(tree, id)
+
+	// 重置在文档其他位置已经存在的子块 ID
+	existIDs := map[string]bool{}
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() {
+			return ast.WalkContinue
+		}
+		if n == current {
+			return ast.WalkSkipChildren
+		}
+		existIDs[n.ID] = true
+		return ast.WalkContinue
+	})
+	var prevIDs []string
+	for prev := node.Previous; nil != prev; prev = prev.Previous {
+		if "" != prev.ID {
+			prevIDs = append(prevIDs, prev.ID)
+		}
+	}
+	parentID := ""
+	if nil != node.Parent && ast.NodeDocument != node.Parent.Type {
+		parentID = node.Parent.ID
+	}
+	node.Unlink()
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && n != node && existIDs[n.ID] {
+			treenode.ResetNodeID(n)
+		}
+		return ast.WalkContinue
+	})
+
+	if nil != current {
+		current.InsertBefore(node)
+		current.Unlink()
+	} else {
+		insertRollbackBlock(tree, node, prevIDs, parentID)
+	}
+
+	if err = indexWriteTreeUpsertQueue(tree); err != nil {
+		return
+	}
+	ReloadProtyle(tree.ID)
+	IncSync()
+	return
+}
+
+// insertRollbackBlock 将已经被删除的块插入到历史版本中它前面最近的兄弟块之后，找不到时插入为父块或者文档的第一个子块。
+func insertRollbackBlock(tree *parse.Tree, node *ast.Node, prevIDs []string, parentID string) {
+	for _, prevID := range prevIDs {
+		if prevNode := treenode.GetNodeInTree(tree, prevID); nil != prevNode {
+			prevNode.InsertAfter(node)
+			return
+		}
+	}
+
+	if "" != parentID {
+		if parentNode := treenode.GetNodeInTree(tree, parentID); nil != parentNode {
+			prependChildBlock(parentNode, node)
+			return
+		}
+	}
+	prependChildBlock(tree.Root, node)
+}
+
+// getDocVersions 从数据历史和数据快照中收集文档的历史版本，按时间升序排列。
+func getDocVersions(rootID, box, p string, luteEngine *lute.Lute) (ret []*docVersion) {
+	if !ast.IsNodeIDPattern(rootID) {
+		return
+	}
+
+	stmt := "SELECT * FROM histories_fts_case_insensitive WHERE id = '" + rootID + "' AND type = " + strconv.Itoa(HistoryTypeDoc)
+	for _, history := range sql.SelectHistoriesRawStmt(stmt) {
+		created, parseErr := strconv.ParseInt(history.Created, 10, 64)
+		if nil != parseErr {
+			continue
+		}
+
+		historyPath := filepath.Join(util.HistoryDir, history.Path)
+		tree, loadErr := loadTree(historyPath, luteEngine)
+		if nil != loadErr {
+			continue
+		}
+		ret = append(ret, &docVersion{created: created * 1000, source: "history", op: history.Op, path: historyPath, tree: tree, sigs: map[string]string{}})
+	}
+
+	ret = append(ret, getDocSnapshotVersions(box, p, luteEngine)...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].created < ret[j].created
+	})
+	return
+}
+
+func getDocSnapshotVersions(box, p string, luteEngine *lute.Lute) (ret []*docVersion) {
+	if 1 > len(Conf.Repo.Key) || "" == box || "" == p {
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	indexes, _, _, err := repo.GetIndexes(1, blockHistorySnapshotLimit)
+	if err != nil {
+		logging.LogErrorf("get data repo indexes failed: %s", err)
+		return
+	}
+
+	filePath := "/" + box + p
+	fileIDs := map[string]bool{}
+	for _, index := range indexes {
+		files, getErr := repo.GetFiles(index)
+		if nil != getErr {
+			continue
+		}
+
+		var file *entity.File
+		for _, f := range files {
+			if filePath == f.Path {
+				file = f
+				break
+			}
+		}
+		if nil == file || fileIDs[file.ID] {
+			continue
+		}
+		fileIDs[file.ID] = true
+
+		tree, openErr := openSnapshotTree(repo, file.ID, luteEngine)
+		if nil != openErr {
+			continue
+		}
+		ret = append(ret, &docVersion{created: file.Updated, source: "snapshot", op: strings.TrimSpace(index.Memo), path: file.ID, tree: tree, sigs: map[string]string{}})
+	}
+	return
+}
@@ -47,7 +47,6 @@ import (
 	"github.com/siyuan-note/dataparser"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
-	"github.com/siyuan-note/riff"
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
@@ -377,7 +376,7 @@ func ImportSY(zipPath, boxID, toPath string) (err error) {
 	// 将关联的闪卡数据合并到默认卡包 data/storage/riff/20230218211946-2kw8jgx 中
 	storageRiffDir := filepath.Join(storage, "riff")
 	if gulu.File.IsExist(storageRiffDir) {
-		deckToImport, loadErr := riff.LoadDeck(storageRiffDir, builtinDeckID, Conf.Flashcard.RequestRetention, Conf.Flashcard.MaximumInterval, Conf.Flashcard.Weights)
+		deckToImport, loadErr := loadDeck(storageRiffDir, builtinDeckID)
 		if nil != loadErr {
 			logging.LogErrorf("load deck [%s] failed: %s", name, loadErr)
 		} else {
@@ -782,6 +781,40 @@ func ImportData(zipPath string) (err error) {
 }
 
 func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
+	_, _, err = importFromLocalPath(boxID, localPath, toPath, nil)
+	return
+}
+
+// ImportReport 描述了一次导入的结果。
+type ImportReport struct {
+	Docs            int                     `json:"docs"`            // 导入的文档数
+	Assets          int                     `json:"assets"`          // 导入的资源文件数
+	AttributeViews  []string                `json:"attributeViews"`  // 导入时新建的数据库 ID
+	UnresolvedLinks []*ImportUnresolvedLink `json:"unresolvedLinks"` // 未能解析的链接
+}
+
+// ImportUnresolvedLink 描述了导入时未能解析的链接。
+type ImportUnresolvedLink struct {
+	HPath string `json:"hPath"` // 链接所在文档的路径
+	Link  string `json:"link"`  // 链接内容
+}
+
+// localImportHooks 用于在导入本地 Markdown 时插入特定来源（比如 Obsidian）的处理逻辑，字段为空时使用默认处理。
+type localImportHooks struct {
+	parseMd     func(data []byte) (ret *parse.Tree, yfmRootID, yfmTitle, yfmUpdated string) // 解析 Markdown
+	treeParsed  func(tree *parse.Tree, localPath string)                                    // 文档树生成并分配 ID 后调用
+	beforeLinks func(assets map[string]string)                                              // 转换文档链接前调用，assets 为本地路径到资源文件名的映射
+}
+
+// importFromLocalPath 导入本地 Markdown 文件或文件夹，返回导入报告和本地路径到文档 ID 的映射。
+func importFromLocalPath(boxID, localPath string, toPath string, hooks *localImportHooks) (report *ImportReport, docIDs map[string]string, err error) {
+	report = &ImportReport{AttributeViews: []string{}, UnresolvedLinks: []*ImportUnresolvedLink{}}
+	docIDs = map[string]string{}
+	parseMd := parseStdMd
+	if nil != hooks && nil != hooks.parseMd {
+		parseMd = hooks.parseMd
+	}
+
 	util.PushEndlessProgress(Conf.Language(73))
 	defer func() {
 		util.PushClearProgress()
@@ -807,7 +840,7 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 		block := treenode.GetBlockTreeRootByPath(boxID, toPath)
 		if nil == block {
 			logging.LogErrorf("not found block by path [%s]", toPath)
-			return nil
+			return
 		}
 		baseHPath = block.HPath
 		baseTargetPath = strings.TrimSuffix(block.Path, ".sy")
@@ -904,6 +937,7 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 
 				tree = treenode.NewTree(boxID, targetPath, hPath, title)
 				importTrees = append(importTrees, tree)
+				docIDs[currentPath] = tree.ID
 				return nil
 			}
 
@@ -917,7 +951,7 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 				return io.EOF
 			}
 
-			tree, yfmRootID, yfmTitle, yfmUpdated := parseStdMd(data)
+			tree, yfmRootID, yfmTitle, yfmUpdated := parseMd(data)
 			if nil == tree {
 				logging.LogErrorf("parse tree [%s] failed", currentPath)
 				return nil
@@ -1026,7 +1060,11 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 			})
 
 			reassignIDUpdated(tree, id, updated)
+			if nil != hooks && nil != hooks.treeParsed {
+				hooks.treeParsed(tree, currentPath)
+			}
 			importTrees = append(importTrees, tree)
+			docIDs[currentPath] = tree.ID
 
 			hPathsIDs[tree.HPath] = tree.ID
 			idPaths[tree.ID] = tree.Path
@@ -1040,7 +1078,8 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 	} else { // 导入单个文件
 		fileName := filepath.Base(localPath)
 		if !strings.HasSuffix(fileName, ".md") && !strings.HasSuffix(fileName, ".markdown") {
-			return errors.New(Conf.Language(79))
+			err = errors.New(Conf.Language(79))
+			return
 		}
 
 		title := strings.TrimSuffix(fileName, ".markdown")
@@ -1051,13 +1090,14 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 		var data []byte
 		data, err = os.ReadFile(localPath)
 		if err != nil {
-			return err
+			return
 		}
-		tree, yfmRootID, yfmTitle, yfmUpdated := parseStdMd(data)
+		tree, yfmRootID, yfmTitle, yfmUpdated := parseMd(data)
 		if nil == tree {
 			msg := fmt.Sprintf("parse tree [%s] failed", localPath)
 			logging.LogErrorf(msg)
-			return errors.New(msg)
+			err = errors.New(msg)
+			return
 		}
 
 		if "" != yfmRootID {
@@ -1149,7 +1189,11 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 		})
 
 		reassignIDUpdated(tree, id, updated)
+		if nil != hooks && nil != hooks.treeParsed {
+			hooks.treeParsed(tree, localPath)
+		}
 		importTrees = append(importTrees, tree)
+		docIDs[localPath] = tree.ID
 	}
 
 	if 0 < len(importTrees) {
@@ -1161,9 +1205,15 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 		}
 
 		initSearchLinks()
+		if nil != hooks && nil != hooks.beforeLinks {
+			hooks.beforeLinks(assetsDone)
+		}
 		convertMdHyperlinks2WikiLinks()
 		convertWikiLinksAndTags()
 		mergeTextAndHandlerNestedInlines()
+		report.Docs = len(importTrees)
+		report.Assets = len(assetsDone)
+		report.UnresolvedLinks = append(report.UnresolvedLinks, unresolvedLinks...)
 
 		box := Conf.Box(boxID)
 		for i, tree := range importTrees {
@@ -1176,6 +1226,7 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 
 		importTrees = []*parse.Tree{}
 		searchLinks = map[string]string{}
+		unresolvedLinks = nil
 
 		// 按照路径排序 Improve sort when importing markdown files https://github.com/siyuan-note/siyuan/issues/11390
 		var hPaths []string
@@ -1223,6 +1274,10 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 func parseStdMd(markdown []byte) (ret *parse.Tree, yfmRootID, yfmTitle, yfmUpdated string) {
 	luteEngine := util.NewStdLute()
 	luteEngine.SetYamlFrontMatter(true) // 解析 YAML Front Matter https://github.com/siyuan-note/siyuan/issues/10878
+	return parseStdMdWithLute(luteEngine, markdown)
+}
+
+func parseStdMdWithLute(luteEngine *lute.Lute, markdown []byte) (ret *parse.Tree, yfmRootID, yfmTitle, yfmUpdated string) {
 	ret = parse.Parse("", markdown, luteEngine.ParseOptions)
 	if nil == ret {
 		return
@@ -1542,6 +1597,7 @@ func domAttrValue(n *html.Node, attrName string) string {
 
 var importTrees []*parse.Tree
 var searchLinks = map[string]string{}
+var unresolvedLinks []*ImportUnresolvedLink
 
 func initSearchLinks() {
 	for _, tree := range importTrees {
@@ -1591,6 +1647,9 @@ func convertMdHyperlinks2WikiLinks() {
 			}
 			linkDest = strings.TrimSuffix(linkDest, ".md")
 			linkDest = strings.TrimSuffix(linkDest, ".markdown")
+			if unescaped, unescapeErr := url.PathUnescape(linkDest); nil == unescapeErr {
+				linkDest = unescaped
+			}
 
 			buf := bytes.Buffer{}
 			buf.WriteString("[[")
@@ -1642,7 +1701,12 @@ func convertWikiLinksAndTags0(tree *parse.Tree) {
 				break
 			}
 
-			link := path.Join(path.Dir(tree.HPath), text[start+2:end]) // 统一转为绝对路径方便后续查找
+			rawLink := text[start+2 : end]
+			link := path.Join(path.Dir(tree.HPath), rawLink) // 统一转为绝对路径方便后续查找
+			if strings.HasPrefix(rawLink, "#") || strings.HasPrefix(rawLink, "^") {
+				// 链接到当前文档中的标题或者块
+				link = tree.HPath + rawLink
+			}
 			linkText := path.Base(link)
 			dynamicAnchorText := true
 			if linkParts := strings.Split(link, "|"); 1 < len(linkParts) {
@@ -1651,13 +1715,12 @@ func convertWikiLinksAndTags0(tree *parse.Tree) {
 				dynamicAnchorText = false
 			}
 			link, linkText = strings.TrimSpace(link), strings.TrimSpace(linkText)
-			if !strings.Contains(link, "#") {
-				link += "#" // 在结尾统一带上锚点方便后续查找
-			}
+			link = normalizeWikiLinkAnchor(link)
 
 			id := searchLinkID(link)
 			if "" == id {
-				start, end = end, length
+				unresolvedLinks = append(unresolvedLinks, &ImportUnresolvedLink{HPath: tree.HPath, Link: rawLink})
+				start, end = end+2, length
 				continue
 			}
 
@@ -1678,6 +1741,22 @@ func convertWikiLinksAndTags0(tree *parse.Tree) {
 	})
 }
 
+// normalizeWikiLinkAnchor 规范化链接锚点：[[note#^block]] 转为 [[note^block]]，[[note#h1#h2]] 仅保留最后一级标题，没有锚点时在结尾带上 #。
+func normalizeWikiLinkAnchor(link string) string {
+	if idx := strings.Index(link, "^"); 0 <= idx {
+		return strings.TrimSuffix(link[:idx], "#") + link[idx:]
+	}
+
+	idx := strings.Index(link, "#")
+	if 0 > idx {
+		return link + "#" // 在结尾统一带上锚点方便后续查找
+	}
+	if lastIdx := strings.LastIndex(link, "#"); lastIdx != idx {
+		link = link[:idx] + link[lastIdx:]
+	}
+	return link
+}
+
 func convertTags(text string) (ret string) {
 	if !util.MarkdownSettings.InlineTag {
 		return text
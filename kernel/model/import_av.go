@@ -0,0 +1,633 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/araddon/dateparse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/xuri/excelize/v2"
+)
+
+// ImportAttributeView 从 CSV、TSV 或者 XLSX 文件导入数据库，文件第一行为字段名。
+//
+// avID 为空时新建数据库，并将数据库块插入到 blockID 之后（blockID 为文档时插入到文档末尾）；否则追加到 avID 对应的数据库中。
+// keyMapping 将列名映射到已有的字段 ID，映射为空字符串的列不导入；未映射的列优先按名称匹配已有字段，匹配不到时按列内容推断类型新建字段。
+// boxID 不为空时为每一行在笔记本 boxID 的 hPath 路径下创建文档并绑定，否则创建非绑定块。
+func ImportAttributeView(filePath, avID, blockID string, keyMapping map[string]string, boxID, hPath string) (retAvID string, err error) {
+	rows, err := readAttrViewImportRows(filePath)
+	if err != nil {
+		return
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return importAttributeViewRows(rows, name, avID, blockID, keyMapping, boxID, hPath, nil)
+}
+
+// importAttributeViewRows 导入表格数据到数据库，rows 第一行为字段名。
+//
+// rowBlockIDs 按顺序给出每一行需要绑定的已有块 ID，为空字符串的行按照 boxID 的规则处理。
+func importAttributeViewRows(rows [][]string, name, avID, blockID string, keyMapping map[string]string, boxID, hPath string, rowBlockIDs []string) (retAvID string, err error) {
+	if 2 > len(rows) {
+		err = errors.New("no data rows found")
+		return
+	}
+
+	if "" != boxID && nil == Conf.Box(boxID) {
+		err = errors.New(Conf.Language(0))
+		return
+	}
+
+	FlushTxQueue()
+
+	isNew := "" == avID
+	if isNew {
+		if "" == blockID || nil == treenode.GetBlockTree(blockID) {
+			err = ErrBlockNotFound
+			return
+		}
+
+		avID = ast.NewNodeID()
+		attrView := av.NewAttributeView(avID)
+		attrView.Name = name
+		// 移除新建数据库时默认添加的单选字段
+		for i, keyValues := range attrView.KeyValues {
+			if av.KeyTypeSelect == keyValues.Key.Type {
+				attrView.KeyValues = append(attrView.KeyValues[:i], attrView.KeyValues[i+1:]...)
+				attrView.Views[0].Table.Columns = attrView.Views[0].Table.Columns[:1]
+				break
+			}
+		}
+		if err = av.SaveAttributeView(attrView); err != nil {
+			return
+		}
+	}
+	retAvID = avID
+
+	attrView, err := av.ParseAttributeView(avID)
+	if err != nil {
+		return
+	}
+
+	header, records := rows[0], rows[1:]
+	blockKey := attrView.GetBlockKey()
+	columnKeys := make([]*av.Key, len(header))
+	primaryCol := -1
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		header[i] = name
+		if keyID, ok := keyMapping[name]; ok {
+			if "" == keyID {
+				continue
+			}
+
+			key, getErr := attrView.GetKey(keyID)
+			if nil != getErr {
+				err = getErr
+				return
+			}
+			columnKeys[i] = key
+		} else if !isNew {
+			for _, keyValues := range attrView.KeyValues {
+				if name == keyValues.Key.Name {
+					columnKeys[i] = keyValues.Key
+					break
+				}
+			}
+		}
+
+		if nil != columnKeys[i] && blockKey.ID == columnKeys[i].ID && -1 == primaryCol {
+			primaryCol = i
+		}
+	}
+
+	// 没有映射到主键的列时使用第一个未映射的列作为主键
+	if -1 == primaryCol {
+		for i, name := range header {
+			if keyID, ok := keyMapping[name]; ok && "" == keyID {
+				continue
+			}
+			if nil == columnKeys[i] {
+				primaryCol = i
+				columnKeys[i] = blockKey
+				if isNew && "" != name {
+					blockKey.Name = name
+				}
+				break
+			}
+		}
+	}
+	if err = av.SaveAttributeView(attrView); err != nil {
+		return
+	}
+
+	// 按照列内容推断类型新建字段
+	previousKeyID := blockKey.ID
+	for i, name := range header {
+		if keyID, ok := keyMapping[name]; ok && "" == keyID {
+			continue
+		}
+		if nil != columnKeys[i] {
+			previousKeyID = columnKeys[i].ID
+			continue
+		}
+
+		var values []string
+		for _, record := range records {
+			if i < len(record) {
+				values = append(values, record[i])
+			}
+		}
+		keyType, numberFormat := inferAttrViewImportKeyType(values)
+		if "" == name {
+			name = strconv.Itoa(i + 1)
+		}
+
+		keyID := ast.NewNodeID()
+		if err = AddAttributeViewKey(avID, keyID, name, string(keyType), "", previousKeyID); err != nil {
+			return
+		}
+		columnKeys[i] = &av.Key{ID: keyID, Type: keyType, NumberFormat: numberFormat}
+		previousKeyID = keyID
+	}
+
+	attrView, err = av.ParseAttributeView(avID)
+	if err != nil {
+		return
+	}
+	for i, columnKey := range columnKeys {
+		if nil == columnKey {
+			continue
+		}
+
+		key, _ := attrView.GetKey(columnKey.ID)
+		if nil == key {
+			continue
+		}
+		if "" != columnKey.NumberFormat {
+			key.NumberFormat = columnKey.NumberFormat
+		}
+		columnKeys[i] = key
+	}
+
+	// 每一行都在父文档下新建文档，不能按照 hPath 查找，否则同名的行会绑定到同一个文档
+	parentPath := ""
+	if parentHPath := path.Join("/", hPath); "" != boxID && "/" != parentHPath {
+		createDocLock.Lock()
+		parentID, createErr := createDocsByHPath(boxID, parentHPath, "", "", "")
+		createDocLock.Unlock()
+		if nil != createErr {
+			logging.LogErrorf("create parent doc [%s] failed: %s", parentHPath, createErr)
+			err = createErr
+			return
+		}
+
+		parent := treenode.GetBlockTree(parentID)
+		if nil == parent {
+			err = ErrBlockNotFound
+			return
+		}
+		parentPath = strings.TrimSuffix(parent.Path, ".sy")
+	}
+
+	now := util.CurrentTimeMillis()
+	var itemIDs, boundBlockIDs []string
+	for rowIndex, record := range records {
+		itemID := ast.NewNodeID()
+		var content string
+		if -1 < primaryCol && primaryCol < len(record) {
+			content = strings.TrimSpace(record[primaryCol])
+		}
+
+		blockValue := &av.Value{Block: &av.ValueBlock{Content: content, Created: now, Updated: now}, IsDetached: true}
+		if rowIndex < len(rowBlockIDs) && "" != rowBlockIDs[rowIndex] {
+			blockValue.Block.ID = rowBlockIDs[rowIndex]
+			blockValue.IsDetached = false
+			boundBlockIDs = append(boundBlockIDs, rowBlockIDs[rowIndex])
+		} else if "" != boxID {
+			title := content
+			if "" == title {
+				title = Conf.language(16)
+			}
+			title = strings.ReplaceAll(title, "/", "")
+			boundBlockID := ast.NewNodeID()
+			createDocLock.Lock()
+			_, createErr := createDoc(boxID, parentPath+"/"+boundBlockID+".sy", title, "")
+			createDocLock.Unlock()
+			if nil != createErr {
+				logging.LogErrorf("create doc for row [%d] failed: %s", rowIndex+1, createErr)
+				err = createErr
+				return
+			}
+
+			blockValue.Block.ID = boundBlockID
+			blockValue.Block.Content = title
+			blockValue.IsDetached = false
+			boundBlockIDs = append(boundBlockIDs, boundBlockID)
+		}
+
+		for i, key := range columnKeys {
+			if nil == key {
+				continue
+			}
+
+			var val *av.Value
+			if av.KeyTypeBlock == key.Type {
+				val = blockValue
+			} else {
+				if i >= len(record) {
+					continue
+				}
+
+				if val = newAttrViewImportValue(key, record[i]); nil == val {
+					continue
+				}
+				val.IsDetached = blockValue.IsDetached
+			}
+
+			keyValues, _ := attrView.GetKeyValues(key.ID)
+			if nil == keyValues {
+				continue
+			}
+
+			val.ID = ast.NewNodeID()
+			val.KeyID = key.ID
+			val.BlockID = itemID
+			val.Type = key.Type
+			val.CreatedAt = now
+			val.UpdatedAt = now
+			keyValues.Values = append(keyValues.Values, val)
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+
+	for _, v := range attrView.Views {
+		v.ItemIDs = append(v.ItemIDs, itemIDs...)
+	}
+
+	regenAttrViewGroups(attrView)
+	if err = av.SaveAttributeView(attrView); err != nil {
+		logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	FlushTxQueue()
+	for _, boundBlockID := range boundBlockIDs {
+		bindBlockAv(nil, avID, boundBlockID)
+	}
+
+	if isNew {
+		if err = insertAttrViewImportBlock(avID, attrView.ViewID, blockID); err != nil {
+			return
+		}
+	}
+
+	ReloadAttrView(avID)
+	return
+}
+
+// insertAttrViewImportBlock 在 blockID 之后插入数据库块，blockID 为文档时插入到文档末尾。
+func insertAttrViewImportBlock(avID, viewID, blockID string) (err error) {
+	tree, err := LoadTreeByBlockID(blockID)
+	if err != nil {
+		return
+	}
+
+	node := treenode.GetNodeInTree(tree, blockID)
+	if nil == node {
+		return ErrBlockNotFound
+	}
+
+	avNode := &ast.Node{Type: ast.NodeAttributeView, ID: ast.NewNodeID(), AttributeViewID: avID, AttributeViewType: string(av.LayoutTypeTable)}
+	avNode.SetIALAttr("id", avNode.ID)
+	avNode.SetIALAttr("updated", util.TimeFromID(avNode.ID))
+	avNode.SetIALAttr(av.NodeAttrView, viewID)
+
+	operation := &Operation{Action: "insert", ID: avNode.ID, Data: util.NewLute().RenderNodeBlockDOM(avNode)}
+	if ast.NodeDocument == node.Type {
+		operation.ParentID = node.ID
+		if nil != node.LastChild {
+			operation.PreviousID = node.LastChild.ID
+		}
+	} else {
+		operation.PreviousID = node.ID
+	}
+
+	transactions := []*Transaction{{
+		DoOperations:   []*Operation{operation},
+		UndoOperations: []*Operation{{Action: "delete", ID: avNode.ID}},
+	}}
+	PerformTransactions(&transactions)
+	FlushTxQueue()
+
+	evt := util.NewCmdResult("transactions", 0, util.PushModeBroadcast)
+	evt.Data = transactions
+	util.PushEvent(evt)
+	return
+}
+
+func readAttrViewImportRows(filePath string) (ret [][]string, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".xlsx", ".xlsm":
+		x, openErr := excelize.OpenFile(filePath)
+		if nil != openErr {
+			logging.LogErrorf("open [%s] failed: %s", filePath, openErr)
+			err = openErr
+			return
+		}
+		defer x.Close()
+
+		ret, err = x.GetRows(x.GetSheetName(x.GetActiveSheetIndex()))
+		if err != nil {
+			logging.LogErrorf("read [%s] rows failed: %s", filePath, err)
+			return
+		}
+	default:
+		data, readErr := os.ReadFile(filePath)
+		if nil != readErr {
+			err = readErr
+			return
+		}
+
+		content := strings.TrimPrefix(string(data), "\xEF\xBB\xBF")
+		if !utf8.ValidString(content) {
+			err = errors.New("file is not UTF-8 encoded")
+			return
+		}
+
+		reader := csv.NewReader(strings.NewReader(content))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		firstLine, _, _ := strings.Cut(content, "\n")
+		if ext := strings.ToLower(filepath.Ext(filePath)); ".tsv" == ext || ".tab" == ext || (strings.Contains(firstLine, "\t") && !strings.Contains(firstLine, ",")) {
+			reader.Comma = '\t'
+		}
+		ret, err = reader.ReadAll()
+		if err != nil {
+			logging.LogErrorf("read [%s] failed: %s", filePath, err)
+			return
+		}
+	}
+
+	// 去掉末尾的空行
+	for 0 < len(ret) && "" == strings.TrimSpace(strings.Join(ret[len(ret)-1], "")) {
+		ret = ret[:len(ret)-1]
+	}
+	return
+}
+
+var (
+	attrViewImportEmailRegexp        = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+	attrViewImportThousandsRegexp    = regexp.MustCompile(`^[-+]?\d{1,3}(,\d{3})+(\.\d+)?$`)
+	attrViewImportCheckboxCheckeds   = []string{av.CheckboxCheckedStr, "✓", "✔", "☑", "[x]", "true", "yes", "checked", "是"}
+	attrViewImportCheckboxUncheckeds = []string{"☐", "[ ]", "false", "no", "unchecked", "否"}
+)
+
+const attrViewImportMSelectSeps = ",，;；|"
+
+// inferAttrViewImportKeyType 根据列中所有非空内容推断字段类型。
+func inferAttrViewImportKeyType(values []string) (keyType av.KeyType, numberFormat av.NumberFormat) {
+	var nonEmpty []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); "" != v {
+			nonEmpty = append(nonEmpty, v)
+		}
+	}
+	if 1 > len(nonEmpty) {
+		return av.KeyTypeText, av.NumberFormatNone
+	}
+
+	allMatch := func(match func(v string) bool) bool {
+		for _, v := range nonEmpty {
+			if !match(v) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if allMatch(func(v string) bool { _, ok := parseAttrViewImportCheckbox(v); return ok }) {
+		return av.KeyTypeCheckbox, av.NumberFormatNone
+	}
+	if allMatch(func(v string) bool { _, _, ok := parseAttrViewImportNumber(v); return ok }) {
+		if allMatch(func(v string) bool { return strings.HasSuffix(v, "%") }) {
+			return av.KeyTypeNumber, av.NumberFormatPercent
+		}
+		if allMatch(func(v string) bool { return attrViewImportThousandsRegexp.MatchString(v) }) {
+			return av.KeyTypeNumber, av.NumberFormatCommas
+		}
+		return av.KeyTypeNumber, av.NumberFormatNone
+	}
+	if allMatch(func(v string) bool { _, _, _, ok := parseAttrViewImportDate(v); return ok }) {
+		return av.KeyTypeDate, av.NumberFormatNone
+	}
+	if allMatch(isAttrViewImportURL) {
+		return av.KeyTypeURL, av.NumberFormatNone
+	}
+	if allMatch(attrViewImportEmailRegexp.MatchString) {
+		return av.KeyTypeEmail, av.NumberFormatNone
+	}
+
+	// 选项数量较少且存在重复时识别为单选或多选
+	options := map[string]bool{}
+	multi := false
+	optCount := 0
+	for _, v := range nonEmpty {
+		opts := splitAttrViewImportOptions(v)
+		if 1 < len(opts) {
+			multi = true
+		}
+		optCount += len(opts)
+		for _, opt := range opts {
+			if 64 < utf8.RuneCountInString(opt) {
+				return av.KeyTypeText, av.NumberFormatNone
+			}
+			options[opt] = true
+		}
+	}
+	if 2 <= len(nonEmpty) && len(options) <= 32 && len(options) < optCount {
+		if multi {
+			return av.KeyTypeMSelect, av.NumberFormatNone
+		}
+		return av.KeyTypeSelect, av.NumberFormatNone
+	}
+	return av.KeyTypeText, av.NumberFormatNone
+}
+
+// newAttrViewImportValue 根据字段类型将单元格内容转换为字段值，内容为空或者字段类型不支持导入时返回 nil。
+func newAttrViewImportValue(key *av.Key, content string) (ret *av.Value) {
+	content = strings.TrimSpace(content)
+	if "" == content {
+		return
+	}
+
+	switch key.Type {
+	case av.KeyTypeText:
+		ret = &av.Value{Text: &av.ValueText{Content: content}}
+	case av.KeyTypeNumber:
+		num, _, ok := parseAttrViewImportNumber(content)
+		if !ok {
+			return
+		}
+		ret = &av.Value{Number: av.NewFormattedValueNumber(num, key.NumberFormat)}
+	case av.KeyTypeDate:
+		start, end, isNotTime, ok := parseAttrViewImportDate(content)
+		if !ok {
+			return
+		}
+		ret = &av.Value{Date: &av.ValueDate{Content: start, IsNotEmpty: true, IsNotTime: isNotTime}}
+		if 0 < end {
+			ret.Date.HasEndDate, ret.Date.Content2, ret.Date.IsNotEmpty2 = true, end, true
+		}
+	case av.KeyTypeSelect, av.KeyTypeMSelect:
+		opts := []string{content}
+		if av.KeyTypeMSelect == key.Type {
+			opts = splitAttrViewImportOptions(content)
+		}
+
+		ret = &av.Value{}
+		for _, name := range opts {
+			opt := key.GetOption(name)
+			if nil == opt {
+				opt = &av.SelectOption{Name: name, Color: strconv.Itoa(len(key.Options)%14 + 1)}
+				key.Options = append(key.Options, opt)
+			}
+			ret.MSelect = append(ret.MSelect, &av.ValueSelect{Content: opt.Name, Color: opt.Color})
+		}
+	case av.KeyTypeURL:
+		ret = &av.Value{URL: &av.ValueURL{Content: content}}
+	case av.KeyTypeEmail:
+		ret = &av.Value{Email: &av.ValueEmail{Content: content}}
+	case av.KeyTypePhone:
+		ret = &av.Value{Phone: &av.ValuePhone{Content: content}}
+	case av.KeyTypeCheckbox:
+		checked, ok := parseAttrViewImportCheckbox(content)
+		if !ok {
+			// 无法识别的非空内容视为勾选
+			checked = true
+		}
+		ret = &av.Value{Checkbox: &av.ValueCheckbox{Checked: checked}}
+	case av.KeyTypeMAsset:
+		ret = &av.Value{}
+		for _, field := range strings.Fields(content) {
+			var assetType av.AssetType = av.AssetTypeFile
+			if gulu.Str.Contains(strings.ToLower(path.Ext(field)), util.SiYuanAssetsImage) {
+				assetType = av.AssetTypeImage
+			}
+			ret.MAsset = append(ret.MAsset, &av.ValueAsset{Type: assetType, Name: path.Base(field), Content: field})
+		}
+	}
+	return
+}
+
+func parseAttrViewImportCheckbox(content string) (checked, ok bool) {
+	content = strings.ToLower(strings.TrimSpace(content))
+	for _, s := range attrViewImportCheckboxCheckeds {
+		if content == s {
+			return true, true
+		}
+	}
+	for _, s := range attrViewImportCheckboxUncheckeds {
+		if content == s {
+			return false, true
+		}
+	}
+	return
+}
+
+func parseAttrViewImportNumber(content string) (ret float64, isPercent, ok bool) {
+	content = strings.TrimSpace(content)
+	if isPercent = strings.HasSuffix(content, "%"); isPercent {
+		content = strings.TrimSpace(strings.TrimSuffix(content, "%"))
+	}
+	if attrViewImportThousandsRegexp.MatchString(content) {
+		content = strings.ReplaceAll(content, ",", "")
+	}
+
+	ret, err := strconv.ParseFloat(content, 64)
+	if nil != err || math.IsNaN(ret) || math.IsInf(ret, 0) {
+		return 0, false, false
+	}
+	if isPercent {
+		ret /= 100
+	}
+	ok = true
+	return
+}
+
+// parseAttrViewImportDate 解析日期，支持导出 CSV 时使用的 "开始 → 结束" 格式。
+func parseAttrViewImportDate(content string) (start, end int64, isNotTime, ok bool) {
+	content = strings.TrimSpace(content)
+	if !strings.ContainsAny(content, "0123456789") {
+		return
+	}
+	if _, numErr := strconv.ParseFloat(content, 64); nil == numErr {
+		// 纯数字不作为日期
+		return
+	}
+
+	startStr, endStr, hasEnd := strings.Cut(content, "→")
+	startTime, err := dateparse.ParseIn(strings.TrimSpace(startStr), time.Local)
+	if err != nil {
+		return
+	}
+	start = startTime.UnixMilli()
+	isNotTime = !strings.Contains(startStr, ":")
+	if hasEnd {
+		endTime, endErr := dateparse.ParseIn(strings.TrimSpace(endStr), time.Local)
+		if nil != endErr {
+			return
+		}
+		end = endTime.UnixMilli()
+	}
+	ok = true
+	return
+}
+
+func isAttrViewImportURL(content string) bool {
+	u, err := url.Parse(strings.TrimSpace(content))
+	if err != nil {
+		return false
+	}
+	return ("http" == u.Scheme || "https" == u.Scheme || "ftp" == u.Scheme) && "" != u.Host
+}
+
+func splitAttrViewImportOptions(content string) (ret []string) {
+	for _, opt := range strings.FieldsFunc(content, func(r rune) bool { return strings.ContainsRune(attrViewImportMSelectSeps, r) }) {
+		if opt = strings.TrimSpace(opt); "" != opt && !gulu.Str.Contains(opt, ret) {
+			ret = append(ret, opt)
+		}
+	}
+	return
+}
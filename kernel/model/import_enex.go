@@ -0,0 +1,245 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"gopkg.in/yaml.v3"
+)
+
+// enexExport 描述了 Evernote 导出的 .enex 文件的结构。
+type enexExport struct {
+	Notes []*enexNote `xml:"note"`
+}
+
+type enexNote struct {
+	Title     string          `xml:"title"`
+	Content   string          `xml:"content"`
+	Created   string          `xml:"created"`
+	Updated   string          `xml:"updated"`
+	Tags      []string        `xml:"tag"`
+	Resources []*enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+// importFrontMatter 描述了导入时生成的 YAML Front Matter，由 normalizeTree 解析为文档属性。
+type importFrontMatter struct {
+	Title   string   `yaml:"title"`
+	Date    string   `yaml:"date,omitempty"`
+	Lastmod string   `yaml:"lastmod,omitempty"`
+	Tags    []string `yaml:"tags,omitempty"`
+}
+
+// ImportENEX 导入 Evernote 导出的 .enex 文件，每篇笔记导入为一个文档。
+//
+// 笔记中的资源文件导入为资源文件，笔记标签导入为文档标签，笔记的创建和更新时间保留在文档属性中。
+func ImportENEX(boxID, enexPath, toPath string) (report *ImportReport, err error) {
+	data, err := os.ReadFile(enexPath)
+	if err != nil {
+		return
+	}
+
+	export := &enexExport{}
+	if err = xml.Unmarshal(data, export); err != nil {
+		logging.LogErrorf("parse ENEX [%s] failed: %s", enexPath, err)
+		return
+	}
+	if 1 > len(export.Notes) {
+		err = errors.New("no notes found")
+		return
+	}
+
+	tmpDir := filepath.Join(util.TempDir, "import", "enex-"+ast.NewNodeID())
+	defer os.RemoveAll(tmpDir)
+	localPath := filepath.Join(tmpDir, util.FilterFileName(strings.TrimSuffix(filepath.Base(enexPath), filepath.Ext(enexPath))))
+
+	if err = os.MkdirAll(localPath, 0755); err != nil {
+		return
+	}
+
+	usedNames := map[string]bool{}
+	for _, note := range export.Notes {
+		markdown, convertErr := convertENEXNote(note, localPath)
+		if nil != convertErr {
+			logging.LogErrorf("convert ENEX note [%s] failed: %s", note.Title, convertErr)
+			continue
+		}
+
+		name := uniqueImportFileName(note.Title, ".md", usedNames)
+		if err = filelock.WriteFile(filepath.Join(localPath, name), []byte(markdown)); err != nil {
+			return
+		}
+	}
+
+	hooks := &localImportHooks{treeParsed: func(tree *parse.Tree, localPath string) { removeFrontMatterCodeBlock(tree) }}
+	report, _, err = importFromLocalPath(boxID, localPath, toPath, hooks)
+	return
+}
+
+var (
+	enexNoteRegexp      = regexp.MustCompile(`(?s)<en-note[^>]*>(.*)</en-note>`)
+	enexMediaRegexp     = regexp.MustCompile(`(?s)<en-media([^>]*?)/?>(\s*</en-media>)?`)
+	enexHashRegexp      = regexp.MustCompile(`hash="([0-9a-fA-F]+)"`)
+	enexTodoLineRegexp  = regexp.MustCompile(`(?s)<div>\s*<en-todo([^>]*?)/?>(\s*</en-todo>)?(.*?)</div>`)
+	enexTodoRegexp      = regexp.MustCompile(`(?s)<en-todo([^>]*?)/?>(\s*</en-todo>)?`)
+	enexCheckedRegexp   = regexp.MustCompile(`checked="true"`)
+	enexEncryptedRegexp = regexp.MustCompile(`(?s)<en-crypt[^>]*>.*?</en-crypt>`)
+)
+
+// convertENEXNote 将笔记的 ENML 转换为 Markdown，资源文件解码后保存到 localPath 下的 resources 文件夹中。
+func convertENEXNote(note *enexNote, localPath string) (ret string, err error) {
+	resources := map[string]string{} // 资源 MD5 到相对路径的映射
+	for _, resource := range note.Resources {
+		data, decodeErr := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resource.Data), ""))
+		if nil != decodeErr {
+			logging.LogWarnf("decode ENEX resource [%s] failed: %s", resource.FileName, decodeErr)
+			continue
+		}
+
+		sum := md5.Sum(data)
+		hash := hex.EncodeToString(sum[:])
+		ext := filepath.Ext(resource.FileName)
+		if "" == ext {
+			if exts, _ := mime.ExtensionsByType(resource.Mime); 0 < len(exts) {
+				ext = exts[0]
+			}
+		}
+		name := strings.TrimSuffix(util.FilterUploadFileName(resource.FileName), ext)
+		if "" == name {
+			name = hash
+		}
+		name = strings.ReplaceAll(name, " ", "_") + ext
+		rel := "resources/" + hash + "/" + name
+		resourcePath := filepath.Join(localPath, filepath.FromSlash(rel))
+		if err = os.MkdirAll(filepath.Dir(resourcePath), 0755); err != nil {
+			return
+		}
+		if err = filelock.WriteFile(resourcePath, data); err != nil {
+			return
+		}
+		resources[hash] = rel
+	}
+
+	enml := note.Content
+	if matches := enexNoteRegexp.FindStringSubmatch(enml); nil != matches {
+		enml = matches[1]
+	}
+	enml = enexEncryptedRegexp.ReplaceAllString(enml, "")
+	enml = enexMediaRegexp.ReplaceAllStringFunc(enml, func(media string) string {
+		matches := enexHashRegexp.FindStringSubmatch(media)
+		if nil == matches {
+			return ""
+		}
+
+		rel := resources[strings.ToLower(matches[1])]
+		if "" == rel {
+			return ""
+		}
+		if strings.Contains(media, `type="image/`) {
+			return `<img src="` + rel + `">`
+		}
+		return `<a href="` + rel + `">` + filepath.Base(rel) + `</a>`
+	})
+	// 单独一行的待办转换为任务列表
+	enml = enexTodoLineRegexp.ReplaceAllStringFunc(enml, func(todo string) string {
+		matches := enexTodoLineRegexp.FindStringSubmatch(todo)
+		checkbox := `<input type="checkbox">`
+		if enexCheckedRegexp.MatchString(matches[1]) {
+			checkbox = `<input type="checkbox" checked="">`
+		}
+		return "<ul><li>" + checkbox + " " + matches[3] + "</li></ul>"
+	})
+	enml = enexTodoRegexp.ReplaceAllStringFunc(enml, func(todo string) string {
+		if enexCheckedRegexp.MatchString(todo) {
+			return "☑ "
+		}
+		return "☐ "
+	})
+
+	markdown, _, err := HTML2Markdown(enml, util.NewStdLute())
+	if err != nil {
+		return
+	}
+
+	frontMatter := &importFrontMatter{Title: note.Title, Tags: note.Tags}
+	if created, parseErr := time.Parse("20060102T150405Z", note.Created); nil == parseErr {
+		frontMatter.Date = created.Local().Format(time.RFC3339)
+	}
+	if updated, parseErr := time.Parse("20060102T150405Z", note.Updated); nil == parseErr {
+		frontMatter.Lastmod = updated.Local().Format(time.RFC3339)
+	}
+	ret, err = newImportMarkdown(frontMatter, markdown)
+	return
+}
+
+// newImportMarkdown 生成带有 YAML Front Matter 的 Markdown。
+func newImportMarkdown(frontMatter *importFrontMatter, markdown string) (ret string, err error) {
+	yfm, err := yaml.Marshal(frontMatter)
+	if err != nil {
+		return
+	}
+	ret = "---\n" + string(yfm) + "---\n\n" + markdown
+	return
+}
+
+// uniqueImportFileName 根据标题生成不重复的文件名。
+func uniqueImportFileName(title, ext string, usedNames map[string]bool) (ret string) {
+	base := util.FilterFileName(title)
+	if "" == base {
+		base = "Untitled"
+	}
+	ret = base + ext
+	for i := 2; usedNames[strings.ToLower(ret)]; i++ {
+		ret = base + " (" + strconv.Itoa(i) + ")" + ext
+	}
+	usedNames[strings.ToLower(ret)] = true
+	return
+}
+
+// removeFrontMatterCodeBlock 移除 normalizeTree 根据 YAML Front Matter 生成的代码块，导入时生成的 YAML Front Matter 已经转换为文档属性。
+func removeFrontMatterCodeBlock(tree *parse.Tree) {
+	codeBlock := tree.Root.FirstChild
+	if nil == codeBlock || ast.NodeCodeBlock != codeBlock.Type {
+		return
+	}
+	if info := codeBlock.ChildByType(ast.NodeCodeBlockFenceInfoMarker); nil == info || "yaml" != string(info.CodeBlockInfo) {
+		return
+	}
+	codeBlock.Unlink()
+}
@@ -0,0 +1,161 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"html"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ImportHTMLZip 导入 HTML 文件压缩包，每个 HTML 文件导入为一个文档，HTML 文件之间的相对链接转换为块引用。
+func ImportHTMLZip(boxID, zipPath, toPath string) (report *ImportReport, err error) {
+	tmpDir := filepath.Join(util.TempDir, "import", "html-"+ast.NewNodeID())
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, util.FilterFileName(strings.TrimSuffix(filepath.Base(zipPath), filepath.Ext(zipPath))))
+	if err = gulu.Zip.Unzip(zipPath, localPath); err != nil {
+		logging.LogErrorf("unzip HTML archive [%s] failed: %s", zipPath, err)
+		return
+	}
+
+	var htmlPaths, unusedPaths []string
+	err = filepath.WalkDir(localPath, func(currentPath string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(d.Name())) {
+		case ".html", ".htm":
+			htmlPaths = append(htmlPaths, currentPath)
+		case ".css", ".js":
+			// 样式和脚本文件不作为资源文件导入
+			unusedPaths = append(unusedPaths, currentPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	titles := map[string]string{}
+	for _, htmlPath := range htmlPaths {
+		data, readErr := os.ReadFile(htmlPath)
+		if nil != readErr {
+			err = readErr
+			return
+		}
+		titles[htmlPath] = htmlArchiveTitle(string(data), strings.TrimSuffix(filepath.Base(htmlPath), filepath.Ext(htmlPath)))
+	}
+
+	for _, htmlPath := range htmlPaths {
+		data, readErr := os.ReadFile(htmlPath)
+		if nil != readErr {
+			err = readErr
+			return
+		}
+
+		markdown, convertErr := convertHTMLArchivePage(string(data), htmlPath, titles)
+		if nil != convertErr {
+			logging.LogErrorf("convert HTML [%s] failed: %s", htmlPath, convertErr)
+			continue
+		}
+
+		mdPath := strings.TrimSuffix(htmlPath, filepath.Ext(htmlPath)) + ".md"
+		if err = filelock.WriteFile(mdPath, []byte(markdown)); err != nil {
+			return
+		}
+		unusedPaths = append(unusedPaths, htmlPath)
+	}
+	for _, unusedPath := range unusedPaths {
+		os.Remove(unusedPath)
+	}
+
+	hooks := &localImportHooks{treeParsed: func(tree *parse.Tree, localPath string) { removeFrontMatterCodeBlock(tree) }}
+	report, _, err = importFromLocalPath(boxID, localPath, toPath, hooks)
+	return
+}
+
+var (
+	htmlArchiveTitleRegexp = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlArchiveBodyRegexp  = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	htmlArchiveLinkRegexp  = regexp.MustCompile(`(?i)(href|src)\s*=\s*"([^"]*)"`)
+)
+
+func htmlArchiveTitle(htmlStr, defaultTitle string) string {
+	if matches := htmlArchiveTitleRegexp.FindStringSubmatch(htmlStr); nil != matches {
+		if title := strings.TrimSpace(html.UnescapeString(matches[1])); "" != title {
+			return title
+		}
+	}
+	return defaultTitle
+}
+
+// convertHTMLArchivePage 将 HTML 页面转换为 Markdown，titles 为 HTML 文件路径到页面标题的映射。
+//
+// 指向其他 HTML 页面的相对链接改为使用标题的文档路径，后续在 convertMdHyperlinks2WikiLinks 中转换为块引用。
+func convertHTMLArchivePage(htmlStr, htmlPath string, titles map[string]string) (ret string, err error) {
+	if matches := htmlArchiveBodyRegexp.FindStringSubmatch(htmlStr); nil != matches {
+		htmlStr = matches[1]
+	}
+
+	dir := filepath.Dir(htmlPath)
+	htmlStr = htmlArchiveLinkRegexp.ReplaceAllStringFunc(htmlStr, func(attr string) string {
+		matches := htmlArchiveLinkRegexp.FindStringSubmatch(attr)
+		dest := html.UnescapeString(matches[2])
+		if !util.IsRelativePath(dest) || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "data:") {
+			return attr
+		}
+
+		if idx := strings.IndexAny(dest, "?#"); 0 <= idx {
+			dest = dest[:idx]
+		}
+		if unescaped, unescapeErr := url.PathUnescape(dest); nil == unescapeErr {
+			dest = unescaped
+		}
+
+		if title, ok := titles[filepath.Join(dir, filepath.FromSlash(dest))]; ok {
+			// 文档路径使用标题，标题中可能包含空格和 /，这里进行转义，convertMdHyperlinks2WikiLinks 中会反转义
+			dest = path.Join(path.Dir(dest), url.PathEscape(title)) + ".md"
+		} else if !strings.EqualFold("src", matches[1]) {
+			return attr
+		}
+		return matches[1] + `="` + html.EscapeString(dest) + `"`
+	})
+
+	markdown, _, err := HTML2Markdown(htmlStr, util.NewStdLute())
+	if err != nil {
+		return
+	}
+	ret, err = newImportMarkdown(&importFrontMatter{Title: titles[htmlPath]}, markdown)
+	return
+}
@@ -0,0 +1,351 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ImportNotionZip 导入 Notion 导出的 Markdown & CSV 压缩包。
+//
+// 文件名中的 Notion ID 会被去掉，页面之间的相对链接转换为块引用，数据库（CSV 和行页面）导入为数据库，行绑定到对应的行页面文档。
+func ImportNotionZip(boxID, zipPath, toPath string) (report *ImportReport, err error) {
+	tmpDir := filepath.Join(util.TempDir, "import", "notion-"+ast.NewNodeID())
+	defer os.RemoveAll(tmpDir)
+
+	unzipPath := filepath.Join(tmpDir, "unzip")
+	if err = gulu.Zip.Unzip(zipPath, unzipPath); err != nil {
+		logging.LogErrorf("unzip Notion export [%s] failed: %s", zipPath, err)
+		return
+	}
+
+	// 导出内容较多时 Notion 会将多个分卷压缩包再打包一次
+	entries, err := os.ReadDir(unzipPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || ".zip" != strings.ToLower(filepath.Ext(entry.Name())) {
+			continue
+		}
+
+		partPath := filepath.Join(unzipPath, entry.Name())
+		if err = gulu.Zip.Unzip(partPath, unzipPath); err != nil {
+			logging.LogErrorf("unzip Notion export part [%s] failed: %s", partPath, err)
+			return
+		}
+		os.Remove(partPath)
+	}
+
+	name, _ := cleanNotionName(strings.TrimSuffix(filepath.Base(zipPath), filepath.Ext(zipPath)))
+	localPath := filepath.Join(tmpDir, util.FilterFileName(name))
+	export, err := copyNotionExport(unzipPath, localPath)
+	if err != nil {
+		return
+	}
+
+	report, docIDs, err := importFromLocalPath(boxID, localPath, toPath, nil)
+	if err != nil {
+		return
+	}
+
+	for _, db := range export.databases {
+		rows, readErr := readAttrViewImportRows(db.csvPath)
+		if nil != readErr {
+			logging.LogErrorf("read Notion database [%s] failed: %s", db.csvPath, readErr)
+			continue
+		}
+		if 2 > len(rows) {
+			continue
+		}
+
+		dirPath := filepath.Join(localPath, db.dirRel)
+		hostID := docIDs[dirPath]
+		if "" == hostID {
+			hostID = docIDs[dirPath+".md"]
+		}
+		if "" == hostID {
+			hostID = docIDs[filepath.Dir(dirPath)]
+		}
+		if "" == hostID {
+			if hostID, err = createNotionDatabaseDoc(boxID, toPath, db.name); err != nil {
+				return
+			}
+		}
+
+		// 第一列是页面标题，按标题绑定行页面
+		rowPages := map[string][]string{}
+		for _, rowPage := range db.rowPages {
+			if id := docIDs[rowPage.path]; "" != id {
+				rowPages[rowPage.title] = append(rowPages[rowPage.title], id)
+			}
+		}
+		var rowBlockIDs []string
+		for _, record := range rows[1:] {
+			var rowBlockID string
+			if 0 < len(record) {
+				title := strings.TrimSpace(record[0])
+				if ids := rowPages[title]; 0 < len(ids) {
+					rowBlockID, rowPages[title] = ids[0], ids[1:]
+				}
+			}
+			rowBlockIDs = append(rowBlockIDs, rowBlockID)
+
+			for i := range record {
+				record[i] = strings.TrimSpace(notionRelationLinkRegexp.ReplaceAllString(record[i], ""))
+			}
+		}
+
+		avID, importErr := importAttributeViewRows(rows, db.name, "", hostID, map[string]string{}, "", "", rowBlockIDs)
+		if nil != importErr {
+			logging.LogErrorf("import Notion database [%s] failed: %s", db.csvPath, importErr)
+			continue
+		}
+		report.AttributeViews = append(report.AttributeViews, avID)
+	}
+	return
+}
+
+var (
+	notionIDRegexp           = regexp.MustCompile(`\s([0-9a-f]{32})(_all)?$`)
+	notionLinkRegexp         = regexp.MustCompile(`\]\(([^)\s]+)\)`)
+	notionRelationLinkRegexp = regexp.MustCompile(`\s*\([^()]*\.(md|csv)\)`)
+)
+
+// cleanNotionName 去掉 Notion 文件名中的 ID，返回去掉 ID 后的名称和 ID。
+func cleanNotionName(name string) (ret, id string) {
+	ret = name
+	if matches := notionIDRegexp.FindStringSubmatchIndex(name); nil != matches {
+		ret, id = strings.TrimSpace(name[:matches[0]]), name[matches[2]:matches[3]]
+	}
+	return
+}
+
+type notionExport struct {
+	paths     map[string]string // Notion 导出文件的相对路径到导入路径的映射
+	databases []*notionDatabase
+}
+
+type notionDatabase struct {
+	name     string
+	csvPath  string
+	dirRel   string // 行页面所在文件夹的导入路径
+	rowPages []*notionRowPage
+}
+
+type notionRowPage struct {
+	title string
+	path  string
+}
+
+// copyNotionExport 将 Notion 导出的文件复制到 destDir，去掉文件名中的 ID 并改写页面中的链接。
+func copyNotionExport(srcDir, destDir string) (ret *notionExport, err error) {
+	ret = &notionExport{paths: map[string]string{}}
+	stems := map[string]string{} // Notion ID 到去掉 ID 后名称的映射，页面文件和同名文件夹使用相同的名称
+	dirs := map[string]string{}  // Notion ID 到文件夹导入路径的映射
+	used := map[string]bool{}    // 已经使用的导入路径
+	databases := map[string]*notionDatabase{}
+	var mdPaths []string
+	err = filepath.WalkDir(srcDir, func(currentPath string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr {
+			return walkErr
+		}
+		if srcDir == currentPath {
+			return nil
+		}
+
+		rel := filepath.ToSlash(strings.TrimPrefix(currentPath, srcDir+string(os.PathSeparator)))
+		ext := ""
+		if !d.IsDir() {
+			ext = filepath.Ext(d.Name())
+		}
+		stem, id := cleanNotionName(strings.TrimSuffix(d.Name(), ext))
+		if ".csv" == strings.ToLower(ext) && "" != id {
+			db := databases[id]
+			if nil == db {
+				db = &notionDatabase{name: stem, dirRel: path.Join(ret.paths[path.Dir(rel)], util.FilterFileName(stem))}
+				databases[id] = db
+				ret.databases = append(ret.databases, db)
+			}
+			// 优先使用包含所有行的 _all.csv
+			if "" == db.csvPath || strings.HasSuffix(strings.TrimSuffix(d.Name(), ext), "_all") {
+				db.csvPath = currentPath
+			}
+			return nil
+		}
+
+		if existStem, ok := stems[id]; ok && "" != id {
+			stem = existStem
+		} else {
+			stem = util.FilterFileName(stem)
+			if "" == stem {
+				stem = "Untitled"
+			}
+			dir := ret.paths[path.Dir(rel)]
+			base := stem
+			for i := 2; used[path.Join(dir, stem+ext)]; i++ {
+				stem = base + " (" + strconv.Itoa(i) + ")"
+			}
+			if "" != id {
+				stems[id] = stem
+			}
+		}
+		destRel := path.Join(ret.paths[path.Dir(rel)], stem+ext)
+		used[destRel] = true
+		ret.paths[rel] = destRel
+
+		if d.IsDir() {
+			if "" != id {
+				dirs[id] = destRel
+			}
+			return os.MkdirAll(filepath.Join(destDir, destRel), 0755)
+		}
+		if ".md" == strings.ToLower(ext) {
+			mdPaths = append(mdPaths, rel)
+			return nil
+		}
+		return filelock.Copy(currentPath, filepath.Join(destDir, destRel))
+	})
+	if err != nil {
+		return
+	}
+
+	// 数据库的行页面位于和 CSV 同名的文件夹中
+	for id, db := range databases {
+		if dirRel := dirs[id]; "" != dirRel {
+			db.dirRel = dirRel
+		}
+	}
+
+	for _, rel := range mdPaths {
+		data, readErr := os.ReadFile(filepath.Join(srcDir, rel))
+		if nil != readErr {
+			err = readErr
+			return
+		}
+
+		destPath := filepath.Join(destDir, ret.paths[rel])
+		var db *notionDatabase
+		for _, database := range databases {
+			if path.Dir(ret.paths[rel]) == database.dirRel {
+				db = database
+				break
+			}
+		}
+
+		title, _ := cleanNotionName(strings.TrimSuffix(path.Base(rel), path.Ext(rel)))
+		if nil != db {
+			db.rowPages = append(db.rowPages, &notionRowPage{title: title, path: destPath})
+		}
+
+		content := convertNotionMarkdown(string(data), rel, nil != db, ret.paths, databases)
+		if err = filelock.WriteFile(destPath, []byte(content)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// convertNotionMarkdown 去掉页面开头的标题（数据库行页面还要去掉标题下的属性列表），并将链接改写为导入后的相对路径。
+func convertNotionMarkdown(content, rel string, isRowPage bool, paths map[string]string, databases map[string]*notionDatabase) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if 0 < len(lines) && strings.HasPrefix(lines[0], "# ") {
+		lines = lines[1:]
+		for 0 < len(lines) && "" == strings.TrimSpace(lines[0]) {
+			lines = lines[1:]
+		}
+		if isRowPage {
+			for 0 < len(lines) && "" != strings.TrimSpace(lines[0]) && strings.Contains(lines[0], ": ") {
+				lines = lines[1:]
+			}
+			for 0 < len(lines) && "" == strings.TrimSpace(lines[0]) {
+				lines = lines[1:]
+			}
+		}
+	}
+	content = strings.Join(lines, "\n")
+
+	destDir := path.Dir(paths[rel])
+	return notionLinkRegexp.ReplaceAllStringFunc(content, func(link string) string {
+		dest := link[2 : len(link)-1]
+		if strings.Contains(dest, "://") || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "mailto:") {
+			return link
+		}
+
+		decoded, unescapeErr := url.PathUnescape(dest)
+		if nil != unescapeErr {
+			return link
+		}
+
+		target := path.Clean(path.Join(path.Dir(rel), decoded))
+		destTarget, ok := paths[target]
+		if !ok && ".csv" == strings.ToLower(path.Ext(target)) {
+			// 链接到数据库时改为链接到数据库所在的文档
+			_, id := cleanNotionName(strings.TrimSuffix(path.Base(target), path.Ext(target)))
+			if db := databases[id]; nil != db {
+				destTarget, ok = db.dirRel+".md", true
+			}
+		}
+		if !ok {
+			return link
+		}
+
+		relTarget, relErr := filepath.Rel(destDir, destTarget)
+		if nil != relErr {
+			return link
+		}
+		return "](<" + filepath.ToSlash(relTarget) + ">)"
+	})
+}
+
+func createNotionDatabaseDoc(boxID, toPath, name string) (id string, err error) {
+	hPath := "/"
+	if "/" != toPath {
+		block := treenode.GetBlockTreeRootByPath(boxID, toPath)
+		if nil == block {
+			err = ErrBlockNotFound
+			return
+		}
+		hPath = block.HPath
+	}
+
+	createDocLock.Lock()
+	defer createDocLock.Unlock()
+	id, err = createDocsByHPath(boxID, path.Join(hPath, strings.ReplaceAll(name, "/", "")), "", "", "")
+	if err != nil {
+		return
+	}
+	if nil == treenode.GetBlockTree(id) {
+		err = errors.New("create Notion database doc failed")
+	}
+	return
+}
@@ -0,0 +1,371 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/lex"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"gopkg.in/yaml.v3"
+)
+
+// ImportObsidianVault 导入 Obsidian 库。
+//
+// propsAsAv 为 true 时将带有 YAML Front Matter 属性的文档作为行导入到一个新建的数据库中，否则仅导入为文档属性。
+func ImportObsidianVault(boxID, vaultPath, toPath string, propsAsAv bool) (report *ImportReport, err error) {
+	if !gulu.File.IsDir(vaultPath) {
+		err = errors.New("not found Obsidian vault")
+		return
+	}
+
+	var propDocs []*obsidianPropDoc
+	hooks := &localImportHooks{
+		parseMd: parseObsidianMd,
+		treeParsed: func(tree *parse.Tree, localPath string) {
+			if propDoc := convertObsidianTree(tree); nil != propDoc && propsAsAv {
+				propDocs = append(propDocs, propDoc)
+			}
+		},
+		beforeLinks: convertObsidianEmbeds,
+	}
+
+	report, docIDs, err := importFromLocalPath(boxID, vaultPath, toPath, hooks)
+	if err != nil || 1 > len(propDocs) {
+		return
+	}
+
+	// 在库的根文档中插入数据库，每个带有属性的文档作为一行
+	rootID := docIDs[vaultPath]
+	if "" == rootID {
+		return
+	}
+
+	header := []string{Conf.language(16)}
+	keyIndexes := map[string]int{}
+	for _, propDoc := range propDocs {
+		for _, k := range propDoc.keys {
+			if _, ok := keyIndexes[k]; !ok {
+				keyIndexes[k] = len(header)
+				header = append(header, k)
+			}
+		}
+	}
+
+	rows := [][]string{header}
+	var rowBlockIDs []string
+	for _, propDoc := range propDocs {
+		row := make([]string, len(header))
+		row[0] = propDoc.title
+		for k, v := range propDoc.props {
+			row[keyIndexes[k]] = v
+		}
+		rows = append(rows, row)
+		rowBlockIDs = append(rowBlockIDs, propDoc.id)
+	}
+
+	avID, err := importAttributeViewRows(rows, filepath.Base(vaultPath), "", rootID, map[string]string{}, "", "", rowBlockIDs)
+	if err != nil {
+		logging.LogErrorf("import Obsidian properties as attribute view failed: %s", err)
+		return
+	}
+	report.AttributeViews = append(report.AttributeViews, avID)
+	return
+}
+
+// obsidianPropDoc 描述了带有 YAML Front Matter 属性的文档。
+type obsidianPropDoc struct {
+	id    string
+	title string
+	keys  []string
+	props map[string]string
+}
+
+func parseObsidianMd(markdown []byte) (ret *parse.Tree, yfmRootID, yfmTitle, yfmUpdated string) {
+	luteEngine := util.NewStdLute()
+	luteEngine.SetYamlFrontMatter(true)
+	luteEngine.SetCallout(true) // 解析 > [!note] 提示块
+	return parseStdMdWithLute(luteEngine, markdown)
+}
+
+var (
+	obsidianCommentRegexp     = regexp.MustCompile(`%%.*?%%`)
+	obsidianBlockMarkerRegexp = regexp.MustCompile(`(^|\s)\^([A-Za-z0-9-]+)\s*$`)
+	obsidianEmbedRegexp       = regexp.MustCompile(`!\[\[([^\]]+)\]\]`)
+)
+
+// convertObsidianTree 转换 Obsidian 特有的语法：属性、块标识 ^id、提示块和注释，返回文档属性。
+func convertObsidianTree(tree *parse.Tree) (ret *obsidianPropDoc) {
+	ret = convertObsidianProps(tree)
+
+	var unlinks []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		switch n.Type {
+		case ast.NodeText:
+			n.Tokens = obsidianCommentRegexp.ReplaceAll(n.Tokens, nil)
+		case ast.NodeParagraph:
+			if nil == n.LastChild || ast.NodeText != n.LastChild.Type {
+				return ast.WalkContinue
+			}
+
+			matches := obsidianBlockMarkerRegexp.FindSubmatchIndex(n.LastChild.Tokens)
+			if nil == matches {
+				return ast.WalkContinue
+			}
+
+			blockMarker := string(n.LastChild.Tokens[matches[4]:matches[5]])
+			n.LastChild.Tokens = n.LastChild.Tokens[:matches[0]]
+			target := n
+			if nil == n.FirstChild.Next && 1 > len(n.FirstChild.Tokens) && nil != n.Previous {
+				// 单独一行的块标识指向上一个块
+				unlinks = append(unlinks, n)
+				if target = n.Previous; ast.NodeKramdownBlockIAL == target.Type && nil != target.Previous {
+					target = target.Previous
+				}
+			} else if nil != n.Parent && ast.NodeListItem == n.Parent.Type && n.Parent.FirstChild == n {
+				target = n.Parent
+			}
+			searchLinks[tree.HPath+"^"+blockMarker] = target.ID
+		case ast.NodeCallout:
+			convertObsidianCallout(n)
+		}
+		return ast.WalkContinue
+	})
+	for _, n := range unlinks {
+		if nil != n.Next && ast.NodeKramdownBlockIAL == n.Next.Type {
+			n.Next.Unlink()
+		}
+		n.Unlink()
+	}
+	return
+}
+
+// convertObsidianProps 将 YAML Front Matter 转换为文档属性，并移除导入时生成的 YAML 代码块。
+func convertObsidianProps(tree *parse.Tree) (ret *obsidianPropDoc) {
+	codeBlock := tree.Root.FirstChild
+	if nil == codeBlock || ast.NodeCodeBlock != codeBlock.Type {
+		return
+	}
+	info := codeBlock.ChildByType(ast.NodeCodeBlockFenceInfoMarker)
+	code := codeBlock.ChildByType(ast.NodeCodeBlockCode)
+	if nil == info || "yaml" != string(info.CodeBlockInfo) || nil == code || !strings.HasPrefix(code.TokensStr(), "---\n") {
+		return
+	}
+
+	content := strings.TrimSuffix(strings.TrimPrefix(code.TokensStr(), "---\n"), "\n---")
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(content), doc); nil != err || 1 > len(doc.Content) || yaml.MappingNode != doc.Content[0].Kind {
+		return
+	}
+	codeBlock.Unlink()
+
+	ret = &obsidianPropDoc{id: tree.ID, title: tree.Root.IALAttr("title"), props: map[string]string{}}
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		k, valueNode := mapping.Content[i].Value, mapping.Content[i+1]
+		var values []string
+		switch valueNode.Kind {
+		case yaml.ScalarNode:
+			if "" != valueNode.Value {
+				values = append(values, valueNode.Value)
+			}
+		case yaml.SequenceNode:
+			for _, item := range valueNode.Content {
+				if yaml.ScalarNode == item.Kind && "" != item.Value {
+					values = append(values, item.Value)
+				}
+			}
+		}
+
+		tree.Root.RemoveIALAttr("custom-" + k) // 移除 normalizeTree 中按原样生成的属性
+		switch k {
+		case "title", "date", "lastmod", "tags", "cssclasses", "cssclass":
+			// 标题、时间和标签已经在 normalizeTree 中处理
+			continue
+		case "aliases", "alias":
+			if 0 < len(values) {
+				tree.Root.SetIALAttr("alias", strings.Join(values, ","))
+			}
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		ret.keys = append(ret.keys, k)
+		ret.props[k] = value
+
+		attrName := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(k), " ", "-"))
+		validKeyName := "" != attrName
+		for j := 0; j < len(attrName); j++ {
+			if !lex.IsASCIILetterNumHyphen(attrName[j]) {
+				validKeyName = false
+				break
+			}
+		}
+		if validKeyName {
+			tree.Root.SetIALAttr("custom-"+attrName, value)
+		}
+	}
+	if 1 > len(ret.keys) {
+		ret = nil
+	}
+	return
+}
+
+// convertObsidianCallout 将 Obsidian 提示块类型映射为内置提示块类型，quote 和 cite 类型转换为引述块。
+func convertObsidianCallout(n *ast.Node) {
+	typ := strings.ToLower(n.CalloutType)
+	title := strings.TrimSpace(strings.TrimLeft(n.CalloutTitle, "+-")) // 去掉折叠标记 [!note]- 和 [!note]+
+
+	if "quote" == typ || "cite" == typ {
+		n.Type = ast.NodeBlockquote
+		n.CalloutType, n.CalloutTitle, n.CalloutIcon = "", "", ""
+		if "" != title {
+			p := treenode.NewParagraph("")
+			p.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(title)})
+			n.PrependChild(p)
+		}
+		n.PrependChild(&ast.Node{Type: ast.NodeBlockquoteMarker})
+		return
+	}
+
+	var builtInType string
+	switch typ {
+	case "note", "info", "todo", "abstract", "summary", "tldr", "example":
+		builtInType = ast.CalloutTypeNote
+	case "tip", "hint", "success", "check", "done", "question", "help", "faq":
+		builtInType = ast.CalloutTypeTip
+	case "important":
+		builtInType = ast.CalloutTypeImportant
+	case "warning", "attention":
+		builtInType = ast.CalloutTypeWarning
+	case "caution", "danger", "error", "failure", "fail", "missing", "bug":
+		builtInType = ast.CalloutTypeCaution
+	default:
+		n.CalloutTitle = title
+		return
+	}
+
+	if "" == title && strings.ToLower(builtInType) != typ {
+		// Obsidian 在没有标题时使用类型作为标题
+		title = strings.ToUpper(typ[:1]) + typ[1:]
+	}
+	if ast.GetCalloutIcon(n.CalloutType) == n.CalloutIcon || "" == n.CalloutIcon {
+		n.CalloutIcon = ast.GetCalloutIcon(builtInType)
+	}
+	n.CalloutType = builtInType
+	n.CalloutTitle = title
+}
+
+// convertObsidianEmbeds 转换嵌入语法 ![[...]]：单独成段的文档、标题或块嵌入转换为嵌入块，资源文件转换为图片或链接，其他情况转换为块引用。
+func convertObsidianEmbeds(assets map[string]string) {
+	assetNames := map[string]string{}
+	for localPath, name := range assets {
+		assetNames[strings.ToLower(filepath.Base(localPath))] = name
+	}
+
+	for _, tree := range importTrees {
+		var unlinks []*ast.Node
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || ast.NodeText != n.Type || !strings.Contains(n.TokensStr(), "![[") {
+				return ast.WalkContinue
+			}
+
+			text := strings.TrimSpace(n.TokensStr())
+			if p := n.Parent; nil != p && ast.NodeParagraph == p.Type && p.FirstChild == n && nil == n.Next {
+				if matches := obsidianEmbedRegexp.FindStringSubmatch(text); nil != matches && matches[0] == text {
+					target := strings.TrimSpace(strings.Split(matches[1], "|")[0])
+					if _, isAsset := obsidianEmbedAsset(target, assetNames); !isAsset {
+						if id := searchLinkID(obsidianEmbedLink(tree, target)); "" != id {
+							p.InsertBefore(newObsidianEmbedNode(id))
+							unlinks = append(unlinks, p)
+							return ast.WalkContinue
+						}
+					}
+				}
+			}
+
+			text = obsidianEmbedRegexp.ReplaceAllStringFunc(n.TokensStr(), func(embed string) string {
+				inner := embed[3 : len(embed)-2]
+				target := strings.TrimSpace(strings.Split(inner, "|")[0])
+				name, isAsset := obsidianEmbedAsset(target, assetNames)
+				if !isAsset {
+					return "[[" + inner + "]]"
+				}
+				if "" == name {
+					unresolvedLinks = append(unresolvedLinks, &ImportUnresolvedLink{HPath: tree.HPath, Link: target})
+					return path.Base(target)
+				}
+				if gulu.Str.Contains(strings.ToLower(path.Ext(name)), util.SiYuanAssetsImage) {
+					return "![](<assets/" + name + ">)"
+				}
+				return "[" + path.Base(target) + "](<assets/" + name + ">)"
+			})
+			n.Tokens = []byte(text)
+			return ast.WalkContinue
+		})
+
+		for _, p := range unlinks {
+			if nil != p.Next && ast.NodeKramdownBlockIAL == p.Next.Type {
+				p.Next.Unlink()
+			}
+			p.Unlink()
+		}
+	}
+}
+
+// obsidianEmbedAsset 判断嵌入目标是否是资源文件，是的话返回导入后的资源文件名，没有找到时返回空字符串。
+func obsidianEmbedAsset(target string, assetNames map[string]string) (name string, isAsset bool) {
+	ext := strings.ToLower(path.Ext(target))
+	if "" == ext || ".md" == ext || ".markdown" == ext || strings.ContainsAny(target, "#^") {
+		return
+	}
+	return assetNames[strings.ToLower(path.Base(target))], true
+}
+
+// obsidianEmbedLink 返回嵌入目标用于查找的链接。
+func obsidianEmbedLink(tree *parse.Tree, target string) string {
+	link := path.Join(path.Dir(tree.HPath), target)
+	if strings.HasPrefix(target, "#") || strings.HasPrefix(target, "^") {
+		link = tree.HPath + target
+	}
+	return normalizeWikiLinkAnchor(link)
+}
+
+func newObsidianEmbedNode(id string) (ret *ast.Node) {
+	ret = &ast.Node{Type: ast.NodeBlockQueryEmbed, ID: ast.NewNodeID()}
+	ret.SetIALAttr("id", ret.ID)
+	ret.SetIALAttr("updated", util.TimeFromID(ret.ID))
+	ret.AppendChild(&ast.Node{Type: ast.NodeOpenBrace})
+	ret.AppendChild(&ast.Node{Type: ast.NodeOpenBrace})
+	ret.AppendChild(&ast.Node{Type: ast.NodeBlockQueryEmbedScript, Tokens: []byte("SELECT * FROM blocks WHERE id = '" + id + "'")})
+	ret.AppendChild(&ast.Node{Type: ast.NodeCloseBrace})
+	ret.AppendChild(&ast.Node{Type: ast.NodeCloseBrace})
+	return
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
@@ -443,4 +444,28 @@ func subscribeSQLEvents() {
 		Conf.DataIndexState = 0
 		Conf.Save()
 	})
+
+	eventbus.Subscribe(util.EvtAttributeViewSaved, func(avID string) {
+		sql.IndexAttributeViewQueue(avID)
+	})
+}
+
+// indexAttributeViews 重建所有数据库的 SQL 索引。
+func indexAttributeViews() {
+	entries, err := os.ReadDir(filepath.Join(util.DataDir, "storage", "av"))
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || ".json" != filepath.Ext(name) {
+			continue
+		}
+
+		avID := strings.TrimSuffix(name, ".json")
+		if ast.IsNodeIDPattern(avID) {
+			sql.IndexAttributeViewQueue(avID)
+		}
+	}
 }
@@ -0,0 +1,311 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	gcache "github.com/patrickmn/go-cache"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MemberContextKey = "member"
+
+	memberTokenPrefix = "sym_" // 帐号 API token 前缀，便于和全局 API token 区分
+)
+
+var (
+	ErrMemberNotFound      = errors.New("member not found")
+	ErrMemberExists        = errors.New("member already exists")
+	ErrInvalidMemberName   = errors.New("invalid member name")
+	ErrInvalidMemberRole   = errors.New("invalid member role")
+	ErrMemberTokenNotFound = errors.New("member token not found")
+
+	membersLock = sync.Mutex{}
+)
+
+// GetGinContextMember 返回发起请求的帐号名，为空时表示使用访问授权码或者全局 API token 的工作空间所有者。
+func GetGinContextMember(c *gin.Context) string {
+	if member, exists := c.Get(MemberContextKey); exists {
+		return member.(string)
+	}
+	return ""
+}
+
+// GetMember 获取可用的帐号，帐号不存在或者被禁用时返回 nil。
+func GetMember(name string) *conf.Member {
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	member := getMember(name)
+	if nil == member || member.Disabled {
+		return nil
+	}
+	return member
+}
+
+// AuthenticateMember 使用帐号名和密码认证帐号，认证失败时返回 nil。
+func AuthenticateMember(name, password string) *conf.Member {
+	member := GetMember(name)
+	if nil == member {
+		return nil
+	}
+
+	// BasicAuth 每次请求都会携带密码，缓存校验通过的结果以免每个请求都计算 bcrypt
+	// 缓存键包含密码哈希，修改密码后旧密码的缓存自然失效
+	cacheKey := memberAuthCacheKey(name, password, member.Password)
+	if _, ok := memberAuthCache.Get(cacheKey); ok {
+		return member
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(member.Password), []byte(password)); err != nil {
+		return nil
+	}
+	memberAuthCache.SetDefault(cacheKey, true)
+	return member
+}
+
+var memberAuthCache = gcache.New(10*time.Minute, 20*time.Minute)
+
+func memberAuthCacheKey(name, password, hash string) string {
+	sum := sha256.Sum256([]byte(name + "\x00" + password + "\x00" + hash))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetMemberByToken 通过 API token 查找帐号，已吊销的 token 无效。
+func GetMemberByToken(token string) *conf.Member {
+	if !strings.HasPrefix(token, memberTokenPrefix) {
+		return nil
+	}
+
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	digest := []byte(memberTokenDigest(token))
+	for _, member := range Conf.Members.Accounts {
+		if member.Disabled {
+			continue
+		}
+
+		for _, t := range member.Tokens {
+			if !t.Revoked && 1 == subtle.ConstantTimeCompare(digest, []byte(t.Digest)) {
+				return member
+			}
+		}
+	}
+	return nil
+}
+
+// LogMemberChange 记录帐号对工作空间文件的修改，工作空间所有者的修改不记录。
+func LogMemberChange(c *gin.Context, action, target string) {
+	if member := GetGinContextMember(c); "" != member {
+		logging.LogInfof("member [%s] %s [%s]", member, action, target)
+	}
+}
+
+func ListMembers() (ret []*conf.Member) {
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	data, err := gulu.JSON.MarshalJSON(Conf.Members.Accounts)
+	if err != nil {
+		logging.LogErrorf("marshal members failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal members failed: %s", err)
+		return
+	}
+	maskMembers(ret)
+	return
+}
+
+func CreateMember(name, password string, role Role, memo string) (err error) {
+	name = strings.TrimSpace(name)
+	if "" == name || strings.ContainsAny(name, " \t\r\n:/") {
+		return ErrInvalidMemberName
+	}
+	if !isValidMemberRole(role) {
+		return ErrInvalidMemberRole
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logging.LogErrorf("hash member password failed: %s", err)
+		return
+	}
+
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	if nil != getMember(name) {
+		return ErrMemberExists
+	}
+
+	Conf.Members.Accounts = append(Conf.Members.Accounts, &conf.Member{
+		Name:     name,
+		Password: string(hash),
+		Role:     uint(role),
+		Memo:     memo,
+		Tokens:   []*conf.MemberToken{},
+		Created:  time.Now().UnixMilli(),
+	})
+	Conf.Save()
+	logging.LogInfof("created member [%s, role=%d]", name, role)
+	return
+}
+
+// UpdateMember 更新帐号，password 为空时不修改密码。
+func UpdateMember(name, password string, role Role, disabled bool, memo string) (err error) {
+	if !isValidMemberRole(role) {
+		return ErrInvalidMemberRole
+	}
+
+	var hash []byte
+	if "" != password {
+		if hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			logging.LogErrorf("hash member password failed: %s", err)
+			return
+		}
+	}
+
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	member := getMember(name)
+	if nil == member {
+		return ErrMemberNotFound
+	}
+
+	if 0 < len(hash) {
+		member.Password = string(hash)
+	}
+	member.Role = uint(role)
+	member.Disabled = disabled
+	member.Memo = memo
+	Conf.Save()
+	return
+}
+
+func RemoveMember(name string) (err error) {
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	for i, member := range Conf.Members.Accounts {
+		if member.Name == name {
+			Conf.Members.Accounts = append(Conf.Members.Accounts[:i], Conf.Members.Accounts[i+1:]...)
+			Conf.Save()
+			logging.LogInfof("removed member [%s]", name)
+			return
+		}
+	}
+	return ErrMemberNotFound
+}
+
+// CreateMemberToken 为帐号创建 API token，返回的 token 明文不会被保存。
+func CreateMemberToken(name, memo string) (id, token string, err error) {
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	member := getMember(name)
+	if nil == member {
+		err = ErrMemberNotFound
+		return
+	}
+
+	if id, err = util.RandSecret(8); err != nil {
+		logging.LogErrorf("generate member token id failed: %s", err)
+		return
+	}
+	secret, err := util.RandSecret(32)
+	if err != nil {
+		logging.LogErrorf("generate member token failed: %s", err)
+		return
+	}
+	token = memberTokenPrefix + secret
+	member.Tokens = append(member.Tokens, &conf.MemberToken{
+		ID:      id,
+		Memo:    memo,
+		Digest:  memberTokenDigest(token),
+		Created: time.Now().UnixMilli(),
+	})
+	Conf.Save()
+	return
+}
+
+func RevokeMemberToken(name, id string) (err error) {
+	membersLock.Lock()
+	defer membersLock.Unlock()
+
+	member := getMember(name)
+	if nil == member {
+		return ErrMemberNotFound
+	}
+
+	for _, t := range member.Tokens {
+		if t.ID == id {
+			t.Revoked = true
+			Conf.Save()
+			logging.LogInfof("revoked member [%s] token [%s]", name, id)
+			return
+		}
+	}
+	return ErrMemberTokenNotFound
+}
+
+func getMember(name string) *conf.Member {
+	for _, member := range Conf.Members.Accounts {
+		if member.Name == name {
+			return member
+		}
+	}
+	return nil
+}
+
+func isValidMemberRole(role Role) bool {
+	return IsValidRole(role, []Role{
+		RoleAdministrator,
+		RoleEditor,
+		RoleReader,
+	})
+}
+
+func memberTokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func maskMembers(members []*conf.Member) {
+	for _, member := range members {
+		member.Password = ""
+		for _, t := range member.Tokens {
+			t.Digest = ""
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	PublishScopeContextKey = "publishScope"
+
+	PublishShareCookieName = "publish-share-token"
+	PublishShareQueryKey   = "share"
+
+	publishShareTokenPrefix = "sps_" // 分享令牌前缀
+)
+
+var ErrPublishShareNotFound = errors.New("publish share not found")
+
+// PublishScope 描述发布服务帐号或者分享链接可以访问的笔记本和文档，nil 表示不限制。
+type PublishScope struct {
+	boxes []string
+	docs  []*treenode.BlockTree
+}
+
+func newPublishScope(boxes, docIDs []string) (ret *PublishScope) {
+	ret = &PublishScope{boxes: boxes}
+	for _, bt := range treenode.GetBlockTrees(docIDs) {
+		ret.docs = append(ret.docs, bt)
+	}
+	return
+}
+
+// canRead 判断笔记本 boxID 下的路径 p 是否在访问范围内。
+func (scope *PublishScope) canRead(boxID, p string) bool {
+	if nil == scope {
+		return true
+	}
+
+	if gulu.Str.Contains(boxID, scope.boxes) {
+		return true
+	}
+
+	p = aclPath(p)
+	for _, doc := range scope.docs {
+		if doc.BoxID == boxID && isACLSubPath(aclPath(doc.Path), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVisible 判断路径 p 在文档树中是否可见，访问范围内文档的上级路径需要可见才能逐级展开。
+func (scope *PublishScope) isVisible(boxID, p string) bool {
+	if scope.canRead(boxID, p) {
+		return true
+	}
+
+	p = aclPath(p)
+	for _, doc := range scope.docs {
+		if doc.BoxID == boxID && isACLSubPath(p, aclPath(doc.Path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 受访问范围约束时可以调用的接口，这些接口都会按访问范围检查或者过滤读取的数据，其他接口默认拒绝
+var publishScopeAPIs = map[string]bool{
+	"/api/system/bootProgress":     true,
+	"/api/system/version":          true,
+	"/api/system/currentTime":      true,
+	"/api/system/getConf":          true,
+	"/api/system/getEmojiConf":     true,
+	"/api/icon/getDynamicIcon":     true,
+	"/api/storage/getLocalStorage": true,
+	"/api/storage/getRecentDocs":   true,
+
+	"/api/notebook/lsNotebooks":     true,
+	"/api/notebook/getNotebookConf": true,
+	"/api/notebook/getNotebookInfo": true,
+
+	"/api/filetree/searchDocs":       true,
+	"/api/filetree/listDocsByPath":   true,
+	"/api/filetree/getDoc":           true,
+	"/api/filetree/getHPathByPath":   true,
+	"/api/filetree/getHPathsByPaths": true,
+	"/api/filetree/getHPathByID":     true,
+	"/api/filetree/getPathByID":      true,
+	"/api/filetree/getFullHPathByID": true,
+	"/api/filetree/getIDsByHPath":    true,
+
+	"/api/outline/getDocOutline": true,
+	"/api/bookmark/getBookmark":  true,
+	"/api/tag/getTag":            true,
+
+	"/api/lute/spinBlockDOM":  true,
+	"/api/lute/html2BlockDOM": true,
+
+	"/api/query/sql":         true,
+	"/api/query/sqlReadonly": true,
+
+	"/api/search/searchTag":                  true,
+	"/api/search/searchRefBlock":             true,
+	"/api/search/searchEmbedBlock":           true,
+	"/api/search/getEmbedBlock":              true,
+	"/api/search/fullTextSearchBlock":        true,
+	"/api/search/searchAsset":                true,
+	"/api/search/fullTextSearchAssetContent": true,
+	"/api/search/getAssetContent":            true,
+
+	"/api/block/getBlockInfo":                true,
+	"/api/block/getBlockDOM":                 true,
+	"/api/block/getBlockDOMs":                true,
+	"/api/block/getBlockDOMWithEmbed":        true,
+	"/api/block/getBlockDOMsWithEmbed":       true,
+	"/api/block/getBlockKramdown":            true,
+	"/api/block/getBlockKramdowns":           true,
+	"/api/block/getChildBlocks":              true,
+	"/api/block/getTailChildBlocks":          true,
+	"/api/block/getBlockBreadcrumb":          true,
+	"/api/block/getBlockIndex":               true,
+	"/api/block/getBlocksIndexes":            true,
+	"/api/block/getRefIDs":                   true,
+	"/api/block/getRefIDsByFileAnnotationID": true,
+	"/api/block/getBlockDefIDsByRefText":     true,
+	"/api/block/getRefText":                  true,
+	"/api/block/getDOMText":                  true,
+	"/api/block/getTreeStat":                 true,
+	"/api/block/getBlocksWordCount":          true,
+	"/api/block/getContentWordCount":         true,
+	"/api/block/getRecentUpdatedBlocks":      true,
+	"/api/block/getDocInfo":                  true,
+	"/api/block/getDocsInfo":                 true,
+	"/api/block/checkBlockExist":             true,
+	"/api/block/getUnfoldedParentID":         true,
+	"/api/block/checkBlockFold":              true,
+	"/api/block/getHeadingChildrenIDs":       true,
+	"/api/block/getHeadingChildrenDOM":       true,
+	"/api/block/getBlockSiblingID":           true,
+	"/api/block/getBlockRelevantIDs":         true,
+	"/api/block/getBlockTreeInfos":           true,
+	"/api/block/checkBlockRef":               true,
+
+	"/api/ref/getBacklink":       true,
+	"/api/ref/getBacklink2":      true,
+	"/api/ref/getBacklinkDoc":    true,
+	"/api/ref/getBackmentionDoc": true,
+
+	"/api/attr/getBookmarkLabels":  true,
+	"/api/attr/getBlockAttrs":      true,
+	"/api/attr/batchGetBlockAttrs": true,
+
+	"/api/asset/getFileAnnotation": true,
+	"/api/asset/getDocImageAssets": true,
+	"/api/asset/getDocAssets":      true,
+
+	"/api/graph/getGraph":      true,
+	"/api/graph/getLocalGraph": true,
+
+	"/api/av/renderAttributeView":      true,
+	"/api/av/getAttributeViewKeys":     true,
+	"/api/av/getAttributeViewKeysByID": true,
+	"/api/av/getCurrentAttrViewImages": true,
+}
+
+// refuseByPublishScope 拒绝受访问范围约束的请求调用不在 publishScopeAPIs 中的接口，拒绝时返回 true。
+func refuseByPublishScope(c *gin.Context) bool {
+	if nil == GetGinContextPublishScope(c) {
+		return false
+	}
+
+	p := c.Request.URL.Path
+	if !strings.HasPrefix(p, "/api/") || publishScopeAPIs[p] {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": http.StatusText(http.StatusForbidden)})
+	c.Abort()
+	return true
+}
+
+func GetGinContextPublishScope(c *gin.Context) *PublishScope {
+	if scope, exists := c.Get(PublishScopeContextKey); exists {
+		return scope.(*PublishScope)
+	}
+	return nil
+}
+
+// GetClaimPublishScope 根据发布服务令牌获取访问范围，分享失效或者帐号被删除后返回空的访问范围。
+func GetClaimPublishScope(claims jwt.MapClaims) *PublishScope {
+	if shareID, _ := claims[ClaimsKeyPublishShare].(string); "" != shareID {
+		share := getPublishShare(shareID)
+		if nil == share || isPublishShareExpired(share) {
+			return &PublishScope{}
+		}
+		return newPublishScope(nil, []string{share.RootID})
+	}
+
+	username, _ := claims["jti"].(string)
+	if "" == username {
+		return nil // 未启用认证时的匿名访问者
+	}
+
+	for _, account := range Conf.Publish.Auth.Accounts {
+		if account.Username != username {
+			continue
+		}
+
+		if 1 > len(account.Notebooks) && 1 > len(account.Docs) {
+			return nil
+		}
+		return newPublishScope(account.Notebooks, account.Docs)
+	}
+	return &PublishScope{}
+}
+
+// GetPublishShareByToken 获取分享令牌对应的未过期分享。
+func GetPublishShareByToken(token string) *conf.PublishShare {
+	if "" == token {
+		return nil
+	}
+
+	digest := memberTokenDigest(token)
+	for _, share := range Conf.Publish.Shares {
+		if 1 == subtle.ConstantTimeCompare([]byte(share.Digest), []byte(digest)) && !isPublishShareExpired(share) {
+			return share
+		}
+	}
+	return nil
+}
+
+// CreatePublishShare 为文档 rootID 创建匿名只读分享，expired 为过期时间（毫秒），0 表示永不过期。
+func CreatePublishShare(rootID, memo string, expired int64) (share *conf.PublishShare, token string, err error) {
+	bt := treenode.GetBlockTree(rootID)
+	if nil == bt || bt.ID != bt.RootID {
+		err = ErrBlockNotFound
+		return
+	}
+
+	secret, err := util.RandSecret(32)
+	if err != nil {
+		return
+	}
+
+	token = publishShareTokenPrefix + secret
+	share = &conf.PublishShare{
+		ID:      gulu.Rand.String(7),
+		RootID:  rootID,
+		Digest:  memberTokenDigest(token),
+		Memo:    memo,
+		Created: time.Now().UnixMilli(),
+		Expired: expired,
+	}
+	Conf.Publish.Shares = append(Conf.Publish.Shares, share)
+	Conf.Save()
+	return
+}
+
+func RemovePublishShare(id string) (err error) {
+	for i, share := range Conf.Publish.Shares {
+		if share.ID == id {
+			Conf.Publish.Shares = append(Conf.Publish.Shares[:i], Conf.Publish.Shares[i+1:]...)
+			Conf.Save()
+			return
+		}
+	}
+	return ErrPublishShareNotFound
+}
+
+func getPublishShare(id string) *conf.PublishShare {
+	for _, share := range Conf.Publish.Shares {
+		if share.ID == id {
+			return share
+		}
+	}
+	return nil
+}
+
+func isPublishShareExpired(share *conf.PublishShare) bool {
+	return 0 < share.Expired && share.Expired < time.Now().UnixMilli()
+}
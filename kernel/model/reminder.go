@@ -0,0 +1,546 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/araddon/dateparse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/teambition/rrule-go"
+)
+
+// 内核提醒：提醒保存在 data/storage/reminder.json 中，由内核定时检查并推送，不依赖界面和云端服务
+// 提醒的触发状态（下次提醒时间、稍后提醒时间和上次提醒时间）保存在 conf/reminder-state.json 中，
+// 该文件不参与数据同步，每台设备各自维护，避免每次提醒都修改同步数据导致多端冲突
+
+const (
+	reminderMaxUpcoming = 1024 // 列出即将到来的提醒时最多返回的数量
+)
+
+type Reminder struct {
+	BlockID   string `json:"blockID"`
+	Content   string `json:"content"`   // 提醒内容，为空时使用块内容
+	Start     int64  `json:"start"`     // 首次提醒时间
+	RRule     string `json:"rrule"`     // RRULE 重复规则，如 FREQ=WEEKLY;BYDAY=MO,WE，为空时仅提醒一次
+	Next      int64  `json:"next"`      // 下次提醒时间，为 0 时说明提醒已经结束
+	Snoozed   int64  `json:"snoozed"`   // 稍后提醒时间，不为 0 时替代下次提醒时间
+	LastFired int64  `json:"lastFired"` // 上次提醒时间
+	Created   int64  `json:"created"`
+	Updated   int64  `json:"updated"`
+}
+
+// reminderDefinition 为保存在 data/storage/reminder.json 中的提醒定义，参与数据同步。
+type reminderDefinition struct {
+	BlockID string `json:"blockID"`
+	Content string `json:"content"`
+	Start   int64  `json:"start"`
+	RRule   string `json:"rrule"`
+	Created int64  `json:"created"`
+	Updated int64  `json:"updated"`
+}
+
+// reminderState 为提醒在当前设备上的触发状态，保存在 conf/reminder-state.json 中，不参与数据同步。
+type reminderState struct {
+	Next      int64 `json:"next"`
+	Snoozed   int64 `json:"snoozed"`
+	LastFired int64 `json:"lastFired"`
+	Updated   int64 `json:"updated"` // 计算该状态时提醒定义的更新时间，定义在其他设备上修改后需要重新计算
+}
+
+// UpcomingReminder 为提醒的某一次发生。
+type UpcomingReminder struct {
+	BlockID string `json:"blockID"`
+	Box     string `json:"box"`
+	HPath   string `json:"hPath"`
+	Content string `json:"content"`
+	RRule   string `json:"rrule"`
+	Time    int64  `json:"time"`
+	Snoozed bool   `json:"snoozed"`
+}
+
+var reminderLock = sync.Mutex{}
+
+// due 返回提醒下一次需要触发的时间，稍后提醒优先。
+func (reminder *Reminder) due() int64 {
+	if 0 < reminder.Snoozed {
+		return reminder.Snoozed
+	}
+	return reminder.Next
+}
+
+// occurrencesBetween 返回 (after, before] 区间内的提醒时间。
+func (reminder *Reminder) occurrencesBetween(after, before time.Time) (ret []time.Time) {
+	if "" == reminder.RRule {
+		start := time.UnixMilli(reminder.Start)
+		if start.After(after) && !start.After(before) {
+			ret = append(ret, start)
+		}
+		return
+	}
+
+	rule, err := parseReminderRRule(reminder.RRule, time.UnixMilli(reminder.Start))
+	if err != nil {
+		return
+	}
+	return rule.Between(after, before, true)
+}
+
+// nextAfter 返回 t 之后的下一次提醒时间，没有下一次时返回 0。
+func (reminder *Reminder) nextAfter(t time.Time) int64 {
+	if "" == reminder.RRule {
+		if start := time.UnixMilli(reminder.Start); start.After(t) {
+			return start.UnixMilli()
+		}
+		return 0
+	}
+
+	rule, err := parseReminderRRule(reminder.RRule, time.UnixMilli(reminder.Start))
+	if err != nil {
+		return 0
+	}
+	if next := rule.After(t, false); !next.IsZero() {
+		return next.UnixMilli()
+	}
+	return 0
+}
+
+func parseReminderRRule(rule string, start time.Time) (ret *rrule.RRule, err error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	opt, err := rrule.StrToROptionInLocation(rule, time.Local)
+	if err != nil {
+		return
+	}
+	opt.Dtstart = start
+	return rrule.NewRRule(*opt)
+}
+
+// SetReminder 设置块提醒，start 为首次提醒时间，rule 为 RRULE 重复规则。
+func SetReminder(blockID, content, start, rule string) (ret *Reminder, err error) {
+	if nil == treenode.GetBlockTree(blockID) {
+		err = errors.New(fmt.Sprintf(Conf.Language(15), blockID))
+		return
+	}
+
+	startTime, err := dateparse.ParseIn(start, time.Now().Location())
+	if err != nil {
+		return
+	}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if "" != rule {
+		if _, err = parseReminderRRule(rule, startTime); err != nil {
+			err = errors.New("invalid rrule [" + rule + "]: " + err.Error())
+			return
+		}
+	}
+
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders, err := getReminders()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	ret = &Reminder{BlockID: blockID, Created: now.UnixMilli()}
+	for _, reminder := range reminders {
+		if blockID == reminder.BlockID {
+			ret = reminder
+			break
+		}
+	}
+	ret.Content = strings.TrimSpace(content)
+	ret.Start = startTime.UnixMilli()
+	ret.RRule = rule
+	ret.Snoozed = 0
+	ret.Updated = now.UnixMilli()
+	if "" == rule {
+		// 一次性提醒即使已经过期也保留，会在下一次检查时立即提醒
+		ret.Next = ret.Start
+	} else {
+		ret.Next = ret.nextAfter(now.Add(-time.Second))
+	}
+	if ret.Created == ret.Updated {
+		reminders = append(reminders, ret)
+	}
+	err = setReminders(reminders)
+	return
+}
+
+func RemoveReminder(blockID string) (err error) {
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders, err := getReminders()
+	if err != nil {
+		return
+	}
+
+	for i, reminder := range reminders {
+		if blockID == reminder.BlockID {
+			reminders = append(reminders[:i], reminders[i+1:]...)
+			return setReminders(reminders)
+		}
+	}
+	return
+}
+
+// SnoozeReminder 将提醒延后 minutes 分钟，不影响重复规则中的后续提醒。
+func SnoozeReminder(blockID string, minutes int) (ret *Reminder, err error) {
+	if 1 > minutes {
+		err = errors.New("invalid snooze minutes")
+		return
+	}
+
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders, err := getReminders()
+	if err != nil {
+		return
+	}
+
+	for _, reminder := range reminders {
+		if blockID == reminder.BlockID {
+			// 稍后提醒只修改当前设备的触发状态，不修改参与同步的提醒定义
+			reminder.Snoozed = time.Now().Add(time.Duration(minutes) * time.Minute).UnixMilli()
+			ret = reminder
+			err = setReminderStates(reminders)
+			return
+		}
+	}
+	err = errors.New("not found reminder of block [" + blockID + "]")
+	return
+}
+
+func GetReminder(blockID string) (ret *Reminder) {
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders, _ := getReminders()
+	for _, reminder := range reminders {
+		if blockID == reminder.BlockID {
+			return reminder
+		}
+	}
+	return
+}
+
+// GetUpcomingReminders 列出接下来 days 天内的提醒，重复提醒会展开为多次。
+func GetUpcomingReminders(days int) (ret []*UpcomingReminder) {
+	ret = []*UpcomingReminder{}
+	if 1 > days {
+		days = 7
+	}
+
+	reminderLock.Lock()
+	reminders, _ := getReminders()
+	reminderLock.Unlock()
+
+	now := time.Now()
+	until := now.AddDate(0, 0, days)
+	for _, reminder := range reminders {
+		var times []time.Time
+		if 0 < reminder.Snoozed {
+			times = append(times, time.UnixMilli(reminder.Snoozed))
+		}
+		if 0 < reminder.Next {
+			// 已经过期但是还没有触发的提醒也需要列出
+			after := now
+			if next := time.UnixMilli(reminder.Next); !next.After(now) && 0 == reminder.Snoozed {
+				after = next.Add(-time.Millisecond)
+			}
+			times = append(times, reminder.occurrencesBetween(after, until)...)
+		}
+
+		var box, hPath string
+		if bt := treenode.GetBlockTree(reminder.BlockID); nil != bt {
+			box, hPath = bt.BoxID, bt.HPath
+		}
+		for i, t := range times {
+			ret = append(ret, &UpcomingReminder{
+				BlockID: reminder.BlockID,
+				Box:     box,
+				HPath:   hPath,
+				Content: reminder.Content,
+				RRule:   reminder.RRule,
+				Time:    t.UnixMilli(),
+				Snoozed: 0 == i && 0 < reminder.Snoozed,
+			})
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Time < ret[j].Time })
+	if reminderMaxUpcoming < len(ret) {
+		ret = ret[:reminderMaxUpcoming]
+	}
+	return
+}
+
+// ReminderJob 检查到期的提醒并推送，错过的多次重复提醒只推送一次。
+func ReminderJob() {
+	if !util.IsBooted() {
+		return
+	}
+
+	reminderLock.Lock()
+	reminders, stateChanged, err := loadReminders()
+	if err != nil {
+		reminderLock.Unlock()
+		return
+	}
+
+	now := time.Now()
+	var fired, remains []*Reminder
+	for _, reminder := range reminders {
+		due := reminder.due()
+		if 1 > due || due > now.UnixMilli() {
+			remains = append(remains, reminder)
+			continue
+		}
+
+		if nil == treenode.GetBlockTree(reminder.BlockID) {
+			// 块已经被删除，移除提醒
+			logging.LogInfof("remove reminder of deleted block [%s]", reminder.BlockID)
+			continue
+		}
+
+		reminder.LastFired = now.UnixMilli()
+		reminder.Snoozed = 0
+		reminder.Next = reminder.nextAfter(now)
+		fired = append(fired, reminder)
+		remains = append(remains, reminder)
+	}
+	if len(remains) != len(reminders) {
+		setReminders(remains)
+	} else if stateChanged || 0 < len(fired) {
+		// 仅触发状态变化时不修改同步数据
+		setReminderStates(remains)
+	}
+	reminderLock.Unlock()
+
+	for _, reminder := range fired {
+		fireReminder(reminder, now)
+	}
+}
+
+// fireReminder 通过 WebSocket 和 Webhook 推送提醒。
+func fireReminder(reminder *Reminder, now time.Time) {
+	content := reminder.Content
+	if "" == content {
+		content = getReminderBlockContent(reminder.BlockID)
+	}
+	logging.LogInfof("fire reminder [%s]", reminder.BlockID)
+
+	util.PushMsg(content, 0)
+	util.BroadcastByType("main", "reminder", 0, content, map[string]interface{}{
+		"blockID": reminder.BlockID,
+		"rrule":   reminder.RRule,
+		"next":    reminder.Next,
+		"time":    now.UnixMilli(),
+	})
+
+	if webhookSubscribed(webhookEventReminderFired) {
+		webhookEvt := newWebhookEventByBlockID(webhookEventReminderFired, reminder.BlockID)
+		webhookEvt.Data["content"] = content
+		webhookEvt.Data["rrule"] = reminder.RRule
+		webhookEvt.Data["next"] = reminder.Next
+		fireWebhookEvent(webhookEvt)
+	}
+}
+
+func getReminderBlockContent(blockID string) (ret string) {
+	tree, _ := LoadTreeByBlockID(blockID)
+	if nil == tree {
+		return blockID
+	}
+
+	node := treenode.GetNodeInTree(tree, blockID)
+	if nil == node {
+		return blockID
+	}
+	if ast.NodeDocument != node.Type && node.IsContainerBlock() {
+		node = treenode.FirstLeafBlock(node)
+	}
+	ret = sql.NodeStaticContent(node, nil, false, false, false)
+	ret = strings.ReplaceAll(ret, editor.Zwsp, "")
+	ret = gulu.Str.SubStr(ret, 128)
+	if "" == ret {
+		ret = tree.Root.IALAttr("title")
+	}
+	return
+}
+
+// getReminderMap 返回以块 ID 为键的提醒。
+func getReminderMap() (ret map[string]*Reminder) {
+	ret = map[string]*Reminder{}
+	reminderLock.Lock()
+	defer reminderLock.Unlock()
+
+	reminders, _ := getReminders()
+	for _, reminder := range reminders {
+		ret[reminder.BlockID] = reminder
+	}
+	return
+}
+
+func setReminders(reminders []*Reminder) (err error) {
+	dirPath := filepath.Join(util.DataDir, "storage")
+	if err = os.MkdirAll(dirPath, 0755); err != nil {
+		logging.LogErrorf("create storage [reminder] dir failed: %s", err)
+		return
+	}
+
+	definitions := []*reminderDefinition{}
+	for _, reminder := range reminders {
+		definitions = append(definitions, &reminderDefinition{
+			BlockID: reminder.BlockID,
+			Content: reminder.Content,
+			Start:   reminder.Start,
+			RRule:   reminder.RRule,
+			Created: reminder.Created,
+			Updated: reminder.Updated,
+		})
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(definitions, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal storage [reminder] failed: %s", err)
+		return
+	}
+
+	lsPath := filepath.Join(dirPath, "reminder.json")
+	err = filelock.WriteFile(lsPath, data)
+	if err != nil {
+		logging.LogErrorf("write storage [reminder] failed: %s", err)
+		return
+	}
+	return setReminderStates(reminders)
+}
+
+func setReminderStates(reminders []*Reminder) (err error) {
+	states := map[string]*reminderState{}
+	for _, reminder := range reminders {
+		states[reminder.BlockID] = &reminderState{
+			Next:      reminder.Next,
+			Snoozed:   reminder.Snoozed,
+			LastFired: reminder.LastFired,
+			Updated:   reminder.Updated,
+		}
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(states, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal reminder state failed: %s", err)
+		return
+	}
+
+	if err = gulu.File.WriteFileSafer(reminderStatePath(), data, 0644); err != nil {
+		logging.LogErrorf("write reminder state failed: %s", err)
+		return
+	}
+	return
+}
+
+func getReminders() (ret []*Reminder, err error) {
+	ret, _, err = loadReminders()
+	return
+}
+
+// loadReminders 加载提醒定义并合并当前设备上的触发状态，stateChanged 说明有提醒的触发状态需要重新计算。
+func loadReminders() (ret []*Reminder, stateChanged bool, err error) {
+	ret = []*Reminder{}
+	dataPath := filepath.Join(util.DataDir, "storage/reminder.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read storage [reminder] failed: %s", err)
+		return
+	}
+
+	var definitions []*reminderDefinition
+	if err = gulu.JSON.UnmarshalJSON(data, &definitions); err != nil {
+		logging.LogErrorf("unmarshal storage [reminder] failed: %s", err)
+		return
+	}
+
+	states := getReminderStates()
+	now := time.Now()
+	for _, definition := range definitions {
+		reminder := &Reminder{
+			BlockID: definition.BlockID,
+			Content: definition.Content,
+			Start:   definition.Start,
+			RRule:   definition.RRule,
+			Created: definition.Created,
+			Updated: definition.Updated,
+		}
+		if state := states[reminder.BlockID]; nil != state && state.Updated == reminder.Updated {
+			reminder.Next = state.Next
+			reminder.Snoozed = state.Snoozed
+			reminder.LastFired = state.LastFired
+		} else {
+			// 提醒在其他设备上创建或修改后同步过来，从当前时间开始计算，已经过期的提醒不再补发
+			reminder.Next = reminder.nextAfter(now)
+			if nil != state {
+				reminder.LastFired = state.LastFired
+			}
+			stateChanged = true
+		}
+		ret = append(ret, reminder)
+	}
+	return
+}
+
+func getReminderStates() (ret map[string]*reminderState) {
+	ret = map[string]*reminderState{}
+	statePath := reminderStatePath()
+	if !gulu.File.IsExist(statePath) {
+		return
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		logging.LogErrorf("read reminder state failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal reminder state failed: %s", err)
+		ret = map[string]*reminderState{}
+	}
+	return
+}
+
+func reminderStatePath() string {
+	return filepath.Join(util.ConfDir, "reminder-state.json")
+}
@@ -54,6 +54,7 @@ import (
 	"github.com/siyuan-note/httpclient"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/task"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -726,9 +727,14 @@ func CheckoutRepo(id string) {
 	task.AppendTask(task.RepoCheckout, checkoutRepo, id)
 }
 
-func checkoutRepo(id string) {
-	var err error
+// CheckoutRepoSync 不经过任务队列直接检出快照，用于需要获取检出结果的命令行模式。
+func CheckoutRepoSync(id string) error {
+	return checkoutRepo(id)
+}
+
+func checkoutRepo(id string) (err error) {
 	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
 		util.PushErrMsg(Conf.Language(26), 7000)
 		return
 	}
@@ -1535,8 +1541,13 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 	//logSyncMergeResult(mergeResult)
 
 	var needReloadFiletree bool
+	var mergedConflicts []string
 	if 0 < len(mergeResult.Conflicts) {
 		luteEngine := util.NewLute()
+
+		// 使用最近一次同步点作为共同祖先对冲突文档进行块级三路合并
+		mergedConflicts = mergeSyncConflicts(mergeResult, mode, luteEngine)
+
 		if Conf.Sync.GenerateConflictDoc {
 			// 云端同步发生冲突时生成副本 https://github.com/siyuan-note/siyuan/issues/5687
 
@@ -1545,6 +1556,11 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 					continue
 				}
 
+				if gulu.Str.Contains(file.Path, mergedConflicts) {
+					// 已经完成块级合并的文档不再生成副本
+					continue
+				}
+
 				parts := strings.Split(file.Path[1:], "/")
 				if 2 > len(parts) {
 					continue
@@ -1609,6 +1625,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 			needReloadOcrTexts = true
 		}
 
+		if strings.HasPrefix(file.Path, "/storage/av/") && strings.HasSuffix(file.Path, ".json") {
+			sql.IndexAttributeViewQueue(strings.TrimSuffix(path.Base(file.Path), ".json"))
+		}
+
 		if strings.HasSuffix(file.Path, "/.siyuan/conf.json") {
 			needReloadFiletree = true
 			boxID := strings.TrimSuffix(strings.TrimPrefix(file.Path, "/"), "/.siyuan/conf.json")
@@ -1646,6 +1666,11 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 		}
 	}
 
+	for _, p := range mergedConflicts {
+		upserts = append(upserts, p)
+		upsertTrees++
+	}
+
 	removeWidgetDirSet, unloadPluginSet, uninstallPluginSet := hashset.New(), hashset.New(), hashset.New()
 	for _, file := range mergeResult.Removes {
 		removes = append(removes, file.Path)
@@ -1657,6 +1682,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 			needReloadOcrTexts = true
 		}
 
+		if strings.HasPrefix(file.Path, "/storage/av/") && strings.HasSuffix(file.Path, ".json") {
+			sql.RemoveAttributeViewQueue(strings.TrimSuffix(path.Base(file.Path), ".json"))
+		}
+
 		if strings.HasSuffix(file.Path, "/.siyuan/conf.json") {
 			needReloadFiletree = true
 			boxID := strings.TrimSuffix(strings.TrimPrefix(file.Path, "/"), "/.siyuan/conf.json")
@@ -1839,6 +1868,7 @@ func indexRepoBeforeCloudSync(repo *dejavu.Repo) (beforeIndex, afterIndex *entit
 	start := time.Now()
 
 	beforeIndex, _ = repo.Latest()
+	recordSyncMergeBase(repo)
 	FlushTxQueue()
 
 	checkChunks := true
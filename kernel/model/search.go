@@ -18,6 +18,7 @@ package model
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -239,7 +240,13 @@ func getEmbedBlock(embedBlockID string, includeIDs []string, headingMode int, br
 	return
 }
 
-func SearchEmbedBlock(embedBlockID, stmt string, excludeIDs []string, headingMode int, breadcrumb bool) (ret []*EmbedBlock) {
+func SearchEmbedBlock(embedBlockID, stmt string, excludeIDs []string, headingMode int, breadcrumb bool, filter *ReadableFilter) (ret []*EmbedBlock) {
+	if nil != filter {
+		// 受限请求的嵌入块查询语句在只读连接上执行
+		sqlBlocks := filter.selectBlocksRawStmt(stmt, 1, Conf.Search.Limit)
+		ret = buildEmbedBlock(embedBlockID, excludeIDs, headingMode, breadcrumb, sqlBlocks)
+		return
+	}
 	return searchEmbedBlock(embedBlockID, stmt, excludeIDs, headingMode, breadcrumb)
 }
 
@@ -522,7 +529,7 @@ func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids
 
 	if 1 > len(ids) {
 		// `Replace All` is no longer affected by pagination https://github.com/siyuan-note/siyuan/issues/8265
-		blocks, _, _, _, _ := FullTextSearchBlock(keyword, boxes, paths, types, method, orderBy, groupBy, 1, math.MaxInt)
+		blocks, _, _, _, _ := FullTextSearchBlock(keyword, boxes, paths, types, method, orderBy, groupBy, 1, math.MaxInt, nil)
 		for _, block := range blocks {
 			ids = append(ids, block.ID)
 		}
@@ -1171,7 +1178,8 @@ func mergeSamePreNext(n *ast.Node) {
 // method：0：关键字，1：查询语法，2：SQL，3：正则表达式
 // orderBy: 0：按块类型（默认），1：按创建时间升序，2：按创建时间降序，3：按更新时间升序，4：按更新时间降序，5：按内容顺序（仅在按文档分组时），6：按相关度升序，7：按相关度降序
 // groupBy：0：不分组，1：按文档分组
-func FullTextSearchBlock(query string, boxes, paths []string, types map[string]bool, method, orderBy, groupBy, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
+// filter：受限请求的读取过滤器，为空时不过滤
+func FullTextSearchBlock(query string, boxes, paths []string, types map[string]bool, method, orderBy, groupBy, page, pageSize int, filter *ReadableFilter) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
 	ret = []*Block{}
 	if "" == query {
 		return
@@ -1188,6 +1196,9 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		}
 		ignoreFilter += buf.String()
 	}
+	// 在查询条件中过滤不可读的文档，这样分页和计数才准确
+	readableFilter := filter.sqlCondition("path")
+	ignoreFilter += readableFilter
 
 	beforeLen := 36
 	var blocks []*Block
@@ -1198,12 +1209,16 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		boxFilter := buildBoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		if ast.IsNodeIDPattern(query) {
-			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", beforeLen, page, pageSize)
+			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'"+readableFilter, beforeLen, page, pageSize)
 		} else {
 			blocks, matchedBlockCount, matchedRootCount = fullTextSearchByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
 		}
 	case 2: // SQL
-		blocks, matchedBlockCount, matchedRootCount = searchBySQL(query, beforeLen, page, pageSize)
+		if nil != filter {
+			blocks, matchedBlockCount, matchedRootCount = searchBySQLReadable(query, filter, beforeLen, page, pageSize)
+		} else {
+			blocks, matchedBlockCount, matchedRootCount = searchBySQL(query, beforeLen, page, pageSize)
+		}
 	case 3: // 正则表达式
 		typeFilter := buildTypeFilter(types)
 		boxFilter := buildBoxesFilter(boxes)
@@ -1214,7 +1229,7 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		boxFilter := buildBoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		if ast.IsNodeIDPattern(query) {
-			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", beforeLen, page, pageSize)
+			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'"+readableFilter, beforeLen, page, pageSize)
 		} else {
 			if 2 > len(strings.Split(strings.TrimSpace(query), " ")) {
 				query = stringQuery(query)
@@ -1498,6 +1513,30 @@ func searchBySQL(stmt string, beforeLen, page, pageSize int) (ret []*Block, matc
 	return
 }
 
+// searchBySQLReadable 在受访问控制约束的只读连接上执行受限请求的 SQL 搜索。
+func searchBySQLReadable(stmt string, filter *ReadableFilter, beforeLen, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount int) {
+	stmt = strings.TrimSpace(stmt)
+	blocks := filter.selectBlocksRawStmt(stmt, page, pageSize)
+	ret = fromSQLBlocks(&blocks, "", beforeLen)
+	if 1 > len(ret) {
+		ret = []*Block{}
+		return
+	}
+
+	countStmt := "SELECT COUNT(id) AS `matches`, COUNT(DISTINCT(root_id)) AS `docs` FROM (" + removeLimitClause(stmt) + ")"
+	result, err := sql.QueryReadonly(context.Background(), &sql.ReadonlyQuery{Stmt: countStmt, Limit: 1, Readable: filter.CanRead})
+	if err != nil || 1 > len(result.Rows) {
+		return
+	}
+	if matches, ok := result.Rows[0]["matches"].(int64); ok {
+		matchedBlockCount = int(matches)
+	}
+	if docs, ok := result.Rows[0]["docs"].(int64); ok {
+		matchedRootCount = int(docs)
+	}
+	return
+}
+
 func removeLimitClause(stmt string) string {
 	parsedStmt, err := sqlparser.Parse(stmt)
 	if err != nil {
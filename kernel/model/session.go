@@ -17,6 +17,8 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"image/color"
 	"net/http"
 	"net/url"
@@ -44,14 +46,14 @@ func LogoutAuth(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	if "" == Conf.AccessAuthCode {
+	session := util.GetSession(c)
+	if "" == Conf.AccessAuthCode && "" == util.GetWorkspaceSession(session).Member {
 		ret.Code = -1
 		ret.Msg = Conf.Language(86)
 		ret.Data = map[string]interface{}{"closeTimeout": 5000}
 		return
 	}
 
-	session := util.GetSession(c)
 	util.RemoveWorkspaceSession(session)
 	if err := session.Save(c); err != nil {
 		logging.LogErrorf("saves session failed: " + err.Error())
@@ -152,6 +154,88 @@ func LoginAuth(c *gin.Context) {
 	}
 }
 
+// LoginMember 使用帐号名和密码登录，登录成功后写入 Cookie 并返回可用于 X-Auth-Token 请求头的 JWT。
+func LoginMember(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	if util.NeedCaptcha() {
+		inputCaptcha, _ := arg["captcha"].(string)
+		if "" == inputCaptcha || strings.ToLower(workspaceSession.Captcha) != strings.ToLower(inputCaptcha) {
+			ret.Code = 1
+			ret.Msg = Conf.Language(22)
+			logging.LogWarnf("invalid captcha")
+			workspaceSession.Captcha = gulu.Rand.String(7)
+			if err := session.Save(c); err != nil {
+				logging.LogErrorf("save session failed: %s", err)
+			}
+			return
+		}
+	}
+
+	name, _ := arg["name"].(string)
+	password, _ := arg["password"].(string)
+	member := AuthenticateMember(strings.TrimSpace(name), password)
+	if nil == member {
+		ret.Code = -1
+		ret.Msg = Conf.Language(83)
+		logging.LogWarnf("invalid member name or password [name=%s, ip=%s]", name, util.GetRemoteAddr(c.Request))
+
+		util.WrongAuthCount++
+		workspaceSession.Captcha = gulu.Rand.String(7)
+		if util.NeedCaptcha() {
+			ret.Code = 1 // 需要渲染验证码
+		}
+		if err := session.Save(c); err != nil {
+			logging.LogErrorf("save session failed: %s", err)
+		}
+		return
+	}
+
+	token, err := NewMemberJWT(member.Name)
+	if err != nil {
+		logging.LogErrorf("sign member JWT failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	workspaceSession.Member = member.Name
+	util.WrongAuthCount = 0
+	workspaceSession.Captcha = gulu.Rand.String(7)
+
+	maxAge := 0
+	if rememberMe, ok := arg["rememberMe"].(bool); ok && rememberMe {
+		maxAge = 60 * 60 * 24 * 30 // 30 days
+	}
+	ginSessions.Default(c).Options(ginSessions.Options{
+		Path:     "/",
+		Secure:   util.SSL,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+	})
+
+	logging.LogInfof("member [%s] auth success [ip=%s, maxAge=%d]", member.Name, util.GetRemoteAddr(c.Request), maxAge)
+	if err = session.Save(c); err != nil {
+		logging.LogErrorf("save session failed: %s", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"name":  member.Name,
+		"role":  member.Role,
+		"token": token,
+	}
+}
+
 func GetCaptcha(c *gin.Context) {
 	img, err := captcha.New(100, 26, func(options *captcha.Options) {
 		options.CharPreset = "ABCDEFGHKLMNPQRSTUVWXYZ23456789"
@@ -192,6 +276,8 @@ func CheckReadonly(c *gin.Context) {
 		c.Abort()
 		return
 	}
+
+	markAuditRequest(c)
 }
 
 func CheckAuth(c *gin.Context) {
@@ -201,6 +287,10 @@ func CheckAuth(c *gin.Context) {
 		RoleEditor,
 		RoleReader,
 	}) {
+		if refuseByPublishScope(c) {
+			return
+		}
+
 		c.Next()
 		return
 	}
@@ -225,6 +315,12 @@ func CheckAuth(c *gin.Context) {
 				return
 			}
 
+			if member := GetMemberByToken(token); nil != member {
+				setGinContextMember(c, member.Name, Role(member.Role))
+				c.Next()
+				return
+			}
+
 			c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [header: Authorization]"})
 			c.Abort()
 			return
@@ -239,11 +335,28 @@ func CheckAuth(c *gin.Context) {
 			return
 		}
 
+		if member := GetMemberByToken(token); nil != member {
+			setGinContextMember(c, member.Name, Role(member.Role))
+			c.Next()
+			return
+		}
+
 		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [query: token]"})
 		c.Abort()
 		return
 	}
 
+	// 通过帐号登录的 Cookie
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	if "" != workspaceSession.Member {
+		if member := GetMember(workspaceSession.Member); nil != member {
+			setGinContextMember(c, member.Name, Role(member.Role))
+			c.Next()
+			return
+		}
+	}
+
 	//logging.LogInfof("check auth for [%s]", c.Request.RequestURI)
 	localhost := util.IsLocalHost(c.Request.RemoteAddr)
 
@@ -311,8 +424,6 @@ func CheckAuth(c *gin.Context) {
 	}
 
 	// 通过 Cookie
-	session := util.GetSession(c)
-	workspaceSession := util.GetWorkspaceSession(session)
 	if workspaceSession.AccessAuthCode == Conf.AccessAuthCode {
 		c.Set(RoleContextKey, RoleAdministrator)
 		c.Next()
@@ -327,6 +438,13 @@ func CheckAuth(c *gin.Context) {
 			c.Next()
 			return
 		}
+
+		// 使用帐号名和密码
+		if member := AuthenticateMember(username, password); nil != member {
+			setGinContextMember(c, member.Name, Role(member.Role))
+			c.Next()
+			return
+		}
 	}
 
 	// WebDAV BasicAuth Authenticate
@@ -373,6 +491,32 @@ func CheckAuth(c *gin.Context) {
 	c.Next()
 }
 
+// GetGinContextSessionKey 返回标识请求会话的键，使用请求携带的凭据（JWT、API token 或者会话 Cookie）计算，
+// 不同会话的键不同。没有凭据时（本机免授权访问）返回空字符串。
+func GetGinContextSessionKey(c *gin.Context) string {
+	credential := c.GetHeader(XAuthTokenKey)
+	if "" == credential {
+		credential = c.GetHeader("Authorization")
+	}
+	if "" == credential {
+		credential = c.Query("token")
+	}
+	if "" == credential {
+		credential, _ = c.Cookie("siyuan")
+	}
+	if "" == credential {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+func setGinContextMember(c *gin.Context, member string, role Role) {
+	c.Set(RoleContextKey, role)
+	c.Set(MemberContextKey, member)
+}
+
 func CheckAdminRole(c *gin.Context) {
 	if IsAdminRoleContext(c) {
 		c.Next()
@@ -381,6 +525,15 @@ func CheckAdminRole(c *gin.Context) {
 	}
 }
 
+// CheckTransactionRole 检查是否可以提交事务，未启用访问控制时只有管理员可以提交，启用后编辑者也可以提交，事务中的操作会按访问控制规则逐个检查。
+func CheckTransactionRole(c *gin.Context) {
+	if nil != Conf.ACL && Conf.ACL.Enable {
+		CheckEditRole(c)
+		return
+	}
+	CheckAdminRole(c)
+}
+
 func CheckEditRole(c *gin.Context) {
 	if IsValidRole(GetGinContextRole(c), []Role{
 		RoleAdministrator,
@@ -450,7 +603,26 @@ func ControlConcurrency(c *gin.Context) {
 	}
 
 	reqPath := c.Request.URL.Path
+	if isReadRequest(reqPath) {
+		c.Next()
+		return
+	}
+
+	requestingLock.Lock()
+	mutex := requesting[reqPath]
+	if nil == mutex {
+		mutex = &sync.Mutex{}
+		requesting[reqPath] = mutex
+	}
+	requestingLock.Unlock()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	c.Next()
+}
 
+// isReadRequest 判断请求是否只读取数据。
+func isReadRequest(reqPath string) bool {
 	// Improve the concurrency of the kernel data reading interfaces https://github.com/siyuan-note/siyuan/issues/10149
 	if strings.HasPrefix(reqPath, "/stage/") ||
 		strings.HasPrefix(reqPath, "/assets/") ||
@@ -468,8 +640,7 @@ func ControlConcurrency(c *gin.Context) {
 		strings.HasPrefix(reqPath, "/api/network/") ||
 		strings.HasPrefix(reqPath, "/api/broadcast/") ||
 		strings.HasPrefix(reqPath, "/es/") {
-		c.Next()
-		return
+		return true
 	}
 
 	parts := strings.Split(reqPath, "/")
@@ -479,19 +650,7 @@ func ControlConcurrency(c *gin.Context) {
 		strings.HasPrefix(function, "search") ||
 		strings.HasPrefix(function, "render") ||
 		strings.HasPrefix(function, "ls") {
-		c.Next()
-		return
-	}
-
-	requestingLock.Lock()
-	mutex := requesting[reqPath]
-	if nil == mutex {
-		mutex = &sync.Mutex{}
-		requesting[reqPath] = mutex
+		return true
 	}
-	requestingLock.Unlock()
-
-	mutex.Lock()
-	defer mutex.Unlock()
-	c.Next()
+	return false
 }
@@ -206,6 +206,17 @@ func syncData(exit, byHand bool) {
 	}
 	util.BroadcastByType("main", "syncing", code, Conf.Sync.Stat, nil)
 
+	if !exit {
+		webhookEvt := newWebhookEvent(webhookEventSyncFinished)
+		webhookEvt.Data["byHand"] = byHand
+		webhookEvt.Data["dataChanged"] = dataChanged
+		webhookEvt.Data["stat"] = Conf.Sync.Stat
+		if err != nil {
+			webhookEvt.Data["err"] = err.Error()
+		}
+		fireWebhookEvent(webhookEvt)
+	}
+
 	if nil == webSocketConn && Conf.Sync.Perception {
 		// 如果 websocket 连接已经断开，则重新连接
 		connectSyncWebSocket()
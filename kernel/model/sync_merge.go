@@ -0,0 +1,572 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	syncConflictAttr     = "custom-sync-conflict"      // 块级合并冲突标识，冲突双方的块使用相同的值
+	syncConflictSideAttr = "custom-sync-conflict-side" // 冲突块来源：local 本地，cloud 云端
+)
+
+// syncMergeBaseIndexID 记录同步前的最近一次同步点索引，作为块级三路合并的共同祖先。
+var syncMergeBaseIndexID string
+
+func recordSyncMergeBase(repo *dejavu.Repo) {
+	syncMergeBaseIndexID = ""
+
+	latestSync := filepath.Join(repo.Path, "refs", "latest-sync")
+	if !gulu.File.IsExist(latestSync) {
+		return
+	}
+
+	data, err := os.ReadFile(latestSync)
+	if err != nil {
+		logging.LogWarnf("read latest sync index failed: %s", err)
+		return
+	}
+	syncMergeBaseIndexID = strings.TrimSpace(string(data))
+}
+
+// mergeSyncConflicts 对发生冲突的文档进行块级三路合并，返回合并成功的文件路径。
+//
+// 数据文件夹中保留的是一侧的版本，另一侧的版本由 dejavu 迁出到临时文件夹下，共同祖先从最近一次同步点中获取。
+// 互不重叠的块变更直接合并，双方都修改了的块保留为相邻的两个块并通过 custom-sync-conflict 属性标记。
+func mergeSyncConflicts(mergeResult *dejavu.MergeResult, mode string, luteEngine *lute.Lute) (ret []string) {
+	if "" == syncMergeBaseIndexID {
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		logging.LogErrorf("merge sync conflicts failed: %s", err)
+		return
+	}
+
+	baseIndex, err := repo.GetIndex(syncMergeBaseIndexID)
+	if err != nil {
+		logging.LogErrorf("get sync merge base index [%s] failed: %s", syncMergeBaseIndexID, err)
+		return
+	}
+	baseFiles, err := repo.GetFiles(baseIndex)
+	if err != nil {
+		logging.LogErrorf("get sync merge base files failed: %s", err)
+		return
+	}
+	baseFileMap := map[string]*entity.File{}
+	for _, file := range baseFiles {
+		baseFileMap[file.Path] = file
+	}
+
+	// 同步下载时数据文件夹中是云端版本，其他情况下是本地版本
+	oursSide, theirsSide := "local", "cloud"
+	if "d" == mode {
+		oursSide, theirsSide = "cloud", "local"
+	}
+
+	conflictsDir := filepath.Join(util.TempDir, "repo", "sync", "conflicts", mergeResult.Time.Format("2006-01-02-150405"))
+	for _, file := range mergeResult.Conflicts {
+		if !strings.HasSuffix(file.Path, ".sy") {
+			continue
+		}
+
+		parts := strings.Split(file.Path[1:], "/")
+		if 2 > len(parts) {
+			continue
+		}
+		boxID := parts[0]
+
+		baseFile := baseFileMap[file.Path]
+		if nil == baseFile {
+			continue
+		}
+
+		data, openErr := repo.OpenFile(baseFile)
+		if nil != openErr {
+			logging.LogErrorf("open sync merge base file [%s] failed: %s", file.Path, openErr)
+			continue
+		}
+		_, baseTree, parseErr := parseTreeInSnapshot(data, luteEngine)
+		if nil != parseErr {
+			logging.LogErrorf("parse sync merge base file [%s] failed: %s", file.Path, parseErr)
+			continue
+		}
+
+		theirsTree, loadErr := loadTree(filepath.Join(conflictsDir, file.Path), luteEngine)
+		if nil != loadErr {
+			continue
+		}
+		oursTree, loadErr := loadTree(filepath.Join(util.DataDir, file.Path), luteEngine)
+		if nil != loadErr {
+			continue
+		}
+		if oursTree.Root.ID != theirsTree.Root.ID || oursTree.Root.ID != baseTree.Root.ID {
+			continue
+		}
+
+		merger := newSyncTreeMerger(oursTree, oursSide, theirsSide, luteEngine)
+		merger.mergeAttrs(baseTree.Root, oursTree.Root, theirsTree.Root)
+		merger.mergeChildren(baseTree.Root, oursTree.Root, theirsTree.Root)
+
+		oursTree.Box = boxID
+		oursTree.Path = strings.TrimPrefix(file.Path, "/"+boxID)
+		if _, err = filesys.WriteTree(oursTree); err != nil {
+			logging.LogErrorf("write merged tree [%s] failed: %s", file.Path, err)
+			continue
+		}
+		ret = append(ret, file.Path)
+		logging.LogInfof("merged sync conflict [%s] with [%d] conflicted blocks", file.Path, merger.conflicts)
+	}
+	return
+}
+
+type syncTreeMerger struct {
+	luteEngine *lute.Lute
+	oursSide   string
+	theirsSide string
+	oursIDs    map[string]bool // 合并结果中已经存在的块，用于避免移动块后重复插入
+	conflicts  int
+}
+
+func newSyncTreeMerger(oursTree *parse.Tree, oursSide, theirsSide string, luteEngine *lute.Lute) (ret *syncTreeMerger) {
+	ret = &syncTreeMerger{luteEngine: luteEngine, oursSide: oursSide, theirsSide: theirsSide, oursIDs: map[string]bool{}}
+	ret.addIDs(oursTree.Root)
+	return
+}
+
+// mergeChildren 合并 ours 的子块，base 为空时表示没有共同祖先。
+func (m *syncTreeMerger) mergeChildren(base, ours, theirs *ast.Node) {
+	baseBlocks := map[string]*ast.Node{}
+	for _, b := range syncMergeChildBlocks(base) {
+		baseBlocks[b.ID] = b
+	}
+	theirsList := syncMergeChildBlocks(theirs)
+	theirsBlocks := map[string]*ast.Node{}
+	var theirsIDs []string // 合并冲突时会重置对侧块的 ID，这里需要记录原始 ID
+	for _, t := range theirsList {
+		theirsBlocks[t.ID] = t
+		theirsIDs = append(theirsIDs, t.ID)
+	}
+
+	kept := map[string]*ast.Node{}
+	lasts := map[string]*ast.Node{} // 合并后块在 ours 中的最后一个节点，发生冲突时是插入的对侧副本
+	for _, o := range syncMergeChildBlocks(ours) {
+		b, t := baseBlocks[o.ID], theirsBlocks[o.ID]
+		if nil == t {
+			if nil == b { // 本侧新增
+				kept[o.ID], lasts[o.ID] = o, o
+				continue
+			}
+
+			if m.sameBlock(o, b) { // 对侧删除
+				o.Unlink()
+				continue
+			}
+
+			// 对侧删除但本侧修改
+			m.markConflict(o, ast.NewNodeID(), m.oursSide)
+			kept[o.ID], lasts[o.ID] = o, o
+			continue
+		}
+
+		kept[o.ID], lasts[o.ID] = m.mergeBlock(b, o, t)
+	}
+
+	var prev *ast.Node
+	for i, t := range theirsList {
+		if nil != kept[theirsIDs[i]] {
+			prev = lasts[theirsIDs[i]]
+			continue
+		}
+
+		b := baseBlocks[t.ID]
+		if nil != b && m.sameBlock(t, b) { // 本侧删除
+			continue
+		}
+
+		if m.oursIDs[t.ID] { // 本侧移动到了其他位置
+			continue
+		}
+
+		t.Unlink()
+		m.insertAfter(ours, prev, t)
+		m.addIDs(t)
+		if nil != b {
+			// 本侧删除但对侧修改
+			m.markConflict(t, ast.NewNodeID(), m.theirsSide)
+		}
+		prev = t
+	}
+}
+
+// mergeBlock 合并双方都存在的块，返回合并后保留在 ours 中的块以及该块在 ours 中的最后一个节点。
+func (m *syncTreeMerger) mergeBlock(base, ours, theirs *ast.Node) (kept, last *ast.Node) {
+	if m.sameBlock(ours, theirs) {
+		return ours, ours
+	}
+
+	if nil != base {
+		if m.sameBlock(ours, base) {
+			theirs.Unlink()
+			ours.InsertBefore(theirs)
+			ours.Unlink()
+			m.addIDs(theirs)
+			return theirs, theirs
+		}
+		if m.sameBlock(theirs, base) {
+			return ours, ours
+		}
+	}
+
+	if ours.Type == theirs.Type && ours.IsContainerBlock() {
+		m.mergeAttrs(base, ours, theirs)
+		m.mergeChildren(base, ours, theirs)
+		return ours, ours
+	}
+
+	conflictID := ast.NewNodeID()
+	theirs.Unlink()
+	ast.Walk(theirs, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() {
+			return ast.WalkContinue
+		}
+
+		treenode.ResetNodeID(n)
+		return ast.WalkContinue
+	})
+	ours.InsertAfter(theirs)
+	m.addIDs(theirs)
+	m.markConflict(ours, conflictID, m.oursSide)
+	m.markConflict(theirs, conflictID, m.theirsSide)
+	return ours, theirs
+}
+
+// mergeAttrs 对块属性进行三路合并，双方都修改了的属性以 ours 为准。
+func (m *syncTreeMerger) mergeAttrs(base, ours, theirs *ast.Node) {
+	if nil == base {
+		return
+	}
+
+	baseAttrs, oursAttrs, theirsAttrs := parse.IAL2Map(base.KramdownIAL), parse.IAL2Map(ours.KramdownIAL), parse.IAL2Map(theirs.KramdownIAL)
+	for name, theirsVal := range theirsAttrs {
+		oursVal, inOurs := oursAttrs[name]
+		if theirsVal == oursVal {
+			continue
+		}
+
+		baseVal, inBase := baseAttrs[name]
+		if (inBase && inOurs && baseVal == oursVal) || (!inBase && !inOurs) {
+			ours.SetIALAttr(name, theirsVal)
+		}
+	}
+	for name, oursVal := range oursAttrs {
+		if _, inTheirs := theirsAttrs[name]; inTheirs {
+			continue
+		}
+
+		if baseVal, inBase := baseAttrs[name]; inBase && baseVal == oursVal {
+			ours.RemoveIALAttr(name)
+		}
+	}
+}
+
+func (m *syncTreeMerger) markConflict(node *ast.Node, conflictID, side string) {
+	node.SetIALAttr(syncConflictAttr, conflictID)
+	node.SetIALAttr(syncConflictSideAttr, side)
+	m.conflicts++
+}
+
+func (m *syncTreeMerger) insertAfter(parent, prev, node *ast.Node) {
+	if nil != prev {
+		prev.InsertAfter(node)
+		return
+	}
+	prependChildBlock(parent, node)
+}
+
+// prependChildBlock 将 node 插入为 parent 的第一个子块，需要跳过引述块、超级块等容器开头的标记节点。
+func prependChildBlock(parent, node *ast.Node) {
+	for c := parent.FirstChild; nil != c; c = c.Next {
+		if c.IsBlock() {
+			c.InsertBefore(node)
+			return
+		}
+	}
+	if nil != parent.LastChild && ast.NodeSuperBlockCloseMarker == parent.LastChild.Type {
+		parent.LastChild.InsertBefore(node)
+		return
+	}
+	parent.AppendChild(node)
+}
+
+func (m *syncTreeMerger) addIDs(node *ast.Node) {
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && "" != n.ID {
+			m.oursIDs[n.ID] = true
+		}
+		return ast.WalkContinue
+	})
+}
+
+func (m *syncTreeMerger) sameBlock(n1, n2 *ast.Node) bool {
+	return treenode.FormatNode(n1, m.luteEngine) == treenode.FormatNode(n2, m.luteEngine)
+}
+
+func syncMergeChildBlocks(node *ast.Node) (ret []*ast.Node) {
+	if nil == node {
+		return
+	}
+
+	for c := node.FirstChild; nil != c; c = c.Next {
+		if c.IsBlock() && "" != c.ID {
+			ret = append(ret, c)
+		}
+	}
+	return
+}
+
+type SyncMergeConflict struct {
+	ID     string                    `json:"id"`
+	Box    string                    `json:"box"`
+	RootID string                    `json:"rootID"`
+	HPath  string                    `json:"hPath"`
+	Blocks []*SyncMergeConflictBlock `json:"blocks"`
+}
+
+type SyncMergeConflictBlock struct {
+	ID      string `json:"id"`
+	Side    string `json:"side"`
+	Content string `json:"content"`
+}
+
+// GetSyncMergeConflicts 列出块级合并后仍未解决的冲突。
+func GetSyncMergeConflicts() (ret []*SyncMergeConflict) {
+	ret = []*SyncMergeConflict{}
+	FlushTxQueue()
+
+	conflicts := map[string]*SyncMergeConflict{}
+	for _, tree := range loadSyncMergeConflictTrees("") {
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || !n.IsBlock() {
+				return ast.WalkContinue
+			}
+
+			conflictID := n.IALAttr(syncConflictAttr)
+			if "" == conflictID {
+				return ast.WalkContinue
+			}
+
+			conflict := conflicts[conflictID]
+			if nil == conflict {
+				conflict = &SyncMergeConflict{ID: conflictID, Box: tree.Box, RootID: tree.ID, HPath: tree.HPath}
+				conflicts[conflictID] = conflict
+				ret = append(ret, conflict)
+			}
+			conflict.Blocks = append(conflict.Blocks, &SyncMergeConflictBlock{
+				ID:      n.ID,
+				Side:    n.IALAttr(syncConflictSideAttr),
+				Content: renderBlockText(n, nil, false),
+			})
+			return ast.WalkSkipChildren
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+	return
+}
+
+// ResolveSyncMergeConflict 解决块级合并冲突，keep 为 local、cloud 或者 both。
+func ResolveSyncMergeConflict(id, keep string) (err error) {
+	if !ast.IsNodeIDPattern(id) {
+		return errors.New("invalid conflict id")
+	}
+	if "local" != keep && "cloud" != keep && "both" != keep {
+		return errors.New("invalid keep side")
+	}
+
+	FlushTxQueue()
+
+	trees := loadSyncMergeConflictTrees(id)
+	if 1 > len(trees) {
+		return ErrBlockNotFound
+	}
+
+	for _, tree := range trees {
+		var unlinks []*ast.Node
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || !n.IsBlock() || id != n.IALAttr(syncConflictAttr) {
+				return ast.WalkContinue
+			}
+
+			if "both" != keep && keep != n.IALAttr(syncConflictSideAttr) {
+				unlinks = append(unlinks, n)
+				return ast.WalkSkipChildren
+			}
+
+			n.RemoveIALAttr(syncConflictAttr)
+			n.RemoveIALAttr(syncConflictSideAttr)
+			return ast.WalkSkipChildren
+		})
+		for _, n := range unlinks {
+			n.Unlink()
+		}
+
+		if err = indexWriteTreeUpsertQueue(tree); err != nil {
+			return
+		}
+		ReloadProtyle(tree.ID)
+	}
+
+	IncSync()
+	return
+}
+
+func loadSyncMergeConflictTrees(conflictID string) (ret []*parse.Tree) {
+	stmt := "SELECT DISTINCT root_id FROM attributes WHERE name = '" + syncConflictAttr + "'"
+	if "" != conflictID {
+		stmt += " AND value = '" + conflictID + "'"
+	}
+	result, err := sql.QueryNoLimit(stmt)
+	if err != nil {
+		logging.LogErrorf("query sync merge conflicts failed: %s", err)
+		return
+	}
+
+	for _, row := range result {
+		rootID, _ := row["root_id"].(string)
+		tree, loadErr := LoadTreeByBlockID(rootID)
+		if nil != loadErr {
+			continue
+		}
+		ret = append(ret, tree)
+	}
+	return
+}
+
+type DocBlockDiff struct {
+	Title   string       `json:"title"`
+	Adds    []*DiffBlock `json:"adds"`    // 仅存在于左侧快照中的块
+	Updates []*DiffBlock `json:"updates"` // 两侧快照中都存在但内容不同的块
+	Removes []*DiffBlock `json:"removes"` // 仅存在于右侧快照中的块
+}
+
+type DiffBlock struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// DiffRepoSnapshotDoc 对比两个快照中同一文档的块级差异，仅对比叶子块。
+func DiffRepoSnapshotDoc(leftFileID, rightFileID string) (ret *DocBlockDiff, err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	luteEngine := NewLute()
+	leftTree, err := openSnapshotTree(repo, leftFileID, luteEngine)
+	if err != nil {
+		return
+	}
+	rightTree, err := openSnapshotTree(repo, rightFileID, luteEngine)
+	if err != nil {
+		return
+	}
+
+	ret = &DocBlockDiff{Title: leftTree.Root.IALAttr("title"), Adds: []*DiffBlock{}, Updates: []*DiffBlock{}, Removes: []*DiffBlock{}}
+	leftBlocks, rightBlocks := snapshotLeafBlocks(leftTree), snapshotLeafBlocks(rightTree)
+	rightMap := map[string]*ast.Node{}
+	for _, n := range rightBlocks {
+		rightMap[n.ID] = n
+	}
+	leftMap := map[string]*ast.Node{}
+	for _, n := range leftBlocks {
+		leftMap[n.ID] = n
+		right := rightMap[n.ID]
+		if nil == right {
+			ret.Adds = append(ret.Adds, &DiffBlock{ID: n.ID, Type: n.Type.String(), Left: treenode.ExportNodeStdMd(n, luteEngine)})
+			continue
+		}
+
+		if treenode.FormatNode(n, luteEngine) != treenode.FormatNode(right, luteEngine) {
+			ret.Updates = append(ret.Updates, &DiffBlock{ID: n.ID, Type: n.Type.String(),
+				Left: treenode.ExportNodeStdMd(n, luteEngine), Right: treenode.ExportNodeStdMd(right, luteEngine)})
+		}
+	}
+	for _, n := range rightBlocks {
+		if nil == leftMap[n.ID] {
+			ret.Removes = append(ret.Removes, &DiffBlock{ID: n.ID, Type: n.Type.String(), Right: treenode.ExportNodeStdMd(n, luteEngine)})
+		}
+	}
+	return
+}
+
+func openSnapshotTree(repo *dejavu.Repo, fileID string, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
+	file, err := repo.GetFile(fileID)
+	if err != nil {
+		return
+	}
+	if !strings.HasSuffix(file.Path, ".sy") {
+		err = errors.New("not a document file")
+		return
+	}
+
+	data, err := repo.OpenFile(file)
+	if err != nil {
+		return
+	}
+	_, ret, err = parseTreeInSnapshot(data, luteEngine)
+	return
+}
+
+func snapshotLeafBlocks(tree *parse.Tree) (ret []*ast.Node) {
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || "" == n.ID || n.IsContainerBlock() {
+			return ast.WalkContinue
+		}
+
+		ret = append(ret, n)
+		return ast.WalkSkipChildren
+	})
+	return
+}
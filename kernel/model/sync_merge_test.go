@@ -0,0 +1,122 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestSyncTreeMerger(t *testing.T) {
+	// 块使用 "编号 内容" 表示，引述块使用 "编号 [子块, 子块]" 表示，冲突块在内容后附加 !local 或者 !cloud
+	cases := []struct {
+		name     string
+		base     string
+		ours     string
+		theirs   string
+		expected string
+	}{
+		{"edit/edit", "1 a, 2 b", "1 a1, 2 b", "1 a2, 2 b", "a1!local, a2!cloud, b"},
+		{"edit/edit different blocks", "1 a, 2 b", "1 a1, 2 b", "1 a, 2 b2", "a1, b2"},
+		{"delete/edit", "1 a, 2 b", "2 b", "1 a2, 2 b", "a2!cloud, b"},
+		{"edit/delete", "1 a, 2 b", "1 a1, 2 b", "2 b", "a1!local, b"},
+		{"delete/unchanged", "1 a, 2 b", "2 b", "1 a, 2 b", "b"},
+		{"insert/insert", "1 a", "1 a, 2 b", "1 a, 3 c", "a, c, b"},
+		{"move", "1 a, 2 b, 3 c", "2 b, 3 c, 1 a", "1 a, 2 b, 3 c, 4 d", "b, c, d, a"},
+		{"insert after conflict", "1 a, 2 b", "1 a1, 2 b", "1 a2, 3 x, 2 b", "a1!local, a2!cloud, x, b"},
+		{"insert after conflict at end", "1 a", "1 a1", "1 a2, 3 x", "a1!local, a2!cloud, x"},
+		{"container", "5 [1 a, 2 b]", "5 [1 a1, 2 b]", "5 [1 a, 2 b, 3 c]", "[a1, b, c]"},
+		{"container conflict", "5 [1 a]", "5 [1 a1]", "5 [1 a2, 3 c]", "[a1!local, a2!cloud, c]"},
+	}
+
+	luteEngine := util.NewLute()
+	for _, c := range cases {
+		base, ours, theirs := syncMergeTestTree(c.base, luteEngine), syncMergeTestTree(c.ours, luteEngine), syncMergeTestTree(c.theirs, luteEngine)
+		merger := newSyncTreeMerger(ours, "local", "cloud", luteEngine)
+		merger.mergeAttrs(base.Root, ours.Root, theirs.Root)
+		merger.mergeChildren(base.Root, ours.Root, theirs.Root)
+
+		if got := syncMergeTestDump(ours.Root); got != c.expected {
+			t.Errorf("[%s] expected [%s], got [%s]", c.name, c.expected, got)
+		}
+	}
+}
+
+func syncMergeTestTree(spec string, luteEngine *lute.Lute) *parse.Tree {
+	tree := parse.Parse("", []byte(syncMergeTestMarkdown(syncMergeTestSplit(spec), "")), luteEngine.ParseOptions)
+	tree.Root.ID = "20200101000000-0000000"
+	return tree
+}
+
+func syncMergeTestMarkdown(blocks []string, indent string) string {
+	buf := strings.Builder{}
+	for i, block := range blocks {
+		num, content, _ := strings.Cut(block, " ")
+		ial := indent + "{: id=\"20200101000000-000000" + num + "\"}\n"
+		if strings.HasPrefix(content, "[") {
+			buf.WriteString(syncMergeTestMarkdown(syncMergeTestSplit(content[1:len(content)-1]), indent+"> "))
+		} else {
+			buf.WriteString(indent + content + "\n")
+		}
+		buf.WriteString(ial)
+		if i < len(blocks)-1 {
+			buf.WriteString(strings.TrimSuffix(indent, " ") + "\n")
+		}
+	}
+	return buf.String()
+}
+
+// syncMergeTestSplit 按顶层逗号分割块。
+func syncMergeTestSplit(spec string) (ret []string) {
+	depth, start := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if 0 == depth {
+				ret = append(ret, strings.TrimSpace(spec[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(ret, strings.TrimSpace(spec[start:]))
+}
+
+func syncMergeTestDump(node *ast.Node) string {
+	var blocks []string
+	for _, c := range syncMergeChildBlocks(node) {
+		var s string
+		if ast.NodeBlockquote == c.Type {
+			s = "[" + syncMergeTestDump(c) + "]"
+		} else {
+			s = c.Text()
+		}
+		if side := c.IALAttr(syncConflictSideAttr); "" != side {
+			s += "!" + side
+		}
+		blocks = append(blocks, s)
+	}
+	return strings.Join(blocks, ", ")
+}
@@ -236,12 +236,13 @@ type Tag struct {
 
 type Tags []*Tag
 
-func BuildTags(ignoreMaxListHintArg bool, appID string) (ret *Tags) {
+// BuildTags 构建标签面板，filter 为受限请求的读取过滤器，为空时不过滤。
+func BuildTags(ignoreMaxListHintArg bool, appID string, filter *ReadableFilter) (ret *Tags) {
 	FlushTxQueue()
 	sql.FlushQueue()
 
 	ret = &Tags{}
-	labels := labelTags()
+	labels := labelTags(filter)
 	tags := Tags{}
 	for label := range labels {
 		tags = buildTags(tags, strings.Split(label, "/"), 0)
@@ -300,12 +301,12 @@ func sortTags(tags Tags) {
 	}
 }
 
-func SearchTags(keyword string) (ret []string) {
+func SearchTags(keyword string, filter *ReadableFilter) (ret []string) {
 	ret = []string{}
 
 	sql.FlushQueue()
 
-	labels := labelBlocksByKeyword(keyword)
+	labels := labelBlocksByKeyword(keyword, filter)
 	keyword = strings.Join(strings.Split(keyword, " "), search.TermSep)
 	for label := range labels {
 		if "" == keyword {
@@ -320,10 +321,15 @@ func SearchTags(keyword string) (ret []string) {
 	return
 }
 
-func labelBlocksByKeyword(keyword string) (ret map[string]TagBlocks) {
+func labelBlocksByKeyword(keyword string, filter *ReadableFilter) (ret map[string]TagBlocks) {
 	ret = map[string]TagBlocks{}
 
-	tags := sql.QueryTagSpansByKeyword(keyword, Conf.Search.Limit)
+	var tags []*sql.Span
+	for _, tag := range sql.QueryTagSpansByKeyword(keyword, Conf.Search.Limit) {
+		if filter.CanRead(tag.Box, tag.Path) {
+			tags = append(tags, tag)
+		}
+	}
 	set := hashset.New()
 	for _, tag := range tags {
 		set.Add(tag.BlockID)
@@ -361,11 +367,15 @@ func labelBlocksByKeyword(keyword string) (ret map[string]TagBlocks) {
 	return
 }
 
-func labelTags() (ret map[string]Tags) {
+func labelTags(filter *ReadableFilter) (ret map[string]Tags) {
 	ret = map[string]Tags{}
 
 	tagSpans := sql.QueryTagSpans("")
 	for _, tagSpan := range tagSpans {
+		if !filter.CanRead(tagSpan.Box, tagSpan.Path) {
+			continue
+		}
+
 		label := util.UnescapeHTML(tagSpan.Content)
 		if _, ok := ret[label]; ok {
 			ret[label] = append(ret[label], &Tag{})
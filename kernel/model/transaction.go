@@ -100,6 +100,8 @@ func flushTx(tx *Transaction) {
 		case TxErrHandleAttributeView:
 			util.PushMsg(Conf.language(258), 5000)
 			logging.LogErrorf("handle attribute view failed: %s", txErr.msg)
+		case TxErrCodeAccessDenied:
+			return // 拒绝原因已在访问控制检查时记录日志
 		default:
 			txData, _ := gulu.JSON.MarshalJSON(tx)
 			logging.LogFatalf(logging.ExitCodeFatal, "transaction failed [%d]: %s\n  tx [%s]", txErr.code, txErr.msg, txData)
@@ -126,6 +128,7 @@ const (
 	TxErrCodeDataIsSyncing   = 1
 	TxErrCodeWriteTree       = 2
 	TxErrHandleAttributeView = 3
+	TxErrCodeAccessDenied    = 4
 )
 
 type TxErr struct {
@@ -139,6 +142,12 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		return
 	}
 
+	if ret = tx.checkACL(); nil != ret {
+		return
+	}
+
+	audits := tx.buildAudits()
+
 	//os.MkdirAll("pprof", 0755)
 	//cpuProfile, _ := os.Create("pprof/cpu_profile_tx")
 	//pprof.StartCPUProfile(cpuProfile)
@@ -258,6 +267,8 @@ func performTx(tx *Transaction) (ret *TxErr) {
 				ret = tx.doReplaceAttrViewBlock(op)
 			case "updateAttrViewColTemplate":
 				ret = tx.doUpdateAttrViewColTemplate(op)
+			case "updateAttrViewColFormula":
+				ret = tx.doUpdateAttrViewColFormula(op)
 			case "addAttrViewView":
 				ret = tx.doAddAttrViewView(op)
 			case "removeAttrViewView":
@@ -294,6 +305,18 @@ func performTx(tx *Transaction) (ret *TxErr) {
 				ret = tx.doSetAttrViewCoverFromAssetKeyID(op)
 			case "setAttrViewCardSize":
 				ret = tx.doSetAttrViewCardSize(op)
+			case "setAttrViewDateKey":
+				ret = tx.doSetAttrViewDateKey(op)
+			case "setAttrViewCalendarMode":
+				ret = tx.doSetAttrViewCalendarMode(op)
+			case "setAttrViewCalendarStartWeekday":
+				ret = tx.doSetAttrViewCalendarStartWeekday(op)
+			case "setAttrViewCalendarCurrent":
+				ret = tx.doSetAttrViewCalendarCurrent(op)
+			case "setAttrViewTimelineScale":
+				ret = tx.doSetAttrViewTimelineScale(op)
+			case "moveAttrViewItemDate":
+				ret = tx.doMoveAttrViewItemDate(op)
 			case "setAttrViewFitImage":
 				ret = tx.doSetAttrViewFitImage(op)
 			case "setAttrViewDisplayFieldName":
@@ -337,6 +360,8 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		logging.LogErrorf("commit tx failed: %s", cr)
 		return &TxErr{msg: cr.Error()}
 	}
+	sql.AppendAudits(audits...)
+	fireTxWebhook(tx)
 	return
 }
 
@@ -1544,6 +1569,7 @@ func (tx *Transaction) doUpdate(operation *Operation) (ret *TxErr) {
 	tx.writeTree(tree)
 
 	upsertAvBlockRel(updatedNode)
+	refreshBlockFlashcards(updatedNode)
 
 	if ast.NodeAttributeView == updatedNode.Type {
 		// 设置视图 https://github.com/siyuan-note/siyuan/issues/15279
@@ -1752,6 +1778,7 @@ func (tx *Transaction) doSetAttrs(operation *Operation) (ret *TxErr) {
 		delete(attrs, name)
 	}
 
+	oldAttrs := parse.IAL2Map(node.KramdownIAL)
 	for name, value := range attrs {
 		if "" == value {
 			node.RemoveIALAttr(name)
@@ -1761,7 +1788,9 @@ func (tx *Transaction) doSetAttrs(operation *Operation) (ret *TxErr) {
 	}
 
 	tx.writeTree(tree)
-	cache.PutBlockIAL(id, parse.IAL2Map(node.KramdownIAL))
+	newAttrs := parse.IAL2Map(node.KramdownIAL)
+	cache.PutBlockIAL(id, newAttrs)
+	fireAttrAutomations(id, oldAttrs, newAttrs)
 	return
 }
 
@@ -1844,6 +1873,11 @@ type Transaction struct {
 	DoOperations   []*Operation `json:"doOperations"`
 	UndoOperations []*Operation `json:"undoOperations"`
 
+	Role     Role   `json:"-"` // 提交事务的角色，用于访问控制检查
+	Member   string `json:"-"` // 提交事务的帐号名，为空时表示工作空间所有者
+	ClientIP string `json:"-"` // 提交事务的客户端 IP，用于审计日志
+	Endpoint string `json:"-"` // 提交事务的接口，为空时表示内核内部生成的事务
+
 	trees          map[string]*parse.Tree // 事务中变更的树
 	nodes          map[string]*ast.Node   // 事务中变更的节点
 	relatedAvIDs   []string               // 事务中变更的属性视图 ID
@@ -0,0 +1,484 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	webhookEventDocCreated        = "doc.created"
+	webhookEventDocRenamed        = "doc.renamed"
+	webhookEventDocMoved          = "doc.moved"
+	webhookEventDocRemoved        = "doc.removed"
+	webhookEventBlocksChanged     = "blocks.changed"
+	webhookEventAvRowAdded        = "av.rowAdded"
+	webhookEventAvValueChanged    = "av.valueChanged"
+	webhookEventFlashcardReviewed = "flashcard.reviewed"
+	webhookEventSyncFinished      = "sync.finished"
+	webhookEventReminderFired     = "reminder.fired"
+	webhookEventPing              = "ping"
+
+	webhookDefaultRetries  = 3   // 默认重试次数
+	webhookMaxRetries      = 10  // 最大重试次数
+	webhookMaxDeadLetters  = 200 // 最多保留的死信数
+	webhookConcurrentLimit = 4   // 同时进行的推送请求数
+	webhookMaxPending      = 512 // 最多等待推送（包括等待重试）的事件数
+)
+
+var webhookEvents = []string{
+	webhookEventDocCreated, webhookEventDocRenamed, webhookEventDocMoved, webhookEventDocRemoved,
+	webhookEventBlocksChanged, webhookEventAvRowAdded, webhookEventAvValueChanged,
+	webhookEventFlashcardReviewed, webhookEventSyncFinished, webhookEventReminderFired,
+}
+
+// WebhookEvent 为推送给 Webhook 的请求体。
+type WebhookEvent struct {
+	ID     string                 `json:"id"`     // 事件 ID，重试时保持不变，接收方可以据此去重
+	Type   string                 `json:"type"`   // 事件类型
+	Time   int64                  `json:"time"`   // 事件发生时间
+	Box    string                 `json:"box"`    // 笔记本 ID
+	RootID string                 `json:"rootID"` // 文档 ID
+	Path   string                 `json:"path"`   // 文档数据路径
+	HPath  string                 `json:"hPath"`  // 文档人类可读路径
+	IDs    []string               `json:"ids"`    // 相关的块 ID
+	Data   map[string]interface{} `json:"data"`   // 事件附加数据
+}
+
+// WebhookDeadLetter 记录重试后仍然推送失败的事件。
+type WebhookDeadLetter struct {
+	ID       string        `json:"id"`
+	HookID   string        `json:"hookID"`
+	URL      string        `json:"url"`
+	Event    *WebhookEvent `json:"event"`
+	Attempts int           `json:"attempts"`
+	Err      string        `json:"err"`
+	Created  int64         `json:"created"`
+}
+
+var (
+	webhookDeadLettersLock = sync.Mutex{}
+	webhookConcurrent      = make(chan struct{}, webhookConcurrentLimit)
+)
+
+func newWebhookEvent(typ string) *WebhookEvent {
+	return &WebhookEvent{Type: typ, IDs: []string{}, Data: map[string]interface{}{}}
+}
+
+func newWebhookEventByTree(typ string, tree *parse.Tree) (ret *WebhookEvent) {
+	ret = newWebhookEvent(typ)
+	ret.Box, ret.RootID, ret.Path, ret.HPath = tree.Box, tree.ID, tree.Path, tree.HPath
+	ret.IDs = append(ret.IDs, tree.ID)
+	return
+}
+
+func newWebhookEventByBlockID(typ, id string) (ret *WebhookEvent) {
+	ret = newWebhookEvent(typ)
+	if "" != id {
+		ret.IDs = append(ret.IDs, id)
+	}
+	if bt := treenode.GetBlockTree(id); nil != bt {
+		ret.Box, ret.RootID, ret.Path, ret.HPath = bt.BoxID, bt.RootID, bt.Path, bt.HPath
+	}
+	return
+}
+
+// webhookSubscribed 判断是否有启用的 Webhook 订阅了该事件，调用方可以据此避免构造事件的开销。
+func webhookSubscribed(typ string) bool {
+	return 0 < len(getSubscribedWebhooks(typ))
+}
+
+func getSubscribedWebhooks(typ string) (ret []*conf.Webhook) {
+	if nil == Conf || nil == Conf.Webhooks || !Conf.Webhooks.Enable {
+		return
+	}
+
+	for _, hook := range Conf.Webhooks.Hooks {
+		if hook.Enabled && (1 > len(hook.Events) || gulu.Str.Contains(typ, hook.Events)) {
+			ret = append(ret, hook)
+		}
+	}
+	return
+}
+
+func fireWebhookEvent(evt *WebhookEvent) {
+	hooks := getSubscribedWebhooks(evt.Type)
+	if 1 > len(hooks) {
+		return
+	}
+
+	evt.ID = ast.NewNodeID()
+	if 0 == evt.Time {
+		evt.Time = time.Now().UnixMilli()
+	}
+	for _, hook := range hooks {
+		queueWebhookDelivery(&webhookDelivery{hook: hook, evt: evt})
+	}
+}
+
+type webhookDelivery struct {
+	hook    *conf.Webhook
+	evt     *WebhookEvent
+	attempt int // 已经推送的次数
+}
+
+var (
+	// webhookDeliveries 待推送的事件，由固定数量的 worker 推送
+	// 等待重试的事件也计入 webhookPending，超过上限时直接写入死信，避免目标不可达时推送无限制地堆积
+	webhookDeliveries     = make(chan *webhookDelivery, webhookMaxPending)
+	webhookDeliveriesOnce = sync.Once{}
+	webhookPending        = atomic.Int32{}
+)
+
+func queueWebhookDelivery(delivery *webhookDelivery) {
+	webhookDeliveriesOnce.Do(func() {
+		for range webhookConcurrentLimit {
+			go func() {
+				for delivery := range webhookDeliveries {
+					deliverWebhook(delivery)
+				}
+			}()
+		}
+	})
+
+	if webhookMaxPending < webhookPending.Add(1) {
+		webhookPending.Add(-1)
+		logging.LogWarnf("webhook queue is full, dead letter event [%s, %s] to [%s]", delivery.evt.Type, delivery.evt.ID, delivery.hook.URL)
+		appendWebhookDeadLetter(newWebhookDeadLetter(delivery, errors.New("webhook queue is full")))
+		return
+	}
+	webhookDeliveries <- delivery
+}
+
+// fireTxWebhook 在事务提交后按文档推送块变更事件，数据库相关的操作由数据库事件单独推送。
+func fireTxWebhook(tx *Transaction) {
+	if !webhookSubscribed(webhookEventBlocksChanged) {
+		return
+	}
+
+	events := map[string]*WebhookEvent{}
+	for _, op := range tx.DoOperations {
+		if "" != op.AvID || strings.Contains(op.Action, "AttrView") {
+			continue
+		}
+
+		ids := op.BlockIDs
+		if "" != op.ID {
+			ids = []string{op.ID}
+		}
+		for _, id := range ids {
+			tree := tx.findChangedTree(id, op.ParentID, op.PreviousID, op.NextID)
+			if nil == tree {
+				continue
+			}
+
+			evt := events[tree.ID]
+			if nil == evt {
+				evt = newWebhookEventByTree(webhookEventBlocksChanged, tree)
+				evt.IDs = []string{}
+				evt.Data["operations"] = []map[string]string{}
+				events[tree.ID] = evt
+			}
+			if !gulu.Str.Contains(id, evt.IDs) {
+				evt.IDs = append(evt.IDs, id)
+			}
+			evt.Data["operations"] = append(evt.Data["operations"].([]map[string]string), map[string]string{"action": op.Action, "id": id})
+		}
+	}
+
+	for _, evt := range events {
+		fireWebhookEvent(evt)
+	}
+}
+
+// findChangedTree 查找块所在的文档，块已经被删除时通过其父块或者相邻块查找。
+func (tx *Transaction) findChangedTree(ids ...string) *parse.Tree {
+	for _, id := range ids {
+		if "" == id {
+			continue
+		}
+
+		for _, tree := range tx.trees {
+			if tree.ID == id || nil != treenode.GetNodeInTree(tree, id) {
+				return tree
+			}
+		}
+	}
+
+	if 1 == len(tx.trees) {
+		for _, tree := range tx.trees {
+			return tree
+		}
+	}
+	return nil
+}
+
+func deliverWebhook(delivery *webhookDelivery) {
+	defer logging.Recover()
+
+	retrying := false
+	defer func() {
+		if !retrying {
+			webhookPending.Add(-1)
+		}
+	}()
+
+	hook, evt := delivery.hook, delivery.evt
+	retries := hook.MaxRetries
+	if 1 > retries {
+		retries = webhookDefaultRetries
+	}
+
+	err := postWebhook(hook, evt)
+	delivery.attempt++
+	if nil == err {
+		return
+	}
+	logging.LogWarnf("deliver webhook [%s] event [%s, %s] failed [attempt=%d]: %s", hook.URL, evt.Type, evt.ID, delivery.attempt, err)
+
+	if delivery.attempt <= retries {
+		// 退避重试：10s、20s、40s……，最长间隔 10 分钟，等待期间不占用 worker
+		backoff := 10 * time.Second << (delivery.attempt - 1)
+		if 10*time.Minute < backoff {
+			backoff = 10 * time.Minute
+		}
+		retrying = true
+		time.AfterFunc(backoff, func() { webhookDeliveries <- delivery })
+		return
+	}
+
+	appendWebhookDeadLetter(newWebhookDeadLetter(delivery, err))
+}
+
+func newWebhookDeadLetter(delivery *webhookDelivery, err error) *WebhookDeadLetter {
+	return &WebhookDeadLetter{
+		ID:       ast.NewNodeID(),
+		HookID:   delivery.hook.ID,
+		URL:      delivery.hook.URL,
+		Event:    delivery.evt,
+		Attempts: delivery.attempt,
+		Err:      err.Error(),
+		Created:  time.Now().UnixMilli(),
+	}
+}
+
+// postWebhook 推送事件，设置了密钥时在 X-SiYuan-Signature 中携带对 "时间戳.请求体" 的 HMAC-SHA256 签名。
+func postWebhook(hook *conf.Webhook, evt *WebhookEvent) (err error) {
+	if !strings.HasPrefix(hook.URL, "http://") && !strings.HasPrefix(hook.URL, "https://") {
+		return errors.New("invalid url [" + hook.URL + "]")
+	}
+
+	body, err := gulu.JSON.MarshalJSON(evt)
+	if err != nil {
+		return
+	}
+
+	webhookConcurrent <- struct{}{}
+	defer func() { <-webhookConcurrent }()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request := httpclient.NewBrowserRequest().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-SiYuan-Event", evt.Type).
+		SetHeader("X-SiYuan-Delivery", evt.ID).
+		SetHeader("X-SiYuan-Timestamp", timestamp).
+		SetBodyBytes(body)
+	if "" != hook.Secret {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		request.SetHeader("X-SiYuan-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := request.Post(hook.URL)
+	if err != nil {
+		return
+	}
+	if 200 > resp.StatusCode || 300 <= resp.StatusCode {
+		err = errors.New("post to [" + hook.URL + "] failed: " + resp.Status)
+	}
+	return
+}
+
+func getWebhook(id string) *conf.Webhook {
+	for _, hook := range Conf.Webhooks.Hooks {
+		if id == hook.ID {
+			return hook
+		}
+	}
+	return nil
+}
+
+// SetWebhooks 校验并保存 Webhook 配置。
+func SetWebhooks(webhooks *conf.Webhooks) (err error) {
+	if nil == webhooks.Hooks {
+		webhooks.Hooks = []*conf.Webhook{}
+	}
+
+	for _, hook := range webhooks.Hooks {
+		if "" == hook.ID {
+			hook.ID = ast.NewNodeID()
+		}
+		hook.URL = strings.TrimSpace(hook.URL)
+		if !strings.HasPrefix(hook.URL, "http://") && !strings.HasPrefix(hook.URL, "https://") {
+			return errors.New("invalid url of webhook [" + hook.Name + "]")
+		}
+		if nil == hook.Events {
+			hook.Events = []string{}
+		}
+		for _, evt := range hook.Events {
+			if !gulu.Str.Contains(evt, webhookEvents) {
+				return errors.New("invalid event [" + evt + "] of webhook [" + hook.Name + "]")
+			}
+		}
+		if 0 > hook.MaxRetries {
+			hook.MaxRetries = 0
+		} else if webhookMaxRetries < hook.MaxRetries {
+			hook.MaxRetries = webhookMaxRetries
+		}
+	}
+
+	Conf.Webhooks = webhooks
+	Conf.Save()
+	return
+}
+
+// TestWebhook 向 Webhook 推送一个 ping 事件，不进行重试。
+func TestWebhook(id string) (err error) {
+	hook := getWebhook(id)
+	if nil == hook {
+		return errors.New("not found webhook [" + id + "]")
+	}
+
+	evt := newWebhookEvent(webhookEventPing)
+	evt.ID = ast.NewNodeID()
+	evt.Time = time.Now().UnixMilli()
+	return postWebhook(hook, evt)
+}
+
+func GetWebhookDeadLetters() (ret []*WebhookDeadLetter) {
+	webhookDeadLettersLock.Lock()
+	defer webhookDeadLettersLock.Unlock()
+	return getWebhookDeadLetters()
+}
+
+// RedeliverWebhookDeadLetter 重新推送一条死信，推送成功后将其移除。
+func RedeliverWebhookDeadLetter(id string) (err error) {
+	var deadLetter *WebhookDeadLetter
+	for _, letter := range GetWebhookDeadLetters() {
+		if id == letter.ID {
+			deadLetter = letter
+			break
+		}
+	}
+	if nil == deadLetter {
+		return errors.New("not found dead letter [" + id + "]")
+	}
+
+	hook := getWebhook(deadLetter.HookID)
+	if nil == hook {
+		hook = &conf.Webhook{ID: deadLetter.HookID, URL: deadLetter.URL}
+	}
+	if err = postWebhook(hook, deadLetter.Event); err != nil {
+		return
+	}
+	RemoveWebhookDeadLetters([]string{id})
+	return
+}
+
+// RemoveWebhookDeadLetters 移除死信，ids 为空时移除所有死信。
+func RemoveWebhookDeadLetters(ids []string) {
+	webhookDeadLettersLock.Lock()
+	defer webhookDeadLettersLock.Unlock()
+
+	var deadLetters []*WebhookDeadLetter
+	if 0 < len(ids) {
+		for _, letter := range getWebhookDeadLetters() {
+			if !gulu.Str.Contains(letter.ID, ids) {
+				deadLetters = append(deadLetters, letter)
+			}
+		}
+	}
+	saveWebhookDeadLetters(deadLetters)
+}
+
+func appendWebhookDeadLetter(deadLetter *WebhookDeadLetter) {
+	webhookDeadLettersLock.Lock()
+	defer webhookDeadLettersLock.Unlock()
+
+	deadLetters := append([]*WebhookDeadLetter{deadLetter}, getWebhookDeadLetters()...)
+	if webhookMaxDeadLetters < len(deadLetters) {
+		deadLetters = deadLetters[:webhookMaxDeadLetters]
+	}
+	saveWebhookDeadLetters(deadLetters)
+}
+
+func getWebhookDeadLetters() (ret []*WebhookDeadLetter) {
+	ret = []*WebhookDeadLetter{}
+	data, err := os.ReadFile(webhookDeadLettersPath())
+	if err != nil {
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal webhook dead letters failed: %s", err)
+		ret = []*WebhookDeadLetter{}
+	}
+	return
+}
+
+func saveWebhookDeadLetters(deadLetters []*WebhookDeadLetter) {
+	if nil == deadLetters {
+		deadLetters = []*WebhookDeadLetter{}
+	}
+
+	p := webhookDeadLettersPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		logging.LogErrorf("create webhook dir failed: %s", err)
+		return
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(deadLetters, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal webhook dead letters failed: %s", err)
+		return
+	}
+	if err = gulu.File.WriteFileSafer(p, data, 0644); err != nil {
+		logging.LogErrorf("write webhook dead letters failed: %s", err)
+	}
+}
+
+func webhookDeadLettersPath() string {
+	return filepath.Join(util.ConfDir, "webhook", "dead-letters.json")
+}
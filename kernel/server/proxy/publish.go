@@ -23,8 +23,10 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"time"
 
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -129,6 +131,35 @@ func rewrite(r *httputil.ProxyRequest) {
 }
 
 func (PublishServiceTransport) RoundTrip(request *http.Request) (response *http.Response, err error) {
+	// 分享链接允许匿名只读访问单个文档树，不需要 Basic 认证
+	if share, queryToken := getRequestPublishShare(request); nil != share {
+		var token string
+		if token, err = model.NewPublishShareJWT(share); err != nil {
+			logging.LogErrorf("sign publish share token failed: %s", err)
+			return
+		}
+
+		request.Header.Set(model.XAuthTokenKey, token)
+		if response, err = http.DefaultTransport.RoundTrip(request); err != nil {
+			return
+		}
+
+		if "" != queryToken {
+			// 通过链接打开后使用 Cookie 保持分享令牌，后续的接口和资源文件请求不会携带链接参数
+			cookie := &http.Cookie{
+				Name:     model.PublishShareCookieName,
+				Value:    queryToken,
+				Path:     "/",
+				HttpOnly: true,
+			}
+			if 0 < share.Expired {
+				cookie.Expires = time.UnixMilli(share.Expired)
+			}
+			response.Header.Add("Set-Cookie", cookie.String())
+		}
+		return
+	}
+
 	if model.Conf.Publish.Auth.Enable {
 		// Session Auth
 		sessionIdCookie, cookieErr := request.Cookie(model.SessionIdCookieName)
@@ -194,3 +225,18 @@ func (PublishServiceTransport) RoundTrip(request *http.Request) (response *http.
 	response, err = http.DefaultTransport.RoundTrip(request)
 	return
 }
+
+// getRequestPublishShare 从链接参数或者 Cookie 中获取分享令牌对应的分享，令牌来自链接参数时返回该令牌。
+func getRequestPublishShare(request *http.Request) (share *conf.PublishShare, queryToken string) {
+	if token := request.URL.Query().Get(model.PublishShareQueryKey); "" != token {
+		if share = model.GetPublishShareByToken(token); nil != share {
+			queryToken = token
+			return
+		}
+	}
+
+	if cookie, err := request.Cookie(model.PublishShareCookieName); nil == err {
+		share = model.GetPublishShareByToken(cookie.Value)
+	}
+	return
+}
@@ -140,6 +140,7 @@ func Serve(fastMode bool, cookieKey string) {
 		model.Recover,
 		corsMiddleware(), // 后端服务支持 CORS 预检请求验证 https://github.com/siyuan-note/siyuan/pull/5593
 		jwtMiddleware,    // 解析 JWT https://github.com/siyuan-note/siyuan/issues/11364
+		model.Audit,      // 记录写操作审计日志
 		gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedExtensions([]string{".pdf", ".mp3", ".wav", ".ogg", ".mov", ".weba", ".mkv", ".mp4", ".webm", ".flac"})),
 	)
 
@@ -302,6 +303,12 @@ func serveExport(ginServer *gin.Engine) {
 			decodedPath = filePath
 		}
 
+		if strings.HasPrefix(path.Clean("/"+filepath.ToSlash(decodedPath)), "/audit/") && !model.IsAdminRoleContext(c) {
+			// 审计日志仅管理员可以下载
+			c.Status(http.StatusForbidden)
+			return
+		}
+
 		fullPath := filepath.Join(exportBaseDir, decodedPath)
 
 		fileInfo, err := os.Stat(fullPath)
@@ -566,6 +573,11 @@ func serveAssets(ginServer *gin.Engine) {
 			}
 		}
 
+		if !model.CanReadAsset(context, relativePath) {
+			context.Status(http.StatusForbidden)
+			return
+		}
+
 		if serveThumbnail(context, p, requestPath) || serveSVG(context, p) {
 			return
 		}
@@ -969,9 +981,23 @@ func jwtMiddleware(c *gin.Context) {
 		if token.Valid {
 			claims := model.GetTokenClaims(token)
 			c.Set(model.ClaimsContextKey, claims)
-			c.Set(model.RoleContextKey, model.GetClaimRole(claims))
-			c.Next()
-			return
+			if name := model.GetClaimMember(claims); "" != name {
+				// 帐号被删除或者禁用后令牌立即失效
+				if member := model.GetMember(name); nil != member {
+					c.Set(model.RoleContextKey, model.Role(member.Role))
+					c.Set(model.MemberContextKey, member.Name)
+					c.Next()
+					return
+				}
+			} else {
+				c.Set(model.RoleContextKey, model.GetClaimRole(claims))
+				// 发布服务帐号和分享链接只能访问授权的笔记本和文档
+				if scope := model.GetClaimPublishScope(claims); nil != scope {
+					c.Set(model.PublishScopeContextKey, scope)
+				}
+				c.Next()
+				return
+			}
 		}
 	}
 	c.Set(model.RoleContextKey, model.RoleVisitor)
@@ -0,0 +1,154 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/siyuan-note/logging"
+)
+
+// Audit 描述一条审计日志。
+type Audit struct {
+	ID       string `json:"id"`
+	Created  int64  `json:"created"`  // 毫秒时间戳
+	Actor    string `json:"actor"`    // 成员帐号名，为空表示工作空间所有者
+	Role     int    `json:"role"`     // 请求时的角色
+	IP       string `json:"ip"`       // 客户端 IP
+	Endpoint string `json:"endpoint"` // API 路径
+	Action   string `json:"action"`   // 事务操作类型或者 HTTP 方法
+	Box      string `json:"box"`      // 笔记本 ID
+	RootIDs  string `json:"rootIDs"`  // 文档 ID，多个以逗号分隔
+	BlockIDs string `json:"blockIDs"` // 块 ID，多个以逗号分隔
+	Diff     string `json:"diff"`     // 精简的变更内容
+}
+
+var (
+	auditQueue     []*Audit
+	auditQueueLock = sync.Mutex{}
+	auditTxLock    = sync.Mutex{}
+)
+
+func AppendAudits(audits ...*Audit) {
+	if 1 > len(audits) {
+		return
+	}
+
+	auditQueueLock.Lock()
+	defer auditQueueLock.Unlock()
+	auditQueue = append(auditQueue, audits...)
+}
+
+func FlushAuditQueue() {
+	auditQueueLock.Lock()
+	audits := auditQueue
+	auditQueue = nil
+	auditQueueLock.Unlock()
+	if 1 > len(audits) || nil == auditDB {
+		return
+	}
+
+	auditTxLock.Lock()
+	defer auditTxLock.Unlock()
+
+	tx, err := auditDB.Begin()
+	if err != nil {
+		logging.LogErrorf("begin audit tx failed: %s", err)
+		return
+	}
+
+	stmt := "INSERT INTO audits (id, created, actor, role, ip, endpoint, action, box, root_ids, block_ids, diff) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	for _, a := range audits {
+		if _, err = tx.Exec(stmt, a.ID, a.Created, a.Actor, a.Role, a.IP, a.Endpoint, a.Action, a.Box, a.RootIDs, a.BlockIDs, a.Diff); err != nil {
+			tx.Rollback()
+			logging.LogErrorf("insert audits failed: %s", err)
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logging.LogErrorf("commit audit tx failed: %s", err)
+	}
+}
+
+// QueryAudits 按时间区间、操作者和笔记本查询审计日志，结果按时间倒序排列。
+//
+// actors 为 nil 时不过滤操作者，空字符串表示工作空间所有者；from 和 to 为 0 时不限制对应边界；pageSize 为 0 时返回全部结果。
+func QueryAudits(from, to int64, actors []string, box string, page, pageSize int) (ret []*Audit, total int) {
+	ret = []*Audit{}
+	if nil == auditDB {
+		return
+	}
+
+	var conds []string
+	var args []interface{}
+	if 0 < from {
+		conds = append(conds, "created >= ?")
+		args = append(args, from)
+	}
+	if 0 < to {
+		conds = append(conds, "created <= ?")
+		args = append(args, to)
+	}
+	if nil != actors {
+		if 1 > len(actors) {
+			return
+		}
+		conds = append(conds, "actor IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(actors)), ", ")+")")
+		for _, actor := range actors {
+			args = append(args, actor)
+		}
+	}
+	if "" != box {
+		conds = append(conds, "box = ?")
+		args = append(args, box)
+	}
+	where := ""
+	if 0 < len(conds) {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	if err := auditDB.QueryRow("SELECT COUNT(*) FROM audits"+where, args...).Scan(&total); err != nil {
+		logging.LogErrorf("query audits count failed: %s", err)
+		return
+	}
+
+	stmt := "SELECT id, created, actor, role, ip, endpoint, action, box, root_ids, block_ids, diff FROM audits" + where + " ORDER BY created DESC, rowid DESC"
+	if 0 < pageSize {
+		if 1 > page {
+			page = 1
+		}
+		stmt += " LIMIT ? OFFSET ?"
+		args = append(args, pageSize, (page-1)*pageSize)
+	}
+	rows, err := auditDB.Query(stmt, args...)
+	if err != nil {
+		logging.LogErrorf("query audits failed: %s", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a := &Audit{}
+		if err = rows.Scan(&a.ID, &a.Created, &a.Actor, &a.Role, &a.IP, &a.Endpoint, &a.Action, &a.Box, &a.RootIDs, &a.BlockIDs, &a.Diff); err != nil {
+			logging.LogErrorf("query audits failed: %s", err)
+			return
+		}
+		ret = append(ret, a)
+	}
+	return
+}
@@ -18,6 +18,7 @@ package sql
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -65,6 +66,24 @@ func RenderGroupView(attrView *av.AttributeView, view, groupView *av.View, query
 		groupView.Kanban.FitImage = view.Kanban.FitImage
 		groupView.Kanban.DisplayFieldName = view.Kanban.DisplayFieldName
 		groupView.Kanban.FillColBackgroundColor = view.Kanban.FillColBackgroundColor
+	case av.LayoutTypeCalendar:
+		err = copier.CopyWithOption(&groupView.Calendar.Fields, &view.Calendar.Fields, copier.Option{DeepCopy: true})
+		groupView.Calendar.ShowIcon = view.Calendar.ShowIcon
+		groupView.Calendar.WrapField = view.Calendar.WrapField
+
+		groupView.Calendar.DateKeyID = view.Calendar.DateKeyID
+		groupView.Calendar.Mode = view.Calendar.Mode
+		groupView.Calendar.StartWeekday = view.Calendar.StartWeekday
+		groupView.Calendar.Current = view.Calendar.Current
+		groupView.Calendar.DisplayFieldName = view.Calendar.DisplayFieldName
+	case av.LayoutTypeTimeline:
+		err = copier.CopyWithOption(&groupView.Timeline.Fields, &view.Timeline.Fields, copier.Option{DeepCopy: true})
+		groupView.Timeline.ShowIcon = view.Timeline.ShowIcon
+		groupView.Timeline.WrapField = view.Timeline.WrapField
+
+		groupView.Timeline.DateKeyID = view.Timeline.DateKeyID
+		groupView.Timeline.Scale = view.Timeline.Scale
+		groupView.Timeline.DisplayFieldName = view.Timeline.DisplayFieldName
 	}
 	if nil != err {
 		logging.LogErrorf("copy view fields [%s] to group [%s] failed: %s", view.ID, groupView.ID, err)
@@ -75,6 +94,10 @@ func RenderGroupView(attrView *av.AttributeView, view, groupView *av.View, query
 			groupView.Gallery.CardFields = view.Gallery.CardFields
 		case av.LayoutTypeKanban:
 			groupView.Kanban.Fields = view.Kanban.Fields
+		case av.LayoutTypeCalendar:
+			groupView.Calendar.Fields = view.Calendar.Fields
+		case av.LayoutTypeTimeline:
+			groupView.Timeline.Fields = view.Timeline.Fields
 		}
 	}
 
@@ -107,6 +130,10 @@ func renderView(attrView *av.AttributeView, view *av.View, query string, depth *
 		ret = RenderAttributeViewGallery(attrView, view, query, depth, cachedAttrViews)
 	case av.LayoutTypeKanban:
 		ret = RenderAttributeViewKanban(attrView, view, query, depth, cachedAttrViews)
+	case av.LayoutTypeCalendar:
+		ret = RenderAttributeViewCalendar(attrView, view, query, depth, cachedAttrViews)
+	case av.LayoutTypeTimeline:
+		ret = RenderAttributeViewTimeline(attrView, view, query, depth, cachedAttrViews)
 	}
 	return
 }
@@ -348,6 +375,8 @@ func fillAttributeViewBaseValue(baseValue *av.BaseValue, fieldID, itemID string,
 		baseValue.Value = &av.Value{ID: baseValue.ID, KeyID: fieldID, BlockID: itemID, Type: av.KeyTypeCreated}
 	case av.KeyTypeUpdated: // 填充更新时间字段值，后面再渲染
 		baseValue.Value = &av.Value{ID: baseValue.ID, KeyID: fieldID, BlockID: itemID, Type: av.KeyTypeUpdated}
+	case av.KeyTypeFormula: // 填充公式字段值，后面再计算
+		baseValue.Value = &av.Value{ID: baseValue.ID, KeyID: fieldID, BlockID: itemID, Type: av.KeyTypeFormula, Formula: &av.ValueFormula{}}
 	}
 
 	if nil == baseValue.Value {
@@ -503,7 +532,7 @@ func fillAttributeViewAutoGeneratedValues(attrView *av.AttributeView, collection
 
 		isSameAv := destAv.ID == attrView.ID
 		var furtherCollection av.Collection
-		if av.KeyTypeTemplate == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
+		if av.KeyTypeTemplate == destKey.Type || av.KeyTypeFormula == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
 			viewable := renderView(destAv, destAv.Views[0], "", depth, cachedAttrViews)
 			if nil != viewable {
 				furtherCollection = viewable.(av.Collection)
@@ -590,7 +619,7 @@ func GetFurtherCollections(attrView *av.AttributeView, cachedAttrViews map[strin
 		isSameAv := destAv.ID == attrView.ID
 
 		var furtherCollection av.Collection
-		if av.KeyTypeTemplate == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
+		if av.KeyTypeTemplate == destKey.Type || av.KeyTypeFormula == destKey.Type || (!isSameAv && (av.KeyTypeUpdated == destKey.Type || av.KeyTypeCreated == destKey.Type || av.KeyTypeRelation == destKey.Type)) {
 			viewable := RenderView(destAv, destAv.Views[0], "")
 			if nil != viewable {
 				furtherCollection = viewable.(av.Collection)
@@ -649,6 +678,99 @@ func fillAttributeViewTemplateValues(attrView *av.AttributeView, view *av.View,
 	return
 }
 
+func fillAttributeViewFormulaValues(attrView *av.AttributeView, collection av.Collection) {
+	formulas := map[string]*av.Formula{}
+	formulaErrs := map[string]error{}
+	keys := map[string]*av.Key{}
+	for _, kVals := range attrView.KeyValues {
+		if _, exist := keys[kVals.Key.Name]; !exist {
+			keys[kVals.Key.Name] = kVals.Key
+		}
+
+		if av.KeyTypeFormula != kVals.Key.Type || "" == strings.TrimSpace(kVals.Key.Formula) {
+			continue
+		}
+
+		formula, parseErr := av.ParseFormula(kVals.Key.Formula)
+		if nil != parseErr {
+			formulaErrs[kVals.Key.ID] = parseErr
+			continue
+		}
+		formulas[kVals.Key.ID] = formula
+	}
+	if 1 > len(formulas) && 1 > len(formulaErrs) {
+		return
+	}
+
+	for _, item := range collection.GetItems() {
+		results := map[string]*av.ValueFormula{}
+		evaluating := map[string]bool{}
+
+		var evalFormula func(key *av.Key) *av.ValueFormula
+		evalFormula = func(key *av.Key) (ret *av.ValueFormula) {
+			if ret = results[key.ID]; nil != ret {
+				return
+			}
+
+			ret = &av.ValueFormula{}
+			defer func() { results[key.ID] = ret }()
+			if parseErr := formulaErrs[key.ID]; nil != parseErr {
+				ret.Error = parseErr.Error()
+				return
+			}
+			formula := formulas[key.ID]
+			if nil == formula {
+				return
+			}
+			if evaluating[key.ID] {
+				ret.Error = fmt.Sprintf("circular reference of field [%s]", key.Name)
+				return
+			}
+
+			evaluating[key.ID] = true
+			defer delete(evaluating, key.ID)
+
+			content, evalErr := formula.Eval(func(name string) (*av.Value, error) {
+				propKey := keys[name]
+				if nil == propKey {
+					return nil, fmt.Errorf("field [%s] not found", name)
+				}
+
+				if av.KeyTypeFormula == propKey.Type {
+					result := evalFormula(propKey)
+					if "" != result.Error {
+						return nil, errors.New(result.Error)
+					}
+					return &av.Value{Type: av.KeyTypeFormula, Formula: result}, nil
+				}
+
+				if value := item.GetValue(propKey.ID); nil != value {
+					return value, nil
+				}
+				return attrView.GetValue(propKey.ID, item.GetID()), nil
+			}, key.NumberFormat)
+			if nil != evalErr {
+				ret.Error = evalErr.Error()
+				return
+			}
+			ret.Content = content
+			return
+		}
+
+		for _, value := range item.GetValues() {
+			if av.KeyTypeFormula != value.Type {
+				continue
+			}
+
+			key, _ := attrView.GetKey(value.KeyID)
+			if nil == key {
+				continue
+			}
+			value.Formula = evalFormula(key)
+		}
+	}
+}
+
 func fillAttributeViewKeyValues(attrView *av.AttributeView, collection av.Collection) {
 	fieldValues := map[string][]*av.Value{}
 	for _, item := range collection.GetItems() {
@@ -738,6 +860,10 @@ func FillAttributeViewNilValue(value *av.Value, typ av.KeyType) {
 		if nil == value.Rollup {
 			value.Rollup = &av.ValueRollup{}
 		}
+	case av.KeyTypeFormula:
+		if nil == value.Formula {
+			value.Formula = &av.ValueFormula{}
+		}
 	}
 }
 
@@ -793,6 +919,26 @@ func removeMissingField(attrView *av.AttributeView, view *av.View, missingKeyID
 		}
 	}
 
+	if nil != view.Calendar {
+		for i, calendarField := range view.Calendar.Fields {
+			if calendarField.ID == missingKeyID {
+				view.Calendar.Fields = append(view.Calendar.Fields[:i], view.Calendar.Fields[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
+	if nil != view.Timeline {
+		for i, timelineField := range view.Timeline.Fields {
+			if timelineField.ID == missingKeyID {
+				view.Timeline.Fields = append(view.Timeline.Fields[:i], view.Timeline.Fields[i+1:]...)
+				changed = true
+				break
+			}
+		}
+	}
+
 	if changed {
 		av.SaveAttributeView(attrView)
 	}
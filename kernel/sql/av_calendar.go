@@ -0,0 +1,154 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"fmt"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func RenderAttributeViewCalendar(attrView *av.AttributeView, view *av.View, query string, depth *int, cachedAttrViews map[string]*av.AttributeView) (ret *av.Calendar) {
+	viewable := attrView.RenderedViewables[view.ID]
+	if nil != viewable {
+		ret = viewable.(*av.Calendar)
+		return
+	}
+
+	ret = &av.Calendar{
+		BaseInstance:     av.NewViewBaseInstance(view),
+		DateKeyID:        view.Calendar.DateKeyID,
+		Mode:             view.Calendar.Mode,
+		StartWeekday:     view.Calendar.StartWeekday,
+		Current:          view.Calendar.Current,
+		DisplayFieldName: view.Calendar.DisplayFieldName,
+		Fields:           []*av.CalendarField{},
+		Cards:            []*av.CalendarCard{},
+	}
+
+	// 组装字段
+	for _, field := range view.Calendar.Fields {
+		key, getErr := attrView.GetKey(field.ID)
+		if nil != getErr {
+			// 找不到字段则在视图中删除
+			removeMissingField(attrView, view, field.ID)
+			continue
+		}
+
+		ret.Fields = append(ret.Fields, &av.CalendarField{
+			BaseInstanceField: &av.BaseInstanceField{
+				ID:           key.ID,
+				Name:         key.Name,
+				Type:         key.Type,
+				Icon:         key.Icon,
+				Wrap:         field.Wrap,
+				Hidden:       field.Hidden,
+				Desc:         key.Desc,
+				Calc:         field.Calc,
+				Options:      key.Options,
+				NumberFormat: key.NumberFormat,
+				Template:     key.Template,
+				Formula:      key.Formula,
+				Relation:     key.Relation,
+				Rollup:       key.Rollup,
+				Date:         key.Date,
+				Created:      key.Created,
+				Updated:      key.Updated,
+			},
+		})
+	}
+
+	cardsValues := generateAttrViewItems(attrView, view) // 生成卡片
+	filterNotFoundAttrViewItems(cardsValues)             // 过滤掉不存在的卡片
+
+	// 生成卡片字段值
+	for cardID, cardValues := range cardsValues {
+		var calendarCard av.CalendarCard
+		for _, field := range ret.Fields {
+			var fieldValue *av.CalendarFieldValue
+			for _, keyValues := range cardValues {
+				if keyValues.Key.ID == field.ID {
+					fieldValue = &av.CalendarFieldValue{
+						BaseValue: &av.BaseValue{
+							ID:        keyValues.Values[0].ID,
+							Value:     keyValues.Values[0],
+							ValueType: field.Type,
+						},
+					}
+					break
+				}
+			}
+			if nil == fieldValue {
+				fieldValue = &av.CalendarFieldValue{
+					BaseValue: &av.BaseValue{
+						ID:        cardID[:14] + ast.NewNodeID()[14:],
+						ValueType: field.Type,
+					},
+				}
+			}
+			calendarCard.ID = cardID
+
+			filedDateIsTime := false
+			if nil != field.Date {
+				filedDateIsTime = field.Date.FillSpecificTime
+			}
+			fillAttributeViewBaseValue(fieldValue.BaseValue, field.ID, cardID, field.NumberFormat, field.Template, filedDateIsTime)
+			calendarCard.Values = append(calendarCard.Values, fieldValue)
+		}
+		ret.Cards = append(ret.Cards, &calendarCard)
+	}
+
+	// 回填补全数据
+	fillAttributeViewKeyValues(attrView, ret)
+
+	// 批量获取块属性以提升性能
+	var ialIDs []string
+	for _, card := range ret.Cards {
+		blockVal := card.GetBlockValue()
+		if nil != blockVal && !blockVal.IsDetached {
+			ialIDs = append(ialIDs, blockVal.Block.ID)
+		}
+	}
+	ials := BatchGetBlockAttrs(ialIDs)
+
+	// 渲染自动生成的字段值，比如关联、汇总、创建时间和更新时间
+	fillAttributeViewAutoGeneratedValues(attrView, ret, ials, depth, cachedAttrViews)
+
+	// 最后渲染模板字段，这样模板就可以使用汇总、关联、创建时间和更新时间的值了
+	renderTemplateErr := fillAttributeViewTemplateValues(attrView, view, ret, ials)
+	if nil != renderTemplateErr {
+		util.PushErrMsg(fmt.Sprintf(util.Langs[util.Lang][44], util.EscapeHTML(renderTemplateErr.Error())), 30000)
+	}
+
+	// 计算公式字段，公式可以引用包括模板在内的其他字段的值
+	fillAttributeViewFormulaValues(attrView, ret)
+
+	// 使用日期字段设置卡片的开始和结束时间
+	for _, card := range ret.Cards {
+		dateValue := card.GetValue(ret.DateKeyID)
+		if nil == dateValue {
+			dateValue = attrView.GetValue(ret.DateKeyID, card.ID)
+		}
+		card.SetDate(dateValue)
+	}
+
+	filterByQuery(query, ret)
+	manualSort(view, ret)
+	return
+}
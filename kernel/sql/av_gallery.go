@@ -59,6 +59,7 @@ func RenderAttributeViewGallery(attrView *av.AttributeView, view *av.View, query
 				Options:      key.Options,
 				NumberFormat: key.NumberFormat,
 				Template:     key.Template,
+				Formula:      key.Formula,
 				Relation:     key.Relation,
 				Rollup:       key.Rollup,
 				Date:         key.Date,
@@ -137,6 +138,9 @@ func RenderAttributeViewGallery(attrView *av.AttributeView, view *av.View, query
 		util.PushErrMsg(fmt.Sprintf(util.Langs[util.Lang][44], util.EscapeHTML(renderTemplateErr.Error())), 30000)
 	}
 
+	// 计算公式字段，公式可以引用包括模板在内的其他字段的值
+	fillAttributeViewFormulaValues(attrView, ret)
+
 	filterByQuery(query, ret)
 	manualSort(view, ret)
 	return
@@ -0,0 +1,225 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+// 数据库（属性视图）的条目和字段值按照范式保存在 av_items 和 av_values 表中，以便通过 SQL 和块进行联合查询，
+// 例如查询某个数据库中状态为进行中的条目绑定的块：
+//   SELECT b.* FROM blocks b JOIN av_values v ON v.block_id = b.id WHERE v.av_id = '...' AND v.key_name = '状态' AND v.content = '进行中'
+// 仅保存持久化的值，模板、汇总、公式、创建时间和更新时间等渲染时计算的字段不会保存。
+
+type AvItem struct {
+	ID         string // 条目 ID
+	AvID       string
+	AvName     string
+	BlockID    string // 绑定的块 ID，非绑定条目为空
+	IsDetached bool
+	Content    string // 主键内容
+	Created    int64
+	Updated    int64
+}
+
+type AvValue struct {
+	ID      string
+	AvID    string
+	ItemID  string
+	BlockID string // 条目绑定的块 ID，非绑定条目为空
+	KeyID   string
+	KeyName string
+	KeyType string
+	Content string      // 文本形式的值
+	Num     interface{} // 数字、日期（毫秒时间戳）和复选框（0/1）的值，其他类型为 NULL
+	Created int64
+	Updated int64
+}
+
+const (
+	AvItemsPlaceholder  = "(?, ?, ?, ?, ?, ?, ?, ?)"
+	AvValuesPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+func initAttributeViewTables() {
+	stmts := []string{
+		"CREATE TABLE IF NOT EXISTS av_items (id, av_id, av_name, block_id, is_detached, content, created, updated)",
+		"CREATE INDEX IF NOT EXISTS idx_av_items_av_id ON av_items(av_id)",
+		"CREATE INDEX IF NOT EXISTS idx_av_items_block_id ON av_items(block_id)",
+		"CREATE TABLE IF NOT EXISTS av_values (id, av_id, item_id, block_id, key_id, key_name, key_type, content, num, created, updated)",
+		"CREATE INDEX IF NOT EXISTS idx_av_values_av_id ON av_values(av_id)",
+		"CREATE INDEX IF NOT EXISTS idx_av_values_item_id ON av_values(item_id)",
+		"CREATE INDEX IF NOT EXISTS idx_av_values_block_id ON av_values(block_id)",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "exec [%s] failed: %s", stmt, err)
+		}
+	}
+}
+
+func indexAttributeView(tx *sql.Tx, avID string) (err error) {
+	if err = deleteAttributeView(tx, avID); err != nil {
+		return
+	}
+
+	attrView, parseErr := av.ParseAttributeView(avID)
+	if nil == attrView {
+		if nil != parseErr && !errors.Is(parseErr, av.ErrViewNotFound) { // 数据库已经被删除时只需要删除索引
+			logging.LogWarnf("parse attribute view [%s] failed: %s", avID, parseErr)
+		}
+		return
+	}
+
+	items, values := attributeViewRows(attrView)
+	if err = insertAvItems(tx, items); err != nil {
+		return
+	}
+	err = insertAvValues(tx, values)
+	return
+}
+
+func attributeViewRows(attrView *av.AttributeView) (items []*AvItem, values []*AvValue) {
+	boundBlockIDs := map[string]string{}
+	blockKeyValues := attrView.GetBlockKeyValues()
+	if nil == blockKeyValues {
+		return
+	}
+
+	for _, v := range blockKeyValues.Values {
+		item := &AvItem{
+			ID:         v.BlockID,
+			AvID:       attrView.ID,
+			AvName:     attrView.Name,
+			IsDetached: v.IsDetached,
+			Created:    v.CreatedAt,
+			Updated:    v.UpdatedAt,
+		}
+		if nil != v.Block {
+			item.Content = strings.TrimSpace(v.Block.Content)
+			if !v.IsDetached {
+				item.BlockID = v.Block.ID
+			}
+		}
+		boundBlockIDs[item.ID] = item.BlockID
+		items = append(items, item)
+	}
+
+	for _, kv := range attrView.KeyValues {
+		switch kv.Key.Type {
+		case av.KeyTypeTemplate, av.KeyTypeRollup, av.KeyTypeFormula, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeLineNumber:
+			continue
+		}
+
+		for _, v := range kv.Values {
+			boundBlockID, ok := boundBlockIDs[v.BlockID]
+			if !ok {
+				// 值对应的条目已经不存在
+				continue
+			}
+
+			value := &AvValue{
+				ID:      v.ID,
+				AvID:    attrView.ID,
+				ItemID:  v.BlockID,
+				BlockID: boundBlockID,
+				KeyID:   kv.Key.ID,
+				KeyName: kv.Key.Name,
+				KeyType: string(kv.Key.Type),
+				Created: v.CreatedAt,
+				Updated: v.UpdatedAt,
+			}
+
+			v.Type = kv.Key.Type
+			switch kv.Key.Type {
+			case av.KeyTypeNumber:
+				value.Content = v.String(false)
+				if nil != v.Number && v.Number.IsNotEmpty {
+					value.Num = v.Number.Content
+				}
+			case av.KeyTypeDate:
+				value.Content = v.String(false)
+				if nil != v.Date && v.Date.IsNotEmpty {
+					value.Num = v.Date.Content
+				}
+			case av.KeyTypeCheckbox:
+				value.Content = v.String(false)
+				value.Num = 0
+				if nil != v.Checkbox && v.Checkbox.Checked {
+					value.Num = 1
+				}
+			case av.KeyTypeRelation:
+				// 关联字段保存关联的条目 ID，可以和 av_items 表连接查询
+				if nil != v.Relation {
+					value.Content = strings.Join(v.Relation.BlockIDs, ",")
+				}
+			default:
+				value.Content = v.String(false)
+			}
+			values = append(values, value)
+		}
+	}
+	return
+}
+
+func deleteAttributeView(tx *sql.Tx, avID string) (err error) {
+	if err = execStmtTx(tx, "DELETE FROM av_items WHERE av_id = ?", avID); err != nil {
+		return
+	}
+	err = execStmtTx(tx, "DELETE FROM av_values WHERE av_id = ?", avID)
+	return
+}
+
+func insertAvItems(tx *sql.Tx, items []*AvItem) (err error) {
+	for i := 0; i < len(items); i += 512 {
+		bulk := items[i:min(i+512, len(items))]
+		valueStrings := make([]string, 0, len(bulk))
+		valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(AvItemsPlaceholder, "?"))
+		for _, item := range bulk {
+			valueStrings = append(valueStrings, AvItemsPlaceholder)
+			valueArgs = append(valueArgs, item.ID, item.AvID, item.AvName, item.BlockID, item.IsDetached, item.Content, item.Created, item.Updated)
+		}
+		stmt := fmt.Sprintf("INSERT INTO av_items (id, av_id, av_name, block_id, is_detached, content, created, updated) VALUES %s", strings.Join(valueStrings, ","))
+		if err = prepareExecInsertTx(tx, stmt, valueArgs); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func insertAvValues(tx *sql.Tx, values []*AvValue) (err error) {
+	for i := 0; i < len(values); i += 512 {
+		bulk := values[i:min(i+512, len(values))]
+		valueStrings := make([]string, 0, len(bulk))
+		valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(AvValuesPlaceholder, "?"))
+		for _, value := range bulk {
+			valueStrings = append(valueStrings, AvValuesPlaceholder)
+			valueArgs = append(valueArgs, value.ID, value.AvID, value.ItemID, value.BlockID, value.KeyID, value.KeyName, value.KeyType, value.Content, value.Num, value.Created, value.Updated)
+		}
+		stmt := fmt.Sprintf("INSERT INTO av_values (id, av_id, item_id, block_id, key_id, key_name, key_type, content, num, created, updated) VALUES %s", strings.Join(valueStrings, ","))
+		if err = prepareExecInsertTx(tx, stmt, valueArgs); err != nil {
+			return
+		}
+	}
+	return
+}
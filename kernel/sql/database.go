@@ -50,6 +50,7 @@ import (
 var (
 	db             *sql.DB
 	historyDB      *sql.DB
+	auditDB        *sql.DB
 	assetContentDB *sql.DB
 )
 
@@ -88,6 +89,8 @@ func InitDatabase(forceRebuild bool) (err error) {
 	if !forceRebuild {
 		// 检查数据库结构版本，如果版本不一致的话说明改过表结构，需要重建
 		if util.DatabaseVer == getDatabaseVer() {
+			// 数据库（属性视图）表是后来加入的，已有的库直接补建
+			initAttributeViewTables()
 			return
 		}
 		logging.LogInfof("the database structure is changed, rebuilding database...")
@@ -224,6 +227,16 @@ func initDBTables() {
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "create table [refs] failed: %s", err)
 	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS av_items")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "drop table [av_items] failed: %s", err)
+	}
+	_, err = db.Exec("DROP TABLE IF EXISTS av_values")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "drop table [av_values] failed: %s", err)
+	}
+	initAttributeViewTables()
 }
 
 func initDBConnection() {
@@ -308,6 +321,53 @@ func initHistoryDBTables() {
 	}
 }
 
+var initAuditDatabaseLock = sync.Mutex{}
+
+// InitAuditDatabase 初始化审计日志数据库，审计日志只追加不重建。
+func InitAuditDatabase() {
+	initAuditDatabaseLock.Lock()
+	defer initAuditDatabaseLock.Unlock()
+
+	initAuditDBConnection()
+	initAuditDBTables()
+}
+
+func initAuditDBConnection() {
+	if nil != auditDB {
+		auditDB.Close()
+	}
+
+	util.LogDatabaseSize(util.AuditDBPath)
+	dsn := util.AuditDBPath + "?_journal_mode=WAL" +
+		"&_synchronous=NORMAL" +
+		"&_cache_size=-10240" +
+		"&_busy_timeout=7000" +
+		"&_temp_store=MEMORY" +
+		"&_case_sensitive_like=OFF"
+	var err error
+	auditDB, err = sql.Open("sqlite3_extended", dsn)
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "create audit database failed: %s", err)
+	}
+	auditDB.SetMaxIdleConns(1)
+	auditDB.SetMaxOpenConns(1)
+	auditDB.SetConnMaxLifetime(365 * 24 * time.Hour)
+}
+
+func initAuditDBTables() {
+	_, err := auditDB.Exec("CREATE TABLE IF NOT EXISTS audits (id, created INTEGER, actor, role INTEGER, ip, endpoint, action, box, root_ids, block_ids, diff)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeUnavailableDatabase, "create table [audits] failed: %s", err)
+	}
+	auditDB.Exec("CREATE INDEX IF NOT EXISTS idx_audits_created ON audits(created)")
+	auditDB.Exec("CREATE INDEX IF NOT EXISTS idx_audits_actor ON audits(actor)")
+	auditDB.Exec("CREATE INDEX IF NOT EXISTS idx_audits_box ON audits(box)")
+
+	// 审计日志只允许追加，禁止修改和删除
+	auditDB.Exec("CREATE TRIGGER IF NOT EXISTS audits_no_update BEFORE UPDATE ON audits BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END")
+	auditDB.Exec("CREATE TRIGGER IF NOT EXISTS audits_no_delete BEFORE DELETE ON audits BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END")
+}
+
 var initAssetContentDatabaseLock = sync.Mutex{}
 
 func InitAssetContentDatabase(forceRebuild bool) {
@@ -1314,6 +1374,12 @@ func CloseDatabase() {
 		logging.LogErrorf("close asset content database failed: %s", err)
 		return
 	}
+	if nil != auditDB {
+		if err := auditDB.Close(); err != nil {
+			logging.LogErrorf("close audit database failed: %s", err)
+			return
+		}
+	}
 	treenode.CloseDatabase()
 	logging.LogInfof("closed database")
 }
@@ -1565,6 +1631,7 @@ func removeDatabaseFile() (err error) {
 }
 
 func closeDatabase() (err error) {
+	closeReadonlyDatabase()
 	if nil == db {
 		return
 	}
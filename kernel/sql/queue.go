@@ -43,7 +43,7 @@ var (
 
 type dbQueueOperation struct {
 	inQueueTime                   time.Time
-	action                        string      // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets
+	action                        string      // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets/index_av/delete_av
 	indexTree                     *parse.Tree // index
 	upsertTree                    *parse.Tree // upsert/update_refs/delete_refs
 	removeTreeBox, removeTreePath string      // delete
@@ -52,7 +52,7 @@ type dbQueueOperation struct {
 	box                           string      // delete_box/delete_box_refs/index
 	renameTree                    *parse.Tree // rename/rename_sub_tree
 	block                         *Block      // update_block_content
-	id                            string      // index_node
+	id                            string      // index_node/index_av/delete_av
 	removeAssetHashes             []string    // delete_assets
 }
 
@@ -227,6 +227,10 @@ func execOp(op *dbQueueOperation, tx *sql.Tx, context map[string]interface{}) (e
 		err = deleteAssetsByHashes(tx, op.removeAssetHashes)
 	case "index_node":
 		err = indexNode(tx, op.id)
+	case "index_av":
+		err = indexAttributeView(tx, op.id)
+	case "delete_av":
+		err = deleteAttributeView(tx, op.id)
 	default:
 		msg := fmt.Sprintf("unknown operation [%s]", op.action)
 		logging.LogErrorf(msg)
@@ -249,6 +253,34 @@ func IndexNodeQueue(id string) {
 	appendOperation(newOp)
 }
 
+func IndexAttributeViewQueue(avID string) {
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{id: avID, inQueueTime: time.Now(), action: "index_av"}
+	for i, op := range operationQueue {
+		if ("index_av" == op.action || "delete_av" == op.action) && op.id == avID {
+			operationQueue[i] = newOp
+			return
+		}
+	}
+	appendOperation(newOp)
+}
+
+func RemoveAttributeViewQueue(avID string) {
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{id: avID, inQueueTime: time.Now(), action: "delete_av"}
+	for i, op := range operationQueue {
+		if ("index_av" == op.action || "delete_av" == op.action) && op.id == avID {
+			operationQueue[i] = newOp
+			return
+		}
+	}
+	appendOperation(newOp)
+}
+
 func BatchRemoveAssetsQueue(hashes []string) {
 	if 1 > len(hashes) {
 		return
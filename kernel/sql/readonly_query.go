@@ -0,0 +1,633 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	sqlparser2 "github.com/rqlite/sql"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 只读查询使用单独的连接池，连接上注册授权回调，只允许 SELECT 读取，拒绝写入、ATTACH 和 PRAGMA 等操作。
+// 受访问控制约束的查询使用另一个连接池：main 为空的内存数据库，数据库文件以随机 schema 名附加，
+// 授权回调只允许读取该 schema 下的表，语句只能通过过滤后的同名公用表表达式访问数据
+
+const (
+	sqliteRecursive = 33 // SQLITE_RECURSIVE，驱动中未导出
+
+	ReadonlyQueryDefaultTimeout = 10 * time.Second
+	ReadonlyQueryMaxTimeout     = 60 * time.Second
+)
+
+var (
+	roDB     *sql.DB
+	roACLDB  *sql.DB
+	roDBLock = sync.Mutex{}
+
+	// 受访问控制约束时附加数据库文件使用的 schema 名，每次启动随机生成
+	readonlyACLSchema = newReadonlyACLSchema()
+
+	// 连接上当前执行的查询的访问策略
+	roPolicies     = map[*sqlite3.SQLiteConn]*readonlyPolicy{}
+	roPoliciesLock = sync.Mutex{}
+
+	// 正在执行的查询，用于取消
+	roQueries     = map[string]context.CancelFunc{}
+	roQueriesLock = sync.Mutex{}
+)
+
+type readonlyPolicy struct {
+	readable func(box, p string) bool // 为空时不受访问控制约束
+}
+
+// 受访问控制约束时可以查询的表，值为判断文档可见性使用的路径字段
+var readonlyACLTables = map[string]string{
+	"blocks":               "path",
+	"spans":                "path",
+	"assets":               "docpath",
+	"attributes":           "path",
+	"refs":                 "path",
+	"file_annotation_refs": "path",
+}
+
+var readonlyACLTableNames = []string{"blocks", "spans", "assets", "attributes", "refs", "file_annotation_refs"}
+
+// 受访问控制约束时还可以读取的表，表值函数初始化时会读取 sqlite_master
+var readonlyExtraTables = map[string]bool{
+	"json_each":     true,
+	"json_tree":     true,
+	"sqlite_master": true,
+}
+
+func init() {
+	sql.Register("sqlite3_readonly", newReadonlyDriver(false))
+	sql.Register("sqlite3_readonly_acl", newReadonlyDriver(true))
+}
+
+func newReadonlyDriver(restricted bool) *sqlite3.SQLiteDriver {
+	regex := func(re, s string) (bool, error) {
+		re = strings.ReplaceAll(re, "\\\\", "\\")
+		return regexp.MatchString(re, s)
+	}
+
+	return &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if restricted {
+				// 在注册授权回调之前附加数据库文件，并在内存数据库中创建同名空表，
+				// 未限定 schema 的表名会先解析到 main 中，而授权回调不允许读取 main 中的这些表
+				stmts := []string{"PRAGMA query_only = 0", "ATTACH DATABASE ? AS " + readonlyACLSchema}
+				for _, table := range readonlyACLTableNames {
+					stmts = append(stmts, "CREATE TABLE main."+table+" (id)")
+				}
+				stmts = append(stmts, "PRAGMA query_only = 1")
+				for _, stmt := range stmts {
+					var args []driver.Value
+					if strings.HasPrefix(stmt, "ATTACH") {
+						args = append(args, util.DBPath)
+					}
+					if _, err := conn.Exec(stmt, args); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := conn.RegisterFunc("regexp", regex, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("siyuan_readable", func(box, p string) bool {
+				policy := getReadonlyPolicy(conn)
+				return nil != policy && (nil == policy.readable || policy.readable(box, p))
+			}, false); err != nil {
+				return err
+			}
+			conn.RegisterAuthorizer(func(op int, arg1, arg2, arg3 string) int {
+				policy := getReadonlyPolicy(conn)
+				if restricted && (nil == policy || nil == policy.readable) {
+					return sqlite3.SQLITE_DENY
+				}
+				return authorizeReadonly(policy, op, arg1, arg3)
+			})
+			return nil
+		},
+	}
+}
+
+// authorizeReadonly 授权回调，table 为操作的表，schema 为表所在的数据库名。
+func authorizeReadonly(policy *readonlyPolicy, op int, table, schema string) int {
+	table = strings.ToLower(table)
+	switch op {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	case sqlite3.SQLITE_UPDATE:
+		// 表值函数（比如 json_each）初始化时会触发，连接已经设置了 query_only，不会真正写入
+		if "sqlite_master" == table && readonlyACLSchema != schema {
+			return sqlite3.SQLITE_OK
+		}
+		return sqlite3.SQLITE_DENY
+	case sqlite3.SQLITE_READ:
+		if nil == policy || nil == policy.readable {
+			return sqlite3.SQLITE_OK
+		}
+
+		// 数据只能从附加的数据库文件中读取，其他 schema 下（内存数据库）的表不包含数据
+		if readonlyACLSchema == schema {
+			if _, ok := readonlyACLTables[table]; ok {
+				return sqlite3.SQLITE_OK
+			}
+			return sqlite3.SQLITE_DENY
+		}
+		if readonlyExtraTables[table] {
+			return sqlite3.SQLITE_OK
+		}
+		return sqlite3.SQLITE_DENY
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+func newReadonlyACLSchema() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return "siyuan_" + hex.EncodeToString(buf)
+}
+
+func getReadonlyPolicy(conn *sqlite3.SQLiteConn) *readonlyPolicy {
+	roPoliciesLock.Lock()
+	defer roPoliciesLock.Unlock()
+	return roPolicies[conn]
+}
+
+func getReadonlyDB(restricted bool) (ret *sql.DB, err error) {
+	roDBLock.Lock()
+	defer roDBLock.Unlock()
+
+	if restricted {
+		if nil == roACLDB {
+			roACLDB, err = openReadonlyDatabase("sqlite3_readonly_acl", ":memory:")
+		}
+		return roACLDB, err
+	}
+
+	if nil == roDB {
+		roDB, err = openReadonlyDatabase("sqlite3_readonly", util.DBPath)
+	}
+	return roDB, err
+}
+
+func openReadonlyDatabase(driverName, dbPath string) (ret *sql.DB, err error) {
+	dsn := dbPath + "?_query_only=true" +
+		"&_busy_timeout=7000" +
+		"&_case_sensitive_like=OFF"
+	ret, err = sql.Open(driverName, dsn)
+	if err != nil {
+		logging.LogErrorf("create readonly database failed: %s", err)
+		return
+	}
+	ret.SetMaxIdleConns(4)
+	ret.SetMaxOpenConns(4)
+	ret.SetConnMaxLifetime(365 * 24 * time.Hour)
+	return
+}
+
+func closeReadonlyDatabase() {
+	roDBLock.Lock()
+	defer roDBLock.Unlock()
+
+	for _, database := range []*sql.DB{roDB, roACLDB} {
+		if nil == database {
+			continue
+		}
+
+		if err := database.Close(); err != nil {
+			logging.LogErrorf("close readonly database failed: %s", err)
+		}
+	}
+	roDB, roACLDB = nil, nil
+}
+
+type ReadonlyQuery struct {
+	ID       string                   // 查询 ID，用于取消查询，可以为空
+	Session  string                   // 发起查询的会话，查询 ID 仅在会话内有效，其他会话无法取消
+	Stmt     string                   // 单条 SELECT 语句
+	Params   map[string]interface{}   // 命名参数，语句中使用 :name、@name 或者 $name 引用
+	Timeout  time.Duration            // 超时时间
+	Limit    int                      // 每页条数
+	Keyset   []string                 // 游标分页使用的列，这些列的组合需要唯一且非空
+	Desc     bool                     // 游标分页是否降序
+	Cursor   string                   // 上一页返回的游标
+	Explain  bool                     // 仅返回查询计划
+	Readable func(box, p string) bool // 判断文档是否可见，为空时不受访问控制约束
+}
+
+type ReadonlyColumn struct {
+	Name     string `json:"name"`
+	DeclType string `json:"declType"` // 建表时声明的类型
+	Type     string `json:"type"`     // 结果值的存储类型：INTEGER、REAL、TEXT、BLOB 或者 NULL
+}
+
+type QueryPlanNode struct {
+	ID     int64  `json:"id"`
+	Parent int64  `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+type ReadonlyQueryResult struct {
+	Columns    []*ReadonlyColumn        `json:"columns"`
+	Rows       []map[string]interface{} `json:"rows"`
+	NextCursor string                   `json:"nextCursor"`
+	Plan       []*QueryPlanNode         `json:"plan"`
+	Elapsed    int64                    `json:"elapsed"`
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QueryReadonly 在只读连接上执行查询。
+func QueryReadonly(ctx context.Context, query *ReadonlyQuery) (ret *ReadonlyQueryResult, err error) {
+	stmt := strings.TrimSpace(query.Stmt)
+	stmt = strings.TrimSpace(strings.TrimRight(stmt, "; \t\r\n"))
+	if "" == stmt {
+		err = errors.New("statement is empty")
+		return
+	}
+
+	var args []interface{}
+	for name, value := range query.Params {
+		name = strings.TrimLeft(name, ":@$")
+		if !identifierRegexp.MatchString(name) {
+			err = fmt.Errorf("invalid param name [%s]", name)
+			return
+		}
+		args = append(args, sql.Named(name, value))
+	}
+
+	if err = checkReadonlyStmt(stmt, nil != query.Readable); err != nil {
+		return
+	}
+
+	// 包装为子查询，这样可以保证只执行单条 SELECT 语句，并在外层进行分页
+	wrapped := "SELECT * FROM (" + stmt + "\n) AS siyuan_q"
+	if nil != query.Readable {
+		wrapped = readonlyACLStmt(wrapped)
+	}
+	for _, key := range query.Keyset {
+		if !identifierRegexp.MatchString(key) {
+			err = fmt.Errorf("invalid keyset column [%s]", key)
+			return
+		}
+	}
+	if 0 < len(query.Keyset) {
+		if "" != query.Cursor {
+			var values []interface{}
+			if values, err = decodeReadonlyCursor(query.Cursor, len(query.Keyset)); err != nil {
+				return
+			}
+
+			var placeholders []string
+			for i, value := range values {
+				name := fmt.Sprintf("siyuan_cursor%d", i)
+				placeholders = append(placeholders, ":"+name)
+				args = append(args, sql.Named(name, value))
+			}
+			op := ">"
+			if query.Desc {
+				op = "<"
+			}
+			wrapped += " WHERE (" + quoteIdentifiers(query.Keyset) + ") " + op + " (" + strings.Join(placeholders, ", ") + ")"
+		}
+
+		var orders []string
+		for _, key := range query.Keyset {
+			order := "\"" + key + "\""
+			if query.Desc {
+				order += " DESC"
+			}
+			orders = append(orders, order)
+		}
+		wrapped += " ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	limit := query.Limit
+	if 1 > limit {
+		limit = 64
+	}
+	// 多取一条用于判断是否还有下一页
+	wrapped += fmt.Sprintf(" LIMIT %d", limit+1)
+
+	timeout := query.Timeout
+	if 0 >= timeout {
+		timeout = ReadonlyQueryDefaultTimeout
+	}
+	if ReadonlyQueryMaxTimeout < timeout {
+		timeout = ReadonlyQueryMaxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if "" != query.ID {
+		key := readonlyQueryKey(query.Session, query.ID)
+		roQueriesLock.Lock()
+		if _, ok := roQueries[key]; ok {
+			roQueriesLock.Unlock()
+			err = fmt.Errorf("query [%s] is running", query.ID)
+			return
+		}
+		roQueries[key] = cancel
+		roQueriesLock.Unlock()
+		defer func() {
+			roQueriesLock.Lock()
+			delete(roQueries, key)
+			roQueriesLock.Unlock()
+		}()
+	}
+
+	database, err := getReadonlyDB(nil != query.Readable)
+	if err != nil {
+		return
+	}
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	policy := &readonlyPolicy{readable: query.Readable}
+	var sqliteConn *sqlite3.SQLiteConn
+	if err = conn.Raw(func(driverConn interface{}) error {
+		sqliteConn = driverConn.(*sqlite3.SQLiteConn)
+		return nil
+	}); err != nil {
+		return
+	}
+	roPoliciesLock.Lock()
+	roPolicies[sqliteConn] = policy
+	roPoliciesLock.Unlock()
+	defer func() {
+		roPoliciesLock.Lock()
+		delete(roPolicies, sqliteConn)
+		roPoliciesLock.Unlock()
+	}()
+
+	start := time.Now()
+	ret = &ReadonlyQueryResult{Columns: []*ReadonlyColumn{}, Rows: []map[string]interface{}{}}
+	defer func() {
+		if nil != err {
+			ret = nil
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("query timeout after %s", timeout)
+			} else if errors.Is(ctx.Err(), context.Canceled) {
+				err = errors.New("query canceled")
+			}
+			return
+		}
+		ret.Elapsed = time.Since(start).Milliseconds()
+	}()
+
+	if query.Explain {
+		ret.Plan, err = explainReadonly(ctx, conn, wrapped, args)
+		return
+	}
+
+	rows, err := conn.QueryContext(ctx, wrapped, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+	for _, columnType := range columnTypes {
+		ret.Columns = append(ret.Columns, &ReadonlyColumn{Name: columnType.Name(), DeclType: columnType.DatabaseTypeName(), Type: "NULL"})
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(ret.Columns))
+		valuePtrs := make([]interface{}, len(ret.Columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err = rows.Scan(valuePtrs...); err != nil {
+			return
+		}
+
+		row := map[string]interface{}{}
+		for i, column := range ret.Columns {
+			row[column.Name] = values[i]
+			if "NULL" == column.Type {
+				column.Type = storageClass(values[i])
+			}
+		}
+		ret.Rows = append(ret.Rows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if limit < len(ret.Rows) {
+		ret.Rows = ret.Rows[:limit]
+		if 0 < len(query.Keyset) {
+			if ret.NextCursor, err = encodeReadonlyCursor(ret.Rows[len(ret.Rows)-1], query.Keyset); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// CancelReadonlyQuery 取消会话 session 中正在执行的只读查询。
+func CancelReadonlyQuery(session, id string) bool {
+	roQueriesLock.Lock()
+	defer roQueriesLock.Unlock()
+
+	cancel := roQueries[readonlyQueryKey(session, id)]
+	if nil == cancel {
+		return false
+	}
+	cancel()
+	return true
+}
+
+func readonlyQueryKey(session, id string) string {
+	return session + "\x00" + id
+}
+
+func explainReadonly(ctx context.Context, conn *sql.Conn, stmt string, args []interface{}) (ret []*QueryPlanNode, err error) {
+	rows, err := conn.QueryContext(ctx, "EXPLAIN QUERY PLAN "+stmt, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []*QueryPlanNode{}
+	for rows.Next() {
+		node := &QueryPlanNode{}
+		var notUsed int64
+		if err = rows.Scan(&node.ID, &node.Parent, &notUsed, &node.Detail); err != nil {
+			return
+		}
+		ret = append(ret, node)
+	}
+	err = rows.Err()
+	return
+}
+
+func storageClass(value interface{}) string {
+	switch value.(type) {
+	case int64:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	case string:
+		return "TEXT"
+	case []byte:
+		return "BLOB"
+	}
+	return "NULL"
+}
+
+func quoteIdentifiers(names []string) string {
+	var quoted []string
+	for _, name := range names {
+		quoted = append(quoted, "\""+name+"\"")
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func encodeReadonlyCursor(row map[string]interface{}, keyset []string) (ret string, err error) {
+	var values []interface{}
+	for _, key := range keyset {
+		value, ok := row[key]
+		if !ok {
+			err = fmt.Errorf("keyset column [%s] not found in result", key)
+			return
+		}
+		if nil == value {
+			err = fmt.Errorf("keyset column [%s] is null", key)
+			return
+		}
+		if bytes, ok := value.([]byte); ok {
+			value = string(bytes)
+		}
+		values = append(values, value)
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	ret = base64.RawURLEncoding.EncodeToString(data)
+	return
+}
+
+func decodeReadonlyCursor(cursor string, size int) (ret []interface{}, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		err = errors.New("invalid cursor")
+		return
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var values []interface{}
+	if err = decoder.Decode(&values); err != nil || size != len(values) {
+		err = errors.New("invalid cursor")
+		return
+	}
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case json.Number:
+			if i, parseErr := v.Int64(); nil == parseErr {
+				ret = append(ret, i)
+			} else if f, parseErr := v.Float64(); nil == parseErr {
+				ret = append(ret, f)
+			} else {
+				err = errors.New("invalid cursor")
+				return
+			}
+		case string:
+			ret = append(ret, v)
+		default:
+			err = errors.New("invalid cursor")
+			return
+		}
+	}
+	return
+}
+
+// checkReadonlyStmt 检查语句只包含单条语句，驱动会依次执行以分号分隔的所有语句。
+// 受访问控制约束时还需要拒绝显式指定 schema 的写法，以免绕过 readonlyACLStmt 中的同名公用表表达式。
+func checkReadonlyStmt(stmt string, restricted bool) (err error) {
+	scanner := sqlparser2.NewScanner(strings.NewReader(stmt))
+	var prevLit string
+	for {
+		_, tok, lit := scanner.Scan()
+		if sqlparser2.EOF == tok {
+			break
+		}
+		if sqlparser2.COMMENT == tok {
+			continue
+		}
+		if sqlparser2.SEMI == tok {
+			err = errors.New("only one statement is allowed")
+			return
+		}
+		if !restricted {
+			continue
+		}
+
+		if sqlparser2.ILLEGAL == tok {
+			err = fmt.Errorf("illegal token [%s]", lit)
+			return
+		}
+		if sqlparser2.DOT == tok {
+			switch schema := strings.ToLower(prevLit); schema {
+			case "main", "temp", "temporary", readonlyACLSchema:
+				err = fmt.Errorf("schema [%s] is not allowed", prevLit)
+				return
+			}
+		}
+		prevLit = lit
+	}
+	return
+}
+
+// readonlyACLStmt 在语句前加上按文档可见性过滤的同名公用表表达式，用于受访问控制约束的角色。
+// 语句中引用的表（包括子查询中的）都会解析到过滤后的结果上。
+func readonlyACLStmt(stmt string) string {
+	var ctes []string
+	for _, table := range readonlyACLTableNames {
+		ctes = append(ctes, table+" AS (SELECT * FROM "+readonlyACLSchema+"."+table+" WHERE siyuan_readable(box, "+readonlyACLTables[table]+"))")
+	}
+	return "WITH " + strings.Join(ctes, ", ") + "\n" + stmt
+}
@@ -0,0 +1,246 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func initReadonlyTestDB(t *testing.T) {
+	t.Helper()
+
+	util.DBPath = filepath.Join(t.TempDir(), "siyuan.db")
+	db, err := sql.Open("sqlite3", util.DBPath)
+	if err != nil {
+		t.Fatalf("open database failed: %s", err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE blocks (id, box, path, content)",
+		"CREATE TABLE spans (id, box, path, content)",
+		"CREATE TABLE assets (id, box, docpath, path)",
+		"CREATE TABLE attributes (id, box, path, name, value)",
+		"CREATE TABLE refs (id, box, path, content)",
+		"CREATE TABLE file_annotation_refs (id, box, path, content)",
+		"CREATE TABLE stat (key, value)",
+		"INSERT INTO blocks VALUES ('1', 'public', '/1.sy', 'public content'), ('2', 'secret', '/2.sy', 'secret content')",
+		"INSERT INTO attributes VALUES ('1', 'public', '/1.sy', 'a', 'b'), ('2', 'secret', '/2.sy', 'c', 'd')",
+		"INSERT INTO stat VALUES ('k', 'v')",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatalf("exec [%s] failed: %s", stmt, err)
+		}
+	}
+	t.Cleanup(closeReadonlyDatabase)
+}
+
+func readonlyTestReadable(box, p string) bool {
+	return "public" == box
+}
+
+func TestQueryReadonlyACL(t *testing.T) {
+	initReadonlyTestDB(t)
+
+	tests := []struct {
+		stmt string
+		want int // 可见行数，-1 表示需要报错
+	}{
+		{"SELECT * FROM blocks", 1},
+		{"SELECT * FROM blocks WHERE box = 'secret'", 0},
+		{"SELECT * FROM blocks AS b JOIN attributes AS a ON a.id = b.id", 1},
+		{"SELECT * FROM (SELECT content FROM blocks)", 1},
+		{"SELECT * FROM blocks WHERE id IN (SELECT block_id FROM (SELECT id AS block_id FROM blocks))", 1},
+		{"WITH x AS (SELECT * FROM blocks) SELECT * FROM x", 1},
+		{"SELECT value FROM json_each('[1, 2, 3]')", 3},
+
+		// 多条语句
+		{"SELECT 1) AS x; SELECT content FROM blocks, (SELECT 1", -1},
+		{"SELECT 1; SELECT * FROM blocks", -1},
+
+		// 显式指定 schema
+		{"SELECT * FROM main.blocks", -1},
+		{"SELECT * FROM temp.blocks", -1},
+		{"SELECT * FROM \"main\".blocks", -1},
+		{"SELECT * FROM [main].blocks", -1},
+		{"SELECT * FROM `main`.blocks", -1},
+		{"SELECT * FROM main . blocks", -1},
+		{"SELECT * FROM MAIN.blocks", -1},
+		{"SELECT * FROM main/**/.blocks", -1},
+		{"SELECT * FROM " + readonlyACLSchema + ".blocks", -1},
+		{"SELECT * FROM " + strings.ToUpper(readonlyACLSchema) + ".blocks", -1},
+		{"SELECT * FROM \"" + readonlyACLSchema + "\".blocks", -1},
+
+		// 不在访问控制范围内的表和写入
+		{"SELECT * FROM stat", -1},
+		{"SELECT * FROM sqlite_master", len(readonlyACLTableNames)}, // 内存数据库中的同名空表
+		{"SELECT * FROM pragma_table_info('blocks')", -1},
+		{"DELETE FROM blocks", -1},
+		{"ATTACH DATABASE ':memory:' AS x", -1},
+
+		// 重新定义同名公用表表达式
+		{"WITH blocks AS (SELECT 1) SELECT * FROM blocks", 1},
+		{"WITH blocks AS (SELECT * FROM blocks) SELECT * FROM blocks", -1},
+	}
+
+	for _, test := range tests {
+		result, err := QueryReadonly(context.Background(), &ReadonlyQuery{Stmt: test.stmt, Readable: readonlyTestReadable})
+		if 0 > test.want {
+			if nil == err {
+				t.Errorf("[%s] expected error, got %d rows", test.stmt, len(result.Rows))
+			}
+			continue
+		}
+
+		if nil != err {
+			t.Errorf("[%s] failed: %s", test.stmt, err)
+			continue
+		}
+		if test.want != len(result.Rows) {
+			t.Errorf("[%s] expected %d rows, got %d", test.stmt, test.want, len(result.Rows))
+		}
+		for _, row := range result.Rows {
+			if content, ok := row["content"].(string); ok && strings.Contains(content, "secret") {
+				t.Errorf("[%s] leaked row %v", test.stmt, row)
+			}
+		}
+	}
+}
+
+func TestQueryReadonlyUnrestricted(t *testing.T) {
+	initReadonlyTestDB(t)
+
+	tests := []struct {
+		stmt string
+		want int
+	}{
+		{"SELECT * FROM blocks", 2},
+		{"SELECT * FROM main.blocks", 2},
+		{"SELECT * FROM stat", 1},
+		{"SELECT 1) AS x; SELECT content FROM blocks, (SELECT 1", -1},
+		{"DELETE FROM blocks", -1},
+		{"PRAGMA table_info(blocks)", -1},
+	}
+
+	for _, test := range tests {
+		result, err := QueryReadonly(context.Background(), &ReadonlyQuery{Stmt: test.stmt})
+		if 0 > test.want {
+			if nil == err {
+				t.Errorf("[%s] expected error, got %d rows", test.stmt, len(result.Rows))
+			}
+			continue
+		}
+
+		if nil != err {
+			t.Errorf("[%s] failed: %s", test.stmt, err)
+			continue
+		}
+		if test.want != len(result.Rows) {
+			t.Errorf("[%s] expected %d rows, got %d", test.stmt, test.want, len(result.Rows))
+		}
+	}
+}
+
+// 绕过同名公用表表达式时授权回调也不允许读取数据
+func TestAuthorizeReadonly(t *testing.T) {
+	initReadonlyTestDB(t)
+
+	database, err := getReadonlyDB(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 连接上没有设置访问策略时拒绝所有读取
+	for _, stmt := range []string{
+		"SELECT * FROM " + readonlyACLSchema + ".blocks",
+		"SELECT * FROM blocks",
+		"SELECT 1",
+	} {
+		rows, queryErr := conn.QueryContext(ctx, stmt)
+		if nil == queryErr {
+			rows.Close()
+			t.Errorf("[%s] expected error", stmt)
+		}
+	}
+
+	var sqliteConn *sqlite3.SQLiteConn
+	conn.Raw(func(driverConn interface{}) error {
+		sqliteConn = driverConn.(*sqlite3.SQLiteConn)
+		return nil
+	})
+	roPoliciesLock.Lock()
+	roPolicies[sqliteConn] = &readonlyPolicy{readable: readonlyTestReadable}
+	roPoliciesLock.Unlock()
+	defer func() {
+		roPoliciesLock.Lock()
+		delete(roPolicies, sqliteConn)
+		roPoliciesLock.Unlock()
+	}()
+
+	// 不经过过滤直接执行的语句（比如多条语句中后面的语句）不能读取数据
+	for _, stmt := range []string{
+		"SELECT content FROM blocks",
+		"SELECT content FROM main.blocks",
+		"SELECT * FROM stat",
+	} {
+		rows, queryErr := conn.QueryContext(ctx, stmt)
+		if nil == queryErr {
+			rows.Close()
+			t.Errorf("[%s] expected error", stmt)
+		}
+	}
+
+	var count int
+	if err = conn.QueryRowContext(ctx, readonlyACLStmt("SELECT count(*) FROM blocks")).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if 1 != count {
+		t.Errorf("expected 1 readable block, got %d", count)
+	}
+}
+
+func TestCancelReadonlyQuerySession(t *testing.T) {
+	canceled := false
+	roQueriesLock.Lock()
+	roQueries[readonlyQueryKey("session1", "q")] = func() { canceled = true }
+	roQueriesLock.Unlock()
+	defer func() {
+		roQueriesLock.Lock()
+		delete(roQueries, readonlyQueryKey("session1", "q"))
+		roQueriesLock.Unlock()
+	}()
+
+	// 其他会话不能取消查询
+	if CancelReadonlyQuery("session2", "q") || CancelReadonlyQuery("", "q") || canceled {
+		t.Fatal("query canceled by another session")
+	}
+	if !CancelReadonlyQuery("session1", "q") || !canceled {
+		t.Fatal("query not canceled by its session")
+	}
+}
@@ -334,6 +334,13 @@ func ExecTaskJob() {
 	execTask(task)
 }
 
+// ExecAllTasks 依次执行队列中的同步任务直到队列为空，用于不启动定时任务的命令行模式。
+func ExecAllTasks() {
+	for task := popTask(); nil != task; task = popTask() {
+		execTask(task)
+	}
+}
+
 func popTask() (ret *Task) {
 	queueLock.Lock()
 	defer queueLock.Unlock()
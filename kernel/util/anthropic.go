@@ -0,0 +1,173 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+)
+
+type anthropicResponse struct {
+	Type    string `json:"type"`
+	Content []*struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Delta      *struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ChatAnthropic 请求 Anthropic Messages 接口 /v1/messages，onDelta 不为 nil 时使用流式接口。
+func ChatAnthropic(msg string, contextMsgs []string, param *LLMParam, onDelta func(delta string)) (ret string, stop bool, err error) {
+	reqMsgs := newLLMMessages(msg, contextMsgs)
+	if 1 > len(reqMsgs) {
+		stop = true
+		return
+	}
+
+	payload := map[string]interface{}{
+		"model":       param.Model,
+		"messages":    reqMsgs,
+		"max_tokens":  param.MaxTokens,
+		"temperature": param.Temperature,
+	}
+	if nil != onDelta {
+		payload["stream"] = true
+	}
+	data, err := gulu.JSON.MarshalJSON(payload)
+	if err != nil {
+		stop = true
+		return
+	}
+
+	ctx, touch, cancel := newLLMIdleContext(param.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, param.APIBaseURL+"/v1/messages", bytes.NewReader(data))
+	if err != nil {
+		stop = true
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", param.APIKey)
+	req.Header.Set("Anthropic-Version", param.APIVersion)
+
+	resp, err := newLLMHTTPClient(param.APIProxy, param.APIUserAgent).Do(req)
+	if err != nil {
+		PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
+		logging.LogErrorf("request anthropic messages failed: %s", err)
+		stop = true
+		return
+	}
+	defer resp.Body.Close()
+
+	if http.StatusOK != resp.StatusCode {
+		body, _ := io.ReadAll(resp.Body)
+		PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
+		err = fmt.Errorf("request anthropic messages failed [%d]: %s", resp.StatusCode, body)
+		logging.LogErrorf("%s", err)
+		stop = true
+		return
+	}
+
+	buf := &strings.Builder{}
+	stop = true
+	if nil == onDelta {
+		body, readErr := io.ReadAll(&llmIdleReader{r: resp.Body, touch: touch})
+		if nil != readErr {
+			err = readErr
+			return
+		}
+
+		msgResp := &anthropicResponse{}
+		if err = gulu.JSON.UnmarshalJSON(body, msgResp); err != nil {
+			logging.LogErrorf("unmarshal anthropic messages response failed: %s", err)
+			return
+		}
+		for _, content := range msgResp.Content {
+			if "text" == content.Type {
+				buf.WriteString(content.Text)
+			}
+		}
+		stop = "max_tokens" != msgResp.StopReason
+		ret = strings.TrimSpace(buf.String())
+		return
+	}
+
+	// 流式响应为 SSE，只需要处理 data 行
+	scanner := bufio.NewScanner(&llmIdleReader{r: resp.Body, touch: touch})
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		event := &anthropicResponse{}
+		if err = gulu.JSON.UnmarshalJSON([]byte(strings.TrimSpace(line[len("data:"):])), event); err != nil {
+			logging.LogErrorf("unmarshal anthropic messages event [%s] failed: %s", line, err)
+			return
+		}
+
+		switch event.Type {
+		case "content_block_delta":
+			if nil != event.Delta && "" != event.Delta.Text {
+				buf.WriteString(event.Delta.Text)
+				onDelta(event.Delta.Text)
+			}
+		case "message_delta":
+			if nil != event.Delta {
+				stop = "max_tokens" != event.Delta.StopReason
+			}
+		case "error":
+			if nil != event.Error {
+				err = errors.New(event.Error.Message)
+			} else {
+				err = errors.New("anthropic messages stream error")
+			}
+			logging.LogErrorf("anthropic messages stream failed: %s", err)
+			stop = true
+		}
+		if "message_stop" == event.Type || nil != err {
+			break
+		}
+	}
+	if scanErr := scanner.Err(); nil != scanErr {
+		logging.LogErrorf("read anthropic messages stream failed: %s", scanErr)
+		err = scanErr
+		stop = true
+	}
+
+	ret = buf.String()
+	ret = strings.TrimSpace(ret)
+	return
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/hex"
 
 	"github.com/siyuan-note/logging"
@@ -76,3 +77,13 @@ func pkcs5Trimming(encrypt []byte) []byte {
 	padding := encrypt[len(encrypt)-1]
 	return encrypt[:len(encrypt)-int(padding)]
 }
+
+// RandSecret 使用 crypto/rand 生成 n 字节的随机数并编码为十六进制字符串，用于令牌等不可预测的场景。
+func RandSecret(n int) (ret string, err error) {
+	b := make([]byte, n)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	ret = hex.EncodeToString(b)
+	return
+}
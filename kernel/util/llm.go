@@ -0,0 +1,104 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/siyuan-note/logging"
+)
+
+// LLMParam 描述了请求大语言模型接口的参数。
+type LLMParam struct {
+	APIKey       string
+	APIBaseURL   string
+	APIProxy     string
+	APIUserAgent string
+	APIVersion   string
+	Model        string
+	MaxTokens    int
+	Temperature  float64
+	Timeout      int // 秒，连续这么长时间没有收到数据时取消请求
+}
+
+// LLMMessage 描述了一条对话消息。
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// newLLMMessages 将上下文消息和当前消息组装为请求消息列表。
+func newLLMMessages(msg string, contextMsgs []string) (ret []*LLMMessage) {
+	for _, ctxMsg := range contextMsgs {
+		if "" == ctxMsg {
+			continue
+		}
+		ret = append(ret, &LLMMessage{Role: "user", Content: ctxMsg})
+	}
+
+	if "" != msg {
+		ret = append(ret, &LLMMessage{Role: "user", Content: msg})
+	}
+	return
+}
+
+func newLLMHTTPClient(apiProxy, apiUserAgent string) *http.Client {
+	transport := &http.Transport{}
+	if "" != apiProxy {
+		proxyUrl, err := url.Parse(apiProxy)
+		if err != nil {
+			logging.LogErrorf("LLM API proxy failed: %v", err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		}
+	}
+	return &http.Client{Transport: newAddHeaderTransport(transport, apiUserAgent)}
+}
+
+// newLLMIdleContext 返回请求大语言模型接口使用的上下文，超过 timeout 秒没有收到数据时取消请求。
+// 流式生成时只要持续收到数据就不会超时，调用方收到数据后需要调用 touch 重新计时。
+func newLLMIdleContext(timeout int) (ctx context.Context, touch, cancel func()) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	idle := time.Duration(timeout) * time.Second
+	timer := time.AfterFunc(idle, cancelCtx)
+	touch = func() {
+		timer.Reset(idle)
+	}
+	cancel = func() {
+		timer.Stop()
+		cancelCtx()
+	}
+	return
+}
+
+// llmIdleReader 在每次读取到数据时重新计时。
+type llmIdleReader struct {
+	r     io.Reader
+	touch func()
+}
+
+func (r *llmIdleReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	if 0 < n {
+		r.touch()
+	}
+	return
+}
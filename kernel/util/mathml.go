@@ -0,0 +1,383 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// LaTeX2MathML 将 LaTeX 公式转换为 MathML，仅支持常用的命令，无法识别的命令按原样作为文本输出。
+//
+// 转换结果中通过 annotation 保留原始的 LaTeX 公式，方便阅读器使用其他方式渲染。
+func LaTeX2MathML(tex string, display bool) string {
+	p := &texParser{tokens: tokenizeTeX(tex)}
+	buf := strings.Builder{}
+	buf.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		buf.WriteString(` display="block"`)
+	}
+	buf.WriteString(`><semantics><mrow>`)
+	buf.WriteString(p.parseTop())
+	buf.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	buf.WriteString(html.EscapeString(tex))
+	buf.WriteString(`</annotation></semantics></math>`)
+	return buf.String()
+}
+
+type texParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenizeTeX(tex string) (ret []string) {
+	runes := []rune(tex)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case '\\' == r:
+			if i+1 >= len(runes) {
+				continue
+			}
+			j := i + 1
+			if unicode.IsLetter(runes[j]) {
+				for j < len(runes) && unicode.IsLetter(runes[j]) {
+					j++
+				}
+			} else {
+				j++
+			}
+			ret = append(ret, string(runes[i:j]))
+			i = j - 1
+		case unicode.IsDigit(r) || '.' == r:
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || '.' == runes[j]) {
+				j++
+			}
+			ret = append(ret, string(runes[i:j]))
+			i = j - 1
+		default:
+			ret = append(ret, string(r))
+		}
+	}
+	return
+}
+
+func (p *texParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *texParser) next() string {
+	ret := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return ret
+}
+
+// parseTop 解析整个公式，顶层的 \\ 换行按表格的行输出，不匹配的 }、\right、& 和 \end 作为运算符或者错误输出，保证所有记号都被解析。
+func (p *texParser) parseTop() string {
+	var rows []string
+	row := strings.Builder{}
+	for p.pos < len(p.tokens) {
+		row.WriteString(p.parseRow(""))
+		switch token := p.next(); token {
+		case "\\\\":
+			rows = append(rows, row.String())
+			row.Reset()
+		case "&":
+			row.WriteString("<mo>&amp;</mo>")
+		case "\\right":
+			row.WriteString(texFence(p.next()))
+		case "\\end":
+			row.WriteString("<merror><mtext>" + html.EscapeString("\\end{"+p.parseText()+"}") + "</mtext></merror>")
+		case "}":
+			row.WriteString("<merror><mtext>}</mtext></merror>")
+		}
+	}
+	if 0 < row.Len() || 1 > len(rows) {
+		rows = append(rows, row.String())
+	}
+	if 1 == len(rows) {
+		return rows[0]
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("<mtable>")
+	for _, r := range rows {
+		buf.WriteString("<mtr><mtd>" + r + "</mtd></mtr>")
+	}
+	buf.WriteString("</mtable>")
+	return buf.String()
+}
+
+// parseRow 解析到 end 或者 } 为止的公式片段。
+func (p *texParser) parseRow(end string) string {
+	buf := strings.Builder{}
+	for p.pos < len(p.tokens) {
+		token := p.peek()
+		if "}" == token || ("" != end && end == token) || "\\right" == token || "&" == token || "\\\\" == token || "\\end" == token {
+			break
+		}
+		buf.WriteString(p.parseScripts())
+	}
+	return buf.String()
+}
+
+// parseScripts 解析一个原子以及其后的上下标。
+func (p *texParser) parseScripts() string {
+	base := p.parseAtom()
+	var sub, sup string
+	for {
+		switch p.peek() {
+		case "_":
+			p.next()
+			sub = p.parseAtom()
+			continue
+		case "^":
+			p.next()
+			sup = p.parseAtom()
+			continue
+		case "'":
+			p.next()
+			sup += "<mo>′</mo>"
+			continue
+		}
+		break
+	}
+
+	switch {
+	case "" != sub && "" != sup:
+		return "<msubsup>" + base + "<mrow>" + sub + "</mrow><mrow>" + sup + "</mrow></msubsup>"
+	case "" != sub:
+		return "<msub>" + base + "<mrow>" + sub + "</mrow></msub>"
+	case "" != sup:
+		return "<msup>" + base + "<mrow>" + sup + "</mrow></msup>"
+	}
+	return base
+}
+
+// parseGroup 解析 {...} 分组或者单个原子。
+func (p *texParser) parseGroup() string {
+	if "{" == p.peek() {
+		p.next()
+		ret := p.parseRow("}")
+		if "}" == p.peek() {
+			p.next()
+		}
+		return "<mrow>" + ret + "</mrow>"
+	}
+	return p.parseAtom()
+}
+
+// parseText 解析 {...} 分组中的原始文本。
+func (p *texParser) parseText() string {
+	if "{" != p.peek() {
+		return p.next()
+	}
+
+	p.next()
+	buf := strings.Builder{}
+	depth := 1
+	for p.pos < len(p.tokens) {
+		token := p.next()
+		if "{" == token {
+			depth++
+		} else if "}" == token {
+			if depth--; 0 == depth {
+				break
+			}
+		}
+		if 0 < buf.Len() && strings.HasPrefix(token, "\\") {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(strings.TrimPrefix(token, "\\"))
+	}
+	return buf.String()
+}
+
+func (p *texParser) parseAtom() string {
+	token := p.next()
+	switch {
+	case "" == token:
+		return ""
+	case "{" == token:
+		ret := p.parseRow("}")
+		if "}" == p.peek() {
+			p.next()
+		}
+		return "<mrow>" + ret + "</mrow>"
+	case unicode.IsDigit([]rune(token)[0]) || "." == token:
+		return "<mn>" + token + "</mn>"
+	case !strings.HasPrefix(token, "\\"):
+		if r := []rune(token)[0]; unicode.IsLetter(r) {
+			return "<mi>" + html.EscapeString(token) + "</mi>"
+		}
+		return "<mo>" + html.EscapeString(token) + "</mo>"
+	}
+
+	switch token {
+	case "\\frac", "\\dfrac", "\\tfrac", "\\cfrac":
+		num := p.parseGroup()
+		den := p.parseGroup()
+		return "<mfrac>" + num + den + "</mfrac>"
+	case "\\binom":
+		top := p.parseGroup()
+		bottom := p.parseGroup()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + `</mfrac><mo>)</mo></mrow>`
+	case "\\sqrt":
+		if "[" == p.peek() {
+			p.next()
+			index := p.parseRow("]")
+			if "]" == p.peek() {
+				p.next()
+			}
+			return "<mroot>" + p.parseGroup() + "<mrow>" + index + "</mrow></mroot>"
+		}
+		return "<msqrt>" + p.parseGroup() + "</msqrt>"
+	case "\\left":
+		open := p.next()
+		inner := p.parseRow("")
+		var closing string
+		if "\\right" == p.peek() {
+			p.next()
+			closing = p.next()
+		}
+		return "<mrow>" + texFence(open) + inner + texFence(closing) + "</mrow>"
+	case "\\text", "\\textrm", "\\mbox", "\\operatorname":
+		return "<mtext>" + html.EscapeString(p.parseText()) + "</mtext>"
+	case "\\mathrm", "\\mathbf", "\\mathit", "\\mathbb", "\\mathcal", "\\mathfrak", "\\mathsf", "\\mathtt", "\\boldsymbol":
+		variant := map[string]string{"\\mathrm": "normal", "\\mathbf": "bold", "\\mathit": "italic", "\\mathbb": "double-struck",
+			"\\mathcal": "script", "\\mathfrak": "fraktur", "\\mathsf": "sans-serif", "\\mathtt": "monospace", "\\boldsymbol": "bold-italic"}[token]
+		return `<mstyle mathvariant="` + variant + `">` + p.parseGroup() + "</mstyle>"
+	case "\\overline", "\\bar":
+		return `<mover accent="true">` + p.parseGroup() + "<mo>¯</mo></mover>"
+	case "\\hat", "\\widehat":
+		return `<mover accent="true">` + p.parseGroup() + "<mo>^</mo></mover>"
+	case "\\vec", "\\overrightarrow":
+		return `<mover accent="true">` + p.parseGroup() + "<mo>→</mo></mover>"
+	case "\\dot":
+		return `<mover accent="true">` + p.parseGroup() + "<mo>˙</mo></mover>"
+	case "\\tilde", "\\widetilde":
+		return `<mover accent="true">` + p.parseGroup() + "<mo>~</mo></mover>"
+	case "\\underline":
+		return `<munder accentunder="true">` + p.parseGroup() + "<mo>_</mo></munder>"
+	case "\\begin":
+		return p.parseEnvironment(p.parseText())
+	case "\\,", "\\:", "\\;", "\\ ", "\\quad", "\\qquad", "\\!":
+		return `<mspace width="0.3em"/>`
+	case "\\{", "\\}", "\\%", "\\$", "\\#", "\\&", "\\_", "\\|":
+		return "<mo>" + html.EscapeString(token[1:]) + "</mo>"
+	}
+
+	name := token[1:]
+	if symbol := texSymbols[name]; "" != symbol {
+		if r := []rune(symbol)[0]; unicode.IsLetter(r) && !unicode.Is(unicode.Sm, r) {
+			return "<mi>" + symbol + "</mi>"
+		}
+		return "<mo>" + symbol + "</mo>"
+	}
+	if texFunctions[name] {
+		return "<mi>" + name + "</mi><mo>&#x2061;</mo>"
+	}
+	return "<mtext>" + html.EscapeString(token) + "</mtext>"
+}
+
+// parseEnvironment 解析 \begin{env} ... \end{env} 矩阵类环境。
+func (p *texParser) parseEnvironment(env string) string {
+	buf := strings.Builder{}
+	buf.WriteString("<mtable><mtr><mtd>")
+	for p.pos < len(p.tokens) {
+		buf.WriteString(p.parseRow(""))
+		token := p.next()
+		switch token {
+		case "&":
+			buf.WriteString("</mtd><mtd>")
+			continue
+		case "\\\\":
+			buf.WriteString("</mtd></mtr><mtr><mtd>")
+			continue
+		case "\\end":
+			p.parseText()
+		case "}", "\\right":
+			continue
+		}
+		break
+	}
+	buf.WriteString("</mtd></mtr></mtable>")
+
+	switch env {
+	case "pmatrix":
+		return "<mrow><mo>(</mo>" + buf.String() + "<mo>)</mo></mrow>"
+	case "bmatrix":
+		return "<mrow><mo>[</mo>" + buf.String() + "<mo>]</mo></mrow>"
+	case "vmatrix":
+		return "<mrow><mo>|</mo>" + buf.String() + "<mo>|</mo></mrow>"
+	case "cases":
+		return "<mrow><mo>{</mo>" + buf.String() + "</mrow>"
+	}
+	return buf.String()
+}
+
+func texFence(delimiter string) string {
+	switch delimiter {
+	case ".", "":
+		return ""
+	case "\\{":
+		return "<mo>{</mo>"
+	case "\\}":
+		return "<mo>}</mo>"
+	case "\\langle":
+		return "<mo>⟨</mo>"
+	case "\\rangle":
+		return "<mo>⟩</mo>"
+	case "\\|":
+		return "<mo>‖</mo>"
+	}
+	return "<mo>" + html.EscapeString(delimiter) + "</mo>"
+}
+
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true, "arcsin": true, "arccos": true, "arctan": true,
+	"sinh": true, "cosh": true, "tanh": true, "log": true, "ln": true, "lg": true, "exp": true, "lim": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "dim": true, "gcd": true, "arg": true, "deg": true, "ker": true, "Pr": true,
+}
+
+var texSymbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ",
+	"vartheta": "ϑ", "iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮", "bigcup": "⋃", "bigcap": "⋂",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "forall": "∀", "exists": "∃", "nexists": "∄", "emptyset": "∅", "varnothing": "∅",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃", "subseteq": "⊆", "supseteq": "⊇", "cup": "∪", "cap": "∩",
+	"setminus": "∖", "land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨", "neg": "¬", "lnot": "¬",
+	"times": "×", "div": "÷", "cdot": "⋅", "ast": "∗", "star": "⋆", "circ": "∘", "bullet": "∙", "pm": "±", "mp": "∓", "oplus": "⊕", "otimes": "⊗",
+	"le": "≤", "leq": "≤", "ge": "≥", "geq": "≥", "neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "perp": "⊥", "parallel": "∥", "mid": "∣",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔",
+	"implies": "⟹", "iff": "⟺", "mapsto": "↦", "uparrow": "↑", "downarrow": "↓", "longrightarrow": "⟶", "longleftarrow": "⟵",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱", "dots": "…", "prime": "′", "angle": "∠", "triangle": "△",
+	"hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ", "langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "degree": "°", "therefore": "∴", "because": "∵",
+}
@@ -0,0 +1,69 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLaTeX2MathML(t *testing.T) {
+	cases := []struct {
+		tex      string
+		expected string
+	}{
+		{"x^2", "<msup><mi>x</mi><mrow><mn>2</mn></mrow></msup>"},
+		{"\\frac{a}{b}", "<mfrac><mrow><mi>a</mi></mrow><mrow><mi>b</mi></mrow></mfrac>"},
+		{"\\alpha + 1", "<mi>α</mi><mo>+</mo><mn>1</mn>"},
+		{"\\sin x", "<mi>sin</mi><mo>&#x2061;</mo><mi>x</mi>"},
+		{"\\left( x \\right)", "<mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow>"},
+		{"\\text{if } x", "<mtext>if</mtext><mi>x</mi>"},
+		{"\\begin{pmatrix} a & b \\\\ c & d \\end{pmatrix}", "<mrow><mo>(</mo><mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable><mo>)</mo></mrow>"},
+		{"\\unknown", "<mtext>\\unknown</mtext>"},
+
+		// 顶层换行按表格的行输出
+		{"a = 1 \\\\ b = 2", "<mtable><mtr><mtd><mi>a</mi><mo>=</mo><mn>1</mn></mtd></mtr><mtr><mtd><mi>b</mi><mo>=</mo><mn>2</mn></mtd></mtr></mtable>"},
+		{"a \\\\", "<mi>a</mi>"},
+
+		// 不匹配的记号不会导致后续内容丢失
+		{"a } b", "<mi>a</mi><merror><mtext>}</mtext></merror><mi>b</mi>"},
+		{"a & b", "<mi>a</mi><mo>&amp;</mo><mi>b</mi>"},
+		{"x \\right) y", "<mi>x</mi><mo>)</mo><mi>y</mi>"},
+		{"x \\end{align} y", "<mi>x</mi><merror><mtext>\\end{align}</mtext></merror><mi>y</mi>"},
+		{"{a}} + b", "<mrow><mi>a</mi></mrow><merror><mtext>}</mtext></merror><mo>+</mo><mi>b</mi>"},
+	}
+
+	for _, c := range cases {
+		got := LaTeX2MathML(c.tex, false)
+		prefix := `<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow>`
+		end := strings.Index(got, `</mrow><annotation`)
+		if !strings.HasPrefix(got, prefix) || 0 > end {
+			t.Errorf("[%s] unexpected MathML [%s]", c.tex, got)
+			continue
+		}
+		if body := got[len(prefix):end]; body != c.expected {
+			t.Errorf("[%s] expected [%s], got [%s]", c.tex, c.expected, body)
+		}
+	}
+}
+
+func TestLaTeX2MathMLDisplay(t *testing.T) {
+	got := LaTeX2MathML("a<b", true)
+	if !strings.Contains(got, ` display="block"`) || !strings.Contains(got, `<annotation encoding="application/x-tex">a&lt;b</annotation>`) {
+		t.Errorf("unexpected MathML [%s]", got)
+	}
+}
@@ -0,0 +1,135 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+)
+
+type ollamaChatResponse struct {
+	Message    *LLMMessage `json:"message"`
+	Done       bool        `json:"done"`
+	DoneReason string      `json:"done_reason"`
+	Error      string      `json:"error"`
+}
+
+// ChatOllama 请求 Ollama 本地接口 /api/chat，onDelta 不为 nil 时使用流式接口。
+func ChatOllama(msg string, contextMsgs []string, param *LLMParam, onDelta func(delta string)) (ret string, stop bool, err error) {
+	reqMsgs := newLLMMessages(msg, contextMsgs)
+	if 1 > len(reqMsgs) {
+		stop = true
+		return
+	}
+
+	options := map[string]interface{}{"temperature": param.Temperature}
+	if 0 < param.MaxTokens {
+		options["num_predict"] = param.MaxTokens
+	}
+	payload := map[string]interface{}{
+		"model":    param.Model,
+		"messages": reqMsgs,
+		"stream":   nil != onDelta,
+		"options":  options,
+	}
+	data, err := gulu.JSON.MarshalJSON(payload)
+	if err != nil {
+		stop = true
+		return
+	}
+
+	ctx, touch, cancel := newLLMIdleContext(param.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, param.APIBaseURL+"/api/chat", bytes.NewReader(data))
+	if err != nil {
+		stop = true
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if "" != param.APIKey {
+		req.Header.Set("Authorization", "Bearer "+param.APIKey)
+	}
+
+	resp, err := newLLMHTTPClient(param.APIProxy, param.APIUserAgent).Do(req)
+	if err != nil {
+		PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
+		logging.LogErrorf("request ollama chat failed: %s", err)
+		stop = true
+		return
+	}
+	defer resp.Body.Close()
+
+	if http.StatusOK != resp.StatusCode {
+		body, _ := io.ReadAll(resp.Body)
+		PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
+		err = fmt.Errorf("request ollama chat failed [%d]: %s", resp.StatusCode, body)
+		logging.LogErrorf("%s", err)
+		stop = true
+		return
+	}
+
+	// 非流式响应也是一个 JSON 对象，和流式响应的每一行格式相同
+	buf := &strings.Builder{}
+	stop = true
+	scanner := bufio.NewScanner(&llmIdleReader{r: resp.Body, touch: touch})
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if 1 > len(line) {
+			continue
+		}
+
+		chatResp := &ollamaChatResponse{}
+		if err = gulu.JSON.UnmarshalJSON(line, chatResp); err != nil {
+			logging.LogErrorf("unmarshal ollama chat response [%s] failed: %s", line, err)
+			return
+		}
+		if "" != chatResp.Error {
+			err = errors.New(chatResp.Error)
+			logging.LogErrorf("ollama chat failed: %s", err)
+			return
+		}
+
+		if nil != chatResp.Message && "" != chatResp.Message.Content {
+			buf.WriteString(chatResp.Message.Content)
+			if nil != onDelta {
+				onDelta(chatResp.Message.Content)
+			}
+		}
+		if chatResp.Done {
+			stop = "length" != chatResp.DoneReason
+			break
+		}
+	}
+	if scanErr := scanner.Err(); nil != scanErr {
+		logging.LogErrorf("read ollama chat response failed: %s", scanErr)
+		err = scanErr
+		stop = true
+	}
+
+	ret = buf.String()
+	ret = strings.TrimSpace(ret)
+	return
+}
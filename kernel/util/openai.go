@@ -18,6 +18,8 @@ package util
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -28,26 +30,7 @@ import (
 )
 
 func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, maxTokens int, temperature float64, timeout int) (ret string, stop bool, err error) {
-	var reqMsgs []openai.ChatCompletionMessage
-
-	for _, ctxMsg := range contextMsgs {
-		if "" == ctxMsg {
-			continue
-		}
-
-		reqMsgs = append(reqMsgs, openai.ChatCompletionMessage{
-			Role:    "user",
-			Content: ctxMsg,
-		})
-	}
-
-	if "" != msg {
-		reqMsgs = append(reqMsgs, openai.ChatCompletionMessage{
-			Role:    "user",
-			Content: msg,
-		})
-	}
-
+	reqMsgs := newChatGPTMessages(msg, contextMsgs)
 	if 1 > len(reqMsgs) {
 		stop = true
 		return
@@ -88,6 +71,73 @@ func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, m
 	return
 }
 
+// ChatGPTStream 使用流式接口请求，每收到一段内容就回调 onDelta。
+func ChatGPTStream(msg string, contextMsgs []string, c *openai.Client, model string, maxTokens int, temperature float64, timeout int, onDelta func(delta string)) (ret string, stop bool, err error) {
+	reqMsgs := newChatGPTMessages(msg, contextMsgs)
+	if 1 > len(reqMsgs) {
+		stop = true
+		return
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:               model,
+		MaxCompletionTokens: maxTokens,
+		Temperature:         float32(temperature),
+		Messages:            reqMsgs,
+		Stream:              true,
+	}
+	ctx, touch, cancel := newLLMIdleContext(timeout)
+	defer cancel()
+	stream, err := c.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		PushErrMsg("Requesting failed, please check kernel log for more details", 3000)
+		logging.LogErrorf("create chat completion stream failed: %s", err)
+		stop = true
+		return
+	}
+	defer stream.Close()
+
+	buf := &strings.Builder{}
+	stop = true
+	for {
+		resp, recvErr := stream.Recv()
+		touch()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+		if nil != recvErr {
+			logging.LogErrorf("receive chat completion stream failed: %s", recvErr)
+			err = recvErr
+			stop = true
+			break
+		}
+
+		if 1 > len(resp.Choices) {
+			continue
+		}
+
+		choice := resp.Choices[0]
+		if "" != choice.Delta.Content {
+			buf.WriteString(choice.Delta.Content)
+			onDelta(choice.Delta.Content)
+		}
+		if "length" == choice.FinishReason {
+			stop = false
+		}
+	}
+
+	ret = buf.String()
+	ret = strings.TrimSpace(ret)
+	return
+}
+
+func newChatGPTMessages(msg string, contextMsgs []string) (ret []openai.ChatCompletionMessage) {
+	for _, llmMsg := range newLLMMessages(msg, contextMsgs) {
+		ret = append(ret, openai.ChatCompletionMessage{Role: llmMsg.Role, Content: llmMsg.Content})
+	}
+	return
+}
+
 func NewOpenAIClient(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider string) *openai.Client {
 	config := openai.DefaultConfig(apiKey)
 	if "Azure" == apiProvider {
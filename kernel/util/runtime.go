@@ -483,6 +483,8 @@ const (
 
 	EvtSQLHistoryRebuild      = "sql.history.rebuild"
 	EvtSQLAssetContentRebuild = "sql.assetContent.rebuild"
+
+	EvtAttributeViewSaved = "av.saved"
 )
 
 var SearchCaseSensitive bool
@@ -37,6 +37,7 @@ type SessionData struct {
 type WorkspaceSession struct {
 	AccessAuthCode string
 	Captcha        string
+	Member         string // 通过帐号密码登录的帐号名
 }
 
 func (sd *SessionData) Clear(c *gin.Context) {
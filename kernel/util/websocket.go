@@ -332,6 +332,23 @@ func PushDownloadProgress(id string, percent float32) {
 	PushEvent(evt)
 }
 
+// PushAIStream 推送 AI 流式生成的内容片段，只推送给发起请求的应用会话，id 用于前端区分不同的请求。
+func PushAIStream(app, session, id, content string, done bool) {
+	pushMode := PushModeSingleSelf
+	if "" == session {
+		pushMode = PushModeBroadcastApp
+	}
+	evt := NewCmdResult("aiStream", 0, pushMode)
+	evt.AppId = app
+	evt.SessionId = session
+	evt.Data = map[string]interface{}{
+		"id":      id,
+		"content": content,
+		"done":    done,
+	}
+	PushEvent(evt)
+}
+
 func PushEvent(event *Result) {
 	msg := event.Bytes()
 	mode := event.PushMode
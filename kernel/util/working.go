@@ -181,6 +181,55 @@ func Boot() {
 	logBootInfo()
 }
 
+// BootCLI 以命令行模式启动内核，不启动 HTTP 服务，也不会修改工作空间列表。
+func BootCLI(workspacePath, wdPath, lang string) (err error) {
+	initEnvVars()
+	rand.Seed(time.Now().UTC().UnixNano())
+	initMime()
+	initHttpClient()
+
+	if "" != wdPath {
+		WorkingDir = wdPath
+	}
+	if "" != lang {
+		Lang = lang
+	}
+	Container = ContainerStd
+	UserAgent = UserAgent + " " + Container + "/" + runtime.GOOS
+	httpclient.SetUserAgent(UserAgent)
+
+	WorkspaceDir, err = filepath.Abs(workspacePath)
+	if nil != err {
+		return
+	}
+	if !gulu.File.IsDir(filepath.Join(WorkspaceDir, "data")) {
+		return fmt.Errorf("workspace [%s] is not a valid SiYuan workspace", WorkspaceDir)
+	}
+	userHomeConfDir := filepath.Join(HomeDir, ".config", "siyuan")
+	if err = os.MkdirAll(userHomeConfDir, 0755); nil != err {
+		return
+	}
+	initWorkspacePaths(userHomeConfDir)
+
+	LogPath = filepath.Join(TempDir, "siyuan.log")
+	logging.SetLogPath(LogPath)
+
+	WorkspaceLock = flock.New(filepath.Join(WorkspaceDir, ".lock"))
+	if ok, lockErr := WorkspaceLock.TryLock(); !ok {
+		if nil != lockErr {
+			return fmt.Errorf("lock workspace [%s] failed: %s", WorkspaceDir, lockErr)
+		}
+		return fmt.Errorf("workspace [%s] is locked by another kernel process", WorkspaceDir)
+	}
+
+	AppearancePath = filepath.Join(ConfDir, "appearance")
+	ThemesPath = filepath.Join(AppearancePath, "themes")
+	IconsPath = filepath.Join(AppearancePath, "icons")
+	initPathDir()
+	logBootInfo()
+	return
+}
+
 var bootDetailsLock = sync.Mutex{}
 
 func setBootDetails(details string) {
@@ -242,6 +291,7 @@ var (
 	DBName             = "siyuan.db" // SQLite 数据库文件名
 	DBPath             string        // SQLite 数据库文件路径
 	HistoryDBPath      string        // SQLite 历史数据库文件路径
+	AuditDBPath        string        // SQLite 审计日志数据库文件路径
 	AssetContentDBPath string        // SQLite 资源文件内容数据库文件路径
 	BlockTreeDBPath    string        // 区块树数据库文件路径
 	AppearancePath     string        // 配置目录下的外观目录 appearance/ 路径
@@ -304,6 +354,10 @@ func initWorkspaceDir(workspaceArg string) {
 		os.Exit(logging.ExitCodeInitWorkspaceErr)
 	}
 
+	initWorkspacePaths(userHomeConfDir)
+}
+
+func initWorkspacePaths(userHomeConfDir string) {
 	WorkspaceName = filepath.Base(WorkspaceDir)
 	ConfDir = filepath.Join(WorkspaceDir, "conf")
 	DataDir = filepath.Join(WorkspaceDir, "data")
@@ -322,6 +376,7 @@ func initWorkspaceDir(workspaceArg string) {
 	os.Setenv("TMP", osTmpDir)
 	DBPath = filepath.Join(TempDir, DBName)
 	HistoryDBPath = filepath.Join(TempDir, "history.db")
+	AuditDBPath = filepath.Join(TempDir, "audit.db")
 	AssetContentDBPath = filepath.Join(TempDir, "asset_content.db")
 	BlockTreeDBPath = filepath.Join(TempDir, "blocktree.db")
 	SnippetsPath = filepath.Join(DataDir, "snippets")